@@ -0,0 +1,10 @@
+// Package api embeds the public API contract so the gateway and the drift
+// tests read the same copy as client authors.
+package api
+
+import _ "embed"
+
+// OpenAPI is the OpenAPI 3 document for the gateway's public endpoints.
+//
+//go:embed openapi.json
+var OpenAPI []byte
@@ -0,0 +1,142 @@
+// Package grpcx holds the interceptors gRPC servers share, mirroring the
+// request ID, metrics and logging the HTTP services get from their
+// middleware.
+package grpcx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/rs/zerolog"
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDKey is the metadata key for the request ID, the gRPC spelling of
+// httpx.RequestIDHeader.
+const RequestIDKey = "x-request-id"
+
+type requestIDContextKey struct{}
+
+// WithRequestID stores a request ID in ctx.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, id)
+}
+
+// RequestIDFromContext returns the ID stored by WithRequestID or the
+// RequestID interceptor.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
+
+// IncomingValue returns the first value of an incoming metadata key.
+func IncomingValue(ctx context.Context, key string) string {
+	if values := metadata.ValueFromIncomingContext(ctx, key); len(values) > 0 {
+		return strings.TrimSpace(values[0])
+	}
+	return ""
+}
+
+// RequestID takes the caller's x-request-id, or generates one, stores it in
+// the context and echoes it in the response header.
+func RequestID(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	id := IncomingValue(ctx, RequestIDKey)
+	if id == "" {
+		id = newRequestID()
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDKey, id))
+	return handler(WithRequestID(ctx, id), req)
+}
+
+// PropagateRequestID is a client interceptor that forwards the context's
+// request ID to the server being called.
+func PropagateRequestID(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if id := RequestIDFromContext(ctx); id != "" {
+		if md, _ := metadata.FromOutgoingContext(ctx); len(md.Get(RequestIDKey)) == 0 {
+			ctx = metadata.AppendToOutgoingContext(ctx, RequestIDKey, id)
+		}
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// Metrics counts calls, their status codes and their duration, like the
+// gateway's HTTP metrics.
+func Metrics(metrics *metricsx.Registry) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		method := MethodName(info.FullMethod)
+		code := snakeCase(status.Code(err).String())
+		metrics.Inc("grpc_requests_total")
+		metrics.Inc("grpc_" + method + "_requests_total")
+		metrics.Inc("grpc_response_code_" + code + "_total")
+		metrics.ObserveDuration("grpc_request_duration", time.Since(start))
+		return resp, err
+	}
+}
+
+// Logging writes one line per call: info for OK, warn for client errors and
+// error for server faults.
+func Logging(log zerolog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		code := status.Code(err)
+		event := log.Info()
+		switch code {
+		case codes.OK:
+		case codes.Internal, codes.Unknown, codes.Unavailable, codes.DataLoss, codes.DeadlineExceeded:
+			event = log.Error().Err(err)
+		default:
+			event = log.Warn().Str("error", status.Convert(err).Message())
+		}
+		event.
+			Str("method", info.FullMethod).
+			Str("code", code.String()).
+			Dur("duration", time.Since(start)).
+			Str("request_id", RequestIDFromContext(ctx)).
+			Msg("grpc call")
+		return resp, err
+	}
+}
+
+// MethodName turns "/pkg.Service/CreateOrder" into "create_order" for metric
+// names.
+func MethodName(fullMethod string) string {
+	if i := strings.LastIndexByte(fullMethod, '/'); i >= 0 {
+		fullMethod = fullMethod[i+1:]
+	}
+	return snakeCase(fullMethod)
+}
+
+func snakeCase(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "req-fallback"
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,74 @@
+package grpcx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestMethodName(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"/pulsecart.orders.v1.OrdersService/CreateOrder": "create_order",
+		"/pulsecart.orders.v1.OrdersService/GetOrder":    "get_order",
+		"ListOrders": "list_orders",
+	}
+	for in, want := range tests {
+		if got := MethodName(in); got != want {
+			t.Fatalf("method name mismatch for %q: got=%q want=%q", in, got, want)
+		}
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	t.Parallel()
+
+	var seen string
+	handler := func(ctx context.Context, _ any) (any, error) {
+		seen = RequestIDFromContext(ctx)
+		return nil, nil
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIDKey, "req-1"))
+	if _, err := RequestID(ctx, nil, &grpc.UnaryServerInfo{}, handler); err != nil {
+		t.Fatalf("request id interceptor: %v", err)
+	}
+	if seen != "req-1" {
+		t.Fatalf("request id mismatch: got=%q want=%q", seen, "req-1")
+	}
+
+	if _, err := RequestID(context.Background(), nil, &grpc.UnaryServerInfo{}, handler); err != nil {
+		t.Fatalf("request id interceptor: %v", err)
+	}
+	if len(seen) != 32 {
+		t.Fatalf("generated request id mismatch: got=%q", seen)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	reg := metricsx.NewRegistry("test")
+	interceptor := Metrics(reg)
+	info := &grpc.UnaryServerInfo{FullMethod: "/pulsecart.orders.v1.OrdersService/GetOrder"}
+	_, _ = interceptor(context.Background(), nil, info, func(context.Context, any) (any, error) {
+		return nil, status.Error(codes.NotFound, "order not found")
+	})
+
+	rec := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, want := range []string{"test_grpc_get_order_requests_total 1", "test_grpc_response_code_not_found_total 1"} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Fatalf("metric %q missing: %q", want, rec.Body.String())
+		}
+	}
+}
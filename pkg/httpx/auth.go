@@ -0,0 +1,80 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// AuthenticatedUserHeader and AuthenticatedRolesHeader carry the verified
+// principal from the gateway to internal services. The gateway strips any
+// client-supplied values, so they are only trustworthy on requests that cannot
+// bypass the gateway.
+const (
+	AuthenticatedUserHeader  = "X-Authenticated-User"
+	AuthenticatedRolesHeader = "X-Authenticated-Roles"
+)
+
+const (
+	RoleCustomer = "customer"
+	RoleAdmin    = "admin"
+)
+
+// Principal is the authenticated caller as established by the gateway.
+type Principal struct {
+	Subject string
+	Roles   []string
+	Scopes  []string
+}
+
+func (p Principal) HasRole(role string) bool {
+	return slices.Contains(p.Roles, role)
+}
+
+func (p Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+// SetHeaders writes the principal onto an upstream request.
+func (p Principal) SetHeaders(h http.Header) {
+	h.Set(AuthenticatedUserHeader, p.Subject)
+	if len(p.Roles) > 0 {
+		h.Set(AuthenticatedRolesHeader, strings.Join(p.Roles, ","))
+	} else {
+		h.Del(AuthenticatedRolesHeader)
+	}
+}
+
+// PrincipalFromHeaders reads the principal forwarded by the gateway. It
+// reports false when no authenticated user is present.
+func PrincipalFromHeaders(h http.Header) (Principal, bool) {
+	subject := strings.TrimSpace(h.Get(AuthenticatedUserHeader))
+	if subject == "" {
+		return Principal{}, false
+	}
+	p := Principal{Subject: subject}
+	for _, role := range strings.Split(h.Get(AuthenticatedRolesHeader), ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			p.Roles = append(p.Roles, role)
+		}
+	}
+	return p, true
+}
+
+// StripPrincipalHeaders removes identity headers a client may have forged.
+func StripPrincipalHeaders(h http.Header) {
+	h.Del(AuthenticatedUserHeader)
+	h.Del(AuthenticatedRolesHeader)
+}
+
+type principalContextKey struct{}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, p)
+}
+
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalContextKey{}).(Principal)
+	return p, ok
+}
@@ -0,0 +1,114 @@
+package httpx
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig is a cross-origin policy. An AllowedOrigins entry of "*" admits
+// every origin. Header names are matched case-insensitively.
+type CORSConfig struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight answer.
+	MaxAge time.Duration
+}
+
+// CORS answers preflight requests and adds the Access-Control-* headers to
+// responses for allowed origins. A preflight for a disallowed origin, method
+// or header gets 403 without CORS headers. Requests without an Origin, or
+// from disallowed origins, pass through unchanged, and the browser enforces
+// the policy.
+func CORS(cfg CORSConfig) func(http.Handler) http.Handler {
+	methods := make([]string, 0, len(cfg.AllowedMethods))
+	for _, m := range cfg.AllowedMethods {
+		methods = append(methods, strings.ToUpper(m))
+	}
+	headers := make([]string, 0, len(cfg.AllowedHeaders))
+	for _, h := range cfg.AllowedHeaders {
+		headers = append(headers, http.CanonicalHeaderKey(h))
+	}
+	anyOrigin := slices.Contains(cfg.AllowedOrigins, "*")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			w.Header().Add("Vary", "Origin")
+			if preflight {
+				w.Header().Add("Vary", "Access-Control-Request-Method")
+				w.Header().Add("Vary", "Access-Control-Request-Headers")
+			}
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			allowed := anyOrigin || slices.Contains(cfg.AllowedOrigins, origin)
+
+			if !preflight {
+				if allowed {
+					setAllowOrigin(w.Header(), cfg, anyOrigin, origin)
+					if len(cfg.ExposedHeaders) > 0 {
+						w.Header().Set("Access-Control-Expose-Headers", strings.Join(cfg.ExposedHeaders, ", "))
+					}
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+			requested := requestedHeaders(r.Header.Get("Access-Control-Request-Headers"))
+			if !allowed || !slices.Contains(methods, method) || !allContained(headers, requested) {
+				Error(w, r, http.StatusForbidden, CodeCORSRejected, "cors preflight rejected")
+				return
+			}
+			setAllowOrigin(w.Header(), cfg, anyOrigin, origin)
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+			if len(headers) > 0 {
+				w.Header().Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+			}
+			if cfg.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// setAllowOrigin echoes the origin when credentials are allowed, since
+// browsers reject "*" for credentialed requests.
+func setAllowOrigin(h http.Header, cfg CORSConfig, anyOrigin bool, origin string) {
+	if anyOrigin && !cfg.AllowCredentials {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if cfg.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func requestedHeaders(v string) []string {
+	var out []string
+	for _, h := range strings.Split(v, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			out = append(out, http.CanonicalHeaderKey(h))
+		}
+	}
+	return out
+}
+
+func allContained(allowed, requested []string) bool {
+	for _, h := range requested {
+		if !slices.Contains(allowed, h) {
+			return false
+		}
+	}
+	return true
+}
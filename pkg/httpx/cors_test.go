@@ -0,0 +1,87 @@
+package httpx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	t.Parallel()
+
+	cfg := CORSConfig{
+		AllowedOrigins:   []string{"https://shop.example.com"},
+		AllowedMethods:   []string{"get", "POST"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type", "Idempotency-Key"},
+		ExposedHeaders:   []string{"X-Request-Id"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+	h := CORS(cfg)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	tests := []struct {
+		name        string
+		method      string
+		origin      string
+		reqMethod   string
+		reqHeaders  string
+		wantStatus  int
+		wantOrigin  string
+		wantMethods string
+	}{
+		{name: "preflight allowed", method: http.MethodOptions, origin: "https://shop.example.com", reqMethod: "POST", reqHeaders: "content-type, idempotency-key", wantStatus: http.StatusNoContent, wantOrigin: "https://shop.example.com", wantMethods: "GET, POST"},
+		{name: "preflight other origin", method: http.MethodOptions, origin: "https://evil.example.com", reqMethod: "POST", wantStatus: http.StatusForbidden},
+		{name: "preflight method", method: http.MethodOptions, origin: "https://shop.example.com", reqMethod: "DELETE", wantStatus: http.StatusForbidden},
+		{name: "preflight header", method: http.MethodOptions, origin: "https://shop.example.com", reqMethod: "POST", reqHeaders: "X-Debug", wantStatus: http.StatusForbidden},
+		{name: "actual request", method: http.MethodPost, origin: "https://shop.example.com", wantStatus: http.StatusTeapot, wantOrigin: "https://shop.example.com"},
+		{name: "actual request other origin", method: http.MethodPost, origin: "https://evil.example.com", wantStatus: http.StatusTeapot},
+		{name: "same origin", method: http.MethodGet, wantStatus: http.StatusTeapot},
+		{name: "plain options", method: http.MethodOptions, origin: "https://shop.example.com", wantStatus: http.StatusTeapot, wantOrigin: "https://shop.example.com"},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest(tc.method, "/v1/orders", nil)
+		if tc.origin != "" {
+			req.Header.Set("Origin", tc.origin)
+		}
+		if tc.reqMethod != "" {
+			req.Header.Set("Access-Control-Request-Method", tc.reqMethod)
+		}
+		if tc.reqHeaders != "" {
+			req.Header.Set("Access-Control-Request-Headers", tc.reqHeaders)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != tc.wantStatus {
+			t.Fatalf("%s: status code mismatch: got=%d want=%d", tc.name, rec.Code, tc.wantStatus)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tc.wantOrigin {
+			t.Fatalf("%s: allow origin mismatch: got=%q want=%q", tc.name, got, tc.wantOrigin)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Methods"); got != tc.wantMethods {
+			t.Fatalf("%s: allow methods mismatch: got=%q want=%q", tc.name, got, tc.wantMethods)
+		}
+		if tc.wantOrigin != "" && rec.Header().Get("Access-Control-Allow-Credentials") != "true" {
+			t.Fatalf("%s: credentials header missing", tc.name)
+		}
+		if rec.Header().Get("Vary") == "" {
+			t.Fatalf("%s: Vary header missing", tc.name)
+		}
+	}
+}
+
+func TestCORS_AnyOrigin(t *testing.T) {
+	t.Parallel()
+
+	h := CORS(CORSConfig{AllowedOrigins: []string{"*"}, AllowedMethods: []string{http.MethodGet}})(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	req := httptest.NewRequest(http.MethodGet, "/v1/orders/o-1", nil)
+	req.Header.Set("Origin", "https://anywhere.example.com")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Fatalf("allow origin mismatch: got=%q want=%q", got, "*")
+	}
+}
@@ -0,0 +1,71 @@
+package httpx
+
+import (
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// LimitBody caps r's body at limit bytes. A declared Content-Length over the
+// limit is answered with 413 and LimitBody returns false; a body that only
+// turns out too long while streaming fails the read with
+// *http.MaxBytesError, which the reader should map to 413.
+func LimitBody(w http.ResponseWriter, r *http.Request, limit int64) bool {
+	if r.ContentLength > limit {
+		Error(w, r, http.StatusRequestEntityTooLarge, CodeBodyTooLarge, "request body too large")
+		return false
+	}
+	if r.Body != nil && r.Body != http.NoBody {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+	}
+	return true
+}
+
+// MaxBodyBytes applies LimitBody to every request.
+func MaxBodyBytes(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if LimitBody(w, r, limit) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// RequireContentType answers 415 when a request with a body has a media type
+// other than types. Structured suffixes count, so "application/json" also
+// admits "application/problem+json". Bodiless requests pass.
+func RequireContentType(types ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !hasBody(r) || contentTypeAllowed(r.Header.Get("Content-Type"), types) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("Accept", strings.Join(types, ", "))
+			Error(w, r, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, "unsupported content type; use "+strings.Join(types, " or "))
+		})
+	}
+}
+
+func hasBody(r *http.Request) bool {
+	return r.ContentLength > 0 || (r.ContentLength < 0 && r.Body != nil && r.Body != http.NoBody)
+}
+
+func contentTypeAllowed(header string, types []string) bool {
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return false
+	}
+	if slices.Contains(types, mediaType) {
+		return true
+	}
+	// application/problem+json matches application/json.
+	if i := strings.LastIndexByte(mediaType, '+'); i >= 0 {
+		if j := strings.IndexByte(mediaType, '/'); j >= 0 && j < i {
+			return slices.Contains(types, mediaType[:j+1]+mediaType[i+1:])
+		}
+	}
+	return false
+}
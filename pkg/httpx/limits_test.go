@@ -0,0 +1,89 @@
+package httpx
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMaxBodyBytes(t *testing.T) {
+	t.Parallel()
+
+	h := MaxBodyBytes(8)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				http.Error(w, "too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "read failed", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name    string
+		body    string
+		chunked bool
+		want    int
+	}{
+		{name: "within limit", body: "12345678", want: http.StatusOK},
+		{name: "declared length over limit", body: "123456789", want: http.StatusRequestEntityTooLarge},
+		{name: "chunked over limit", body: "123456789", chunked: true, want: http.StatusRequestEntityTooLarge},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/v1/orders", strings.NewReader(tc.body))
+		if tc.chunked {
+			req.ContentLength = -1
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Fatalf("%s: status code mismatch: got=%d want=%d", tc.name, rec.Code, tc.want)
+		}
+	}
+}
+
+func TestRequireContentType(t *testing.T) {
+	t.Parallel()
+
+	h := RequireContentType("application/json")(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name        string
+		method      string
+		body        string
+		contentType string
+		want        int
+	}{
+		{name: "json", method: http.MethodPost, body: `{}`, contentType: "application/json", want: http.StatusOK},
+		{name: "json with charset", method: http.MethodPost, body: `{}`, contentType: "Application/JSON; charset=utf-8", want: http.StatusOK},
+		{name: "structured suffix", method: http.MethodPatch, body: `{}`, contentType: "application/merge-patch+json", want: http.StatusOK},
+		{name: "form", method: http.MethodPost, body: "a=1", contentType: "application/x-www-form-urlencoded", want: http.StatusUnsupportedMediaType},
+		{name: "missing", method: http.MethodPost, body: `{}`, want: http.StatusUnsupportedMediaType},
+		{name: "malformed", method: http.MethodPost, body: `{}`, contentType: "json;;", want: http.StatusUnsupportedMediaType},
+		{name: "no body", method: http.MethodPost, want: http.StatusOK},
+		{name: "get", method: http.MethodGet, want: http.StatusOK},
+	}
+	for _, tc := range tests {
+		var body io.Reader
+		if tc.body != "" {
+			body = strings.NewReader(tc.body)
+		}
+		req := httptest.NewRequest(tc.method, "/v1/orders", body)
+		if tc.contentType != "" {
+			req.Header.Set("Content-Type", tc.contentType)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Fatalf("%s: status code mismatch: got=%d want=%d", tc.name, rec.Code, tc.want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package httpx
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// Policy is what a caller needs to reach a route. When AnyRole or AnyScope is
+// set, a caller must hold one of those roles or one of those scopes; users
+// carry roles and API keys carry scopes. Every scope in AllScopes is required
+// on top of that.
+type Policy struct {
+	AnyRole   []string
+	AnyScope  []string
+	AllScopes []string
+}
+
+// Allows reports whether p may proceed and, if not, a short reason suitable
+// for audit logs.
+func (pol Policy) Allows(p Principal) (bool, string) {
+	if len(pol.AnyRole) > 0 || len(pol.AnyScope) > 0 {
+		ok := slices.ContainsFunc(pol.AnyRole, p.HasRole) || slices.ContainsFunc(pol.AnyScope, p.HasScope)
+		if !ok {
+			return false, "missing role or scope: one of " + strings.Join(append(slices.Clone(pol.AnyRole), pol.AnyScope...), ",")
+		}
+	}
+	for _, scope := range pol.AllScopes {
+		if !p.HasScope(scope) {
+			return false, "missing scope: " + scope
+		}
+	}
+	return true, ""
+}
+
+// RoutePolicy binds a policy to a method and path pattern. Patterns match
+// segment by segment; "{name}" matches any single segment and a trailing "*"
+// matches the rest of the path. An empty Method matches every method.
+type RoutePolicy struct {
+	Method  string
+	Pattern string
+	Policy  Policy
+}
+
+// PolicyTable is an ordered route→policy table; the first match wins.
+type PolicyTable []RoutePolicy
+
+func (t PolicyTable) Lookup(method, path string) (Policy, bool) {
+	for _, rp := range t {
+		if rp.Method != "" && rp.Method != method {
+			continue
+		}
+		if MatchPattern(rp.Pattern, path) {
+			return rp.Policy, true
+		}
+	}
+	return Policy{}, false
+}
+
+// MatchPattern reports whether path matches a RoutePolicy-style pattern.
+func MatchPattern(pattern, path string) bool {
+	pat := strings.Split(strings.Trim(pattern, "/"), "/")
+	segs := strings.Split(strings.Trim(path, "/"), "/")
+	for i, p := range pat {
+		if p == "*" && i == len(pat)-1 {
+			return len(segs) >= i
+		}
+		if i >= len(segs) {
+			return false
+		}
+		if strings.HasPrefix(p, "{") && strings.HasSuffix(p, "}") {
+			if segs[i] == "" {
+				return false
+			}
+			continue
+		}
+		if p != segs[i] {
+			return false
+		}
+	}
+	return len(pat) == len(segs)
+}
+
+// Authorize enforces table against the principal stored by the authentication
+// middleware. Routes missing from the table are denied, so a new route cannot
+// become reachable by forgetting its policy.
+func Authorize(table PolicyTable, log zerolog.Logger) func(http.Handler) http.Handler {
+	return AuthorizeFunc(func(r *http.Request) (Policy, bool) {
+		return table.Lookup(r.Method, r.URL.Path)
+	}, log)
+}
+
+// AuthorizeFunc is Authorize with the policy chosen by lookup, for tables
+// that change at runtime. A request for which lookup finds nothing is denied.
+func AuthorizeFunc(lookup func(*http.Request) (Policy, bool), log zerolog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := PrincipalFromContext(r.Context())
+			if !ok {
+				AuditDenied(log, r, p, "unauthenticated")
+				Error(w, r, http.StatusForbidden, CodeForbidden, "access denied")
+				return
+			}
+			policy, ok := lookup(r)
+			if !ok {
+				AuditDenied(log, r, p, "no policy for route")
+				Error(w, r, http.StatusForbidden, CodeForbidden, "access denied")
+				return
+			}
+			if allowed, reason := policy.Allows(p); !allowed {
+				AuditDenied(log, r, p, reason)
+				Error(w, r, http.StatusForbidden, CodeForbidden, "access denied")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// AuditDenied writes the audit line for a rejected request.
+func AuditDenied(log zerolog.Logger, r *http.Request, p Principal, reason string) {
+	log.Warn().
+		Str("audit", "access_denied").
+		Str("method", r.Method).
+		Str("path", r.URL.Path).
+		Str("subject", p.Subject).
+		Strs("roles", p.Roles).
+		Str("reason", reason).
+		Str("request_id", r.Header.Get(RequestIDHeader)).
+		Str("remote_addr", r.RemoteAddr).
+		Msg("request denied")
+}
@@ -0,0 +1,130 @@
+package httpx
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestPolicyTableLookup(t *testing.T) {
+	t.Parallel()
+
+	admin := Policy{AnyRole: []string{RoleAdmin}}
+	customer := Policy{AnyRole: []string{RoleCustomer}}
+	table := PolicyTable{
+		{Method: http.MethodGet, Pattern: "/v1/orders/{id}", Policy: customer},
+		{Pattern: "/v1/admin/*", Policy: admin},
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		want   *Policy
+	}{
+		{name: "param segment", method: http.MethodGet, path: "/v1/orders/o-1", want: &customer},
+		{name: "method mismatch", method: http.MethodDelete, path: "/v1/orders/o-1"},
+		{name: "empty param", method: http.MethodGet, path: "/v1/orders/"},
+		{name: "extra segment", method: http.MethodGet, path: "/v1/orders/o-1/items"},
+		{name: "wildcard any method", method: http.MethodPut, path: "/v1/admin/catalog/skus/a", want: &admin},
+		{name: "wildcard root", method: http.MethodGet, path: "/v1/admin", want: &admin},
+		{name: "prefix is not a segment", method: http.MethodGet, path: "/v1/administrators"},
+	}
+
+	for _, tc := range tests {
+		got, ok := table.Lookup(tc.method, tc.path)
+		if ok != (tc.want != nil) {
+			t.Fatalf("%s: match mismatch: got=%v want=%v", tc.name, ok, tc.want != nil)
+		}
+		if ok && strings.Join(got.AnyRole, ",") != strings.Join(tc.want.AnyRole, ",") {
+			t.Fatalf("%s: policy mismatch: got=%+v want=%+v", tc.name, got, *tc.want)
+		}
+	}
+}
+
+func TestPolicyAllows(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		policy    Policy
+		principal Principal
+		want      bool
+	}{
+		{name: "empty policy", policy: Policy{}, principal: Principal{Subject: "u"}, want: true},
+		{name: "has one of roles", policy: Policy{AnyRole: []string{RoleAdmin, RoleCustomer}}, principal: Principal{Roles: []string{RoleCustomer}}, want: true},
+		{name: "missing role", policy: Policy{AnyRole: []string{RoleAdmin}}, principal: Principal{Roles: []string{RoleCustomer}}},
+		{name: "scope instead of role", policy: Policy{AnyRole: []string{RoleCustomer}, AnyScope: []string{"orders:write"}}, principal: Principal{Scopes: []string{"orders:write"}}, want: true},
+		{name: "neither role nor scope", policy: Policy{AnyRole: []string{RoleCustomer}, AnyScope: []string{"orders:write"}}, principal: Principal{Scopes: []string{"orders:read"}}},
+		{name: "all scopes", policy: Policy{AllScopes: []string{"orders:read", "orders:write"}}, principal: Principal{Scopes: []string{"orders:write", "orders:read"}}, want: true},
+		{name: "missing scope", policy: Policy{AllScopes: []string{"orders:read", "orders:write"}}, principal: Principal{Scopes: []string{"orders:read"}}},
+	}
+
+	for _, tc := range tests {
+		got, reason := tc.policy.Allows(tc.principal)
+		if got != tc.want {
+			t.Fatalf("%s: allows mismatch: got=%v want=%v", tc.name, got, tc.want)
+		}
+		if !got && reason == "" {
+			t.Fatalf("%s: denial should carry a reason", tc.name)
+		}
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	t.Parallel()
+
+	table := PolicyTable{{Method: http.MethodGet, Pattern: "/v1/admin/*", Policy: Policy{AnyRole: []string{RoleAdmin}}}}
+	tests := []struct {
+		name       string
+		path       string
+		principal  *Principal
+		wantStatus int
+	}{
+		{name: "allowed", path: "/v1/admin/dlq", principal: &Principal{Subject: "u_admin", Roles: []string{RoleAdmin}}, wantStatus: http.StatusOK},
+		{name: "wrong role", path: "/v1/admin/dlq", principal: &Principal{Subject: "u_1", Roles: []string{RoleCustomer}}, wantStatus: http.StatusForbidden},
+		{name: "unlisted route", path: "/v1/other", principal: &Principal{Subject: "u_admin", Roles: []string{RoleAdmin}}, wantStatus: http.StatusForbidden},
+		{name: "no principal", path: "/v1/admin/dlq", wantStatus: http.StatusForbidden},
+	}
+
+	for _, tc := range tests {
+		var logs bytes.Buffer
+		h := Authorize(table, zerolog.New(&logs))(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		if tc.principal != nil {
+			req = req.WithContext(WithPrincipal(req.Context(), *tc.principal))
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != tc.wantStatus {
+			t.Fatalf("%s: status code mismatch: got=%d want=%d", tc.name, rec.Code, tc.wantStatus)
+		}
+		audited := strings.Contains(logs.String(), `"audit":"access_denied"`)
+		if audited != (tc.wantStatus == http.StatusForbidden) {
+			t.Fatalf("%s: audit mismatch: logs=%q", tc.name, logs.String())
+		}
+	}
+}
+
+func TestPrincipalHeadersRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	h := http.Header{}
+	Principal{Subject: "u_1", Roles: []string{RoleCustomer, RoleAdmin}}.SetHeaders(h)
+	got, ok := PrincipalFromHeaders(h)
+	if !ok || got.Subject != "u_1" || !got.HasRole(RoleAdmin) || !got.HasRole(RoleCustomer) {
+		t.Fatalf("principal mismatch: got=%+v ok=%v", got, ok)
+	}
+
+	StripPrincipalHeaders(h)
+	if _, ok := PrincipalFromHeaders(h); ok {
+		t.Fatal("expected no principal after stripping headers")
+	}
+}
@@ -0,0 +1,153 @@
+package httpx
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+const (
+	// ProblemContentType is the media type of Problem bodies (RFC 9457,
+	// which replaced RFC 7807).
+	ProblemContentType = "application/problem+json"
+	// ProblemTypePrefix is followed by the code to form a problem's type URI.
+	ProblemTypePrefix = "urn:pulsecart:problem:"
+
+	RequestIDHeader = "X-Request-Id"
+)
+
+// Error codes shared across services. Codes are part of the API: clients
+// branch on them, so they never change once published. Services define
+// their own codes for domain errors next to the handlers that use them.
+const (
+	CodeInvalidRequest       = "invalid_request"
+	CodeInvalidJSON          = "invalid_json"
+	CodeValidationFailed     = "validation_failed"
+	CodeUnauthenticated      = "unauthenticated"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeMethodNotAllowed     = "method_not_allowed"
+	CodeBodyTooLarge         = "body_too_large"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeCORSRejected         = "cors_rejected"
+	CodeRateLimited          = "rate_limited"
+	CodeServiceUnavailable   = "service_unavailable"
+	CodeUpstreamUnavailable  = "upstream_unavailable"
+	CodeUpstreamTimeout      = "upstream_timeout"
+	CodeUpstreamError        = "upstream_error"
+	CodeInternal             = "internal_error"
+)
+
+// Field error codes.
+const (
+	FieldRequired    = "required"
+	FieldInvalid     = "invalid"
+	FieldInvalidType = "invalid_type"
+	FieldNotPositive = "must_be_positive"
+	FieldUnsupported = "unsupported"
+	FieldOutOfRange  = "out_of_range"
+	FieldUnknown     = "unknown_field"
+)
+
+// Problem is a problem details body. Code is the stable machine-readable
+// error; Detail is for humans and may change. Extensions are added as extra
+// top-level members.
+type Problem struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Code       string
+	RequestID  string
+	Errors     []FieldError
+	Extensions map[string]any
+}
+
+// FieldError names one invalid request field. Field is a path into the JSON
+// body, such as "items[1].qty".
+type FieldError struct {
+	Field  string `json:"field"`
+	Code   string `json:"code"`
+	Detail string `json:"detail,omitempty"`
+}
+
+func (p Problem) MarshalJSON() ([]byte, error) {
+	m := make(map[string]any, len(p.Extensions)+8)
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+	m["type"] = p.Type
+	m["title"] = p.Title
+	m["status"] = p.Status
+	m["code"] = p.Code
+	if p.Detail != "" {
+		m["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		m["instance"] = p.Instance
+	}
+	if p.RequestID != "" {
+		m["request_id"] = p.RequestID
+	}
+	if len(p.Errors) > 0 {
+		m["errors"] = p.Errors
+	}
+	return json.Marshal(m)
+}
+
+// Error replies with a problem body, in the manner of http.Error.
+func Error(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	WriteProblem(w, r, Problem{Status: status, Code: code, Detail: detail})
+}
+
+// ValidationError replies 400 with the invalid fields.
+func ValidationError(w http.ResponseWriter, r *http.Request, detail string, errs []FieldError) {
+	WriteProblem(w, r, Problem{Status: http.StatusBadRequest, Code: CodeValidationFailed, Detail: detail, Errors: errs})
+}
+
+// WriteProblem fills in the type, title, instance and request ID that p
+// leaves empty, and writes it.
+func WriteProblem(w http.ResponseWriter, r *http.Request, p Problem) {
+	if p.Code == "" {
+		p.Code = CodeInternal
+	}
+	if p.Type == "" {
+		p.Type = ProblemTypePrefix + p.Code
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	if p.Instance == "" && r != nil {
+		p.Instance = r.URL.Path
+	}
+	if p.RequestID == "" {
+		p.RequestID = requestID(w, r)
+	}
+
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", ProblemContentType)
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+func requestID(w http.ResponseWriter, r *http.Request) string {
+	if r != nil {
+		if id := strings.TrimSpace(r.Header.Get(RequestIDHeader)); id != "" {
+			return id
+		}
+	}
+	return w.Header().Get(RequestIDHeader)
+}
+
+// NotFound replies 404. It fits chi's NotFound hook.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	Error(w, r, http.StatusNotFound, CodeNotFound, "no such resource")
+}
+
+// MethodNotAllowed replies 405. It fits chi's MethodNotAllowed hook.
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	Error(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "method not allowed")
+}
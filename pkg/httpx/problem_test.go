@@ -0,0 +1,73 @@
+package httpx
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteProblem(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/orders", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	rec := httptest.NewRecorder()
+	WriteProblem(rec, req, Problem{
+		Status:     http.StatusBadRequest,
+		Code:       CodeValidationFailed,
+		Detail:     "request has invalid fields",
+		Errors:     []FieldError{{Field: "items[1].qty", Code: FieldNotPositive}},
+		Extensions: map[string]any{"hint": "check quantities", "code": "overridden"},
+	})
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status code mismatch: got=%d want=%d", rec.Code, http.StatusBadRequest)
+	}
+	if got := rec.Header().Get("Content-Type"); got != ProblemContentType {
+		t.Fatalf("content type mismatch: got=%q want=%q", got, ProblemContentType)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode problem: %v", err)
+	}
+	want := map[string]any{
+		"type":       "urn:pulsecart:problem:validation_failed",
+		"title":      "Bad Request",
+		"status":     float64(400),
+		"code":       CodeValidationFailed,
+		"detail":     "request has invalid fields",
+		"instance":   "/v1/orders",
+		"request_id": "req-1",
+		"hint":       "check quantities",
+	}
+	for k, v := range want {
+		if body[k] != v {
+			t.Fatalf("%s mismatch: got=%v want=%v", k, body[k], v)
+		}
+	}
+	errs, _ := body["errors"].([]any)
+	if len(errs) != 1 || errs[0].(map[string]any)["field"] != "items[1].qty" {
+		t.Fatalf("errors mismatch: %v", body["errors"])
+	}
+}
+
+func TestError_RequestIDFromResponse(t *testing.T) {
+	t.Parallel()
+
+	rec := httptest.NewRecorder()
+	rec.Header().Set(RequestIDHeader, "req-2")
+	Error(rec, httptest.NewRequest(http.MethodGet, "/v1/orders/o-1", nil), http.StatusNotFound, CodeNotFound, "")
+
+	var p struct {
+		Code      string `json:"code"`
+		RequestID string `json:"request_id"`
+		Detail    *string
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatalf("decode problem: %v", err)
+	}
+	if p.Code != CodeNotFound || p.RequestID != "req-2" || p.Detail != nil {
+		t.Fatalf("problem mismatch: %+v", p)
+	}
+}
@@ -0,0 +1,29 @@
+package httpx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	t.Parallel()
+
+	h := SecurityHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/framed" {
+			w.Header().Set("X-Frame-Options", "SAMEORIGIN")
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+
+	for path, wantFrame := range map[string]string{"/": "DENY", "/framed": "SAMEORIGIN"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if got := rec.Header().Values("X-Frame-Options"); len(got) != 1 || got[0] != wantFrame {
+			t.Fatalf("%s: X-Frame-Options mismatch: got=%v want=%q", path, got, wantFrame)
+		}
+		if got := rec.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+			t.Fatalf("%s: X-Content-Type-Options mismatch: got=%q", path, got)
+		}
+	}
+}
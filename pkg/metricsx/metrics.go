@@ -20,6 +20,7 @@ type Registry struct {
 
 	mu        sync.RWMutex
 	counters  map[string]*atomic.Int64
+	gauges    map[string]*atomic.Int64
 	durations map[string]*durationMetric
 }
 
@@ -27,6 +28,7 @@ func NewRegistry(namespace string) *Registry {
 	return &Registry{
 		namespace: strings.TrimSpace(namespace),
 		counters:  map[string]*atomic.Int64{},
+		gauges:    map[string]*atomic.Int64{},
 		durations: map[string]*durationMetric{},
 	}
 }
@@ -49,6 +51,21 @@ func (r *Registry) Add(name string, delta int64) {
 	c.Add(delta)
 }
 
+// SetGauge records the current value of name, replacing the previous one.
+func (r *Registry) SetGauge(name string, value int64) {
+	key := r.metricKey(name)
+
+	r.mu.Lock()
+	g, ok := r.gauges[key]
+	if !ok {
+		g = &atomic.Int64{}
+		r.gauges[key] = g
+	}
+	r.mu.Unlock()
+
+	g.Store(value)
+}
+
 func (r *Registry) ObserveDuration(name string, d time.Duration) {
 	key := r.metricKey(name)
 
@@ -73,11 +90,16 @@ func (r *Registry) Handler() http.Handler {
 		for k := range r.counters {
 			counterKeys = append(counterKeys, k)
 		}
+		gaugeKeys := make([]string, 0, len(r.gauges))
+		for k := range r.gauges {
+			gaugeKeys = append(gaugeKeys, k)
+		}
 		durationKeys := make([]string, 0, len(r.durations))
 		for k := range r.durations {
 			durationKeys = append(durationKeys, k)
 		}
 		sort.Strings(counterKeys)
+		sort.Strings(gaugeKeys)
 		sort.Strings(durationKeys)
 
 		for _, key := range counterKeys {
@@ -86,6 +108,11 @@ func (r *Registry) Handler() http.Handler {
 			fmt.Fprintf(w, "%s %d\n", key, c.Load())
 		}
 
+		for _, key := range gaugeKeys {
+			fmt.Fprintf(w, "# TYPE %s gauge\n", key)
+			fmt.Fprintf(w, "%s %d\n", key, r.gauges[key].Load())
+		}
+
 		for _, key := range durationKeys {
 			dm := r.durations[key]
 			sumSeconds := float64(dm.sumNanos.Load()) / float64(time.Second)
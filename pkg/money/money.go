@@ -0,0 +1,208 @@
+// Package money holds amounts as integer minor units of an ISO-4217
+// currency, so no arithmetic goes through floats.
+//
+// Stores keep an amount in two columns, the minor units as BIGINT (for
+// example orders.total_cents) next to a currency column, so totals stay
+// summable and comparable in SQL. Rows scan straight into a Money's fields,
+// &m.Minor and &m.Currency; Currency implements driver.Valuer and
+// sql.Scanner for its column, so an unknown code fails the scan.
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnknownCurrency  = errors.New("money: unknown currency")
+	ErrCurrencyMismatch = errors.New("money: currency mismatch")
+	ErrOverflow         = errors.New("money: amount overflows int64")
+)
+
+// exponents maps supported ISO-4217 codes to their number of minor-unit digits.
+var exponents = map[string]int{
+	"AED": 2, "ARS": 2, "AUD": 2, "BHD": 3, "BRL": 2, "CAD": 2, "CHF": 2,
+	"CLP": 0, "CNY": 2, "COP": 2, "CZK": 2, "DKK": 2, "EGP": 2, "EUR": 2,
+	"GBP": 2, "HKD": 2, "HUF": 2, "IDR": 2, "ILS": 2, "INR": 2, "IQD": 3,
+	"ISK": 0, "JOD": 3, "JPY": 0, "KES": 2, "KRW": 0, "KWD": 3, "LYD": 3,
+	"MXN": 2, "MYR": 2, "NGN": 2, "NOK": 2, "NZD": 2, "OMR": 3, "PEN": 2,
+	"PHP": 2, "PLN": 2, "PYG": 0, "SAR": 2, "SEK": 2, "SGD": 2, "THB": 2,
+	"TND": 3, "TRY": 2, "TWD": 2, "UGX": 0, "USD": 2, "VND": 0, "XAF": 0,
+	"XOF": 0, "ZAR": 2,
+}
+
+// Currency is a validated ISO-4217 code. The zero value means "no currency".
+type Currency string
+
+func ParseCurrency(code string) (Currency, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if _, ok := exponents[code]; !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownCurrency, code)
+	}
+	return Currency(code), nil
+}
+
+func MustParseCurrency(code string) Currency {
+	c, err := ParseCurrency(code)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Exponent is the number of minor-unit digits (USD 2, JPY 0, KWD 3).
+func (c Currency) Exponent() int {
+	return exponents[string(c)]
+}
+
+func (c Currency) String() string {
+	return string(c)
+}
+
+func (c Currency) IsZero() bool {
+	return c == ""
+}
+
+// MarshalText/UnmarshalText make Currency usable as a JSON value and map key.
+func (c Currency) MarshalText() ([]byte, error) {
+	return []byte(c), nil
+}
+
+func (c *Currency) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*c = ""
+		return nil
+	}
+	parsed, err := ParseCurrency(string(text))
+	if err != nil {
+		return err
+	}
+	*c = parsed
+	return nil
+}
+
+func (c Currency) Value() (driver.Value, error) {
+	return string(c), nil
+}
+
+func (c *Currency) Scan(src any) error {
+	switch v := src.(type) {
+	case string:
+		return c.UnmarshalText([]byte(v))
+	case []byte:
+		return c.UnmarshalText(v)
+	case nil:
+		*c = ""
+		return nil
+	default:
+		return fmt.Errorf("money: cannot scan %T into Currency", src)
+	}
+}
+
+// Money is an amount in the minor units of its currency.
+type Money struct {
+	Currency Currency
+	Minor    int64
+}
+
+func New(minor int64, currency Currency) Money {
+	return Money{Currency: currency, Minor: minor}
+}
+
+func Zero(currency Currency) Money {
+	return Money{Currency: currency}
+}
+
+func (m Money) IsPositive() bool {
+	return m.Minor > 0
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+	a, b := m.Minor, other.Minor
+	if (b > 0 && a > math.MaxInt64-b) || (b < 0 && a < math.MinInt64-b) {
+		return Money{}, ErrOverflow
+	}
+	return Money{Currency: m.Currency, Minor: a + b}, nil
+}
+
+func (m Money) Mul(n int64) (Money, error) {
+	a := m.Minor
+	if a == 0 || n == 0 {
+		return Money{Currency: m.Currency}, nil
+	}
+	if (a == -1 && n == math.MinInt64) || (n == -1 && a == math.MinInt64) {
+		return Money{}, ErrOverflow
+	}
+	product := a * n
+	if product/n != a {
+		return Money{}, ErrOverflow
+	}
+	return Money{Currency: m.Currency, Minor: product}, nil
+}
+
+// Sum adds amounts that must all be in currency; an empty list sums to zero.
+func Sum(currency Currency, amounts ...Money) (Money, error) {
+	total := Zero(currency)
+	for _, amount := range amounts {
+		var err error
+		if total, err = total.Add(amount); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// String renders the amount in major units, e.g. "12.99 USD" or "1500 JPY".
+func (m Money) String() string {
+	exp := m.Currency.Exponent()
+	digits := strconv.FormatUint(absUint64(m.Minor), 10)
+	sign := ""
+	if m.Minor < 0 {
+		sign = "-"
+	}
+	if exp == 0 {
+		return sign + digits + " " + string(m.Currency)
+	}
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp-len(digits)+1) + digits
+	}
+	cut := len(digits) - exp
+	return sign + digits[:cut] + "." + digits[cut:] + " " + string(m.Currency)
+}
+
+type moneyJSON struct {
+	Currency   Currency `json:"currency"`
+	MinorUnits int64    `json:"minor_units"`
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{Currency: m.Currency, MinorUnits: m.Minor})
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var raw moneyJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw.Currency.IsZero() {
+		return fmt.Errorf("%w: missing currency", ErrUnknownCurrency)
+	}
+	m.Currency = raw.Currency
+	m.Minor = raw.MinorUnits
+	return nil
+}
+
+func absUint64(v int64) uint64 {
+	if v < 0 {
+		return uint64(-(v + 1)) + 1
+	}
+	return uint64(v)
+}
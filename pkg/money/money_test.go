@@ -0,0 +1,158 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseCurrency(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in       string
+		want     Currency
+		exponent int
+		wantErr  bool
+	}{
+		{in: "USD", want: "USD", exponent: 2},
+		{in: " usd ", want: "USD", exponent: 2},
+		{in: "JPY", want: "JPY", exponent: 0},
+		{in: "KWD", want: "KWD", exponent: 3},
+		{in: "", wantErr: true},
+		{in: "US", wantErr: true},
+		{in: "XYZ", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		got, err := ParseCurrency(tc.in)
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("ParseCurrency(%q) error mismatch: err=%v wantErr=%v", tc.in, err, tc.wantErr)
+		}
+		if tc.wantErr {
+			if !errors.Is(err, ErrUnknownCurrency) {
+				t.Fatalf("ParseCurrency(%q) should wrap ErrUnknownCurrency: %v", tc.in, err)
+			}
+			continue
+		}
+		if got != tc.want || got.Exponent() != tc.exponent {
+			t.Fatalf("ParseCurrency(%q) = %q (exp %d), want %q (exp %d)", tc.in, got, got.Exponent(), tc.want, tc.exponent)
+		}
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	t.Parallel()
+
+	usd := MustParseCurrency("USD")
+	eur := MustParseCurrency("EUR")
+
+	sum, err := New(1299, usd).Add(New(1, usd))
+	if err != nil || sum.Minor != 1300 {
+		t.Fatalf("Add = %+v, %v; want 1300", sum, err)
+	}
+	if _, err := New(1, usd).Add(New(1, eur)); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Fatalf("Add across currencies should fail with ErrCurrencyMismatch, got %v", err)
+	}
+	if _, err := New(math.MaxInt64, usd).Add(New(1, usd)); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("Add overflow should fail with ErrOverflow, got %v", err)
+	}
+	if _, err := New(math.MinInt64, usd).Add(New(-1, usd)); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("Add underflow should fail with ErrOverflow, got %v", err)
+	}
+
+	product, err := New(1299, usd).Mul(3)
+	if err != nil || product.Minor != 3897 {
+		t.Fatalf("Mul = %+v, %v; want 3897", product, err)
+	}
+	if _, err := New(math.MaxInt64/2+1, usd).Mul(2); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("Mul overflow should fail with ErrOverflow, got %v", err)
+	}
+	if _, err := New(math.MinInt64, usd).Mul(-1); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("Mul MinInt64*-1 should fail with ErrOverflow, got %v", err)
+	}
+
+	total, err := Sum(usd, New(100, usd), New(250, usd))
+	if err != nil || total.Minor != 350 {
+		t.Fatalf("Sum = %+v, %v; want 350", total, err)
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		m    Money
+		want string
+	}{
+		{m: New(1299, "USD"), want: "12.99 USD"},
+		{m: New(5, "USD"), want: "0.05 USD"},
+		{m: New(-250, "EUR"), want: "-2.50 EUR"},
+		{m: New(1500, "JPY"), want: "1500 JPY"},
+		{m: New(1234, "KWD"), want: "1.234 KWD"},
+		{m: New(math.MinInt64, "JPY"), want: "-9223372036854775808 JPY"},
+	}
+	for _, tc := range tests {
+		if got := tc.m.String(); got != tc.want {
+			t.Fatalf("String() = %q, want %q", got, tc.want)
+		}
+	}
+}
+
+func TestJSONCodecs(t *testing.T) {
+	t.Parallel()
+
+	b, err := json.Marshal(New(1299, "USD"))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if got, want := string(b), `{"currency":"USD","minor_units":1299}`; got != want {
+		t.Fatalf("marshal = %s, want %s", got, want)
+	}
+
+	var m Money
+	if err := json.Unmarshal([]byte(`{"currency":"jpy","minor_units":1500}`), &m); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if m.Currency != "JPY" || m.Minor != 1500 {
+		t.Fatalf("unmarshal = %+v", m)
+	}
+	if err := json.Unmarshal([]byte(`{"currency":"ABC","minor_units":1}`), &m); !errors.Is(err, ErrUnknownCurrency) {
+		t.Fatalf("unknown currency should fail with ErrUnknownCurrency, got %v", err)
+	}
+	if err := json.Unmarshal([]byte(`{"minor_units":1}`), &m); !errors.Is(err, ErrUnknownCurrency) {
+		t.Fatalf("missing currency should fail with ErrUnknownCurrency, got %v", err)
+	}
+
+	var prices map[Currency]int64
+	if err := json.Unmarshal([]byte(`{"usd":100,"KWD":1500}`), &prices); err != nil {
+		t.Fatalf("unmarshal currency map keys: %v", err)
+	}
+	if prices["USD"] != 100 || prices["KWD"] != 1500 {
+		t.Fatalf("currency map keys should be normalized: %v", prices)
+	}
+	if err := json.Unmarshal([]byte(`{"nope":1}`), &prices); !errors.Is(err, ErrUnknownCurrency) {
+		t.Fatalf("invalid currency map key should fail with ErrUnknownCurrency, got %v", err)
+	}
+}
+
+func TestCurrencySQLCodec(t *testing.T) {
+	t.Parallel()
+
+	v, err := MustParseCurrency("USD").Value()
+	if err != nil || v != "USD" {
+		t.Fatalf("Value() = %v, %v; want USD", v, err)
+	}
+
+	var c Currency
+	if err := c.Scan([]byte("eur")); err != nil || c != "EUR" {
+		t.Fatalf("Scan([]byte) = %q, %v; want EUR", c, err)
+	}
+	if err := c.Scan("XYZ"); !errors.Is(err, ErrUnknownCurrency) {
+		t.Fatalf("Scan(unknown) should fail with ErrUnknownCurrency, got %v", err)
+	}
+	if err := c.Scan(42); err == nil {
+		t.Fatal("Scan(int) should fail")
+	}
+}
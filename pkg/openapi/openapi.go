@@ -0,0 +1,324 @@
+// Package openapi validates HTTP requests against an OpenAPI 3.0 document.
+//
+// Only the parts of the format that request validation needs are read:
+// paths, parameters, JSON request bodies, and schemas using type, properties,
+// required, additionalProperties: false, items, allOf, enum, length, pattern
+// and range keywords. References must point into #/components.
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Document is a parsed OpenAPI document. Build one with Parse.
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+
+	routes []route
+}
+
+type Components struct {
+	Schemas    map[string]*Schema    `json:"schemas"`
+	Parameters map[string]*Parameter `json:"parameters"`
+}
+
+type PathItem struct {
+	Parameters []*Parameter `json:"parameters"`
+	Get        *Operation   `json:"get"`
+	Put        *Operation   `json:"put"`
+	Post       *Operation   `json:"post"`
+	Delete     *Operation   `json:"delete"`
+	Patch      *Operation   `json:"patch"`
+}
+
+func (p *PathItem) operations() map[string]*Operation {
+	ops := map[string]*Operation{}
+	for method, op := range map[string]*Operation{
+		http.MethodGet: p.Get, http.MethodPut: p.Put, http.MethodPost: p.Post,
+		http.MethodDelete: p.Delete, http.MethodPatch: p.Patch,
+	} {
+		if op != nil {
+			ops[method] = op
+		}
+	}
+	return ops
+}
+
+type Operation struct {
+	OperationID string       `json:"operationId"`
+	Tags        []string     `json:"tags"`
+	Parameters  []*Parameter `json:"parameters"`
+	RequestBody *RequestBody `json:"requestBody"`
+}
+
+// Parameter is a path, query or header parameter.
+type Parameter struct {
+	Ref      string  `json:"$ref"`
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Nullable             bool               `json:"nullable"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties json.RawMessage    `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	AllOf                []*Schema          `json:"allOf"`
+	Enum                 []any              `json:"enum"`
+	Pattern              string             `json:"pattern"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	MinItems             *int               `json:"minItems"`
+	MaxItems             *int               `json:"maxItems"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+
+	target  *Schema
+	pattern *regexp.Regexp
+	closed  bool
+}
+
+// OperationRef names one operation in a document.
+type OperationRef struct {
+	Method string
+	Path   string
+	*Operation
+}
+
+type route struct {
+	path     string
+	segments []string
+	params   int
+	item     *PathItem
+}
+
+// Parse reads a JSON OpenAPI 3.0 document and resolves its references.
+func Parse(data []byte) (*Document, error) {
+	var d Document
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, fmt.Errorf("decode openapi document: %w", err)
+	}
+	if !strings.HasPrefix(d.OpenAPI, "3.") {
+		return nil, fmt.Errorf("unsupported openapi version %q", d.OpenAPI)
+	}
+
+	seen := map[*Schema]bool{}
+	for name, s := range d.Components.Schemas {
+		if err := d.resolveSchema(s, seen); err != nil {
+			return nil, fmt.Errorf("schema %s: %w", name, err)
+		}
+	}
+	for name, p := range d.Components.Parameters {
+		if err := d.resolveSchema(p.Schema, seen); err != nil {
+			return nil, fmt.Errorf("parameter %s: %w", name, err)
+		}
+	}
+	for path, item := range d.Paths {
+		if !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("path %q must start with /", path)
+		}
+		if err := d.resolveParameters(item.Parameters, seen); err != nil {
+			return nil, fmt.Errorf("path %s: %w", path, err)
+		}
+		for method, op := range item.operations() {
+			if err := d.resolveParameters(op.Parameters, seen); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", method, path, err)
+			}
+			op.Parameters = mergeParameters(item.Parameters, op.Parameters)
+			if op.RequestBody == nil {
+				continue
+			}
+			for mediaType, content := range op.RequestBody.Content {
+				if err := d.resolveSchema(content.Schema, seen); err != nil {
+					return nil, fmt.Errorf("%s %s %s body: %w", method, path, mediaType, err)
+				}
+			}
+		}
+		d.routes = append(d.routes, newRoute(path, item))
+	}
+	// Literal segments win over parameters, so /v1/orders/search would match
+	// before /v1/orders/{orderID}.
+	sort.Slice(d.routes, func(i, j int) bool {
+		if d.routes[i].params != d.routes[j].params {
+			return d.routes[i].params < d.routes[j].params
+		}
+		return d.routes[i].path < d.routes[j].path
+	})
+	return &d, nil
+}
+
+func (d *Document) resolveParameters(params []*Parameter, seen map[*Schema]bool) error {
+	for i, p := range params {
+		if p.Ref != "" {
+			name, ok := strings.CutPrefix(p.Ref, "#/components/parameters/")
+			target := d.Components.Parameters[name]
+			if !ok || target == nil {
+				return fmt.Errorf("unresolved reference %q", p.Ref)
+			}
+			params[i] = target
+			p = target
+		}
+		switch p.In {
+		case "path", "query", "header":
+		default:
+			return fmt.Errorf("parameter %q: unsupported location %q", p.Name, p.In)
+		}
+		if err := d.resolveSchema(p.Schema, seen); err != nil {
+			return fmt.Errorf("parameter %q: %w", p.Name, err)
+		}
+	}
+	return nil
+}
+
+func (d *Document) resolveSchema(s *Schema, seen map[*Schema]bool) error {
+	if s == nil || seen[s] {
+		return nil
+	}
+	seen[s] = true
+	if s.Ref != "" {
+		name, ok := strings.CutPrefix(s.Ref, "#/components/schemas/")
+		s.target = d.Components.Schemas[name]
+		if !ok || s.target == nil {
+			return fmt.Errorf("unresolved reference %q", s.Ref)
+		}
+		return nil
+	}
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("pattern %q: %w", s.Pattern, err)
+		}
+		s.pattern = re
+	}
+	if len(s.AdditionalProperties) > 0 {
+		var allowed bool
+		if err := json.Unmarshal(s.AdditionalProperties, &allowed); err == nil {
+			s.closed = !allowed
+		}
+	}
+	for name, prop := range s.Properties {
+		if err := d.resolveSchema(prop, seen); err != nil {
+			return fmt.Errorf("property %s: %w", name, err)
+		}
+	}
+	for _, sub := range s.AllOf {
+		if err := d.resolveSchema(sub, seen); err != nil {
+			return err
+		}
+	}
+	return d.resolveSchema(s.Items, seen)
+}
+
+// mergeParameters adds the path item's parameters to an operation's, which
+// override them by name and location.
+func mergeParameters(shared, own []*Parameter) []*Parameter {
+	out := append([]*Parameter(nil), own...)
+	for _, p := range shared {
+		overridden := false
+		for _, o := range own {
+			if o.Name == p.Name && o.In == p.In {
+				overridden = true
+				break
+			}
+		}
+		if !overridden {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func newRoute(path string, item *PathItem) route {
+	rt := route{path: path, segments: strings.Split(strings.Trim(path, "/"), "/"), item: item}
+	for _, seg := range rt.segments {
+		if isParam(seg) {
+			rt.params++
+		}
+	}
+	return rt
+}
+
+func isParam(segment string) bool {
+	return strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
+
+// Operations lists every operation, sorted by path and method.
+func (d *Document) Operations() []OperationRef {
+	var out []OperationRef
+	for path, item := range d.Paths {
+		for method, op := range item.operations() {
+			out = append(out, OperationRef{Method: method, Path: path, Operation: op})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Path != out[j].Path {
+			return out[i].Path < out[j].Path
+		}
+		return out[i].Method < out[j].Method
+	})
+	return out
+}
+
+// Find returns the operation for method and path along with the path
+// parameters. ok is false when the document does not describe the request.
+func (d *Document) Find(method, path string) (op *Operation, params map[string]string, ok bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for _, rt := range d.routes {
+		if len(rt.segments) != len(segments) {
+			continue
+		}
+		params = map[string]string{}
+		matched := true
+		for i, seg := range rt.segments {
+			switch {
+			case isParam(seg):
+				if segments[i] == "" {
+					matched = false
+				}
+				params[seg[1:len(seg)-1]] = segments[i]
+			case seg != segments[i]:
+				matched = false
+			}
+			if !matched {
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		op = rt.item.operations()[method]
+		return op, params, op != nil
+	}
+	return nil, nil, false
+}
+
+var (
+	// ErrInvalidJSON is returned for request bodies that are not JSON.
+	ErrInvalidJSON = errors.New("invalid json")
+	// ErrUnsupportedMediaType is returned for bodies in a media type the
+	// operation does not accept.
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+)
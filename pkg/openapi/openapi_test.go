@@ -0,0 +1,248 @@
+package openapi
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/triad-platform/triad-app/pkg/httpx"
+)
+
+const testSpec = `{
+  "openapi": "3.0.3",
+  "paths": {
+    "/v1/orders": {
+      "post": {
+        "operationId": "createOrder",
+        "parameters": [{ "$ref": "#/components/parameters/IdempotencyKey" }],
+        "requestBody": {
+          "required": true,
+          "content": { "application/json": { "schema": { "$ref": "#/components/schemas/CreateOrder" } } }
+        }
+      }
+    },
+    "/v1/orders/search": {
+      "get": {
+        "operationId": "searchOrders",
+        "parameters": [{ "name": "limit", "in": "query", "schema": { "type": "integer", "minimum": 1, "maximum": 50 } }]
+      }
+    },
+    "/v1/orders/{orderID}": {
+      "parameters": [{ "name": "orderID", "in": "path", "required": true, "schema": { "type": "string", "maxLength": 8 } }],
+      "get": { "operationId": "getOrder" }
+    }
+  },
+  "components": {
+    "parameters": {
+      "IdempotencyKey": { "name": "Idempotency-Key", "in": "header", "required": true, "schema": { "type": "string", "pattern": "^[a-z0-9-]+$" } }
+    },
+    "schemas": {
+      "CreateOrder": {
+        "type": "object",
+        "required": ["items", "currency"],
+        "additionalProperties": false,
+        "properties": {
+          "items": { "type": "array", "minItems": 1, "items": { "$ref": "#/components/schemas/Item" } },
+          "currency": { "type": "string", "enum": ["USD", "EUR"] },
+          "gift": { "type": "boolean" }
+        }
+      },
+      "Item": {
+        "type": "object",
+        "required": ["sku", "qty"],
+        "additionalProperties": false,
+        "properties": {
+          "sku": { "type": "string", "minLength": 1 },
+          "qty": { "type": "integer", "minimum": 1 }
+        }
+      }
+    }
+  }
+}`
+
+func mustParse(t *testing.T) *Document {
+	t.Helper()
+	doc, err := Parse([]byte(testSpec))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return doc
+}
+
+func TestParse_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		spec string
+		want string
+	}{
+		{name: "not json", spec: `{`, want: "decode openapi document"},
+		{name: "swagger 2", spec: `{"swagger":"2.0"}`, want: "unsupported openapi version"},
+		{name: "dangling schema ref", spec: `{"openapi":"3.0.3","components":{"schemas":{"A":{"$ref":"#/components/schemas/B"}}}}`, want: "unresolved reference"},
+		{name: "dangling parameter ref", spec: `{"openapi":"3.0.3","paths":{"/a":{"get":{"parameters":[{"$ref":"#/components/parameters/X"}]}}}}`, want: "unresolved reference"},
+		{name: "cookie parameter", spec: `{"openapi":"3.0.3","paths":{"/a":{"get":{"parameters":[{"name":"s","in":"cookie"}]}}}}`, want: "unsupported location"},
+		{name: "bad pattern", spec: `{"openapi":"3.0.3","components":{"schemas":{"A":{"type":"string","pattern":"("}}}}`, want: "pattern"},
+	}
+	for _, tc := range tests {
+		_, err := Parse([]byte(tc.spec))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s: error mismatch: got=%v want containing %q", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestDocument_Find(t *testing.T) {
+	t.Parallel()
+
+	doc := mustParse(t)
+	tests := []struct {
+		method    string
+		path      string
+		wantOK    bool
+		wantOp    string
+		wantParam string
+	}{
+		{method: http.MethodPost, path: "/v1/orders", wantOK: true, wantOp: "createOrder"},
+		{method: http.MethodGet, path: "/v1/orders/search", wantOK: true, wantOp: "searchOrders"},
+		{method: http.MethodGet, path: "/v1/orders/o-1", wantOK: true, wantOp: "getOrder", wantParam: "o-1"},
+		{method: http.MethodDelete, path: "/v1/orders/o-1"},
+		{method: http.MethodGet, path: "/v1/orders/o-1/items"},
+		{method: http.MethodGet, path: "/v1/carts"},
+	}
+	for _, tc := range tests {
+		op, params, ok := doc.Find(tc.method, tc.path)
+		if ok != tc.wantOK {
+			t.Fatalf("%s %s: found mismatch: got=%v want=%v", tc.method, tc.path, ok, tc.wantOK)
+		}
+		if !ok {
+			continue
+		}
+		if op.OperationID != tc.wantOp {
+			t.Fatalf("%s %s: operation mismatch: got=%q want=%q", tc.method, tc.path, op.OperationID, tc.wantOp)
+		}
+		if params["orderID"] != tc.wantParam {
+			t.Fatalf("%s %s: path param mismatch: got=%q want=%q", tc.method, tc.path, params["orderID"], tc.wantParam)
+		}
+	}
+
+	if got := len(doc.Operations()); got != 3 {
+		t.Fatalf("operations mismatch: got=%d want=3", got)
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	t.Parallel()
+
+	doc := mustParse(t)
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		body        string
+		contentType string
+		key         string
+		wantErr     error
+		wantFields  []httpx.FieldError
+	}{
+		{
+			name: "valid", method: http.MethodPost, target: "/v1/orders", key: "idem-1",
+			body: `{"items":[{"sku":"sku_1","qty":2}],"currency":"USD"}`,
+		},
+		{
+			name: "missing header and fields", method: http.MethodPost, target: "/v1/orders",
+			body: `{"items":[]}`,
+			wantFields: []httpx.FieldError{
+				{Field: "header.Idempotency-Key", Code: httpx.FieldRequired},
+				{Field: "currency", Code: httpx.FieldRequired},
+				{Field: "items", Code: httpx.FieldRequired},
+			},
+		},
+		{
+			name: "item errors by index", method: http.MethodPost, target: "/v1/orders", key: "idem-1",
+			body: `{"items":[{"sku":"sku_1","qty":1},{"sku":"","qty":0,"price_cents":100},{"sku":"sku_3","qty":1.5}],"currency":"GBP","gift":"yes"}`,
+			wantFields: []httpx.FieldError{
+				{Field: "currency", Code: httpx.FieldUnsupported},
+				{Field: "gift", Code: httpx.FieldInvalidType},
+				{Field: "items[1].price_cents", Code: httpx.FieldUnknown},
+				{Field: "items[1].qty", Code: httpx.FieldNotPositive},
+				{Field: "items[1].sku", Code: httpx.FieldRequired},
+				{Field: "items[2].qty", Code: httpx.FieldInvalidType},
+			},
+		},
+		{
+			name: "bad header", method: http.MethodPost, target: "/v1/orders", key: "IDEM 1",
+			body:       `{"items":[{"sku":"sku_1","qty":1}],"currency":"EUR"}`,
+			wantFields: []httpx.FieldError{{Field: "header.Idempotency-Key", Code: httpx.FieldInvalid}},
+		},
+		{
+			name: "not an object", method: http.MethodPost, target: "/v1/orders", key: "idem-1",
+			body:       `[1]`,
+			wantFields: []httpx.FieldError{{Field: "body", Code: httpx.FieldInvalidType}},
+		},
+		{name: "missing body", method: http.MethodPost, target: "/v1/orders", key: "idem-1", wantFields: []httpx.FieldError{{Field: "body", Code: httpx.FieldRequired}}},
+		{name: "invalid json", method: http.MethodPost, target: "/v1/orders", key: "idem-1", body: `{"items":`, wantErr: ErrInvalidJSON},
+		{name: "trailing data", method: http.MethodPost, target: "/v1/orders", key: "idem-1", body: `{} {}`, wantErr: ErrInvalidJSON},
+		{name: "form body", method: http.MethodPost, target: "/v1/orders", key: "idem-1", body: `a=1`, contentType: "application/x-www-form-urlencoded", wantErr: ErrUnsupportedMediaType},
+		{name: "query in range", method: http.MethodGet, target: "/v1/orders/search?limit=10"},
+		{name: "query out of range", method: http.MethodGet, target: "/v1/orders/search?limit=500", wantFields: []httpx.FieldError{{Field: "query.limit", Code: httpx.FieldOutOfRange}}},
+		{name: "query wrong type", method: http.MethodGet, target: "/v1/orders/search?limit=ten", wantFields: []httpx.FieldError{{Field: "query.limit", Code: httpx.FieldInvalidType}}},
+		{name: "path param too long", method: http.MethodGet, target: "/v1/orders/o-123456789", wantFields: []httpx.FieldError{{Field: "path.orderID", Code: httpx.FieldInvalid}}},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+		if tc.body == "" {
+			req = httptest.NewRequest(tc.method, tc.target, nil)
+		}
+		contentType := tc.contentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		req.Header.Set("Content-Type", contentType)
+		if tc.key != "" {
+			req.Header.Set("Idempotency-Key", tc.key)
+		}
+		op, params, ok := doc.Find(req.Method, req.URL.Path)
+		if !ok {
+			t.Fatalf("%s: operation not found", tc.name)
+		}
+
+		fields, err := ValidateRequest(req, op, params)
+		if !errors.Is(err, tc.wantErr) {
+			t.Fatalf("%s: error mismatch: got=%v want=%v", tc.name, err, tc.wantErr)
+		}
+		if len(fields) != len(tc.wantFields) {
+			t.Fatalf("%s: field errors mismatch: got=%+v want=%+v", tc.name, fields, tc.wantFields)
+		}
+		for i, want := range tc.wantFields {
+			if got := fields[i]; got.Field != want.Field || got.Code != want.Code {
+				t.Fatalf("%s: field error %d mismatch: got=%+v want=%+v", tc.name, i, got, want)
+			}
+		}
+	}
+}
+
+func TestValidateRequest_BodyStaysReadable(t *testing.T) {
+	t.Parallel()
+
+	doc := mustParse(t)
+	body := `{"items":[{"sku":"sku_1","qty":2}],"currency":"USD"}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/orders", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", "idem-1")
+	op, params, _ := doc.Find(req.Method, req.URL.Path)
+	if _, err := ValidateRequest(req, op, params); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	got, err := io.ReadAll(req.Body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+	if string(got) != body {
+		t.Fatalf("body mismatch: got=%q want=%q", got, body)
+	}
+}
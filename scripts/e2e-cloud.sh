@@ -30,7 +30,7 @@ run_id="$(date +%s)-$$"
 idem_key="cloud-smoke-${run_id}"
 request_id="cloud-smoke-${run_id}"
 
-request_body='{"user_id":"cloud-smoke-user","currency":"USD","items":[{"sku":"sku-cloud-smoke","quantity":1,"unit_price":1999}]}'
+request_body='{"user_id":"cloud-smoke-user","currency":"USD","items":[{"sku":"sku-cloud-smoke","qty":1}]}'
 
 echo "Sending create order request..."
 resp_one="$(mktemp)"
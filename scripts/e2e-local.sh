@@ -11,8 +11,9 @@ API_LOG="${LOG_DIR}/api-gateway.log"
 ORDERS_LOG="${LOG_DIR}/orders.log"
 NOTIFY_LOG="${LOG_DIR}/notifications.log"
 WORKER_LOG="${LOG_DIR}/worker.log"
+INVENTORY_LOG="${LOG_DIR}/inventory.log"
 
-rm -f "$API_LOG" "$ORDERS_LOG" "$NOTIFY_LOG" "$WORKER_LOG"
+rm -f "$API_LOG" "$ORDERS_LOG" "$NOTIFY_LOG" "$WORKER_LOG" "$INVENTORY_LOG"
 
 cleanup() {
   local code=$?
@@ -20,6 +21,7 @@ cleanup() {
   if [[ -n "${PID_ORDERS:-}" ]]; then kill "$PID_ORDERS" 2>/dev/null || true; fi
   if [[ -n "${PID_NOTIFY:-}" ]]; then kill "$PID_NOTIFY" 2>/dev/null || true; fi
   if [[ -n "${PID_WORKER:-}" ]]; then kill "$PID_WORKER" 2>/dev/null || true; fi
+  if [[ -n "${PID_INVENTORY:-}" ]]; then kill "$PID_INVENTORY" 2>/dev/null || true; fi
   wait 2>/dev/null || true
   if [[ $code -ne 0 ]]; then
     echo "E2E failed. Logs:"
@@ -27,6 +29,7 @@ cleanup() {
     echo "  $ORDERS_LOG"
     echo "  $NOTIFY_LOG"
     echo "  $WORKER_LOG"
+    echo "  $INVENTORY_LOG"
   fi
   exit "$code"
 }
@@ -103,9 +106,9 @@ stop_stale_local_services() {
   local pids=""
 
   # Kill any stale listeners on service ports from previous runs.
-  pids="$(list_listener_pids 8080 8081 8082 9091)"
+  pids="$(list_listener_pids 8080 8081 8082 8083 9091)"
   if [[ -n "${pids// }" ]]; then
-    echo "Stopping stale services on ports 8080/8081/8082/9091..."
+    echo "Stopping stale services on ports 8080/8081/8082/8083/9091..."
     kill_pids_force "$pids"
   fi
 
@@ -153,11 +156,14 @@ stop_stale_local_services
 assert_port_free 8080
 assert_port_free 8081
 assert_port_free 8082
+assert_port_free 8083
 assert_port_free 9091
 
 echo "Starting services..."
 GOCACHE="${ROOT_DIR}/.gocache" go run ./services/notifications/cmd/notifications >"$NOTIFY_LOG" 2>&1 &
 PID_NOTIFY=$!
+GOCACHE="${ROOT_DIR}/.gocache" go run ./services/inventory/cmd/inventory >"$INVENTORY_LOG" 2>&1 &
+PID_INVENTORY=$!
 GOCACHE="${ROOT_DIR}/.gocache" go run ./services/orders/cmd/orders >"$ORDERS_LOG" 2>&1 &
 PID_ORDERS=$!
 GOCACHE="${ROOT_DIR}/.gocache" go run ./services/api-gateway/cmd/api-gateway >"$API_LOG" 2>&1 &
@@ -167,18 +173,40 @@ PID_WORKER=$!
 
 sleep 0.5
 assert_process_alive "$PID_NOTIFY" "notifications" "$NOTIFY_LOG"
+assert_process_alive "$PID_INVENTORY" "inventory" "$INVENTORY_LOG"
 assert_process_alive "$PID_ORDERS" "orders" "$ORDERS_LOG"
 assert_process_alive "$PID_API" "api-gateway" "$API_LOG"
 assert_process_alive "$PID_WORKER" "worker" "$WORKER_LOG"
 
 wait_for_http "notifications" "http://localhost:8082/healthz"
+wait_for_http "inventory" "http://localhost:8083/healthz"
 wait_for_http "orders" "http://localhost:8081/healthz"
 wait_for_http "api-gateway" "http://localhost:8080/healthz"
 wait_for_http "orders metrics" "http://localhost:8081/metrics"
 wait_for_http "api-gateway metrics" "http://localhost:8080/metrics"
 wait_for_http "worker metrics" "http://localhost:9091/metrics"
 
-request_body='{"user_id":"u_e2e","items":[{"sku":"sku_e2e","qty":2,"price_cents":1250}],"currency":"USD"}'
+echo "Seeding catalog SKU..."
+catalog_status="$(curl -s -o /dev/null -w '%{http_code}' \
+  -X PUT http://localhost:8081/v1/admin/catalog/skus/sku_e2e \
+  -H "Content-Type: application/json" \
+  -d '{"name":"E2E Widget","prices":{"USD":1250}}')"
+if [[ "$catalog_status" != "200" ]]; then
+  echo "expected catalog seed status 200, got ${catalog_status}"
+  exit 1
+fi
+
+echo "Seeding inventory stock..."
+stock_status="$(curl -s -o /dev/null -w '%{http_code}' \
+  -X PUT http://localhost:8083/v1/stock/sku_e2e \
+  -H "Content-Type: application/json" \
+  -d '{"on_hand":1000,"low_stock_threshold":10}')"
+if [[ "$stock_status" != "200" ]]; then
+  echo "expected stock seed status 200, got ${stock_status}"
+  exit 1
+fi
+
+request_body='{"user_id":"u_e2e","items":[{"sku":"sku_e2e","qty":2}],"currency":"USD"}'
 run_id="$(date +%s)-$$"
 idem_key="idem-e2e-${run_id}"
 request_id="req-e2e-${run_id}"
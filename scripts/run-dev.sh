@@ -10,6 +10,8 @@ echo "Starting services..."
 echo "api-gateway   :8080"
 echo "orders        :8081"
 echo "notifications :8082"
+echo "inventory     :8083"
+echo "auth          :8084"
 echo "worker        (no port)"
 
 # Run each service in background
@@ -21,6 +23,10 @@ go run ./services/notifications/cmd/notifications &
 PID3=$!
 go run ./services/worker/cmd/worker &
 PID4=$!
+go run ./services/inventory/cmd/inventory &
+PID5=$!
+go run ./services/auth/cmd/auth &
+PID6=$!
 
-trap 'echo "Stopping..."; kill $PID1 $PID2 $PID3 $PID4 2>/dev/null || true' EXIT
+trap 'echo "Stopping..."; kill $PID1 $PID2 $PID3 $PID4 $PID5 $PID6 2>/dev/null || true' EXIT
 wait
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/triad-platform/triad-app/pkg/httpx"
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+)
+
+const apiKeyHeader = "X-Api-Key"
+
+var (
+	errInvalidAPIKey     = errors.New("invalid api key")
+	errAPIKeyUnavailable = errors.New("api key introspection unavailable")
+)
+
+// apiKeyConfig enables X-Api-Key authentication when an introspection URL is set.
+type apiKeyConfig struct {
+	IntrospectionURL string
+	CacheTTL         time.Duration
+}
+
+func (c apiKeyConfig) enabled() bool {
+	return c.IntrospectionURL != ""
+}
+
+type introspectionResponse struct {
+	Active    bool       `json:"active"`
+	Subject   string     `json:"subject"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type apiKeyEntry struct {
+	principal httpx.Principal
+	active    bool
+	until     time.Time
+}
+
+// apiKeyResolver resolves keys through the auth service introspection endpoint
+// and caches the answer, keyed by the key's hash. A revoked key keeps working
+// for at most ttl; unknown keys are cached for a shorter time so a burst of
+// bad keys does not turn into a burst of introspection calls.
+type apiKeyResolver struct {
+	client      *http.Client
+	url         string
+	ttl         time.Duration
+	negativeTTL time.Duration
+	maxEntries  int
+	metrics     *metricsx.Registry
+	// now is overridden in tests.
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]apiKeyEntry
+}
+
+func newAPIKeyResolver(cfg apiKeyConfig, client *http.Client, metrics *metricsx.Registry) *apiKeyResolver {
+	ttl := cfg.CacheTTL
+	if ttl <= 0 {
+		ttl = time.Minute
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 3 * time.Second}
+	}
+	return &apiKeyResolver{
+		client:      client,
+		url:         cfg.IntrospectionURL,
+		ttl:         ttl,
+		negativeTTL: min(ttl, 10*time.Second),
+		maxEntries:  10000,
+		metrics:     metrics,
+		entries:     map[string]apiKeyEntry{},
+	}
+}
+
+func (a *apiKeyResolver) clock() time.Time {
+	if a.now != nil {
+		return a.now()
+	}
+	return time.Now()
+}
+
+func (a *apiKeyResolver) resolve(ctx context.Context, key string) (httpx.Principal, error) {
+	sum := sha256.Sum256([]byte(key))
+	cacheKey := hex.EncodeToString(sum[:])
+	now := a.clock()
+
+	a.mu.Lock()
+	entry, ok := a.entries[cacheKey]
+	a.mu.Unlock()
+	if ok && now.Before(entry.until) {
+		a.inc("auth_api_key_cache_hits_total")
+		return entry.result()
+	}
+
+	a.inc("auth_api_key_cache_misses_total")
+	resp, err := a.introspect(ctx, key)
+	if err != nil {
+		a.inc("auth_api_key_introspection_errors_total")
+		return httpx.Principal{}, fmt.Errorf("%w: %v", errAPIKeyUnavailable, err)
+	}
+
+	entry = apiKeyEntry{active: resp.Active && resp.Subject != "", until: now.Add(a.negativeTTL)}
+	if entry.active {
+		entry.principal = httpx.Principal{Subject: resp.Subject, Scopes: resp.Scopes}
+		entry.until = now.Add(a.ttl)
+		if resp.ExpiresAt != nil && resp.ExpiresAt.Before(entry.until) {
+			entry.until = *resp.ExpiresAt
+		}
+	}
+
+	a.mu.Lock()
+	if len(a.entries) >= a.maxEntries {
+		a.prune(now)
+	}
+	a.entries[cacheKey] = entry
+	a.mu.Unlock()
+	return entry.result()
+}
+
+func (e apiKeyEntry) result() (httpx.Principal, error) {
+	if !e.active {
+		return httpx.Principal{}, errInvalidAPIKey
+	}
+	return e.principal, nil
+}
+
+// prune drops expired entries, and everything if that is not enough; called
+// with mu held.
+func (a *apiKeyResolver) prune(now time.Time) {
+	for k, e := range a.entries {
+		if !now.Before(e.until) {
+			delete(a.entries, k)
+		}
+	}
+	if len(a.entries) >= a.maxEntries {
+		clear(a.entries)
+	}
+}
+
+func (a *apiKeyResolver) introspect(ctx context.Context, key string) (introspectionResponse, error) {
+	body, err := json.Marshal(map[string]string{"api_key": key})
+	if err != nil {
+		return introspectionResponse{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return introspectionResponse{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return introspectionResponse{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return introspectionResponse{}, fmt.Errorf("unexpected introspection status %d", resp.StatusCode)
+	}
+	var out introspectionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return introspectionResponse{}, err
+	}
+	return out, nil
+}
+
+func (a *apiKeyResolver) inc(name string) {
+	if a.metrics != nil {
+		a.metrics.Inc(name)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/triad-platform/triad-app/pkg/httpx"
+)
+
+const introspectionURL = "http://auth:8084/v1/auth/api-keys/introspect"
+
+func TestGateway_APIKeyAuthentication(t *testing.T) {
+	t.Parallel()
+
+	keys := map[string]string{
+		"pck_writer": `{"active":true,"key_id":"k_1","subject":"partner_1","scopes":["orders:write"]}`,
+		"pck_reader": `{"active":true,"key_id":"k_2","subject":"partner_1","scopes":["orders:read"]}`,
+	}
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		apiKey       string
+		introspectOK bool
+		wantStatus   int
+		wantUpstream string
+		wantUser     string
+		wantScopes   string
+	}{
+		{name: "writer creates order", method: http.MethodPost, path: "/v1/orders", apiKey: "pck_writer", introspectOK: true, wantStatus: http.StatusOK, wantUpstream: "POST /v1/orders", wantUser: "partner_1"},
+		{name: "reader reads order", method: http.MethodGet, path: "/v1/orders/o-1", apiKey: "pck_reader", introspectOK: true, wantStatus: http.StatusOK, wantUpstream: "GET /v1/orders/o-1", wantUser: "partner_1"},
+		{name: "reader creates order", method: http.MethodPost, path: "/v1/orders", apiKey: "pck_reader", introspectOK: true, wantStatus: http.StatusForbidden},
+		{name: "writer manages catalog", method: http.MethodPut, path: "/v1/admin/catalog/skus/sku_1", apiKey: "pck_writer", introspectOK: true, wantStatus: http.StatusForbidden},
+		{name: "unknown key", method: http.MethodPost, path: "/v1/orders", apiKey: "pck_unknown", introspectOK: true, wantStatus: http.StatusUnauthorized},
+		{name: "no credentials", method: http.MethodPost, path: "/v1/orders", introspectOK: true, wantStatus: http.StatusUnauthorized},
+		{name: "introspection down", method: http.MethodPost, path: "/v1/orders", apiKey: "pck_writer", wantStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var upstream, upstreamUser string
+			client := &http.Client{
+				Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					if req.URL.String() == introspectionURL {
+						if !tc.introspectOK {
+							return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: io.NopCloser(strings.NewReader(""))}, nil
+						}
+						var body struct {
+							APIKey string `json:"api_key"`
+						}
+						if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+							return nil, err
+						}
+						resp, ok := keys[body.APIKey]
+						if !ok {
+							resp = `{"active":false}`
+						}
+						return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(resp))}, nil
+					}
+					upstream = req.Method + " " + req.URL.RequestURI()
+					upstreamUser = req.Header.Get(httpx.AuthenticatedUserHeader)
+					return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{}`))}, nil
+				}),
+			}
+			var logs bytes.Buffer
+			r := newRouterWithConfig(gatewayConfig{
+				OrdersURL: "http://orders:8081",
+				Client:    client,
+				APIKeys:   apiKeyConfig{IntrospectionURL: introspectionURL, CacheTTL: time.Minute},
+				Logger:    zerolog.New(&logs),
+			}, nil)
+
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(`{}`))
+			req.Header.Set("Content-Type", "application/json")
+			if tc.apiKey != "" {
+				req.Header.Set(apiKeyHeader, tc.apiKey)
+			}
+			req.Header.Set(httpx.AuthenticatedUserHeader, "spoofed")
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status code mismatch: got=%d want=%d body=%q", rec.Code, tc.wantStatus, rec.Body.String())
+			}
+			if upstream != tc.wantUpstream {
+				t.Fatalf("upstream request mismatch: got=%q want=%q", upstream, tc.wantUpstream)
+			}
+			if upstreamUser != tc.wantUser {
+				t.Fatalf("upstream user mismatch: got=%q want=%q", upstreamUser, tc.wantUser)
+			}
+			if tc.wantStatus == http.StatusUnauthorized && !strings.Contains(rec.Header().Get("WWW-Authenticate"), "ApiKey") {
+				t.Fatalf("missing ApiKey challenge: %q", rec.Header().Values("WWW-Authenticate"))
+			}
+			audited := strings.Contains(logs.String(), `"audit":"access_denied"`)
+			wantAudit := tc.wantStatus == http.StatusUnauthorized || tc.wantStatus == http.StatusForbidden
+			if audited != wantAudit {
+				t.Fatalf("audit log mismatch: audited=%v logs=%q", audited, logs.String())
+			}
+		})
+	}
+}
+
+func TestAPIKeyResolver_Cache(t *testing.T) {
+	t.Parallel()
+
+	var calls int
+	active := true
+	expiresAt := time.Date(2026, 3, 1, 0, 0, 30, 0, time.UTC)
+	client := &http.Client{
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			calls++
+			body := `{"active":false}`
+			if active {
+				body = `{"active":true,"subject":"partner_1","scopes":["orders:read"],"expires_at":"` + expiresAt.Format(time.RFC3339) + `"}`
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+		}),
+	}
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	resolver := newAPIKeyResolver(apiKeyConfig{IntrospectionURL: introspectionURL, CacheTTL: time.Minute}, client, nil)
+	resolver.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	p, err := resolver.resolve(ctx, "pck_1")
+	if err != nil || p.Subject != "partner_1" || !p.HasScope("orders:read") {
+		t.Fatalf("resolve() mismatch: principal=%+v err=%v", p, err)
+	}
+	if _, err := resolver.resolve(ctx, "pck_1"); err != nil || calls != 1 {
+		t.Fatalf("cached resolve mismatch: err=%v calls=%d want=1", err, calls)
+	}
+
+	// The key expires before the cache ttl, so the entry does too.
+	now = now.Add(31 * time.Second)
+	active = false
+	if _, err := resolver.resolve(ctx, "pck_1"); !errors.Is(err, errInvalidAPIKey) || calls != 2 {
+		t.Fatalf("expired resolve mismatch: err=%v calls=%d want=2", err, calls)
+	}
+	if _, err := resolver.resolve(ctx, "pck_1"); !errors.Is(err, errInvalidAPIKey) || calls != 2 {
+		t.Fatalf("negative cache mismatch: err=%v calls=%d want=2", err, calls)
+	}
+	now = now.Add(11 * time.Second)
+	if _, err := resolver.resolve(ctx, "pck_1"); !errors.Is(err, errInvalidAPIKey) || calls != 3 {
+		t.Fatalf("negative cache expiry mismatch: err=%v calls=%d want=3", err, calls)
+	}
+}
+
+func TestAPIKeySettings(t *testing.T) {
+	t.Setenv("API_KEY_INTROSPECTION_URL", " "+introspectionURL+" ")
+	t.Setenv("API_KEY_CACHE_TTL", "-1s")
+
+	got := apiKeySettings()
+	want := apiKeyConfig{IntrospectionURL: introspectionURL, CacheTTL: time.Minute}
+	if got != want {
+		t.Fatalf("apiKeySettings() mismatch: got=%+v want=%+v", got, want)
+	}
+	if !got.enabled() {
+		t.Fatal("expected api key authentication to be enabled")
+	}
+}
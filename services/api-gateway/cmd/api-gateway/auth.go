@@ -0,0 +1,376 @@
+package main
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog"
+	"github.com/triad-platform/triad-app/pkg/httpx"
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+)
+
+const authRealm = "pulsecart"
+
+// Problem codes for rejected credentials.
+const (
+	codeInvalidAPIKey   = "invalid_api_key"
+	codeInvalidToken    = "invalid_token"
+	codeAuthUnavailable = "auth_unavailable"
+)
+
+var (
+	errJWKSUnavailable = errors.New("jwks unavailable")
+	errUnknownKeyID    = errors.New("unknown key id")
+)
+
+// jwtConfig enables bearer token verification when a JWKS URL or file is set.
+type jwtConfig struct {
+	JWKSURL  string
+	JWKSFile string
+	Issuer   string
+	Audience string
+	CacheTTL time.Duration
+}
+
+func (c jwtConfig) enabled() bool {
+	return c.JWKSURL != "" || c.JWKSFile != ""
+}
+
+type accessClaims struct {
+	Email string   `json:"email"`
+	Roles []string `json:"roles,omitempty"`
+	// Scope is space-delimited, as in RFC 9068.
+	Scope string `json:"scope,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func (c *accessClaims) principal() httpx.Principal {
+	return httpx.Principal{
+		Subject: c.Subject,
+		Roles:   c.Roles,
+		Scopes:  strings.Fields(c.Scope),
+	}
+}
+
+type jwtVerifier struct {
+	keys     *jwksCache
+	issuer   string
+	audience string
+}
+
+func newJWTVerifier(cfg jwtConfig, client *http.Client, metrics *metricsx.Registry) *jwtVerifier {
+	ttl := cfg.CacheTTL
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	var fetch func(ctx context.Context) ([]byte, error)
+	if cfg.JWKSURL != "" {
+		if client == nil {
+			client = &http.Client{Timeout: 3 * time.Second}
+		}
+		fetch = func(ctx context.Context) ([]byte, error) { return fetchJWKS(ctx, client, cfg.JWKSURL) }
+	} else {
+		fetch = func(context.Context) ([]byte, error) { return os.ReadFile(cfg.JWKSFile) }
+	}
+	return &jwtVerifier{
+		keys: &jwksCache{
+			fetch:      fetch,
+			ttl:        ttl,
+			minRefresh: 30 * time.Second,
+			metrics:    metrics,
+		},
+		issuer:   cfg.Issuer,
+		audience: cfg.Audience,
+	}
+}
+
+func (v *jwtVerifier) verify(ctx context.Context, raw string) (*accessClaims, error) {
+	claims := &accessClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return v.keys.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(v.issuer),
+		jwt.WithAudience(v.audience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+	return claims, nil
+}
+
+// stripAuthHeaders drops client-supplied identity headers so only the auth
+// middleware can set them.
+func stripAuthHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httpx.StripPrincipalHeaders(r.Header)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authMiddleware accepts an X-Api-Key (when apiKeys is set) or a bearer JWT
+// (when verifier is set). An API key takes precedence if both are sent.
+func authMiddleware(verifier *jwtVerifier, apiKeys *apiKeyResolver, metrics *metricsx.Registry, log zerolog.Logger) func(http.Handler) http.Handler {
+	challenge := func(w http.ResponseWriter, bearerParams string) {
+		if verifier != nil {
+			w.Header().Add("WWW-Authenticate", fmt.Sprintf(`Bearer realm=%q`, authRealm)+bearerParams)
+		}
+		if apiKeys != nil {
+			w.Header().Add("WWW-Authenticate", fmt.Sprintf(`ApiKey realm=%q`, authRealm))
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key := strings.TrimSpace(r.Header.Get(apiKeyHeader)); key != "" && apiKeys != nil {
+				principal, err := apiKeys.resolve(r.Context(), key)
+				if errors.Is(err, errAPIKeyUnavailable) {
+					metrics.Inc("auth_api_key_unavailable_total")
+					httpx.Error(w, r, http.StatusServiceUnavailable, codeAuthUnavailable, "authentication unavailable")
+					return
+				}
+				if err != nil {
+					metrics.Inc("auth_invalid_api_key_total")
+					httpx.AuditDenied(log, r, httpx.Principal{}, "invalid api key")
+					challenge(w, "")
+					httpx.Error(w, r, http.StatusUnauthorized, codeInvalidAPIKey, "invalid api key")
+					return
+				}
+				metrics.Inc("auth_api_key_verified_total")
+				principal.SetHeaders(r.Header)
+				next.ServeHTTP(w, r.WithContext(httpx.WithPrincipal(r.Context(), principal)))
+				return
+			}
+
+			raw, ok := bearerToken(r)
+			if !ok || verifier == nil {
+				metrics.Inc("auth_missing_token_total")
+				httpx.AuditDenied(log, r, httpx.Principal{}, "missing credentials")
+				challenge(w, "")
+				httpx.Error(w, r, http.StatusUnauthorized, httpx.CodeUnauthenticated, "missing credentials")
+				return
+			}
+
+			claims, err := verifier.verify(r.Context(), raw)
+			if errors.Is(err, errJWKSUnavailable) {
+				metrics.Inc("auth_jwks_unavailable_total")
+				httpx.Error(w, r, http.StatusServiceUnavailable, codeAuthUnavailable, "authentication unavailable")
+				return
+			}
+			if err != nil {
+				metrics.Inc("auth_invalid_token_total")
+				reason := invalidTokenReason(err)
+				httpx.AuditDenied(log, r, httpx.Principal{}, reason)
+				challenge(w, fmt.Sprintf(`, error="invalid_token", error_description=%q`, reason))
+				httpx.Error(w, r, http.StatusUnauthorized, codeInvalidToken, "invalid bearer token")
+				return
+			}
+
+			metrics.Inc("auth_verified_total")
+			principal := claims.principal()
+			principal.SetHeaders(r.Header)
+			ctx := httpx.WithPrincipal(r.Context(), principal)
+			ctx = context.WithValue(ctx, credentialExpiryKey{}, claims.ExpiresAt.Time)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+type credentialExpiryKey struct{}
+
+// credentialExpiry returns when the bearer token that authenticated ctx
+// expires. API keys carry no expiry.
+func credentialExpiry(ctx context.Context) (time.Time, bool) {
+	exp, ok := ctx.Value(credentialExpiryKey{}).(time.Time)
+	return exp, ok
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(r.Header.Get("Authorization")), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// invalidTokenReason keeps the WWW-Authenticate description coarse; parser
+// errors can echo token contents.
+func invalidTokenReason(err error) string {
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return "token expired"
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		return "invalid audience"
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		return "invalid issuer"
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, errUnknownKeyID):
+		return "invalid signature"
+	default:
+		return "malformed token"
+	}
+}
+
+// jwksCache keeps the last good key set. It refreshes after ttl, and early
+// (at most once per minRefresh) when a token names an unknown kid so a key
+// rotation is picked up without waiting for the ttl. Only one refresh runs at
+// a time and the lock is not held while it fetches: lookups of cached keys
+// carry on, and lookups of an unknown kid wait for the refresh in flight.
+type jwksCache struct {
+	fetch      func(ctx context.Context) ([]byte, error)
+	ttl        time.Duration
+	minRefresh time.Duration
+	metrics    *metricsx.Registry
+	// now is overridden in tests.
+	now func() time.Time
+
+	mu          sync.Mutex
+	keys        map[string]*rsa.PublicKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	// refreshing is closed when the refresh in flight finishes.
+	refreshing chan struct{}
+}
+
+func (c *jwksCache) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+func (c *jwksCache) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock()
+	_, known := c.keys[kid]
+	stale := c.keys == nil || now.Sub(c.fetchedAt) >= c.ttl
+	canRetry := c.lastAttempt.IsZero() || now.Sub(c.lastAttempt) >= c.minRefresh
+	switch {
+	case c.refreshing != nil && !known:
+		done := c.refreshing
+		c.mu.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+		}
+		c.mu.Lock()
+	case c.refreshing == nil && (stale || !known) && canRetry:
+		c.refresh(ctx, now)
+	}
+
+	if c.keys == nil {
+		return nil, errJWKSUnavailable
+	}
+	key, ok := c.keys[kid]
+	if !ok {
+		return nil, errUnknownKeyID
+	}
+	return key, nil
+}
+
+// refresh replaces the key set on success and keeps serving the previous one on
+// failure, so a JWKS outage only matters to a gateway that never loaded keys.
+// It is called with c.mu held and releases it for the fetch.
+func (c *jwksCache) refresh(ctx context.Context, now time.Time) {
+	c.lastAttempt = now
+	done := make(chan struct{})
+	c.refreshing = done
+	c.mu.Unlock()
+
+	raw, err := c.fetch(ctx)
+	var keys map[string]*rsa.PublicKey
+	if err == nil {
+		keys, err = parseJWKS(raw)
+	}
+
+	c.mu.Lock()
+	c.refreshing = nil
+	close(done)
+	if err != nil {
+		c.inc("auth_jwks_refresh_errors_total")
+		return
+	}
+	c.keys = keys
+	c.fetchedAt = now
+	c.inc("auth_jwks_refresh_total")
+}
+
+func (c *jwksCache) inc(name string) {
+	if c.metrics != nil {
+		c.metrics.Inc(name)
+	}
+}
+
+type jwkSet struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Use string `json:"use"`
+		Kid string `json:"kid"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+func parseJWKS(raw []byte) (map[string]*rsa.PublicKey, error) {
+	var set jwkSet
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return nil, fmt.Errorf("decode jwks: %w", err)
+	}
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("jwk %q: invalid modulus", k.Kid)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("jwk %q: invalid exponent", k.Kid)
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("jwks contains no RSA signing keys")
+	}
+	return keys, nil
+}
+
+func fetchJWKS(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected jwks status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
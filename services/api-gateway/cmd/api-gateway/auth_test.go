@@ -0,0 +1,380 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/triad-platform/triad-app/pkg/httpx"
+)
+
+func TestGateway_JWTAuthentication(t *testing.T) {
+	t.Parallel()
+
+	key := newTestRSAKey(t)
+	jwksFile := writeJWKSFile(t, map[string]*rsa.PrivateKey{"k1": key})
+	otherKey := newTestRSAKey(t)
+
+	valid := signTestToken(t, key, "k1", testClaims("u_1", time.Hour))
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+		wantChallenge string
+		wantUser      string
+	}{
+		{name: "missing token", wantStatus: http.StatusUnauthorized, wantChallenge: `Bearer realm="pulsecart"`},
+		{name: "wrong scheme", authorization: "Basic dTpw", wantStatus: http.StatusUnauthorized, wantChallenge: `Bearer realm="pulsecart"`},
+		{name: "valid token", authorization: "Bearer " + valid, wantStatus: http.StatusCreated, wantUser: "u_1"},
+		{
+			name:          "expired token",
+			authorization: "Bearer " + signTestToken(t, key, "k1", testClaims("u_1", -time.Minute)),
+			wantStatus:    http.StatusUnauthorized,
+			wantChallenge: `Bearer realm="pulsecart", error="invalid_token", error_description="token expired"`,
+		},
+		{
+			name: "wrong audience",
+			authorization: "Bearer " + signTestToken(t, key, "k1", func() accessClaims {
+				c := testClaims("u_1", time.Hour)
+				c.Audience = jwt.ClaimStrings{"someone-else"}
+				return c
+			}()),
+			wantStatus:    http.StatusUnauthorized,
+			wantChallenge: `Bearer realm="pulsecart", error="invalid_token", error_description="invalid audience"`,
+		},
+		{
+			name: "wrong issuer",
+			authorization: "Bearer " + signTestToken(t, key, "k1", func() accessClaims {
+				c := testClaims("u_1", time.Hour)
+				c.Issuer = "evil"
+				return c
+			}()),
+			wantStatus:    http.StatusUnauthorized,
+			wantChallenge: `Bearer realm="pulsecart", error="invalid_token", error_description="invalid issuer"`,
+		},
+		{
+			name:          "forged signature",
+			authorization: "Bearer " + signTestToken(t, otherKey, "k1", testClaims("u_1", time.Hour)),
+			wantStatus:    http.StatusUnauthorized,
+			wantChallenge: `Bearer realm="pulsecart", error="invalid_token", error_description="invalid signature"`,
+		},
+		{
+			name:          "unknown kid",
+			authorization: "Bearer " + signTestToken(t, key, "k2", testClaims("u_1", time.Hour)),
+			wantStatus:    http.StatusUnauthorized,
+			wantChallenge: `Bearer realm="pulsecart", error="invalid_token", error_description="invalid signature"`,
+		},
+		{
+			name:          "garbage",
+			authorization: "Bearer not-a-jwt",
+			wantStatus:    http.StatusUnauthorized,
+			wantChallenge: `Bearer realm="pulsecart", error="invalid_token", error_description="malformed token"`,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var upstreamUser string
+			var called bool
+			client := &http.Client{
+				Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					called = true
+					upstreamUser = req.Header.Get(httpx.AuthenticatedUserHeader)
+					return &http.Response{StatusCode: http.StatusCreated, Body: io.NopCloser(strings.NewReader(`{}`))}, nil
+				}),
+			}
+			r := newRouterWithConfig(gatewayConfig{
+				OrdersURL: "http://orders:8081",
+				Client:    client,
+				JWT:       jwtConfig{JWKSFile: jwksFile, Issuer: "pulsecart-auth", Audience: "pulsecart"},
+			}, nil)
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/orders", strings.NewReader(`{}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(httpx.AuthenticatedUserHeader, "spoofed")
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status code mismatch: got=%d want=%d body=%q", rec.Code, tc.wantStatus, rec.Body.String())
+			}
+			if got := rec.Header().Get("WWW-Authenticate"); got != tc.wantChallenge {
+				t.Fatalf("challenge mismatch: got=%q want=%q", got, tc.wantChallenge)
+			}
+			if tc.wantUser == "" {
+				if called {
+					t.Fatal("unauthenticated request must not reach orders")
+				}
+				return
+			}
+			if upstreamUser != tc.wantUser {
+				t.Fatalf("authenticated user mismatch: got=%q want=%q", upstreamUser, tc.wantUser)
+			}
+		})
+	}
+}
+
+func TestGateway_StripsSpoofedUserWhenAuthDisabled(t *testing.T) {
+	t.Parallel()
+
+	var upstreamUser string
+	client := &http.Client{
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			upstreamUser = req.Header.Get(httpx.AuthenticatedUserHeader)
+			return &http.Response{StatusCode: http.StatusCreated, Body: io.NopCloser(strings.NewReader(`{}`))}, nil
+		}),
+	}
+	r := newRouterWithConfig(gatewayConfig{OrdersURL: "http://orders:8081", Client: client}, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/orders", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(httpx.AuthenticatedUserHeader, "spoofed")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status code mismatch: got=%d want=%d", rec.Code, http.StatusCreated)
+	}
+	if upstreamUser != "" {
+		t.Fatalf("spoofed user forwarded: got=%q", upstreamUser)
+	}
+}
+
+func TestGateway_JWKSUnavailable(t *testing.T) {
+	t.Parallel()
+
+	key := newTestRSAKey(t)
+	r := newRouterWithConfig(gatewayConfig{
+		OrdersURL: "http://orders:8081",
+		JWT:       jwtConfig{JWKSFile: filepath.Join(t.TempDir(), "missing.json"), Issuer: "pulsecart-auth", Audience: "pulsecart"},
+	}, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/orders", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+signTestToken(t, key, "k1", testClaims("u_1", time.Hour)))
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status code mismatch: got=%d want=%d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestJWKSCache(t *testing.T) {
+	t.Parallel()
+
+	k1 := newTestRSAKey(t)
+	k2 := newTestRSAKey(t)
+	current := jwksJSON(t, map[string]*rsa.PrivateKey{"k1": k1})
+	var fetches int
+	var fetchErr error
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	cache := &jwksCache{
+		fetch: func(context.Context) ([]byte, error) {
+			fetches++
+			return current, fetchErr
+		},
+		ttl:        5 * time.Minute,
+		minRefresh: 30 * time.Second,
+		now:        func() time.Time { return now },
+	}
+	ctx := context.Background()
+
+	if _, err := cache.key(ctx, "k1"); err != nil {
+		t.Fatalf("key(k1) error = %v", err)
+	}
+	if _, err := cache.key(ctx, "k1"); err != nil || fetches != 1 {
+		t.Fatalf("cached lookup mismatch: err=%v fetches=%d want=1", err, fetches)
+	}
+
+	// Rotation: an unknown kid triggers one early refresh, then is throttled.
+	current = jwksJSON(t, map[string]*rsa.PrivateKey{"k1": k1, "k2": k2})
+	now = now.Add(time.Minute)
+	if _, err := cache.key(ctx, "k3"); !errors.Is(err, errUnknownKeyID) || fetches != 2 {
+		t.Fatalf("unknown kid mismatch: err=%v fetches=%d want=2", err, fetches)
+	}
+	if _, err := cache.key(ctx, "k3"); !errors.Is(err, errUnknownKeyID) || fetches != 2 {
+		t.Fatalf("throttled refresh mismatch: err=%v fetches=%d want=2", err, fetches)
+	}
+	if _, err := cache.key(ctx, "k2"); err != nil {
+		t.Fatalf("key(k2) after refresh error = %v", err)
+	}
+
+	// A failed refresh after the ttl keeps serving the last good keys.
+	now = now.Add(10 * time.Minute)
+	fetchErr = errors.New("auth down")
+	if _, err := cache.key(ctx, "k1"); err != nil || fetches != 3 {
+		t.Fatalf("stale fallback mismatch: err=%v fetches=%d want=3", err, fetches)
+	}
+}
+
+func TestJWKSCache_SingleFlightRefresh(t *testing.T) {
+	t.Parallel()
+
+	k1 := newTestRSAKey(t)
+	k2 := newTestRSAKey(t)
+	current := jwksJSON(t, map[string]*rsa.PrivateKey{"k1": k1})
+	var fetches atomic.Int32
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	cache := &jwksCache{
+		fetch: func(context.Context) ([]byte, error) {
+			if fetches.Add(1) == 1 {
+				return current, nil
+			}
+			started <- struct{}{}
+			<-release
+			return jwksJSON(t, map[string]*rsa.PrivateKey{"k1": k1, "k2": k2}), nil
+		},
+		ttl:        5 * time.Minute,
+		minRefresh: 0,
+	}
+	ctx := context.Background()
+	if _, err := cache.key(ctx, "k1"); err != nil {
+		t.Fatalf("key(k1) error = %v", err)
+	}
+
+	// Several lookups of a rotated-in kid share one slow refresh.
+	var wg sync.WaitGroup
+	errs := make(chan error, 3)
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cache.key(ctx, "k2")
+			errs <- err
+		}()
+	}
+	<-started
+
+	// A cached key is served while the refresh is in flight.
+	if _, err := cache.key(ctx, "k1"); err != nil {
+		t.Fatalf("key(k1) during refresh error = %v", err)
+	}
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("key(k2) error = %v", err)
+		}
+	}
+	if got := fetches.Load(); got != 2 {
+		t.Fatalf("fetches mismatch: got=%d want=2", got)
+	}
+}
+
+func TestJWTSettings(t *testing.T) {
+	t.Setenv("JWKS_URL", " http://auth:8084/.well-known/jwks.json ")
+	t.Setenv("JWKS_FILE", "")
+	t.Setenv("JWT_ISSUER", "")
+	t.Setenv("JWT_AUDIENCE", "")
+	t.Setenv("JWKS_CACHE_TTL", "nope")
+
+	got := jwtSettings()
+	want := jwtConfig{
+		JWKSURL:  "http://auth:8084/.well-known/jwks.json",
+		Issuer:   "pulsecart-auth",
+		Audience: "pulsecart",
+		CacheTTL: 5 * time.Minute,
+	}
+	if got != want {
+		t.Fatalf("jwtSettings() mismatch: got=%+v want=%+v", got, want)
+	}
+	if !got.enabled() {
+		t.Fatal("expected jwt verification to be enabled")
+	}
+}
+
+func testClaims(sub string, ttl time.Duration) accessClaims {
+	now := time.Now()
+	return accessClaims{Roles: []string{"customer"}, RegisteredClaims: jwt.RegisteredClaims{
+		Issuer:    "pulsecart-auth",
+		Subject:   sub,
+		Audience:  jwt.ClaimStrings{"pulsecart"},
+		IssuedAt:  jwt.NewNumericDate(now.Add(-time.Minute)),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+	}}
+}
+
+func signTestToken(t *testing.T, key *rsa.PrivateKey, kid string, claims accessClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return signed
+}
+
+func newTestRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return key
+}
+
+func jwksJSON(t *testing.T, keys map[string]*rsa.PrivateKey) []byte {
+	t.Helper()
+
+	type jwk struct {
+		Kty string `json:"kty"`
+		Use string `json:"use"`
+		Kid string `json:"kid"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	}
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+	for kid, key := range keys {
+		set.Keys = append(set.Keys, jwk{
+			Kty: "RSA",
+			Use: "sig",
+			Kid: kid,
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+	raw, err := json.Marshal(set)
+	if err != nil {
+		t.Fatalf("encode jwks: %v", err)
+	}
+	return raw
+}
+
+func writeJWKSFile(t *testing.T, keys map[string]*rsa.PrivateKey) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, jwksJSON(t, keys), 0o600); err != nil {
+		t.Fatalf("write jwks: %v", err)
+	}
+	return path
+}
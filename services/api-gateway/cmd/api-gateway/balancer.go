@@ -0,0 +1,407 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+)
+
+const (
+	balancerRoundRobin   = "round_robin"
+	balancerLeastRequest = "least_request"
+)
+
+var errNoEndpoints = errors.New("no upstream endpoints")
+
+// poolConfig is the resolved endpoint configuration of one upstream.
+type poolConfig struct {
+	// endpoints is the static list; with dns set it is ignored and the
+	// addresses of dns.Hostname() are used instead, re-resolved every
+	// dnsRefresh.
+	endpoints  []*url.URL
+	dns        *url.URL
+	dnsRefresh time.Duration
+	balancer   string
+	health     healthCheckConfig
+	outlier    outlierConfig
+}
+
+// healthCheckConfig tunes active checks: a GET of path on every endpoint each
+// interval, where any 2xx passes.
+type healthCheckConfig struct {
+	enabled            bool
+	path               string
+	interval           time.Duration
+	timeout            time.Duration
+	unhealthyThreshold int
+	healthyThreshold   int
+}
+
+// outlierConfig tunes passive detection: an endpoint with consecutiveFailures
+// transport errors or 5xx responses in a row is ejected for ejectionTime, as
+// long as no more than maxEjectionPercent of the pool is ejected already.
+type outlierConfig struct {
+	consecutiveFailures int
+	ejectionTime        time.Duration
+	maxEjectionPercent  int
+}
+
+// endpoint is one instance of an upstream. Its state is guarded by the
+// pool's mutex, except inFlight.
+type endpoint struct {
+	url      *url.URL
+	metricID string
+	inFlight atomic.Int64
+
+	healthy      bool
+	checkFails   int
+	checkPasses  int
+	failures     int
+	ejected      bool
+	ejectedUntil time.Time
+}
+
+// endpointPool spreads one upstream's requests over its endpoints, skipping
+// those failing health checks or ejected as outliers. When none is
+// available it falls back to all of them rather than failing every request.
+type endpointPool struct {
+	name    string
+	metrics *metricsx.Registry
+	log     zerolog.Logger
+	// now and lookupHost are overridden in tests.
+	now        func() time.Time
+	lookupHost func(ctx context.Context, host string) ([]string, error)
+
+	mu        sync.Mutex
+	cfg       poolConfig
+	endpoints []*endpoint
+	next      uint64
+}
+
+func newEndpointPool(name string, metrics *metricsx.Registry, log zerolog.Logger) *endpointPool {
+	return &endpointPool{
+		name:       name,
+		metrics:    metrics,
+		log:        log,
+		now:        time.Now,
+		lookupHost: net.DefaultResolver.LookupHost,
+	}
+}
+
+// configure applies cfg. Endpoints that stay in the pool keep their health
+// and ejection state. With DNS the current addresses are kept until the next
+// resolution.
+func (p *endpointPool) configure(cfg poolConfig) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cfg = cfg
+	if cfg.dns == nil {
+		p.setEndpoints(cfg.endpoints)
+	}
+}
+
+// setEndpoints is called with mu held.
+func (p *endpointPool) setEndpoints(urls []*url.URL) {
+	current := map[string]*endpoint{}
+	for _, ep := range p.endpoints {
+		current[ep.url.Host] = ep
+	}
+	endpoints := make([]*endpoint, 0, len(urls))
+	for _, u := range urls {
+		ep, ok := current[u.Host]
+		if ok {
+			delete(current, u.Host)
+		} else {
+			ep = &endpoint{url: u, metricID: metricID(u.Host), healthy: true}
+		}
+		ep.url = u
+		endpoints = append(endpoints, ep)
+		p.exportEndpoint(ep)
+	}
+	for _, ep := range current {
+		p.setGauge(ep, "healthy", 0)
+	}
+	p.endpoints = endpoints
+}
+
+// pick chooses an endpoint for one attempt, avoiding tried when another is
+// available.
+func (p *endpointPool) pick(tried *endpoint) (*endpoint, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.endpoints) == 0 {
+		return nil, errNoEndpoints
+	}
+	now := p.now()
+	candidates := make([]*endpoint, 0, len(p.endpoints))
+	for _, ep := range p.endpoints {
+		if ep != tried && p.available(ep, now) {
+			candidates = append(candidates, ep)
+		}
+	}
+	if len(candidates) == 0 && tried != nil && p.available(tried, now) {
+		candidates = append(candidates, tried)
+	}
+	if len(candidates) == 0 {
+		p.metrics.Inc(p.name + "_endpoints_unavailable_total")
+		candidates = p.endpoints
+	}
+
+	start := int(p.next % uint64(len(candidates)))
+	p.next++
+	chosen := candidates[start]
+	if p.cfg.balancer == balancerLeastRequest {
+		for i := 1; i < len(candidates); i++ {
+			ep := candidates[(start+i)%len(candidates)]
+			if ep.inFlight.Load() < chosen.inFlight.Load() {
+				chosen = ep
+			}
+		}
+	}
+	return chosen, nil
+}
+
+// available is called with mu held. It ends an expired ejection.
+func (p *endpointPool) available(ep *endpoint, now time.Time) bool {
+	if ep.ejected && !now.Before(ep.ejectedUntil) {
+		ep.ejected, ep.failures = false, 0
+		p.exportEndpoint(ep)
+	}
+	return ep.healthy && !ep.ejected
+}
+
+// begin marks a request to ep as in flight; the returned func records its
+// outcome for outlier detection.
+func (p *endpointPool) begin(ep *endpoint) func(success bool) {
+	p.metrics.Inc(p.name + "_endpoint_" + ep.metricID + "_requests_total")
+	p.setGauge(ep, "in_flight", ep.inFlight.Add(1))
+
+	var once sync.Once
+	return func(success bool) {
+		once.Do(func() {
+			p.setGauge(ep, "in_flight", ep.inFlight.Add(-1))
+			p.record(ep, success)
+		})
+	}
+}
+
+func (p *endpointPool) record(ep *endpoint, success bool) {
+	if !success {
+		p.metrics.Inc(p.name + "_endpoint_" + ep.metricID + "_failures_total")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if success {
+		ep.failures = 0
+		return
+	}
+	ep.failures++
+	cfg := p.cfg.outlier
+	if ep.ejected || ep.failures < cfg.consecutiveFailures {
+		return
+	}
+	now := p.now()
+	ejected := 0
+	for _, other := range p.endpoints {
+		if other.ejected && now.Before(other.ejectedUntil) {
+			ejected++
+		}
+	}
+	if ejected*100 >= cfg.maxEjectionPercent*len(p.endpoints) {
+		return
+	}
+	ep.ejected, ep.ejectedUntil = true, now.Add(cfg.ejectionTime)
+	p.metrics.Inc(p.name + "_endpoint_" + ep.metricID + "_ejections_total")
+	p.exportEndpoint(ep)
+	p.log.Warn().Str("upstream", p.name).Str("endpoint", ep.url.Host).Dur("ejection_time", cfg.ejectionTime).Msg("endpoint ejected after consecutive failures")
+}
+
+// run re-resolves DNS and runs health checks until ctx is done. The schedule
+// follows the current config, so reloads take effect without a restart.
+func (p *endpointPool) run(ctx context.Context, client *http.Client) {
+	var nextResolve, nextCheck time.Time
+	var resolved string
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		p.mu.Lock()
+		cfg := p.cfg
+		p.mu.Unlock()
+		now := p.now()
+		wait := time.Second
+		if cfg.dns != nil {
+			if cfg.dns.String() != resolved {
+				resolved, nextResolve = cfg.dns.String(), time.Time{}
+			}
+			if !now.Before(nextResolve) {
+				p.resolve(ctx)
+				nextResolve = now.Add(cfg.dnsRefresh)
+			}
+			wait = min(wait, nextResolve.Sub(now))
+		}
+		if cfg.health.enabled {
+			if !now.Before(nextCheck) {
+				p.checkHealth(ctx, client)
+				nextCheck = now.Add(cfg.health.interval)
+			}
+			wait = min(wait, nextCheck.Sub(now))
+		}
+		timer.Reset(max(wait, 10*time.Millisecond))
+	}
+}
+
+// resolve replaces the endpoints with the current addresses of the DNS
+// name. On failure, or an empty answer, the previous addresses are kept.
+func (p *endpointPool) resolve(ctx context.Context) {
+	p.mu.Lock()
+	target := p.cfg.dns
+	p.mu.Unlock()
+	if target == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	addrs, err := p.lookupHost(ctx, target.Hostname())
+	if err == nil && len(addrs) == 0 {
+		err = errors.New("no addresses")
+	}
+	if err != nil {
+		p.metrics.Inc(p.name + "_dns_errors_total")
+		p.log.Error().Err(err).Str("upstream", p.name).Str("host", target.Hostname()).Msg("upstream dns resolution failed; keeping previous endpoints")
+		return
+	}
+
+	port := target.Port()
+	if port == "" {
+		port = "80"
+		if target.Scheme == "https" {
+			port = "443"
+		}
+	}
+	urls := make([]*url.URL, 0, len(addrs))
+	for _, addr := range addrs {
+		urls = append(urls, &url.URL{Scheme: target.Scheme, Host: net.JoinHostPort(addr, port), Path: target.Path})
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cfg.dns != nil && p.cfg.dns.String() == target.String() {
+		p.setEndpoints(urls)
+	}
+}
+
+// checkHealth probes every endpoint once, concurrently, and applies the
+// thresholds.
+func (p *endpointPool) checkHealth(ctx context.Context, client *http.Client) {
+	p.mu.Lock()
+	cfg := p.cfg.health
+	endpoints := append([]*endpoint(nil), p.endpoints...)
+	p.mu.Unlock()
+
+	results := make([]bool, len(endpoints))
+	var wg sync.WaitGroup
+	for i, ep := range endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = probe(ctx, client, ep.url.JoinPath(cfg.path).String(), cfg.timeout)
+		}()
+	}
+	wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, ep := range endpoints {
+		if results[i] {
+			ep.checkFails, ep.checkPasses = 0, ep.checkPasses+1
+			if !ep.healthy && ep.checkPasses >= cfg.healthyThreshold {
+				ep.healthy = true
+				p.log.Info().Str("upstream", p.name).Str("endpoint", ep.url.Host).Msg("endpoint passed health checks")
+			}
+		} else {
+			p.metrics.Inc(p.name + "_endpoint_" + ep.metricID + "_health_check_failures_total")
+			ep.checkPasses, ep.checkFails = 0, ep.checkFails+1
+			if ep.healthy && ep.checkFails >= cfg.unhealthyThreshold {
+				ep.healthy = false
+				p.log.Warn().Str("upstream", p.name).Str("endpoint", ep.url.Host).Msg("endpoint failed health checks")
+			}
+		}
+		p.exportEndpoint(ep)
+	}
+}
+
+func probe(ctx context.Context, client *http.Client, target string, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	return resp.StatusCode >= 200 && resp.StatusCode < 300
+}
+
+// exportEndpoint is called with mu held.
+func (p *endpointPool) exportEndpoint(ep *endpoint) {
+	healthy, ejected := int64(0), int64(0)
+	if ep.healthy {
+		healthy = 1
+	}
+	if ep.ejected {
+		ejected = 1
+	}
+	p.setGauge(ep, "healthy", healthy)
+	p.setGauge(ep, "ejected", ejected)
+}
+
+func (p *endpointPool) setGauge(ep *endpoint, name string, value int64) {
+	p.metrics.SetGauge(p.name+"_endpoint_"+ep.metricID+"_"+name, value)
+}
+
+// metricID turns host:port into a metric name segment, e.g. 10_0_0_1_8081.
+func metricID(host string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.Trim(host, "[]"))
+}
+
+// endpointBody reports the outcome once the response body is closed, so
+// least_request counts streaming responses as in flight until they finish.
+type endpointBody struct {
+	io.ReadCloser
+	success bool
+	done    func(success bool)
+}
+
+func (b *endpointBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.done(b.success)
+	return err
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+)
+
+func newTestPool(t *testing.T, spec upstreamSpec) (*endpointPool, *metricsx.Registry) {
+	t.Helper()
+	cfg, _, err := spec.poolConfig()
+	if err != nil {
+		t.Fatalf("poolConfig() error = %v", err)
+	}
+	metrics := metricsx.NewRegistry("test")
+	p := newEndpointPool("orders", metrics, zerolog.Nop())
+	p.configure(cfg)
+	return p, metrics
+}
+
+func pickHost(t *testing.T, p *endpointPool, tried *endpoint) string {
+	t.Helper()
+	ep, err := p.pick(tried)
+	if err != nil {
+		t.Fatalf("pick() error = %v", err)
+	}
+	return ep.url.Host
+}
+
+func TestEndpointPool_Balancing(t *testing.T) {
+	t.Parallel()
+
+	endpoints := []string{"http://a:8081", "http://b:8081", "http://c:8081"}
+
+	t.Run("round robin", func(t *testing.T) {
+		p, _ := newTestPool(t, upstreamSpec{Endpoints: endpoints})
+		counts := map[string]int{}
+		for i := 0; i < 30; i++ {
+			counts[pickHost(t, p, nil)]++
+		}
+		for _, host := range []string{"a:8081", "b:8081", "c:8081"} {
+			if counts[host] != 10 {
+				t.Fatalf("distribution mismatch: %v", counts)
+			}
+		}
+	})
+
+	t.Run("least request", func(t *testing.T) {
+		p, _ := newTestPool(t, upstreamSpec{Endpoints: endpoints, Balancer: balancerLeastRequest})
+		a, _ := p.pick(nil)
+		b, _ := p.pick(nil)
+		doneA := p.begin(a)
+		doneB := p.begin(b)
+		for i := 0; i < 3; i++ {
+			if got := pickHost(t, p, nil); got != "c:8081" {
+				t.Fatalf("least request mismatch: got=%q want=%q", got, "c:8081")
+			}
+		}
+		doneA(true)
+		doneB(true)
+	})
+
+	t.Run("retry avoids the tried endpoint", func(t *testing.T) {
+		p, _ := newTestPool(t, upstreamSpec{Endpoints: endpoints[:2]})
+		first, _ := p.pick(nil)
+		for i := 0; i < 4; i++ {
+			if got := pickHost(t, p, first); got == first.url.Host {
+				t.Fatalf("retry picked the tried endpoint %q", got)
+			}
+		}
+	})
+}
+
+func TestEndpointPool_OutlierEjection(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	p, metrics := newTestPool(t, upstreamSpec{
+		Endpoints: []string{"http://a:8081", "http://b:8081"},
+		Outlier:   outlierSpec{ConsecutiveFailures: 2, EjectionTime: "30s"},
+	})
+	p.now = func() time.Time { return now }
+	a, b := p.endpoints[0], p.endpoints[1]
+
+	// A success resets the run of failures.
+	p.begin(a)(false)
+	p.begin(a)(true)
+	p.begin(a)(false)
+	if a.ejected {
+		t.Fatal("endpoint ejected without consecutive failures")
+	}
+	p.begin(a)(false)
+	if !a.ejected {
+		t.Fatal("endpoint should be ejected after 2 consecutive failures")
+	}
+	for i := 0; i < 4; i++ {
+		if got := pickHost(t, p, nil); got != "b:8081" {
+			t.Fatalf("ejected endpoint picked: %q", got)
+		}
+	}
+
+	// At most half of the pool may be ejected.
+	p.begin(b)(false)
+	p.begin(b)(false)
+	if b.ejected {
+		t.Fatal("max ejection percent should keep the last endpoint in")
+	}
+
+	now = now.Add(30 * time.Second)
+	counts := map[string]int{}
+	for i := 0; i < 4; i++ {
+		counts[pickHost(t, p, nil)]++
+	}
+	if counts["a:8081"] != 2 {
+		t.Fatalf("expired ejection mismatch: %v", counts)
+	}
+	body := scrapeMetrics(t, metrics)
+	for _, want := range []string{"test_orders_endpoint_a_8081_ejections_total 1", "test_orders_endpoint_a_8081_ejected 0", "test_orders_endpoint_b_8081_requests_total 2"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("metric %q missing: %q", want, body)
+		}
+	}
+}
+
+func TestEndpointPool_HealthChecks(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	status := map[string]int{"a:8081": http.StatusOK, "b:8081": http.StatusServiceUnavailable}
+	client := &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path != "/readyz" {
+			t.Errorf("health check path mismatch: got=%q want=%q", req.URL.Path, "/readyz")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return &http.Response{StatusCode: status[req.URL.Host], Body: io.NopCloser(strings.NewReader("ok"))}, nil
+	})}
+	p, metrics := newTestPool(t, upstreamSpec{Endpoints: []string{"http://a:8081", "http://b:8081"}})
+
+	// One failure is below the unhealthy threshold of 2.
+	p.checkHealth(context.Background(), client)
+	if !p.endpoints[1].healthy {
+		t.Fatal("endpoint marked unhealthy after one failed check")
+	}
+	p.checkHealth(context.Background(), client)
+	for i := 0; i < 4; i++ {
+		if got := pickHost(t, p, nil); got != "a:8081" {
+			t.Fatalf("unhealthy endpoint picked: %q", got)
+		}
+	}
+	if body := scrapeMetrics(t, metrics); !strings.Contains(body, "test_orders_endpoint_b_8081_healthy 0") {
+		t.Fatalf("healthy gauge mismatch: %q", body)
+	}
+
+	// With no healthy endpoint left, traffic goes to all of them.
+	mu.Lock()
+	status["a:8081"] = http.StatusServiceUnavailable
+	mu.Unlock()
+	p.checkHealth(context.Background(), client)
+	p.checkHealth(context.Background(), client)
+	if _, err := p.pick(nil); err != nil {
+		t.Fatalf("pick() with no healthy endpoints error = %v", err)
+	}
+
+	mu.Lock()
+	status["b:8081"] = http.StatusOK
+	mu.Unlock()
+	p.checkHealth(context.Background(), client)
+	if !p.endpoints[1].healthy || p.endpoints[0].healthy {
+		t.Fatalf("recovery mismatch: a=%v b=%v", p.endpoints[0].healthy, p.endpoints[1].healthy)
+	}
+}
+
+func TestEndpointPool_DNS(t *testing.T) {
+	t.Parallel()
+
+	p, metrics := newTestPool(t, upstreamSpec{DNS: "http://orders-headless:8081"})
+	if _, err := p.pick(nil); !errors.Is(err, errNoEndpoints) {
+		t.Fatalf("unresolved pick mismatch: err=%v", err)
+	}
+
+	addrs := []string{"10.0.0.1", "10.0.0.2"}
+	var lookupErr error
+	p.lookupHost = func(_ context.Context, host string) ([]string, error) {
+		if host != "orders-headless" {
+			t.Errorf("lookup host mismatch: got=%q want=%q", host, "orders-headless")
+		}
+		return addrs, lookupErr
+	}
+	p.resolve(context.Background())
+	if got, want := pickHost(t, p, nil), "10.0.0.1:8081"; got != want {
+		t.Fatalf("resolved endpoint mismatch: got=%q want=%q", got, want)
+	}
+	kept := p.endpoints[1]
+
+	// Endpoints present in the new answer keep their state.
+	addrs = []string{"10.0.0.2", "10.0.0.3"}
+	p.resolve(context.Background())
+	if len(p.endpoints) != 2 || p.endpoints[0] != kept || p.endpoints[1].url.Host != "10.0.0.3:8081" {
+		t.Fatalf("re-resolved endpoints mismatch: %v", p.endpoints)
+	}
+
+	// A failed lookup keeps the previous addresses.
+	lookupErr = errors.New("servfail")
+	p.resolve(context.Background())
+	if len(p.endpoints) != 2 {
+		t.Fatalf("endpoints dropped after lookup error: %v", p.endpoints)
+	}
+	if body := scrapeMetrics(t, metrics); !strings.Contains(body, "test_orders_dns_errors_total 1") {
+		t.Fatalf("dns error counter missing: %q", body)
+	}
+}
+
+func TestGateway_LoadBalancedRetry(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var hosts []string
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		hosts = append(hosts, req.URL.Host)
+		mu.Unlock()
+		if req.URL.Host == "orders-1:8081" {
+			return nil, &url.Error{Op: "Post", URL: req.URL.String(), Err: errors.New("connection refused")}
+		}
+		return &http.Response{StatusCode: http.StatusCreated, Body: io.NopCloser(strings.NewReader(`{}`))}, nil
+	})
+	r := newRouterWithConfig(gatewayConfig{
+		OrdersURL: "http://orders-1:8081, http://orders-2:8081",
+		Client:    &http.Client{Transport: transport},
+		Upstream:  upstreamConfig{RetryBackoff: time.Millisecond},
+	}, nil)
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, ordersPath, strings.NewReader(`{"n":1}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", "idem-1")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("status code mismatch: got=%d want=%d body=%q", rec.Code, http.StatusCreated, rec.Body.String())
+		}
+	}
+	// Each request that lands on orders-1 fails over to orders-2.
+	want := []string{"orders-1:8081", "orders-2:8081", "orders-1:8081", "orders-2:8081"}
+	if strings.Join(hosts, ",") != strings.Join(want, ",") {
+		t.Fatalf("endpoint sequence mismatch: got=%v want=%v", hosts, want)
+	}
+}
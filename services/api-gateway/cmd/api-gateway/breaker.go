@@ -0,0 +1,225 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+)
+
+// circuitOpenError is returned while a circuit rejects requests.
+type circuitOpenError struct {
+	retryIn time.Duration
+}
+
+func (e *circuitOpenError) Error() string {
+	return "circuit open"
+}
+
+type circuitState int64
+
+// The values are exported as the <upstream>_circuit_breaker_state gauge.
+const (
+	circuitClosed   circuitState = 0
+	circuitHalfOpen circuitState = 1
+	circuitOpen     circuitState = 2
+)
+
+// breakerConfig tunes a circuitBreaker. Zero fields take the defaults noted.
+type breakerConfig struct {
+	// FailureRatio of requests in Window that opens the circuit (0.5), once
+	// at least MinRequests (20) have been seen.
+	FailureRatio float64
+	MinRequests  int
+	Window       time.Duration // 10s
+	// OpenTimeout is how long the circuit fails fast before letting
+	// HalfOpenRequests (1) probes through (30s).
+	OpenTimeout      time.Duration
+	HalfOpenRequests int
+}
+
+func (c breakerConfig) withDefaults() breakerConfig {
+	if c.FailureRatio <= 0 || c.FailureRatio > 1 {
+		c.FailureRatio = 0.5
+	}
+	if c.MinRequests <= 0 {
+		c.MinRequests = 20
+	}
+	if c.Window <= 0 {
+		c.Window = 10 * time.Second
+	}
+	if c.OpenTimeout <= 0 {
+		c.OpenTimeout = 30 * time.Second
+	}
+	if c.HalfOpenRequests <= 0 {
+		c.HalfOpenRequests = 1
+	}
+	return c
+}
+
+// circuitBreaker guards one upstream. Closed, it counts outcomes in fixed
+// windows and opens when the failure ratio is reached. Open, it rejects
+// everything until OpenTimeout passes, then goes half-open and admits up to
+// HalfOpenRequests probes: if they all succeed it closes, and any failure
+// opens it again.
+type circuitBreaker struct {
+	name    string
+	cfg     breakerConfig
+	metrics *metricsx.Registry
+	// now is overridden in tests.
+	now func() time.Time
+
+	mu          sync.Mutex
+	state       circuitState
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	probes      int
+	probeOK     int
+}
+
+func newCircuitBreaker(name string, cfg breakerConfig, metrics *metricsx.Registry) *circuitBreaker {
+	b := &circuitBreaker{
+		name:    name,
+		cfg:     cfg.withDefaults(),
+		metrics: metrics,
+		now:     time.Now,
+	}
+	b.exportState()
+	return b
+}
+
+// allow reserves a slot for one request. The caller must report the outcome
+// through done. When the circuit is open it returns a *circuitOpenError with
+// the time until the next probe is admitted.
+func (b *circuitBreaker) allow() (done func(success bool), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	switch b.state {
+	case circuitOpen:
+		if wait := b.openedAt.Add(b.cfg.OpenTimeout).Sub(now); wait > 0 {
+			return nil, &circuitOpenError{retryIn: wait}
+		}
+		b.setState(circuitHalfOpen, now)
+		fallthrough
+	case circuitHalfOpen:
+		if b.probes >= b.cfg.HalfOpenRequests {
+			return nil, &circuitOpenError{retryIn: time.Second}
+		}
+		b.probes++
+	default:
+		if now.Sub(b.windowStart) >= b.cfg.Window {
+			b.windowStart, b.requests, b.failures = now, 0, 0
+		}
+	}
+
+	state := b.state
+	return func(success bool) { b.record(state, success) }, nil
+}
+
+func (b *circuitBreaker) record(admittedIn circuitState, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	switch {
+	case admittedIn == circuitHalfOpen && b.state == circuitHalfOpen:
+		if !success {
+			b.setState(circuitOpen, now)
+			return
+		}
+		b.probeOK++
+		if b.probeOK >= b.cfg.HalfOpenRequests {
+			b.setState(circuitClosed, now)
+		}
+	case admittedIn == circuitClosed && b.state == circuitClosed:
+		b.requests++
+		if !success {
+			b.failures++
+		}
+		if b.requests >= b.cfg.MinRequests && float64(b.failures)/float64(b.requests) >= b.cfg.FailureRatio {
+			b.setState(circuitOpen, now)
+		}
+	}
+	// Outcomes from an earlier state are stale and ignored.
+}
+
+// setState is called with mu held.
+func (b *circuitBreaker) setState(state circuitState, now time.Time) {
+	b.state = state
+	b.probes, b.probeOK = 0, 0
+	switch state {
+	case circuitOpen:
+		b.openedAt = now
+		b.inc(b.name + "_circuit_breaker_opened_total")
+	case circuitClosed:
+		b.windowStart, b.requests, b.failures = now, 0, 0
+	}
+	b.exportState()
+}
+
+func (b *circuitBreaker) exportState() {
+	if b.metrics != nil {
+		b.metrics.SetGauge(b.name+"_circuit_breaker_state", int64(b.state))
+	}
+}
+
+func (b *circuitBreaker) inc(name string) {
+	if b.metrics != nil {
+		b.metrics.Inc(name)
+	}
+}
+
+// retryBudget caps retries at ratio times the requests seen in the current
+// window, with a floor of minRetries, so retries cannot multiply the load on
+// an upstream that is already failing.
+type retryBudget struct {
+	ratio      float64
+	minRetries int
+	window     time.Duration
+	// now is overridden in tests.
+	now func() time.Time
+
+	mu          sync.Mutex
+	windowStart time.Time
+	requests    int
+	retries     int
+}
+
+func newRetryBudget(ratio float64, minRetries int, window time.Duration) *retryBudget {
+	if ratio < 0 {
+		ratio = 0
+	}
+	if window <= 0 {
+		window = 10 * time.Second
+	}
+	return &retryBudget{ratio: ratio, minRetries: max(0, minRetries), window: window, now: time.Now}
+}
+
+func (rb *retryBudget) roll(now time.Time) {
+	if now.Sub(rb.windowStart) >= rb.window {
+		rb.windowStart, rb.requests, rb.retries = now, 0, 0
+	}
+}
+
+func (rb *retryBudget) recordRequest() {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	rb.roll(rb.now())
+	rb.requests++
+}
+
+// tryRetry spends one retry if the budget allows it.
+func (rb *retryBudget) tryRetry() bool {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	rb.roll(rb.now())
+	if rb.retries >= max(rb.minRetries, int(rb.ratio*float64(rb.requests))) {
+		return false
+	}
+	rb.retries++
+	return true
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+)
+
+func TestCircuitBreaker_Transitions(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	metrics := metricsx.NewRegistry("test")
+	b := newCircuitBreaker("orders", breakerConfig{FailureRatio: 0.5, MinRequests: 4, Window: time.Minute, OpenTimeout: 30 * time.Second}, metrics)
+	b.now = func() time.Time { return now }
+
+	call := func(success bool) error {
+		done, err := b.allow()
+		if err != nil {
+			return err
+		}
+		done(success)
+		return nil
+	}
+
+	// Below MinRequests the circuit stays closed whatever the ratio.
+	for _, ok := range []bool{false, false, true} {
+		if err := call(ok); err != nil {
+			t.Fatalf("closed call error = %v", err)
+		}
+	}
+	if b.state != circuitClosed {
+		t.Fatalf("state mismatch: got=%d want=%d", b.state, circuitClosed)
+	}
+	// The fourth outcome reaches 3/4 failures and opens it.
+	if err := call(false); err != nil {
+		t.Fatalf("closed call error = %v", err)
+	}
+	var openErr *circuitOpenError
+	if err := call(true); !errors.As(err, &openErr) || openErr.retryIn != 30*time.Second {
+		t.Fatalf("open call mismatch: err=%v", err)
+	}
+	if body := scrapeMetrics(t, metrics); !strings.Contains(body, "test_orders_circuit_breaker_state 2") {
+		t.Fatalf("state gauge missing: %q", body)
+	}
+
+	// After OpenTimeout one probe is admitted; a failed probe reopens.
+	now = now.Add(30 * time.Second)
+	done, err := b.allow()
+	if err != nil {
+		t.Fatalf("half-open probe error = %v", err)
+	}
+	if _, err := b.allow(); !errors.As(err, &openErr) {
+		t.Fatalf("second probe should be rejected: err=%v", err)
+	}
+	done(false)
+	if b.state != circuitOpen {
+		t.Fatalf("state mismatch: got=%d want=%d", b.state, circuitOpen)
+	}
+
+	// A successful probe closes it with fresh counts.
+	now = now.Add(30 * time.Second)
+	if err := call(true); err != nil {
+		t.Fatalf("half-open probe error = %v", err)
+	}
+	if b.state != circuitClosed || b.requests != 0 {
+		t.Fatalf("closed reset mismatch: state=%d requests=%d", b.state, b.requests)
+	}
+	if body := scrapeMetrics(t, metrics); !strings.Contains(body, "test_orders_circuit_breaker_opened_total 2") {
+		t.Fatalf("opened counter mismatch: %q", body)
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	rb := newRetryBudget(0.5, 1, time.Minute)
+	rb.now = func() time.Time { return now }
+
+	rb.recordRequest()
+	if !rb.tryRetry() {
+		t.Fatal("first retry should use the floor")
+	}
+	if rb.tryRetry() {
+		t.Fatal("second retry should exceed the budget")
+	}
+	for i := 0; i < 3; i++ {
+		rb.recordRequest()
+	}
+	if !rb.tryRetry() {
+		t.Fatal("retry within 50% of 4 requests should be allowed")
+	}
+	now = now.Add(time.Minute)
+	if !rb.tryRetry() {
+		t.Fatal("a new window should reset the budget")
+	}
+}
+
+func TestGateway_UpstreamRetries(t *testing.T) {
+	t.Parallel()
+
+	connRefused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	tests := []struct {
+		name           string
+		idempotencyKey string
+		failures       int32
+		wantStatus     int
+		wantAttempts   int32
+	}{
+		{name: "retried with idempotency key", idempotencyKey: "idem-1", failures: 2, wantStatus: http.StatusCreated, wantAttempts: 3},
+		{name: "gives up after max attempts", idempotencyKey: "idem-1", failures: 5, wantStatus: http.StatusBadGateway, wantAttempts: 3},
+		{name: "not retried without idempotency key", failures: 1, wantStatus: http.StatusBadGateway, wantAttempts: 1},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var attempts atomic.Int32
+			client := &http.Client{
+				Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					body, _ := io.ReadAll(req.Body)
+					if string(body) != `{"n":1}` {
+						t.Errorf("body not replayed: %q", body)
+					}
+					if attempts.Add(1) <= tc.failures {
+						return nil, connRefused
+					}
+					return &http.Response{StatusCode: http.StatusCreated, Body: io.NopCloser(strings.NewReader(`{}`))}, nil
+				}),
+			}
+			r := newRouterWithConfig(gatewayConfig{
+				OrdersURL: "http://orders:8081",
+				Client:    client,
+				Upstream:  upstreamConfig{RetryBackoff: time.Millisecond},
+			}, nil)
+
+			req := httptest.NewRequest(http.MethodPost, ordersPath, strings.NewReader(`{"n":1}`))
+			req.Header.Set("Content-Type", "application/json")
+			if tc.idempotencyKey != "" {
+				req.Header.Set("Idempotency-Key", tc.idempotencyKey)
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status code mismatch: got=%d want=%d body=%q", rec.Code, tc.wantStatus, rec.Body.String())
+			}
+			if got := attempts.Load(); got != tc.wantAttempts {
+				t.Fatalf("attempts mismatch: got=%d want=%d", got, tc.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestGateway_CircuitOpenFailsFast(t *testing.T) {
+	t.Parallel()
+
+	var attempts atomic.Int32
+	client := &http.Client{
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			attempts.Add(1)
+			return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: io.NopCloser(strings.NewReader("down"))}, nil
+		}),
+	}
+	metrics := metricsx.NewRegistry("test")
+	r := newRouterWithConfig(gatewayConfig{
+		OrdersURL: "http://orders:8081",
+		Client:    client,
+		Upstream:  upstreamConfig{Breaker: breakerConfig{MinRequests: 2, OpenTimeout: time.Minute}},
+	}, metrics)
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, ordersPath, strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", "idem-1")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Upstream 5xx responses are passed through, not retried.
+	for i := 0; i < 2; i++ {
+		if rec := send(); rec.Code != http.StatusServiceUnavailable || rec.Body.String() != "down" {
+			t.Fatalf("request %d mismatch: status=%d body=%q", i, rec.Code, rec.Body.String())
+		}
+	}
+	rec := send()
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "60" {
+		t.Fatalf("open circuit mismatch: status=%d retry-after=%q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if got := attempts.Load(); got != 2 {
+		t.Fatalf("open circuit should not reach upstream: attempts=%d want=2", got)
+	}
+	body := scrapeMetrics(t, metrics)
+	for _, want := range []string{"test_orders_circuit_breaker_state 2", "test_orders_circuit_breaker_rejected_total 1"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("metric %q missing: %q", want, body)
+		}
+	}
+}
+
+func TestUpstreamSettings(t *testing.T) {
+	t.Setenv("UPSTREAM_BREAKER_FAILURE_RATIO", "0.25")
+	t.Setenv("UPSTREAM_BREAKER_MIN_REQUESTS", "nope")
+	t.Setenv("UPSTREAM_BREAKER_OPEN_TIMEOUT", "5s")
+	t.Setenv("UPSTREAM_RETRY_MAX_ATTEMPTS", "2")
+
+	got := upstreamSettings()
+	if got.Breaker.FailureRatio != 0.25 || got.Breaker.MinRequests != 20 || got.Breaker.OpenTimeout != 5*time.Second {
+		t.Fatalf("breaker settings mismatch: %+v", got.Breaker)
+	}
+	if got.MaxAttempts != 2 || got.RetryBudgetRatio != 0.2 || got.RetryBackoff != 50*time.Millisecond {
+		t.Fatalf("retry settings mismatch: %+v", got)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"time"
+
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+)
+
+const (
+	// shadowHeader marks mirrored requests so upstreams can tell them apart.
+	shadowHeader = "X-Shadow-Request"
+
+	variantStable = "stable"
+	variantCanary = "canary"
+	variantMirror = "mirror"
+
+	// maxMirrorsInFlight bounds the shadow requests of one route; mirrors
+	// beyond it are dropped rather than queued.
+	maxMirrorsInFlight = 64
+)
+
+// canarySpec sends part of a route's traffic to another upstream. A request
+// whose header or cookie is "always" goes to the canary and one with
+// "never" stays on the stable upstream; the rest are split by weight.
+type canarySpec struct {
+	Upstream string `json:"upstream"`
+	// Weight is the percentage of remaining requests sent to the canary.
+	Weight int    `json:"weight,omitempty"`
+	Header string `json:"header,omitempty"`
+	Cookie string `json:"cookie,omitempty"`
+}
+
+// mirrorSpec copies a percentage of a route's requests (default 100) to a
+// shadow upstream and discards its responses.
+type mirrorSpec struct {
+	Upstream string `json:"upstream"`
+	Percent  int    `json:"percent,omitempty"`
+}
+
+type routeCanary struct {
+	upstream *upstream
+	proxy    *httputil.ReverseProxy
+	weight   int
+	header   string
+	cookie   string
+}
+
+// selected reports whether r goes to the canary.
+func (c *routeCanary) selected(r *http.Request) bool {
+	var value string
+	if c.header != "" {
+		value = r.Header.Get(c.header)
+	}
+	if value == "" && c.cookie != "" {
+		if cookie, err := r.Cookie(c.cookie); err == nil {
+			value = cookie.Value
+		}
+	}
+	switch value {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	return rand.IntN(100) < c.weight
+}
+
+type routeMirror struct {
+	upstream *upstream
+	proxy    *httputil.ReverseProxy
+	percent  int
+	slots    chan struct{}
+}
+
+// send copies r to the shadow upstream in the background. body is the
+// buffered request body. The copy carries shadowHeader and an Idempotency-Key
+// derived from the original, so a shadow never shares a key with the real
+// request but still deduplicates its own retries. Unsafe requests without an
+// Idempotency-Key are not mirrored, since nothing would stop their copy from
+// repeating the write.
+func (m *routeMirror) send(route string, r *http.Request, body []byte, timeout time.Duration, metrics *metricsx.Registry) {
+	if rand.IntN(100) >= m.percent {
+		return
+	}
+	if !safeMethod(r.Method) && r.Header.Get("Idempotency-Key") == "" {
+		metrics.Inc("route_" + route + "_mirror_skipped_total")
+		return
+	}
+	select {
+	case m.slots <- struct{}{}:
+	default:
+		metrics.Inc("route_" + route + "_mirror_dropped_total")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), timeout)
+	shadow := r.Clone(ctx)
+	shadow.Header.Set(shadowHeader, "true")
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		shadow.Header.Set("Idempotency-Key", shadowIdempotencyKey(key))
+	}
+	setBufferedBody(shadow, body)
+
+	go func() {
+		defer func() { <-m.slots }()
+		defer cancel()
+		serveVariant(m.proxy, discardResponseWriter{header: http.Header{}}, shadow, route, variantMirror, metrics)
+	}()
+}
+
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// shadowIdempotencyKey stays within the 128 letters, digits, '.', '_' and
+// '-' that orders accepts.
+func shadowIdempotencyKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "mirror-" + hex.EncodeToString(sum[:20])
+}
+
+// serveVariant proxies r and records the outcome under
+// route_<route>_<variant>_*, so the variants of a route can be compared.
+func serveVariant(proxy http.Handler, w http.ResponseWriter, r *http.Request, route, variant string, metrics *metricsx.Registry) {
+	prefix := "route_" + route + "_" + variant
+	start := time.Now()
+	rec := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+	proxy.ServeHTTP(rec, r)
+	metrics.Inc(prefix + "_requests_total")
+	metrics.Inc(prefix + "_responses_" + strconv.Itoa(rec.statusCode/100) + "xx_total")
+	metrics.ObserveDuration(prefix+"_request_duration", time.Since(start))
+}
+
+// discardResponseWriter receives shadow responses.
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w discardResponseWriter) Header() http.Header         { return w.header }
+func (w discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w discardResponseWriter) WriteHeader(int)             {}
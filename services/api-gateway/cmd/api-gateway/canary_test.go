@@ -0,0 +1,186 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+)
+
+func TestGateway_CanaryRouting(t *testing.T) {
+	t.Parallel()
+
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusCreated, Body: io.NopCloser(strings.NewReader(req.URL.Host))}, nil
+	})
+	metrics := metricsx.NewRegistry("test")
+	routes := newRouteLoader(transport, upstreamConfig{}, metrics, zerolog.Nop())
+	err := routes.load(routeFile{
+		Upstreams: map[string]upstreamSpec{
+			"orders":        {URL: "http://orders:8081"},
+			"orders-canary": {URL: "http://orders-canary:8081"},
+		},
+		Routes: []routeSpec{{
+			Name: "orders-create", Methods: []string{http.MethodPost}, Path: ordersPath, Upstream: "orders",
+			Auth:   authSpec{Mode: routeAuthNone},
+			Canary: &canarySpec{Upstream: "orders-canary", Weight: 0, Header: "X-Canary", Cookie: "canary"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	r := newRouterWithConfig(gatewayConfig{Routes: routes}, metrics)
+
+	tests := []struct {
+		name   string
+		header string
+		cookie string
+		want   string
+	}{
+		{name: "weight zero stays stable", want: "orders:8081"},
+		{name: "header forces canary", header: "always", want: "orders-canary:8081"},
+		{name: "cookie forces canary", cookie: "always", want: "orders-canary:8081"},
+		{name: "header wins over cookie", header: "never", cookie: "always", want: "orders:8081"},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest(http.MethodPost, ordersPath, strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		if tc.header != "" {
+			req.Header.Set("X-Canary", tc.header)
+		}
+		if tc.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "canary", Value: tc.cookie})
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != http.StatusCreated || rec.Body.String() != tc.want {
+			t.Fatalf("%s: mismatch: status=%d upstream=%q want=%q", tc.name, rec.Code, rec.Body.String(), tc.want)
+		}
+	}
+
+	body := scrapeMetrics(t, metrics)
+	for _, want := range []string{
+		"test_route_orders_create_stable_requests_total 2",
+		"test_route_orders_create_canary_requests_total 2",
+		"test_route_orders_create_canary_responses_2xx_total 2",
+		"test_route_orders_create_canary_request_duration_seconds_count 2",
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("metric %q missing: %q", want, body)
+		}
+	}
+}
+
+func TestRouteCanary_Weight(t *testing.T) {
+	t.Parallel()
+
+	for _, weight := range []int{0, 100} {
+		c := &routeCanary{weight: weight}
+		req := httptest.NewRequest(http.MethodPost, ordersPath, nil)
+		for i := 0; i < 20; i++ {
+			if got, want := c.selected(req), weight == 100; got != want {
+				t.Fatalf("weight %d selected mismatch: got=%v want=%v", weight, got, want)
+			}
+		}
+	}
+}
+
+func TestGateway_Mirror(t *testing.T) {
+	t.Parallel()
+
+	shadows := make(chan *http.Request, 1)
+	shadowBodies := make(chan string, 1)
+	release := make(chan struct{})
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		if req.URL.Host == "orders-shadow:8081" {
+			shadows <- req
+			shadowBodies <- string(body)
+			<-release
+			return &http.Response{StatusCode: http.StatusInternalServerError, Body: io.NopCloser(strings.NewReader("shadow"))}, nil
+		}
+		if req.Header.Get(shadowHeader) != "" {
+			t.Errorf("primary request carries %s", shadowHeader)
+		}
+		return &http.Response{StatusCode: http.StatusCreated, Body: io.NopCloser(strings.NewReader("primary:" + req.Header.Get("Idempotency-Key")))}, nil
+	})
+	metrics := metricsx.NewRegistry("test")
+	routes := newRouteLoader(transport, upstreamConfig{}, metrics, zerolog.Nop())
+	err := routes.load(routeFile{
+		Upstreams: map[string]upstreamSpec{
+			"orders":        {URL: "http://orders:8081"},
+			"orders-shadow": {URL: "http://orders-shadow:8081"},
+		},
+		Routes: []routeSpec{{
+			Name: "orders-create", Methods: []string{http.MethodPost}, Path: ordersPath, Upstream: "orders",
+			RequestHeaders: []string{"Content-Type", "Idempotency-Key"},
+			Auth:           authSpec{Mode: routeAuthNone},
+			Mirror:         &mirrorSpec{Upstream: "orders-shadow"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	r := newRouterWithConfig(gatewayConfig{Routes: routes}, metrics)
+
+	req := httptest.NewRequest(http.MethodPost, ordersPath, strings.NewReader(`{"n":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", "idem-1")
+	req.Header.Set(shadowHeader, "spoofed")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	// The client gets the primary response without waiting for the shadow.
+	if rec.Code != http.StatusCreated || rec.Body.String() != "primary:idem-1" {
+		t.Fatalf("primary response mismatch: status=%d body=%q", rec.Code, rec.Body.String())
+	}
+
+	var shadow *http.Request
+	select {
+	case shadow = <-shadows:
+	case <-time.After(2 * time.Second):
+		t.Fatal("mirrored request not sent")
+	}
+	if got := shadow.Header.Get(shadowHeader); got != "true" {
+		t.Fatalf("shadow marker mismatch: got=%q want=%q", got, "true")
+	}
+	key := shadow.Header.Get("Idempotency-Key")
+	if key == "idem-1" || key != shadowIdempotencyKey("idem-1") || !regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`).MatchString(key) {
+		t.Fatalf("shadow idempotency key mismatch: %q", key)
+	}
+	if got := <-shadowBodies; got != `{"n":1}` {
+		t.Fatalf("shadow body mismatch: got=%q", got)
+	}
+	close(release)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(scrapeMetrics(t, metrics), "test_route_orders_create_mirror_responses_5xx_total 1") {
+		if time.Now().After(deadline) {
+			t.Fatalf("mirror metrics missing: %q", scrapeMetrics(t, metrics))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// A write without an Idempotency-Key is served but not mirrored.
+	req = httptest.NewRequest(http.MethodPost, ordersPath, strings.NewReader(`{"n":2}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("unkeyed primary status mismatch: got=%d want=%d", rec.Code, http.StatusCreated)
+	}
+	if !strings.Contains(scrapeMetrics(t, metrics), "test_route_orders_create_mirror_skipped_total 1") {
+		t.Fatalf("mirror skip metric missing: %q", scrapeMetrics(t, metrics))
+	}
+	select {
+	case shadow := <-shadows:
+		t.Fatalf("unkeyed write was mirrored: %+v", shadow.Header)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
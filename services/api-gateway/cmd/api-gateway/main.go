@@ -1,13 +1,14 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"crypto/rand"
+	"crypto/tls"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"io"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -18,25 +19,57 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
 	"github.com/triad-platform/triad-app/pkg/config"
 	"github.com/triad-platform/triad-app/pkg/httpx"
 	"github.com/triad-platform/triad-app/pkg/logx"
 	"github.com/triad-platform/triad-app/pkg/metricsx"
+	"github.com/triad-platform/triad-app/pkg/openapi"
 )
 
 const (
-	requestIDHeader = "X-Request-Id"
+	requestIDHeader = httpx.RequestIDHeader
 	ordersPath      = "/v1/orders"
+	// adminCatalogPath is served by orders.
+	adminCatalogPath = "/v1/admin/catalog"
+	// preferencesPath is served by notifications.
+	preferencesPath = "/v1/preferences"
 )
 
 type gatewayConfig struct {
-	OrdersURL               string
-	WorkerMetricsURL        string
+	// OrdersURL may list several comma-separated endpoints.
+	OrdersURL        string
+	WorkerMetricsURL string
+	// WorkerURL serves per-order processing records; the endpoint is off
+	// when it is empty.
+	WorkerURL string
+	// NotificationsURL serves notification preferences; they are not
+	// routed when it is empty.
+	NotificationsURL        string
 	NotificationsMetricsURL string
 	EnableDevDiagnostics    bool
 	RequestTimeout          time.Duration
 	UpstreamTimeout         time.Duration
 	Client                  *http.Client
+	JWT                     jwtConfig
+	APIKeys                 apiKeyConfig
+	RateLimit               rateLimitConfig
+	Upstream                upstreamConfig
+	// MaxBodyBytes caps every /v1 request body (1 MiB); routes may set a
+	// lower limit.
+	MaxBodyBytes int64
+	// CORS is enabled when AllowedOrigins is set.
+	CORS httpx.CORSConfig
+	// OpenAPI, when set, validates requests for the operations it
+	// describes.
+	OpenAPI *openapi.Document
+	// OrderEvents serves order status streams when its Hub is set.
+	OrderEvents orderEventsConfig
+	// Routes is the proxy route table; when nil, the default table for
+	// OrdersURL is used.
+	Routes *routeLoader
+	Logger zerolog.Logger
 }
 
 func ordersURL() string {
@@ -47,23 +80,217 @@ func workerMetricsURL() string {
 	return strings.TrimSpace(os.Getenv("WORKER_METRICS_URL"))
 }
 
+func workerURL() string {
+	return strings.TrimSpace(os.Getenv("WORKER_URL"))
+}
+
+func notificationsURL() string {
+	return strings.TrimSpace(os.Getenv("NOTIFICATIONS_URL"))
+}
+
 func notificationsMetricsURL() string {
 	return strings.TrimSpace(os.Getenv("NOTIFICATIONS_METRICS_URL"))
 }
 
+func jwtSettings() jwtConfig {
+	ttl, err := time.ParseDuration(config.Getenv("JWKS_CACHE_TTL", "5m"))
+	if err != nil || ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	return jwtConfig{
+		JWKSURL:  strings.TrimSpace(os.Getenv("JWKS_URL")),
+		JWKSFile: strings.TrimSpace(os.Getenv("JWKS_FILE")),
+		Issuer:   config.Getenv("JWT_ISSUER", "pulsecart-auth"),
+		Audience: config.Getenv("JWT_AUDIENCE", "pulsecart"),
+		CacheTTL: ttl,
+	}
+}
+
+func apiKeySettings() apiKeyConfig {
+	ttl, err := time.ParseDuration(config.Getenv("API_KEY_CACHE_TTL", "1m"))
+	if err != nil || ttl <= 0 {
+		ttl = time.Minute
+	}
+	return apiKeyConfig{
+		IntrospectionURL: strings.TrimSpace(os.Getenv("API_KEY_INTROSPECTION_URL")),
+		CacheTTL:         ttl,
+	}
+}
+
+// rateLimitSettings reads RATE_LIMIT_BACKEND (memory, redis, or off) and the
+// RATE_LIMITS rules. Unlike the other settings a bad value is an error, since
+// silently dropping a limit is worse than failing to start.
+func rateLimitSettings() (rateLimitConfig, error) {
+	cfg := rateLimitConfig{
+		TrustForwardedFor: strings.EqualFold(config.Getenv("RATE_LIMIT_TRUST_FORWARDED_FOR", "false"), "true"),
+	}
+	rules, err := parseRateLimits(config.Getenv("RATE_LIMITS", defaultRateLimits))
+	if err != nil {
+		return rateLimitConfig{}, err
+	}
+	cfg.Rules = rules
+
+	switch backend := strings.ToLower(config.Getenv("RATE_LIMIT_BACKEND", rateLimitBackendMemory)); backend {
+	case rateLimitBackendOff:
+	case rateLimitBackendMemory:
+		cfg.Limiter = newMemoryRateLimiter()
+	case rateLimitBackendRedis:
+		cfg.Limiter = newRedisRateLimiter(redis.NewClient(redisOptions()), "gateway:ratelimit:")
+	default:
+		return rateLimitConfig{}, errors.New("unsupported RATE_LIMIT_BACKEND: " + backend)
+	}
+	return cfg, nil
+}
+
+// upstreamSettings reads the breaker and retry tuning; unset or invalid values
+// fall back to the upstreamConfig defaults.
+func upstreamSettings() upstreamConfig {
+	return upstreamConfig{
+		Breaker: breakerConfig{
+			FailureRatio: floatEnv("UPSTREAM_BREAKER_FAILURE_RATIO"),
+			MinRequests:  intEnv("UPSTREAM_BREAKER_MIN_REQUESTS"),
+			Window:       durationEnv("UPSTREAM_BREAKER_WINDOW"),
+			OpenTimeout:  durationEnv("UPSTREAM_BREAKER_OPEN_TIMEOUT"),
+		},
+		MaxAttempts:      intEnv("UPSTREAM_RETRY_MAX_ATTEMPTS"),
+		RetryBackoff:     durationEnv("UPSTREAM_RETRY_BACKOFF"),
+		RetryBudgetRatio: floatEnv("UPSTREAM_RETRY_BUDGET_RATIO"),
+	}.withDefaults()
+}
+
+func maxBodyBytes() int64 {
+	if v := int64(intEnv("MAX_BODY_BYTES")); v > 0 {
+		return v
+	}
+	return defaultMaxBodyBytes
+}
+
+// corsSettings reads the CORS policy. With CORS_ALLOWED_ORIGINS unset,
+// CORS is off and browsers keep their same-origin default.
+func corsSettings() httpx.CORSConfig {
+	maxAge := durationEnv("CORS_MAX_AGE")
+	if maxAge <= 0 {
+		maxAge = 10 * time.Minute
+	}
+	return httpx.CORSConfig{
+		AllowedOrigins:   listEnv("CORS_ALLOWED_ORIGINS", ""),
+		AllowedMethods:   listEnv("CORS_ALLOWED_METHODS", "GET,POST,PUT,PATCH,DELETE"),
+		AllowedHeaders:   listEnv("CORS_ALLOWED_HEADERS", "Authorization,Content-Type,Idempotency-Key,X-Request-Id,Last-Event-ID"),
+		ExposedHeaders:   listEnv("CORS_EXPOSED_HEADERS", "X-Request-Id,Retry-After,RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset,RateLimit-Policy"),
+		AllowCredentials: strings.EqualFold(config.Getenv("CORS_ALLOW_CREDENTIALS", "false"), "true"),
+		MaxAge:           maxAge,
+	}
+}
+
+func routesFile() string {
+	return strings.TrimSpace(os.Getenv("ROUTES_FILE"))
+}
+
+func routesReloadInterval() time.Duration {
+	if d := durationEnv("ROUTES_RELOAD_INTERVAL"); d > 0 {
+		return d
+	}
+	return 10 * time.Second
+}
+
+func floatEnv(key string) float64 {
+	v, _ := strconv.ParseFloat(strings.TrimSpace(os.Getenv(key)), 64)
+	return v
+}
+
+func intEnv(key string) int {
+	v, _ := strconv.Atoi(strings.TrimSpace(os.Getenv(key)))
+	return v
+}
+
+// listEnv splits a comma-separated value, dropping empty items.
+func listEnv(key, fallback string) []string {
+	var out []string
+	for _, v := range strings.Split(config.Getenv(key, fallback), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func durationEnv(key string) time.Duration {
+	v, _ := time.ParseDuration(strings.TrimSpace(os.Getenv(key)))
+	return v
+}
+
+func redisOptions() *redis.Options {
+	redisAddr := config.Getenv("REDIS_ADDR", "localhost:6379")
+	opts := &redis.Options{
+		Addr: redisAddr,
+		// A slow Redis must not hold up every request; on error the limiter
+		// lets the request through.
+		ReadTimeout:  100 * time.Millisecond,
+		WriteTimeout: 100 * time.Millisecond,
+	}
+	if strings.EqualFold(config.Getenv("REDIS_TLS_ENABLED", "false"), "true") {
+		host, _, err := net.SplitHostPort(redisAddr)
+		if err != nil {
+			host = redisAddr
+		}
+		opts.TLSConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			ServerName: host,
+		}
+	}
+	return opts
+}
+
 func enableDevDiagnostics() bool {
 	return strings.EqualFold(config.Getenv("ENABLE_DEV_DIAGNOSTICS", "false"), "true")
 }
 
 func newRouter() http.Handler {
+	log := logx.New()
 	metrics := metricsx.NewRegistry("triad_api_gateway")
+	rateLimit, err := rateLimitSettings()
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid rate limit config")
+	}
+	upstream := upstreamSettings()
+	jwt, apiKeys := jwtSettings(), apiKeySettings()
+	routes := newRouteLoader(http.DefaultTransport, upstream, metrics, log)
+	if path := routesFile(); path != "" {
+		if _, err := routes.loadFile(path); err != nil {
+			log.Fatal().Err(err).Msg("invalid route table")
+		}
+		go routes.watch(context.Background(), path, routesReloadInterval())
+	} else if err := routes.load(defaultRouteFile(ordersURL(), notificationsURL(), 3*time.Second, jwt.enabled() || apiKeys.enabled())); err != nil {
+		log.Fatal().Err(err).Msg("invalid default route table")
+	}
+	routes.start(context.Background())
+	spec, err := openAPISpec()
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid openapi spec")
+	}
+	orderEvents, err := orderEventsSettings(context.Background(), metrics, log)
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid order events config")
+	}
 	return newRouterWithConfig(gatewayConfig{
 		OrdersURL:               ordersURL(),
 		WorkerMetricsURL:        workerMetricsURL(),
+		WorkerURL:               workerURL(),
+		NotificationsURL:        notificationsURL(),
 		NotificationsMetricsURL: notificationsMetricsURL(),
 		EnableDevDiagnostics:    enableDevDiagnostics(),
 		RequestTimeout:          5 * time.Second,
 		UpstreamTimeout:         3 * time.Second,
+		JWT:                     jwt,
+		APIKeys:                 apiKeys,
+		RateLimit:               rateLimit,
+		Upstream:                upstream,
+		MaxBodyBytes:            maxBodyBytes(),
+		CORS:                    corsSettings(),
+		OpenAPI:                 spec,
+		OrderEvents:             orderEvents,
+		Routes:                  routes,
+		Logger:                  log,
 	}, metrics)
 }
 
@@ -72,19 +299,78 @@ func newRouterWithConfig(cfg gatewayConfig, metrics *metricsx.Registry) http.Han
 		metrics = metricsx.NewRegistry("triad_api_gateway")
 	}
 	r := chi.NewRouter()
+	r.NotFound(httpx.NotFound)
+	r.MethodNotAllowed(httpx.MethodNotAllowed)
 	r.Use(requestIDMiddleware)
+	r.Use(httpx.SecurityHeaders)
+	r.Use(stripAuthHeaders)
 	r.Use(metricsMiddleware(metrics))
 	if cfg.RequestTimeout <= 0 {
 		cfg.RequestTimeout = 5 * time.Second
 	}
-	r.Use(middleware.Timeout(cfg.RequestTimeout))
+	r.Use(requestTimeout(cfg.RequestTimeout))
+	// Preflights carry no credentials, so CORS runs before routing and auth.
+	if len(cfg.CORS.AllowedOrigins) > 0 {
+		r.Use(httpx.CORS(cfg.CORS))
+	}
 	r.Get("/healthz", httpx.Healthz)
 	r.Get("/readyz", httpx.Readyz)
 	r.Get("/metrics", metrics.Handler().ServeHTTP)
-	r.Post(ordersPath, forwardOrdersHandler(cfg, metrics))
-	if cfg.EnableDevDiagnostics {
-		r.Get("/v1/dev/async-status", asyncStatusHandler(cfg, metrics))
+	routes := cfg.Routes
+	if routes == nil {
+		var transport http.RoundTripper
+		if cfg.Client != nil {
+			transport = cfg.Client.Transport
+		}
+		routes = newRouteLoader(transport, cfg.Upstream, metrics, cfg.Logger)
+		if err := routes.load(defaultRouteFile(cfg.OrdersURL, cfg.NotificationsURL, cfg.UpstreamTimeout, cfg.JWT.enabled() || cfg.APIKeys.enabled())); err != nil {
+			panic(err)
+		}
+	}
+
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = defaultMaxBodyBytes
 	}
+
+	r.Group(func(r chi.Router) {
+		r.Use(httpx.MaxBodyBytes(cfg.MaxBodyBytes))
+		r.Use(httpx.RequireContentType("application/json"))
+		r.Use(routes.resolve)
+		if cfg.JWT.enabled() || cfg.APIKeys.enabled() {
+			var verifier *jwtVerifier
+			if cfg.JWT.enabled() {
+				verifier = newJWTVerifier(cfg.JWT, cfg.Client, metrics)
+			}
+			var apiKeys *apiKeyResolver
+			if cfg.APIKeys.enabled() {
+				apiKeys = newAPIKeyResolver(cfg.APIKeys, cfg.Client, metrics)
+			}
+			r.Use(unlessPublic(authMiddleware(verifier, apiKeys, metrics, cfg.Logger)))
+		}
+		// Limits apply before authorization so forbidden requests use up the
+		// caller's budget too.
+		if cfg.RateLimit.enabled() {
+			r.Use(rateLimitMiddleware(cfg.RateLimit, metrics))
+		}
+		if cfg.JWT.enabled() || cfg.APIKeys.enabled() {
+			r.Use(unlessPublic(httpx.AuthorizeFunc(routePolicy, cfg.Logger)))
+		}
+		// Validation runs last so unauthenticated callers learn nothing
+		// about the request shape.
+		if cfg.OpenAPI != nil {
+			r.Use(specValidation(cfg.OpenAPI, metrics))
+		}
+		if cfg.EnableDevDiagnostics {
+			r.Get("/v1/dev/async-status", asyncStatusHandler(cfg, metrics))
+		}
+		if cfg.WorkerURL != "" {
+			r.Get(orderProcessingPattern, orderProcessingHandler(cfg, routes, metrics))
+		}
+		if cfg.OrderEvents.Hub != nil {
+			r.Get(orderEventsPattern, orderEventsHandler(cfg.OrderEvents, routes, metrics, cfg.Logger))
+		}
+		r.Handle("/*", routes)
+	})
 	return r
 }
 
@@ -98,6 +384,28 @@ func (w *statusRecorder) WriteHeader(statusCode int) {
 	w.ResponseWriter.WriteHeader(statusCode)
 }
 
+// Unwrap lets http.ResponseController reach the connection, which event
+// streams need to flush.
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// requestTimeout bounds every request except event streams, which stay open
+// until the client leaves or its credentials expire.
+func requestTimeout(d time.Duration) func(http.Handler) http.Handler {
+	timeout := middleware.Timeout(d)
+	return func(next http.Handler) http.Handler {
+		bounded := timeout(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isEventStream(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			bounded.ServeHTTP(w, r)
+		})
+	}
+}
+
 func metricsMiddleware(metrics *metricsx.Registry) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -135,58 +443,6 @@ func newRequestID() string {
 	return hex.EncodeToString(b)
 }
 
-func forwardOrdersHandler(cfg gatewayConfig, metrics *metricsx.Registry) http.HandlerFunc {
-	ordersURL := strings.TrimRight(cfg.OrdersURL, "/")
-	upstreamTimeout := cfg.UpstreamTimeout
-	if upstreamTimeout <= 0 {
-		upstreamTimeout = 3 * time.Second
-	}
-	client := cfg.Client
-	if client == nil {
-		client = &http.Client{Timeout: upstreamTimeout}
-	}
-
-	return func(w http.ResponseWriter, r *http.Request) {
-		metrics.Inc("orders_forward_requests_total")
-		body, err := io.ReadAll(r.Body)
-		if err != nil {
-			metrics.Inc("orders_forward_errors_total")
-			http.Error(w, "failed to read request body", http.StatusBadRequest)
-			return
-		}
-
-		upstreamReq, err := http.NewRequestWithContext(r.Context(), http.MethodPost, ordersURL+ordersPath, bytes.NewReader(body))
-		if err != nil {
-			metrics.Inc("orders_forward_errors_total")
-			http.Error(w, "failed to create upstream request", http.StatusBadGateway)
-			return
-		}
-
-		copyHeaderIfPresent(r, upstreamReq, "Content-Type")
-		copyHeaderIfPresent(r, upstreamReq, "Idempotency-Key")
-		copyHeaderIfPresent(r, upstreamReq, requestIDHeader)
-
-		resp, err := client.Do(upstreamReq)
-		if err != nil {
-			if errors.Is(err, context.DeadlineExceeded) || errors.Is(r.Context().Err(), context.DeadlineExceeded) {
-				metrics.Inc("orders_forward_timeouts_total")
-				http.Error(w, "upstream timeout", http.StatusGatewayTimeout)
-				return
-			}
-			metrics.Inc("orders_forward_errors_total")
-			http.Error(w, "upstream request failed", http.StatusBadGateway)
-			return
-		}
-		defer resp.Body.Close()
-
-		if contentType := resp.Header.Get("Content-Type"); contentType != "" {
-			w.Header().Set("Content-Type", contentType)
-		}
-		w.WriteHeader(resp.StatusCode)
-		_, _ = io.Copy(w, resp.Body)
-	}
-}
-
 type asyncStatusResponse struct {
 	WorkerMessagesProcessed       int64 `json:"worker_messages_processed"`
 	WorkerMessagesDuplicates      int64 `json:"worker_messages_duplicates"`
@@ -204,20 +460,20 @@ func asyncStatusHandler(cfg gatewayConfig, metrics *metricsx.Registry) http.Hand
 	return func(w http.ResponseWriter, r *http.Request) {
 		if strings.TrimSpace(cfg.WorkerMetricsURL) == "" || strings.TrimSpace(cfg.NotificationsMetricsURL) == "" {
 			metrics.Inc("dev_async_status_errors_total")
-			http.Error(w, "async diagnostics not configured", http.StatusServiceUnavailable)
+			httpx.Error(w, r, http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "async diagnostics not configured")
 			return
 		}
 
 		workerMetrics, err := fetchMetrics(r.Context(), client, cfg.WorkerMetricsURL)
 		if err != nil {
 			metrics.Inc("dev_async_status_errors_total")
-			http.Error(w, "failed to read worker metrics", http.StatusBadGateway)
+			httpx.Error(w, r, http.StatusBadGateway, httpx.CodeUpstreamError, "failed to read worker metrics")
 			return
 		}
 		notificationsMetrics, err := fetchMetrics(r.Context(), client, cfg.NotificationsMetricsURL)
 		if err != nil {
 			metrics.Inc("dev_async_status_errors_total")
-			http.Error(w, "failed to read notifications metrics", http.StatusBadGateway)
+			httpx.Error(w, r, http.StatusBadGateway, httpx.CodeUpstreamError, "failed to read notifications metrics")
 			return
 		}
 
@@ -233,7 +489,7 @@ func asyncStatusHandler(cfg gatewayConfig, metrics *metricsx.Registry) http.Hand
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(resp); err != nil {
 			metrics.Inc("dev_async_status_errors_total")
-			http.Error(w, "failed to encode response", http.StatusInternalServerError)
+			httpx.Error(w, r, http.StatusInternalServerError, httpx.CodeInternal, "failed to encode response")
 			return
 		}
 	}
@@ -279,13 +535,6 @@ func parseMetricsCounters(body string) map[string]int64 {
 	return out
 }
 
-func copyHeaderIfPresent(from *http.Request, to *http.Request, key string) {
-	v := strings.TrimSpace(from.Header.Get(key))
-	if v != "" {
-		to.Header.Set(key, v)
-	}
-}
-
 func main() {
 	log := logx.New()
 
@@ -8,6 +8,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/triad-platform/triad-app/pkg/httpx"
 )
 
 func TestNewRouter_HealthEndpoints(t *testing.T) {
@@ -50,8 +52,8 @@ func TestGateway_OrderForwarding(t *testing.T) {
 			capturedReq = req
 			return &http.Response{
 				StatusCode: http.StatusCreated,
-				Header:     http.Header{"Content-Type": []string{"application/json"}},
-				Body:       io.NopCloser(strings.NewReader(`{"order_id":"o-1","status":"created"}`)),
+				Header:     http.Header{"Content-Type": []string{"application/json"}, "Idempotency-Key-Scoped": []string{"u_1:idem-1"}},
+				Body:       io.NopCloser(strings.NewReader(`{"order_id":"o-1","status":"created","idempotency_key":"u_1:idem-1"}`)),
 			}, nil
 		}),
 	}
@@ -88,6 +90,9 @@ func TestGateway_OrderForwarding(t *testing.T) {
 	if got := rec.Header().Get(requestIDHeader); got == "" {
 		t.Fatal("response should include request id")
 	}
+	if got, want := rec.Header().Get("Idempotency-Key-Scoped"), "u_1:idem-1"; got != want {
+		t.Fatalf("scoped idempotency header mismatch: got=%q want=%q", got, want)
+	}
 }
 
 func TestGateway_AssignsRequestIDWhenMissing(t *testing.T) {
@@ -230,3 +235,124 @@ type roundTripperFunc func(*http.Request) (*http.Response, error)
 func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
 	return f(req)
 }
+
+func TestGateway_EdgeHardening(t *testing.T) {
+	t.Parallel()
+
+	var upstreamCalls int
+	client := &http.Client{
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			upstreamCalls++
+			return &http.Response{StatusCode: http.StatusCreated, Body: io.NopCloser(strings.NewReader(`{}`))}, nil
+		}),
+	}
+	r := newRouterWithConfig(gatewayConfig{
+		OrdersURL:    "http://orders:8081",
+		Client:       client,
+		MaxBodyBytes: 16,
+		CORS: httpx.CORSConfig{
+			AllowedOrigins: []string{"https://shop.example.com"},
+			AllowedMethods: []string{http.MethodGet, http.MethodPost},
+			AllowedHeaders: []string{"Content-Type", "Idempotency-Key"},
+		},
+	}, nil)
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		body        string
+		contentType string
+		origin      string
+		wantStatus  int
+	}{
+		{name: "json accepted", method: http.MethodPost, path: ordersPath, body: `{}`, contentType: "application/json", wantStatus: http.StatusCreated},
+		{name: "wrong content type", method: http.MethodPost, path: ordersPath, body: `{}`, contentType: "text/plain", wantStatus: http.StatusUnsupportedMediaType},
+		{name: "body over gateway limit", method: http.MethodPost, path: ordersPath, body: `{"padding":"xxxxxxxx"}`, contentType: "application/json", wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "preflight", method: http.MethodOptions, path: ordersPath, origin: "https://shop.example.com", wantStatus: http.StatusNoContent},
+		{name: "unknown path", method: http.MethodGet, path: "/v1/nothing", wantStatus: http.StatusNotFound},
+	}
+	for _, tc := range tests {
+		var body io.Reader
+		if tc.body != "" {
+			body = strings.NewReader(tc.body)
+		}
+		req := httptest.NewRequest(tc.method, tc.path, body)
+		if tc.contentType != "" {
+			req.Header.Set("Content-Type", tc.contentType)
+		}
+		if tc.origin != "" {
+			req.Header.Set("Origin", tc.origin)
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			req.Header.Set("Access-Control-Request-Headers", "content-type")
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		if rec.Code != tc.wantStatus {
+			t.Fatalf("%s: status code mismatch: got=%d want=%d body=%q", tc.name, rec.Code, tc.wantStatus, rec.Body.String())
+		}
+		if got := rec.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+			t.Fatalf("%s: security headers missing: %v", tc.name, rec.Header())
+		}
+	}
+	if upstreamCalls != 1 {
+		t.Fatalf("upstream calls mismatch: got=%d want=1", upstreamCalls)
+	}
+}
+
+func TestGateway_ProblemResponses(t *testing.T) {
+	t.Parallel()
+
+	client := &http.Client{
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return nil, io.ErrUnexpectedEOF
+		}),
+	}
+	r := newRouterWithConfig(gatewayConfig{
+		OrdersURL: "http://orders:8081",
+		Client:    client,
+	}, nil)
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		wantStatus  int
+		wantCode    string
+	}{
+		{name: "unknown path", method: http.MethodGet, path: "/v1/nothing", contentType: "application/json", wantStatus: http.StatusNotFound, wantCode: httpx.CodeNotFound},
+		{name: "wrong method", method: http.MethodPut, path: ordersPath, contentType: "application/json", wantStatus: http.StatusMethodNotAllowed, wantCode: httpx.CodeMethodNotAllowed},
+		{name: "wrong content type", method: http.MethodPost, path: ordersPath, contentType: "text/plain", wantStatus: http.StatusUnsupportedMediaType, wantCode: httpx.CodeUnsupportedMediaType},
+		{name: "upstream failure", method: http.MethodPost, path: ordersPath, contentType: "application/json", wantStatus: http.StatusBadGateway, wantCode: httpx.CodeUpstreamError},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", tc.contentType)
+		req.Header.Set("Idempotency-Key", "idem-problem")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		if rec.Code != tc.wantStatus {
+			t.Fatalf("%s: status code mismatch: got=%d want=%d body=%q", tc.name, rec.Code, tc.wantStatus, rec.Body.String())
+		}
+		if got := rec.Header().Get("Content-Type"); got != httpx.ProblemContentType {
+			t.Fatalf("%s: content type mismatch: got=%q want=%q", tc.name, got, httpx.ProblemContentType)
+		}
+		var problem struct {
+			Code      string `json:"code"`
+			Status    int    `json:"status"`
+			RequestID string `json:"request_id"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+			t.Fatalf("%s: decode problem: %v", tc.name, err)
+		}
+		if problem.Code != tc.wantCode || problem.Status != tc.wantStatus {
+			t.Fatalf("%s: problem mismatch: got=%+v want code=%q status=%d", tc.name, problem, tc.wantCode, tc.wantStatus)
+		}
+		if want := rec.Header().Get(requestIDHeader); problem.RequestID == "" || problem.RequestID != want {
+			t.Fatalf("%s: request id mismatch: got=%q want=%q", tc.name, problem.RequestID, want)
+		}
+	}
+}
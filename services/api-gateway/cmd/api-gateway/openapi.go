@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/triad-platform/triad-app/contracts/api"
+	"github.com/triad-platform/triad-app/pkg/config"
+	"github.com/triad-platform/triad-app/pkg/httpx"
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+	"github.com/triad-platform/triad-app/pkg/openapi"
+)
+
+// openAPISpec loads the spec requests are validated against: the embedded
+// contracts/api/openapi.json, or OPENAPI_SPEC_FILE when set. It returns nil
+// when OPENAPI_VALIDATION=false.
+func openAPISpec() (*openapi.Document, error) {
+	if strings.EqualFold(config.Getenv("OPENAPI_VALIDATION", "true"), "false") {
+		return nil, nil
+	}
+	data := api.OpenAPI
+	if path := strings.TrimSpace(os.Getenv("OPENAPI_SPEC_FILE")); path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	return openapi.Parse(data)
+}
+
+// specValidation rejects requests that do not match their operation in doc
+// before they are proxied. Requests the spec does not describe pass through;
+// the route table decides whether they exist.
+func specValidation(doc *openapi.Document, metrics *metricsx.Registry) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			op, params, ok := doc.Find(r.Method, r.URL.Path)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			fields, err := openapi.ValidateRequest(r, op, params)
+			if err == nil && len(fields) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			metrics.Inc("openapi_validation_errors_total")
+			var maxErr *http.MaxBytesError
+			switch {
+			case errors.As(err, &maxErr):
+				httpx.Error(w, r, http.StatusRequestEntityTooLarge, httpx.CodeBodyTooLarge, "request body too large")
+			case errors.Is(err, openapi.ErrInvalidJSON):
+				httpx.Error(w, r, http.StatusBadRequest, httpx.CodeInvalidJSON, "invalid json")
+			case errors.Is(err, openapi.ErrUnsupportedMediaType):
+				httpx.Error(w, r, http.StatusUnsupportedMediaType, httpx.CodeUnsupportedMediaType, "unsupported content type")
+			case err != nil:
+				httpx.Error(w, r, http.StatusBadRequest, httpx.CodeInvalidRequest, "failed to read request body")
+			default:
+				httpx.ValidationError(w, r, "request does not match the API specification", fields)
+			}
+		})
+	}
+}
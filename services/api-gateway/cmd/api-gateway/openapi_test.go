@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/triad-platform/triad-app/contracts/api"
+	"github.com/triad-platform/triad-app/pkg/httpx"
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+	"github.com/triad-platform/triad-app/pkg/openapi"
+)
+
+// TestOpenAPISpec_MatchesDefaultRoutes fails when the public spec and the
+// default route table drift apart.
+func TestOpenAPISpec_MatchesDefaultRoutes(t *testing.T) {
+	t.Parallel()
+
+	doc, err := openapi.Parse(api.OpenAPI)
+	if err != nil {
+		t.Fatalf("parse spec: %v", err)
+	}
+	routes := newRouteLoader(nil, upstreamConfig{}, metricsx.NewRegistry("test"), zerolog.Nop())
+	if err := routes.load(defaultRouteFile("http://orders:8081", "http://notifications:8082", time.Second, true)); err != nil {
+		t.Fatalf("load default routes: %v", err)
+	}
+	table := routes.table.Load()
+
+	params := regexp.MustCompile(`\{[^}]+\}`)
+	for _, op := range doc.Operations() {
+		path := params.ReplaceAllString(op.Path, "x")
+		if _, internal := internalPolicies.Lookup(op.Method, path); internal {
+			continue
+		}
+		if route, _ := table.lookup(op.Method, path); route == nil {
+			t.Fatalf("spec operation %s %s has no gateway route", op.Method, op.Path)
+		}
+	}
+
+	// Operator APIs are routed but deliberately left out of the public spec.
+	internal := map[string]bool{"admin-catalog": true}
+	for _, route := range table.routes {
+		if internal[route.name] {
+			continue
+		}
+		path := params.ReplaceAllString(route.pattern, "x")
+		for _, method := range route.methods {
+			if _, _, ok := doc.Find(method, path); !ok {
+				t.Fatalf("route %s (%s %s) is missing from the spec", route.name, method, route.pattern)
+			}
+		}
+	}
+}
+
+func TestGateway_SpecValidation(t *testing.T) {
+	t.Parallel()
+
+	doc, err := openapi.Parse(api.OpenAPI)
+	if err != nil {
+		t.Fatalf("parse spec: %v", err)
+	}
+	var upstreamBody string
+	client := &http.Client{
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			b, _ := io.ReadAll(req.Body)
+			upstreamBody = string(b)
+			return &http.Response{StatusCode: http.StatusCreated, Body: io.NopCloser(strings.NewReader(`{}`))}, nil
+		}),
+	}
+	metrics := metricsx.NewRegistry("test")
+	r := newRouterWithConfig(gatewayConfig{
+		OrdersURL: "http://orders:8081",
+		Client:    client,
+		OpenAPI:   doc,
+	}, metrics)
+
+	tests := []struct {
+		name       string
+		body       string
+		key        string
+		wantStatus int
+		wantFields []httpx.FieldError
+	}{
+		{
+			name: "valid", key: "idem-1", wantStatus: http.StatusCreated,
+			body: `{"user_id":"u_1","items":[{"sku":"sku_1","qty":2}],"currency":"usd"}`,
+		},
+		{
+			name: "aliases and client prices", key: "idem-1", wantStatus: http.StatusBadRequest,
+			body: `{"items":[{"sku":"sku_1","quantity":2,"price_cents":100}],"currency":"USD"}`,
+			wantFields: []httpx.FieldError{
+				{Field: "items[0].qty", Code: httpx.FieldRequired},
+				{Field: "items[0].price_cents", Code: httpx.FieldUnknown},
+				{Field: "items[0].quantity", Code: httpx.FieldUnknown},
+			},
+		},
+		{
+			name: "missing key and currency", wantStatus: http.StatusBadRequest,
+			body: `{"items":[{"sku":"sku_1","qty":0}]}`,
+			wantFields: []httpx.FieldError{
+				{Field: "header.Idempotency-Key", Code: httpx.FieldRequired},
+				{Field: "currency", Code: httpx.FieldRequired},
+				{Field: "items[0].qty", Code: httpx.FieldNotPositive},
+			},
+		},
+	}
+	for _, tc := range tests {
+		upstreamBody = ""
+		req := httptest.NewRequest(http.MethodPost, ordersPath, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		if tc.key != "" {
+			req.Header.Set("Idempotency-Key", tc.key)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		if rec.Code != tc.wantStatus {
+			t.Fatalf("%s: status code mismatch: got=%d want=%d body=%q", tc.name, rec.Code, tc.wantStatus, rec.Body.String())
+		}
+		if tc.wantStatus == http.StatusCreated {
+			if upstreamBody != tc.body {
+				t.Fatalf("%s: upstream body mismatch: got=%q want=%q", tc.name, upstreamBody, tc.body)
+			}
+			continue
+		}
+		if upstreamBody != "" {
+			t.Fatalf("%s: invalid request reached the upstream", tc.name)
+		}
+		var problem struct {
+			Code   string             `json:"code"`
+			Errors []httpx.FieldError `json:"errors"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+			t.Fatalf("%s: decode problem: %v", tc.name, err)
+		}
+		if problem.Code != httpx.CodeValidationFailed || len(problem.Errors) != len(tc.wantFields) {
+			t.Fatalf("%s: problem mismatch: got=%+v want fields=%+v", tc.name, problem, tc.wantFields)
+		}
+		for i, want := range tc.wantFields {
+			if got := problem.Errors[i]; got.Field != want.Field || got.Code != want.Code {
+				t.Fatalf("%s: field error %d mismatch: got=%+v want=%+v", tc.name, i, got, want)
+			}
+		}
+	}
+
+	metricsRec := httptest.NewRecorder()
+	r.ServeHTTP(metricsRec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(metricsRec.Body.String(), "test_openapi_validation_errors_total 2") {
+		t.Fatalf("validation metric missing: %q", metricsRec.Body.String())
+	}
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog"
+	"github.com/triad-platform/triad-app/pkg/httpx"
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+)
+
+const (
+	// orderEventsPattern streams one order's status changes as Server-Sent
+	// Events.
+	orderEventsPattern = ordersPath + "/{orderID}/events"
+
+	ordersCreatedSubject = "orders.created.v1"
+	orderStatusSubject   = "orders.status.v1"
+
+	lastEventIDHeader = "Last-Event-ID"
+)
+
+// orderEventsConfig enables GET /v1/orders/{orderID}/events when Hub is set.
+type orderEventsConfig struct {
+	Hub *orderEventHub
+	// Heartbeat is how often an idle stream gets a comment line, so proxies
+	// keep it open (15s).
+	Heartbeat time.Duration
+	// Retry is the reconnect delay suggested to clients (3s).
+	Retry time.Duration
+}
+
+func (c orderEventsConfig) withDefaults() orderEventsConfig {
+	if c.Heartbeat <= 0 {
+		c.Heartbeat = 15 * time.Second
+	}
+	if c.Retry <= 0 {
+		c.Retry = 3 * time.Second
+	}
+	return c
+}
+
+// orderEvent is one status an order reached. IDs are derived from the event
+// itself, so every gateway replica assigns the same ID and Last-Event-ID
+// works across reconnects to a different replica.
+type orderEvent struct {
+	ID         string
+	OrderID    string
+	Status     string
+	OccurredAt time.Time
+}
+
+func newOrderEvent(orderID, status string, at time.Time) orderEvent {
+	at = at.UTC().Truncate(time.Second)
+	return orderEvent{
+		ID:         strconv.FormatInt(at.Unix(), 10) + "-" + status,
+		OrderID:    orderID,
+		Status:     status,
+		OccurredAt: at,
+	}
+}
+
+func (e orderEvent) data() []byte {
+	b, _ := json.Marshal(struct {
+		OrderID    string    `json:"order_id"`
+		Status     string    `json:"status"`
+		OccurredAt time.Time `json:"occurred_at"`
+	}{e.OrderID, e.Status, e.OccurredAt})
+	return b
+}
+
+// orderEventHub keeps the recent events of each order and fans new ones out
+// to the streams watching it. Orders nobody watches are forgotten after
+// retention.
+type orderEventHub struct {
+	retention   time.Duration
+	maxPerOrder int
+	bufferSize  int
+	metrics     *metricsx.Registry
+	// now is overridden in tests.
+	now func() time.Time
+
+	mu        sync.Mutex
+	orders    map[string]*orderHistory
+	streams   int
+	lastSweep time.Time
+}
+
+type orderHistory struct {
+	events      []orderEvent
+	subscribers map[*orderSubscription]struct{}
+	updated     time.Time
+}
+
+// orderSubscription receives an order's new events. events is closed when
+// the subscriber falls too far behind; the client then resumes with
+// Last-Event-ID.
+type orderSubscription struct {
+	events chan orderEvent
+}
+
+func newOrderEventHub(retention time.Duration, metrics *metricsx.Registry) *orderEventHub {
+	if retention <= 0 {
+		retention = time.Hour
+	}
+	return &orderEventHub{
+		retention:   retention,
+		maxPerOrder: 32,
+		bufferSize:  16,
+		metrics:     metrics,
+		orders:      map[string]*orderHistory{},
+	}
+}
+
+func (h *orderEventHub) clock() time.Time {
+	if h.now != nil {
+		return h.now()
+	}
+	return time.Now()
+}
+
+// publish records ev and delivers it to the order's subscribers. Events
+// already recorded are ignored, which absorbs bus redeliveries and the
+// snapshot a stream seeds on connect.
+func (h *orderEventHub) publish(ev orderEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.clock()
+	h.sweep(now)
+	history := h.orders[ev.OrderID]
+	if history == nil {
+		history = &orderHistory{subscribers: map[*orderSubscription]struct{}{}}
+		h.orders[ev.OrderID] = history
+	}
+	for _, seen := range history.events {
+		if seen.ID == ev.ID {
+			return
+		}
+	}
+	history.updated = now
+	// Events can arrive out of order across subjects; history stays sorted
+	// so replays are.
+	i := sort.Search(len(history.events), func(i int) bool { return history.events[i].OccurredAt.After(ev.OccurredAt) })
+	history.events = append(history.events, orderEvent{})
+	copy(history.events[i+1:], history.events[i:])
+	history.events[i] = ev
+	if len(history.events) > h.maxPerOrder {
+		history.events = history.events[len(history.events)-h.maxPerOrder:]
+	}
+	h.inc("order_events_received_total")
+
+	for sub := range history.subscribers {
+		select {
+		case sub.events <- ev:
+		default:
+			delete(history.subscribers, sub)
+			close(sub.events)
+			h.inc("order_events_slow_streams_total")
+		}
+	}
+}
+
+// subscribe returns the events to replay and a subscription for new ones.
+// With a lastEventID still in the history only later events are replayed;
+// an unknown ID replays everything kept, since the client may have seen
+// events this replica never did.
+func (h *orderEventHub) subscribe(orderID, lastEventID string) ([]orderEvent, *orderSubscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	history := h.orders[orderID]
+	if history == nil {
+		history = &orderHistory{subscribers: map[*orderSubscription]struct{}{}, updated: h.clock()}
+		h.orders[orderID] = history
+	}
+	replay := history.events
+	if lastEventID != "" {
+		for i, ev := range history.events {
+			if ev.ID == lastEventID {
+				replay = history.events[i+1:]
+				break
+			}
+		}
+	}
+	sub := &orderSubscription{events: make(chan orderEvent, h.bufferSize)}
+	history.subscribers[sub] = struct{}{}
+	h.streams++
+	h.setGauge("order_events_active_streams", int64(h.streams))
+	return append([]orderEvent(nil), replay...), sub
+}
+
+func (h *orderEventHub) unsubscribe(orderID string, sub *orderSubscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if history := h.orders[orderID]; history != nil {
+		delete(history.subscribers, sub)
+		history.updated = h.clock()
+	}
+	h.streams--
+	h.setGauge("order_events_active_streams", int64(h.streams))
+}
+
+// sweep drops unwatched orders idle for longer than retention, at most once
+// per minute. Callers hold mu.
+func (h *orderEventHub) sweep(now time.Time) {
+	if now.Sub(h.lastSweep) < time.Minute {
+		return
+	}
+	h.lastSweep = now
+	for id, history := range h.orders {
+		if len(history.subscribers) == 0 && now.Sub(history.updated) > h.retention {
+			delete(h.orders, id)
+		}
+	}
+}
+
+// handleMessage turns an orders.created or orders.status payload into an
+// event.
+func (h *orderEventHub) handleMessage(subject string, data []byte) {
+	var msg struct {
+		OrderID    string `json:"order_id"`
+		Status     string `json:"status"`
+		CreatedAt  string `json:"created_at"`
+		OccurredAt string `json:"occurred_at"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil || msg.OrderID == "" {
+		h.inc("order_events_decode_errors_total")
+		return
+	}
+	at := msg.OccurredAt
+	if subject == ordersCreatedSubject {
+		msg.Status, at = "created", msg.CreatedAt
+	}
+	occurredAt, err := time.Parse(time.RFC3339, at)
+	if err != nil || msg.Status == "" {
+		h.inc("order_events_decode_errors_total")
+		return
+	}
+	h.publish(newOrderEvent(msg.OrderID, msg.Status, occurredAt))
+}
+
+func (h *orderEventHub) inc(name string) {
+	if h.metrics != nil {
+		h.metrics.Inc(name)
+	}
+}
+
+func (h *orderEventHub) setGauge(name string, v int64) {
+	if h.metrics != nil {
+		h.metrics.SetGauge(name, v)
+	}
+}
+
+// orderEventsHandler streams an order's status changes. Each connection is
+// checked against the orders service with the caller's identity, so only
+// the owner or an admin can watch an order, and a stream opened with a JWT
+// ends when that token expires.
+func orderEventsHandler(cfg orderEventsConfig, routes *routeLoader, metrics *metricsx.Registry, log zerolog.Logger) http.HandlerFunc {
+	cfg = cfg.withDefaults()
+	return func(w http.ResponseWriter, r *http.Request) {
+		orderID := chi.URLParam(r, "orderID")
+		snapshot, ok := fetchOrderSnapshot(w, r, routes, orderID)
+		if !ok {
+			metrics.Inc("order_events_rejected_total")
+			return
+		}
+
+		// The snapshot covers events published before this replica
+		// started or before the stream connected.
+		cfg.Hub.publish(newOrderEvent(orderID, snapshot.Status, snapshot.CreatedAt))
+		replay, sub := cfg.Hub.subscribe(orderID, strings.TrimSpace(r.Header.Get(lastEventIDHeader)))
+		defer cfg.Hub.unsubscribe(orderID, sub)
+		metrics.Inc("order_events_streams_total")
+
+		rc := http.NewResponseController(w)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "retry: %d\n\n", cfg.Retry.Milliseconds())
+		for _, ev := range replay {
+			writeOrderEvent(w, ev)
+		}
+		if err := rc.Flush(); err != nil {
+			log.Error().Err(err).Msg("order event stream cannot flush")
+			return
+		}
+
+		heartbeat := time.NewTicker(cfg.Heartbeat)
+		defer heartbeat.Stop()
+		var expired <-chan time.Time
+		if exp, ok := credentialExpiry(r.Context()); ok {
+			timer := time.NewTimer(time.Until(exp))
+			defer timer.Stop()
+			expired = timer.C
+		}
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-expired:
+				metrics.Inc("order_events_credential_expired_total")
+				fmt.Fprint(w, "event: credential_expired\ndata: {}\n\n")
+				_ = rc.Flush()
+				return
+			case ev, ok := <-sub.events:
+				if !ok {
+					return
+				}
+				writeOrderEvent(w, ev)
+				metrics.Inc("order_events_delivered_total")
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeOrderEvent(w io.Writer, ev orderEvent) {
+	fmt.Fprintf(w, "id: %s\nevent: status\ndata: %s\n\n", ev.ID, ev.data())
+}
+
+type orderSnapshot struct {
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// fetchOrderSnapshot reads the order through the orders route as the caller.
+// Refusals from orders, such as 404 for someone else's order, are passed on
+// unchanged.
+func fetchOrderSnapshot(w http.ResponseWriter, r *http.Request, routes *routeLoader, orderID string) (orderSnapshot, bool) {
+	path := ordersPath + "/" + url.PathEscape(orderID)
+	route, _ := routes.table.Load().lookup(http.MethodGet, path)
+	if route == nil {
+		httpx.Error(w, r, http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "order lookup not configured")
+		return orderSnapshot{}, false
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), route.timeout)
+	defer cancel()
+	// The upstream picks the endpoint; only the path matters here.
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+route.upstream.name+path, nil)
+	if err != nil {
+		httpx.Error(w, r, http.StatusBadRequest, httpx.CodeInvalidRequest, "invalid order id")
+		return orderSnapshot{}, false
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set(requestIDHeader, r.Header.Get(requestIDHeader))
+	if p, ok := httpx.PrincipalFromContext(r.Context()); ok {
+		p.SetHeaders(req.Header)
+	}
+
+	resp, err := route.upstream.RoundTrip(req)
+	if err != nil {
+		route.proxy.ErrorHandler(w, r.WithContext(ctx), err)
+		return orderSnapshot{}, false
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		httpx.Error(w, r, http.StatusBadGateway, httpx.CodeUpstreamError, "upstream request failed")
+		return orderSnapshot{}, false
+	}
+	if resp.StatusCode != http.StatusOK {
+		w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+		w.WriteHeader(resp.StatusCode)
+		_, _ = w.Write(body)
+		return orderSnapshot{}, false
+	}
+
+	var snapshot orderSnapshot
+	if err := json.Unmarshal(body, &snapshot); err != nil || snapshot.Status == "" {
+		httpx.Error(w, r, http.StatusBadGateway, httpx.CodeUpstreamError, "unexpected order response")
+		return orderSnapshot{}, false
+	}
+	return snapshot, true
+}
+
+// isEventStream reports whether r opens a long-lived stream, which the
+// request timeout must not cut off.
+func isEventStream(r *http.Request) bool {
+	return r.Method == http.MethodGet && httpx.MatchPattern(orderEventsPattern, r.URL.Path)
+}
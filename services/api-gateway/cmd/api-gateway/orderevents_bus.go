@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"github.com/triad-platform/triad-app/pkg/config"
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+)
+
+const (
+	eventTransportNATS         = "nats"
+	eventTransportRedisStreams = "redis-streams"
+	eventTransportOff          = "off"
+)
+
+// orderEventsSettings reads ORDER_EVENTS_TRANSPORT (falling back to
+// EVENT_TRANSPORT: nats, redis-streams, or off) and starts feeding a hub from
+// the bus. Like rateLimitSettings, an unknown transport is an error.
+func orderEventsSettings(ctx context.Context, metrics *metricsx.Registry, log zerolog.Logger) (orderEventsConfig, error) {
+	transport := strings.ToLower(strings.TrimSpace(config.Getenv("ORDER_EVENTS_TRANSPORT", config.Getenv("EVENT_TRANSPORT", eventTransportNATS))))
+	if transport == eventTransportOff {
+		return orderEventsConfig{}, nil
+	}
+
+	hub := newOrderEventHub(durationEnv("ORDER_EVENTS_RETENTION"), metrics)
+	switch transport {
+	case eventTransportNATS:
+		// The gateway must start without the bus; streams then only carry
+		// snapshots until NATS is reachable.
+		nc, err := nats.Connect(config.Getenv("NATS_URL", "nats://localhost:4222"),
+			nats.RetryOnFailedConnect(true),
+			nats.MaxReconnects(-1),
+		)
+		if err != nil {
+			return orderEventsConfig{}, err
+		}
+		if err := subscribeOrderEventsNATS(nc, hub); err != nil {
+			nc.Close()
+			return orderEventsConfig{}, err
+		}
+	case eventTransportRedisStreams:
+		go runOrderEventsRedis(ctx, redis.NewClient(redisOptions()), hub, metrics, log)
+	default:
+		return orderEventsConfig{}, errors.New("unsupported ORDER_EVENTS_TRANSPORT: " + transport)
+	}
+	return orderEventsConfig{
+		Hub:       hub,
+		Heartbeat: durationEnv("ORDER_EVENTS_HEARTBEAT"),
+	}, nil
+}
+
+// subscribeOrderEventsNATS uses plain subscriptions rather than a queue
+// group: every replica needs every event for the streams it holds.
+func subscribeOrderEventsNATS(nc *nats.Conn, hub *orderEventHub) error {
+	for _, subject := range []string{ordersCreatedSubject, orderStatusSubject} {
+		if _, err := nc.Subscribe(subject, func(msg *nats.Msg) { hub.handleMessage(msg.Subject, msg.Data) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runOrderEventsRedis tails both streams with XREAD, outside the worker's
+// consumer group, so every replica sees every entry. It starts at new
+// entries; earlier statuses come from the order snapshot.
+func runOrderEventsRedis(ctx context.Context, client *redis.Client, hub *orderEventHub, metrics *metricsx.Registry, log zerolog.Logger) {
+	defer client.Close()
+	streams := []string{ordersCreatedSubject, orderStatusSubject, "$", "$"}
+	for ctx.Err() == nil {
+		result, err := client.XRead(ctx, &redis.XReadArgs{
+			Streams: streams,
+			Count:   100,
+			Block:   5 * time.Second,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			metrics.Inc("order_events_bus_errors_total")
+			log.Error().Err(err).Msg("failed to read order event streams")
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+		for _, stream := range result {
+			for _, msg := range stream.Messages {
+				for i, name := range streams[:2] {
+					if name == stream.Stream {
+						streams[2+i] = msg.ID
+					}
+				}
+				payload, _ := msg.Values["payload"].(string)
+				hub.handleMessage(stream.Stream, []byte(payload))
+			}
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rsa"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/triad-platform/triad-app/pkg/httpx"
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+)
+
+func TestOrderEventHub(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	created := newOrderEvent("o-1", "created", base)
+	notified := newOrderEvent("o-1", "notified", base.Add(time.Second))
+	hub := newOrderEventHub(time.Hour, nil)
+
+	// Out of order and redelivered: history is sorted and deduplicated.
+	hub.publish(notified)
+	hub.publish(created)
+	hub.publish(notified)
+
+	tests := []struct {
+		name        string
+		lastEventID string
+		want        []string
+	}{
+		{name: "fresh stream", want: []string{created.ID, notified.ID}},
+		{name: "resume after created", lastEventID: created.ID, want: []string{notified.ID}},
+		{name: "up to date", lastEventID: notified.ID},
+		{name: "unknown id replays everything", lastEventID: "1-cancelled", want: []string{created.ID, notified.ID}},
+	}
+	for _, tc := range tests {
+		replay, sub := hub.subscribe("o-1", tc.lastEventID)
+		hub.unsubscribe("o-1", sub)
+		var got []string
+		for _, ev := range replay {
+			got = append(got, ev.ID)
+		}
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Fatalf("%s: replay mismatch: got=%v want=%v", tc.name, got, tc.want)
+		}
+	}
+
+	_, sub := hub.subscribe("o-1", "")
+	defer hub.unsubscribe("o-1", sub)
+	for i := 0; i <= hub.bufferSize; i++ {
+		hub.publish(newOrderEvent("o-1", "status", base.Add(time.Duration(i+2)*time.Second)))
+	}
+	drained := 0
+	for range sub.events {
+		drained++
+	}
+	if drained != hub.bufferSize {
+		t.Fatalf("slow subscriber mismatch: got %d buffered events before close, want %d", drained, hub.bufferSize)
+	}
+}
+
+func TestOrderEventHub_HandleMessage(t *testing.T) {
+	t.Parallel()
+
+	metrics := metricsx.NewRegistry("test")
+	hub := newOrderEventHub(time.Hour, metrics)
+	hub.handleMessage(ordersCreatedSubject, []byte(`{"order_id":"o-1","user_id":"u_1","created_at":"2026-03-01T12:00:00Z"}`))
+	hub.handleMessage(orderStatusSubject, []byte(`{"order_id":"o-1","status":"notified","occurred_at":"2026-03-01T12:00:05Z"}`))
+	hub.handleMessage(orderStatusSubject, []byte(`{"order_id":"o-1","occurred_at":"2026-03-01T12:00:05Z"}`))
+	hub.handleMessage(orderStatusSubject, []byte(`not json`))
+
+	replay, sub := hub.subscribe("o-1", "")
+	hub.unsubscribe("o-1", sub)
+	if len(replay) != 2 || replay[0].ID != "1772366400-created" || replay[1].Status != "notified" {
+		t.Fatalf("events mismatch: got=%+v", replay)
+	}
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(rec.Body.String(), "test_order_events_decode_errors_total 2") {
+		t.Fatalf("decode error metric missing: %q", rec.Body.String())
+	}
+}
+
+func TestGateway_OrderEventsStream(t *testing.T) {
+	t.Parallel()
+
+	key := newTestRSAKey(t)
+	jwksFile := writeJWKSFile(t, map[string]*rsa.PrivateKey{"k1": key})
+	owner := signTestToken(t, key, "k1", testClaims("u_owner", time.Hour))
+	other := signTestToken(t, key, "k1", testClaims("u_other", time.Hour))
+
+	client := &http.Client{
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.URL.Path != "/v1/orders/o-1" || req.Header.Get(httpx.AuthenticatedUserHeader) != "u_owner" {
+				return &http.Response{
+					StatusCode: http.StatusNotFound,
+					Header:     http.Header{"Content-Type": []string{httpx.ProblemContentType}},
+					Body:       io.NopCloser(strings.NewReader(`{"code":"order_not_found"}`)),
+				}, nil
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{"application/json"}},
+				Body:       io.NopCloser(strings.NewReader(`{"order_id":"o-1","user_id":"u_owner","status":"created","created_at":"2026-03-01T12:00:00.5Z"}`)),
+			}, nil
+		}),
+	}
+	hub := newOrderEventHub(time.Hour, nil)
+	srv := httptest.NewServer(newRouterWithConfig(gatewayConfig{
+		OrdersURL:      "http://orders:8081",
+		Client:         client,
+		RequestTimeout: 50 * time.Millisecond,
+		JWT:            jwtConfig{JWKSFile: jwksFile, Issuer: "pulsecart-auth", Audience: "pulsecart"},
+		OrderEvents:    orderEventsConfig{Hub: hub, Heartbeat: 20 * time.Millisecond},
+	}, nil))
+	defer srv.Close()
+
+	open := func(token, lastEventID string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/v1/orders/o-1/events", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		if lastEventID != "" {
+			req.Header.Set(lastEventIDHeader, lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("open stream: %v", err)
+		}
+		return resp
+	}
+
+	denied := open(other, "")
+	denied.Body.Close()
+	if denied.StatusCode != http.StatusNotFound {
+		t.Fatalf("other user's stream status mismatch: got=%d want=%d", denied.StatusCode, http.StatusNotFound)
+	}
+
+	resp := open(owner, "")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("stream response mismatch: status=%d content-type=%q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	lines := bufio.NewScanner(resp.Body)
+	next := func(prefix string) string {
+		t.Helper()
+		for lines.Scan() {
+			if strings.HasPrefix(lines.Text(), prefix) {
+				return lines.Text()
+			}
+		}
+		t.Fatalf("stream ended before %q: %v", prefix, lines.Err())
+		return ""
+	}
+
+	if got := next("id: "); got != "id: 1772366400-created" {
+		t.Fatalf("snapshot event mismatch: got=%q", got)
+	}
+	// Outlives the request timeout and keeps the connection warm.
+	next(": heartbeat")
+	time.Sleep(100 * time.Millisecond)
+	hub.handleMessage(orderStatusSubject, []byte(`{"order_id":"o-1","status":"notified","occurred_at":"2026-03-01T12:00:03Z"}`))
+	if got := next("id: "); got != "id: 1772366403-notified" {
+		t.Fatalf("live event mismatch: got=%q", got)
+	}
+	if got := next("data: "); !strings.Contains(got, `"status":"notified"`) {
+		t.Fatalf("live event data mismatch: got=%q", got)
+	}
+
+	resumed := open(owner, "1772366400-created")
+	defer resumed.Body.Close()
+	resumedLines := bufio.NewScanner(resumed.Body)
+	for resumedLines.Scan() && !strings.HasPrefix(resumedLines.Text(), "id: ") {
+	}
+	if got := resumedLines.Text(); got != "id: 1772366403-notified" {
+		t.Fatalf("resumed stream mismatch: got=%q", got)
+	}
+}
+
+func TestGateway_OrderEventsStreamEndsWithCredential(t *testing.T) {
+	t.Parallel()
+
+	key := newTestRSAKey(t)
+	jwksFile := writeJWKSFile(t, map[string]*rsa.PrivateKey{"k1": key})
+	client := &http.Client{
+		Transport: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(`{"order_id":"o-1","status":"created","created_at":"2026-03-01T12:00:00Z"}`)),
+			}, nil
+		}),
+	}
+	r := newRouterWithConfig(gatewayConfig{
+		OrdersURL:   "http://orders:8081",
+		Client:      client,
+		JWT:         jwtConfig{JWKSFile: jwksFile, Issuer: "pulsecart-auth", Audience: "pulsecart"},
+		OrderEvents: orderEventsConfig{Hub: newOrderEventHub(time.Hour, nil), Heartbeat: time.Hour},
+	}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/orders/o-1/events", nil)
+	req.Header.Set("Authorization", "Bearer "+signTestToken(t, key, "k1", testClaims("u_1", 1500*time.Millisecond)))
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		r.ServeHTTP(rec, req)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream outlived its token")
+	}
+	if !strings.Contains(rec.Body.String(), "event: credential_expired") {
+		t.Fatalf("expiry event missing: %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/triad-platform/triad-app/pkg/httpx"
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+)
+
+// orderProcessingPattern shows what the worker did with one order.
+const orderProcessingPattern = ordersPath + "/{orderID}/processing"
+
+// orderProcessingPending is reported for orders the worker has not received.
+const orderProcessingPending = "pending"
+
+// orderProcessingHandler checks the order exists for the caller through
+// orders, then relays the worker's processing record for it.
+func orderProcessingHandler(cfg gatewayConfig, routes *routeLoader, metrics *metricsx.Registry) http.HandlerFunc {
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{Timeout: 3 * time.Second}
+	}
+	workerURL := strings.TrimRight(cfg.WorkerURL, "/")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		orderID := chi.URLParam(r, "orderID")
+		if _, ok := fetchOrderSnapshot(w, r, routes, orderID); !ok {
+			return
+		}
+		metrics.Inc("order_processing_requests_total")
+
+		req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, workerURL+ordersPath+"/"+url.PathEscape(orderID)+"/processing", nil)
+		if err != nil {
+			metrics.Inc("order_processing_errors_total")
+			httpx.Error(w, r, http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "order processing not configured")
+			return
+		}
+		req.Header.Set(requestIDHeader, r.Header.Get(requestIDHeader))
+		resp, err := client.Do(req)
+		if err != nil {
+			metrics.Inc("order_processing_errors_total")
+			httpx.Error(w, r, http.StatusBadGateway, httpx.CodeUpstreamUnavailable, "worker unavailable")
+			return
+		}
+		defer resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+			body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+			if err != nil {
+				metrics.Inc("order_processing_errors_total")
+				httpx.Error(w, r, http.StatusBadGateway, httpx.CodeUpstreamError, "failed to read worker response")
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(body)
+		case http.StatusNotFound:
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(struct {
+				OrderID  string `json:"order_id"`
+				State    string `json:"state"`
+				Attempts int    `json:"attempts"`
+			}{orderID, orderProcessingPending, 0})
+		default:
+			metrics.Inc("order_processing_errors_total")
+			httpx.Error(w, r, http.StatusBadGateway, httpx.CodeUpstreamError, "unexpected worker response")
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"crypto/rsa"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/triad-platform/triad-app/pkg/httpx"
+)
+
+func TestGateway_OrderProcessing(t *testing.T) {
+	t.Parallel()
+
+	key := newTestRSAKey(t)
+	jwksFile := writeJWKSFile(t, map[string]*rsa.PrivateKey{"k1": key})
+	adminClaims := testClaims("u_admin", time.Hour)
+	adminClaims.Roles = []string{"admin"}
+	admin := signTestToken(t, key, "k1", adminClaims)
+	customer := signTestToken(t, key, "k1", testClaims("u_owner", time.Hour))
+
+	var workerCalls []string
+	client := &http.Client{
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			respond := func(status int, body string) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: status,
+					Header:     http.Header{"Content-Type": []string{"application/json"}},
+					Body:       io.NopCloser(strings.NewReader(body)),
+				}, nil
+			}
+			if req.URL.Host == "worker:9091" {
+				workerCalls = append(workerCalls, req.URL.Path)
+				switch req.URL.Path {
+				case "/v1/orders/o-1/processing":
+					return respond(http.StatusOK, `{"order_id":"o-1","state":"failed","attempts":2,"last_error":"notify: timeout"}`)
+				case "/v1/orders/o-3/processing":
+					return respond(http.StatusServiceUnavailable, `{"code":"service_unavailable"}`)
+				}
+				return respond(http.StatusNotFound, `{"code":"processing_not_found"}`)
+			}
+			if req.URL.Path == "/v1/orders/o-missing" || req.Header.Get(httpx.AuthenticatedRolesHeader) != "admin" {
+				return respond(http.StatusNotFound, `{"code":"order_not_found"}`)
+			}
+			return respond(http.StatusOK, `{"order_id":"o-1","status":"created","created_at":"2026-03-01T12:00:00Z"}`)
+		}),
+	}
+	r := newRouterWithConfig(gatewayConfig{
+		OrdersURL: "http://orders:8081",
+		WorkerURL: "http://worker:9091/",
+		Client:    client,
+		JWT:       jwtConfig{JWKSFile: jwksFile, Issuer: "pulsecart-auth", Audience: "pulsecart"},
+	}, nil)
+
+	tests := []struct {
+		name       string
+		token      string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "worker record", token: admin, path: "/v1/orders/o-1/processing", wantStatus: http.StatusOK, wantBody: `"last_error":"notify: timeout"`},
+		{name: "not received yet", token: admin, path: "/v1/orders/o-2/processing", wantStatus: http.StatusOK, wantBody: `"state":"pending"`},
+		{name: "worker error", token: admin, path: "/v1/orders/o-3/processing", wantStatus: http.StatusBadGateway, wantBody: `"code":"upstream_error"`},
+		{name: "unknown order", token: admin, path: "/v1/orders/o-missing/processing", wantStatus: http.StatusNotFound, wantBody: `"code":"order_not_found"`},
+		{name: "customer", token: customer, path: "/v1/orders/o-1/processing", wantStatus: http.StatusForbidden},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set("Authorization", "Bearer "+tc.token)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != tc.wantStatus || !strings.Contains(rec.Body.String(), tc.wantBody) {
+			t.Fatalf("%s: response mismatch: got=%d %q want=%d containing %q", tc.name, rec.Code, rec.Body.String(), tc.wantStatus, tc.wantBody)
+		}
+	}
+	if strings.Join(workerCalls, ",") != "/v1/orders/o-1/processing,/v1/orders/o-2/processing,/v1/orders/o-3/processing" {
+		t.Fatalf("worker calls mismatch: got=%v", workerCalls)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/triad-platform/triad-app/pkg/httpx"
+)
+
+// internalPolicies covers the gateway's own /v1 handlers. Proxied routes
+// carry their policy in the route table.
+var internalPolicies = httpx.PolicyTable{
+	{Method: http.MethodGet, Pattern: "/v1/dev/async-status", Policy: httpx.Policy{AnyRole: []string{httpx.RoleAdmin}}},
+	// Processing records are diagnostics and may name internal failures.
+	{Method: http.MethodGet, Pattern: orderProcessingPattern, Policy: httpx.Policy{AnyRole: []string{httpx.RoleAdmin}}},
+	// Ownership is checked per stream by asking orders as the caller.
+	{Method: http.MethodGet, Pattern: orderEventsPattern, Policy: httpx.Policy{
+		AnyRole:  []string{httpx.RoleCustomer, httpx.RoleAdmin},
+		AnyScope: []string{"orders:read"},
+	}},
+}
+
+// routePolicy finds the policy for a request: the resolved route's, or an
+// internal handler's. Anything else is denied by httpx.AuthorizeFunc.
+func routePolicy(r *http.Request) (httpx.Policy, bool) {
+	if route, ok := routeFromContext(r.Context()); ok {
+		return route.policy, true
+	}
+	return internalPolicies.Lookup(r.Method, r.URL.Path)
+}
+
+// unlessPublic skips mw for routes whose auth mode is "none".
+func unlessPublic(mw func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		guarded := mw(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if route, ok := routeFromContext(r.Context()); ok && route.public {
+				next.ServeHTTP(w, r)
+				return
+			}
+			guarded.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/triad-platform/triad-app/pkg/httpx"
+)
+
+func TestGateway_RoutePolicies(t *testing.T) {
+	t.Parallel()
+
+	key := newTestRSAKey(t)
+	jwksFile := writeJWKSFile(t, map[string]*rsa.PrivateKey{"k1": key})
+	tokenFor := func(roles ...string) string {
+		claims := testClaims("u_1", time.Hour)
+		claims.Roles = roles
+		return signTestToken(t, key, "k1", claims)
+	}
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		token        string
+		wantStatus   int
+		wantUpstream string
+		wantRoles    string
+	}{
+		{name: "customer creates order", method: http.MethodPost, path: "/v1/orders", token: tokenFor("customer"), wantStatus: http.StatusOK, wantUpstream: "POST /v1/orders", wantRoles: "customer"},
+		{name: "customer reads order", method: http.MethodGet, path: "/v1/orders/o-1", token: tokenFor("customer"), wantStatus: http.StatusOK, wantUpstream: "GET /v1/orders/o-1", wantRoles: "customer"},
+		{name: "no roles", method: http.MethodPost, path: "/v1/orders", token: tokenFor(), wantStatus: http.StatusForbidden},
+		{name: "customer manages catalog", method: http.MethodPut, path: "/v1/admin/catalog/skus/sku_1", token: tokenFor("customer"), wantStatus: http.StatusForbidden},
+		{name: "admin manages catalog", method: http.MethodPut, path: "/v1/admin/catalog/skus/sku_1", token: tokenFor("admin"), wantStatus: http.StatusOK, wantUpstream: "PUT /v1/admin/catalog/skus/sku_1", wantRoles: "admin"},
+		{name: "admin lists catalog with query", method: http.MethodGet, path: "/v1/admin/catalog/skus?active=true", token: tokenFor("customer", "admin"), wantStatus: http.StatusOK, wantUpstream: "GET /v1/admin/catalog/skus?active=true", wantRoles: "customer,admin"},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var upstream, upstreamRoles string
+			client := &http.Client{
+				Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					upstream = req.Method + " " + req.URL.RequestURI()
+					upstreamRoles = req.Header.Get(httpx.AuthenticatedRolesHeader)
+					return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{}`))}, nil
+				}),
+			}
+			var logs bytes.Buffer
+			r := newRouterWithConfig(gatewayConfig{
+				OrdersURL: "http://orders:8081",
+				Client:    client,
+				JWT:       jwtConfig{JWKSFile: jwksFile, Issuer: "pulsecart-auth", Audience: "pulsecart"},
+				Logger:    zerolog.New(&logs),
+			}, nil)
+
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(`{}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			req.Header.Set(httpx.AuthenticatedRolesHeader, "admin")
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status code mismatch: got=%d want=%d body=%q", rec.Code, tc.wantStatus, rec.Body.String())
+			}
+			if upstream != tc.wantUpstream {
+				t.Fatalf("upstream request mismatch: got=%q want=%q", upstream, tc.wantUpstream)
+			}
+			if upstreamRoles != tc.wantRoles {
+				t.Fatalf("upstream roles mismatch: got=%q want=%q", upstreamRoles, tc.wantRoles)
+			}
+			denied := strings.Contains(logs.String(), `"audit":"access_denied"`)
+			if denied != (tc.wantStatus == http.StatusForbidden) {
+				t.Fatalf("audit log mismatch: denied=%v logs=%q", denied, logs.String())
+			}
+			if denied && !strings.Contains(logs.String(), `"subject":"u_1"`) {
+				t.Fatalf("audit log should name the subject: %q", logs.String())
+			}
+		})
+	}
+}
+
+func TestGateway_PreferencesRoute(t *testing.T) {
+	t.Parallel()
+
+	key := newTestRSAKey(t)
+	jwksFile := writeJWKSFile(t, map[string]*rsa.PrivateKey{"k1": key})
+	customer := testClaims("u_1", time.Hour)
+	customer.Roles = []string{"customer"}
+	noRoles := testClaims("u_1", time.Hour)
+	noRoles.Roles = nil
+
+	tests := []struct {
+		name             string
+		notificationsURL string
+		method           string
+		token            string
+		wantStatus       int
+		wantUpstream     string
+	}{
+		{name: "customer reads preferences", notificationsURL: "http://notifications:8082", method: http.MethodGet, token: signTestToken(t, key, "k1", customer), wantStatus: http.StatusOK, wantUpstream: "GET http://notifications:8082/v1/preferences/u_1"},
+		{name: "customer replaces preferences", notificationsURL: "http://notifications:8082", method: http.MethodPut, token: signTestToken(t, key, "k1", customer), wantStatus: http.StatusOK, wantUpstream: "PUT http://notifications:8082/v1/preferences/u_1"},
+		{name: "anonymous", notificationsURL: "http://notifications:8082", method: http.MethodGet, wantStatus: http.StatusUnauthorized},
+		{name: "no roles", notificationsURL: "http://notifications:8082", method: http.MethodDelete, token: signTestToken(t, key, "k1", noRoles), wantStatus: http.StatusForbidden},
+		{name: "not routed without notifications url", method: http.MethodGet, token: signTestToken(t, key, "k1", customer), wantStatus: http.StatusNotFound},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var upstream, upstreamUser, upstreamRoles string
+			client := &http.Client{
+				Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					upstream = req.Method + " " + req.URL.String()
+					upstreamUser = req.Header.Get(httpx.AuthenticatedUserHeader)
+					upstreamRoles = req.Header.Get(httpx.AuthenticatedRolesHeader)
+					return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{}`))}, nil
+				}),
+			}
+			r := newRouterWithConfig(gatewayConfig{
+				OrdersURL:        "http://orders:8081",
+				NotificationsURL: tc.notificationsURL,
+				Client:           client,
+				JWT:              jwtConfig{JWKSFile: jwksFile, Issuer: "pulsecart-auth", Audience: "pulsecart"},
+				Logger:           zerolog.Nop(),
+			}, nil)
+
+			req := httptest.NewRequest(tc.method, "/v1/preferences/u_1", strings.NewReader(`{}`))
+			req.Header.Set("Content-Type", "application/json")
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			// Identity headers sent by the client must never reach notifications.
+			req.Header.Set(httpx.AuthenticatedUserHeader, "u_2")
+			req.Header.Set(httpx.AuthenticatedRolesHeader, "admin")
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status code mismatch: got=%d want=%d body=%q", rec.Code, tc.wantStatus, rec.Body.String())
+			}
+			if upstream != tc.wantUpstream {
+				t.Fatalf("upstream request mismatch: got=%q want=%q", upstream, tc.wantUpstream)
+			}
+			if tc.wantUpstream != "" && (upstreamUser != "u_1" || upstreamRoles != "customer") {
+				t.Fatalf("upstream principal mismatch: user=%q roles=%q", upstreamUser, upstreamRoles)
+			}
+		})
+	}
+}
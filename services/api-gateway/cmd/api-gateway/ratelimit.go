@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/triad-platform/triad-app/pkg/httpx"
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+)
+
+const (
+	rateLimitBackendMemory = "memory"
+	rateLimitBackendRedis  = "redis"
+	rateLimitBackendOff    = "off"
+
+	defaultRateLimits = "POST /v1/orders 60/1m 20; * /v1/* 600/1m"
+)
+
+// rateLimitRule is a token bucket for one route: Limit tokens are added every
+// Period, up to Burst (Limit when unset). An empty or "*" Method matches every
+// method; Pattern uses the same syntax as the route policies.
+type rateLimitRule struct {
+	Method  string
+	Pattern string
+	Limit   int
+	Period  time.Duration
+	Burst   int
+}
+
+func (r rateLimitRule) name() string {
+	method := r.Method
+	if method == "" {
+		method = "*"
+	}
+	return method + " " + r.Pattern
+}
+
+func (r rateLimitRule) capacity() float64 {
+	if r.Burst > 0 {
+		return float64(r.Burst)
+	}
+	return float64(r.Limit)
+}
+
+// perSecond is the refill rate.
+func (r rateLimitRule) perSecond() float64 {
+	return float64(r.Limit) / r.Period.Seconds()
+}
+
+type rateLimitConfig struct {
+	Rules []rateLimitRule
+	// Limiter is nil when rate limiting is disabled.
+	Limiter rateLimiter
+	// TrustForwardedFor keys anonymous callers by the last X-Forwarded-For
+	// hop, which is the client address as seen by the load balancer.
+	TrustForwardedFor bool
+}
+
+func (c rateLimitConfig) enabled() bool {
+	return c.Limiter != nil && len(c.Rules) > 0
+}
+
+func (c rateLimitConfig) lookup(method, path string) (rateLimitRule, bool) {
+	for _, rule := range c.Rules {
+		if rule.Method != "" && rule.Method != "*" && rule.Method != method {
+			continue
+		}
+		if httpx.MatchPattern(rule.Pattern, path) {
+			return rule, true
+		}
+	}
+	return rateLimitRule{}, false
+}
+
+// rateLimiter takes one token from the bucket named key and reports whether
+// it was available and how many tokens are left afterwards.
+type rateLimiter interface {
+	take(ctx context.Context, key string, rule rateLimitRule) (allowed bool, tokens float64, err error)
+}
+
+// parseRateLimits parses "METHOD PATTERN LIMIT/PERIOD [BURST]" rules
+// separated by semicolons, e.g. "POST /v1/orders 60/1m 20; * /v1/* 600/1m".
+// The first matching rule applies.
+func parseRateLimits(spec string) ([]rateLimitRule, error) {
+	var rules []rateLimitRule
+	for _, entry := range strings.Split(spec, ";") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 && len(fields) != 4 {
+			return nil, fmt.Errorf("rate limit %q: want METHOD PATTERN LIMIT/PERIOD [BURST]", strings.TrimSpace(entry))
+		}
+		limitStr, periodStr, ok := strings.Cut(fields[2], "/")
+		limit, err := strconv.Atoi(limitStr)
+		if !ok || err != nil || limit <= 0 {
+			return nil, fmt.Errorf("rate limit %q: invalid limit %q", strings.TrimSpace(entry), fields[2])
+		}
+		period, err := time.ParseDuration(periodStr)
+		if err != nil || period <= 0 {
+			return nil, fmt.Errorf("rate limit %q: invalid period %q", strings.TrimSpace(entry), periodStr)
+		}
+		rule := rateLimitRule{Method: strings.ToUpper(fields[0]), Pattern: fields[1], Limit: limit, Period: period}
+		if len(fields) == 4 {
+			burst, err := strconv.Atoi(fields[3])
+			if err != nil || burst <= 0 {
+				return nil, fmt.Errorf("rate limit %q: invalid burst %q", strings.TrimSpace(entry), fields[3])
+			}
+			rule.Burst = burst
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// rateLimitMiddleware limits requests per caller and route. It runs after
+// authentication, so verified callers are limited by subject (one bucket per
+// user or partner, shared across their API keys) and anonymous callers by IP.
+// If the backend fails the request is let through.
+func rateLimitMiddleware(cfg rateLimitConfig, metrics *metricsx.Registry) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rule, ok := cfg.lookup(r.Method, r.URL.Path)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := rule.name() + "|" + rateLimitKey(r, cfg.TrustForwardedFor)
+			allowed, tokens, err := cfg.Limiter.take(r.Context(), key, rule)
+			if err != nil {
+				metrics.Inc("rate_limit_backend_errors_total")
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			setRateLimitHeaders(w.Header(), rule, tokens)
+			if !allowed {
+				metrics.Inc("rate_limit_rejected_total")
+				retryAfter := math.Ceil((1 - tokens) / rule.perSecond())
+				w.Header().Set("Retry-After", strconv.Itoa(max(1, int(retryAfter))))
+				httpx.Error(w, r, http.StatusTooManyRequests, httpx.CodeRateLimited, "rate limit exceeded")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// setRateLimitHeaders follows the IETF RateLimit header fields draft: the
+// bucket size, the whole tokens left, and the seconds until it is full again.
+func setRateLimitHeaders(h http.Header, rule rateLimitRule, tokens float64) {
+	capacity := rule.capacity()
+	reset := math.Ceil((capacity - tokens) / rule.perSecond())
+	h.Set("RateLimit-Limit", strconv.Itoa(int(capacity)))
+	h.Set("RateLimit-Remaining", strconv.Itoa(int(math.Floor(tokens))))
+	h.Set("RateLimit-Reset", strconv.Itoa(int(reset)))
+	h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d;burst=%d", rule.Limit, int(rule.Period.Seconds()), int(capacity)))
+}
+
+func rateLimitKey(r *http.Request, trustForwardedFor bool) string {
+	if p, ok := httpx.PrincipalFromContext(r.Context()); ok && p.Subject != "" {
+		return "sub:" + p.Subject
+	}
+	return "ip:" + clientIP(r, trustForwardedFor)
+}
+
+func clientIP(r *http.Request, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+		if last := strings.TrimSpace(hops[len(hops)-1]); last != "" {
+			return last
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// memoryRateLimiter keeps buckets in process, so each replica enforces the
+// full limit on its own.
+type memoryRateLimiter struct {
+	maxEntries int
+	// now is overridden in tests.
+	now func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newMemoryRateLimiter() *memoryRateLimiter {
+	return &memoryRateLimiter{
+		maxEntries: 100000,
+		now:        time.Now,
+		buckets:    map[string]*tokenBucket{},
+	}
+}
+
+func (m *memoryRateLimiter) take(_ context.Context, key string, rule rateLimitRule) (bool, float64, error) {
+	now := m.now()
+	capacity := rule.capacity()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, ok := m.buckets[key]
+	if !ok {
+		if len(m.buckets) >= m.maxEntries {
+			m.prune(now, rule)
+		}
+		b = &tokenBucket{tokens: capacity, last: now}
+		m.buckets[key] = b
+	}
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+elapsed*rule.perSecond())
+		b.last = now
+	}
+	if b.tokens < 1 {
+		return false, b.tokens, nil
+	}
+	b.tokens--
+	return true, b.tokens, nil
+}
+
+// prune drops buckets idle for longer than a full refill of rule, which would
+// be full again anyway; called with mu held.
+func (m *memoryRateLimiter) prune(now time.Time, rule rateLimitRule) {
+	idle := time.Duration(rule.capacity() / rule.perSecond() * float64(time.Second))
+	for k, b := range m.buckets {
+		if now.Sub(b.last) > idle {
+			delete(m.buckets, k)
+		}
+	}
+	if len(m.buckets) >= m.maxEntries {
+		clear(m.buckets)
+	}
+}
+
+// tokenBucketScript refills and takes from a bucket stored as a hash. It uses
+// the Redis clock so replicas with skewed clocks share one view of time, and
+// returns tokens as a string because Lua numbers are truncated in replies.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local capacity = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or capacity
+local ts = tonumber(state[2]) or now
+if now > ts then
+  tokens = math.min(capacity, tokens + (now - ts) * rate)
+  ts = now
+end
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', ts)
+redis.call('PEXPIRE', KEYS[1], math.ceil(capacity / rate))
+return {allowed, tostring(tokens)}
+`)
+
+// redisRateLimiter shares buckets between gateway replicas.
+type redisRateLimiter struct {
+	client redis.Scripter
+	prefix string
+}
+
+func newRedisRateLimiter(client redis.Scripter, prefix string) *redisRateLimiter {
+	return &redisRateLimiter{client: client, prefix: prefix}
+}
+
+func (l *redisRateLimiter) take(ctx context.Context, key string, rule rateLimitRule) (bool, float64, error) {
+	perMilli := rule.perSecond() / 1000
+	res, err := tokenBucketScript.Run(ctx, l.client, []string{l.prefix + key}, perMilli, rule.capacity()).Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	if len(res) != 2 {
+		return false, 0, fmt.Errorf("unexpected rate limit script reply %v", res)
+	}
+	allowed, _ := res[0].(int64)
+	tokensStr, _ := res[1].(string)
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+	if err != nil {
+		return false, 0, fmt.Errorf("unexpected rate limit tokens %q: %w", tokensStr, err)
+	}
+	return allowed == 1, tokens, nil
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+)
+
+func TestGateway_RateLimit(t *testing.T) {
+	t.Parallel()
+
+	client := &http.Client{
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusCreated, Body: io.NopCloser(strings.NewReader(`{}`))}, nil
+		}),
+	}
+	metrics := metricsx.NewRegistry("test")
+	r := newRouterWithConfig(gatewayConfig{
+		OrdersURL: "http://orders:8081",
+		Client:    client,
+		RateLimit: rateLimitConfig{
+			Rules:   []rateLimitRule{{Method: http.MethodPost, Pattern: ordersPath, Limit: 1, Period: time.Minute, Burst: 2}},
+			Limiter: newMemoryRateLimiter(),
+		},
+	}, metrics)
+
+	send := func(method, path, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i, wantRemaining := range []string{"1", "0"} {
+		rec := send(http.MethodPost, ordersPath, "10.0.0.1:5000")
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("request %d status mismatch: got=%d want=%d", i, rec.Code, http.StatusCreated)
+		}
+		if got := rec.Header().Get("RateLimit-Remaining"); got != wantRemaining {
+			t.Fatalf("request %d remaining mismatch: got=%q want=%q", i, got, wantRemaining)
+		}
+	}
+
+	rec := send(http.MethodPost, ordersPath, "10.0.0.1:5001")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status code mismatch: got=%d want=%d", rec.Code, http.StatusTooManyRequests)
+	}
+	wantHeaders := map[string]string{
+		"Retry-After":         "60",
+		"RateLimit-Limit":     "2",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "120",
+		"RateLimit-Policy":    "1;w=60;burst=2",
+	}
+	for name, want := range wantHeaders {
+		if got := rec.Header().Get(name); got != want {
+			t.Fatalf("%s mismatch: got=%q want=%q", name, got, want)
+		}
+	}
+
+	if rec := send(http.MethodPost, ordersPath, "10.0.0.2:5000"); rec.Code != http.StatusCreated {
+		t.Fatalf("other client status mismatch: got=%d want=%d", rec.Code, http.StatusCreated)
+	}
+	if rec := send(http.MethodGet, ordersPath+"/o-1", "10.0.0.1:5000"); rec.Code != http.StatusCreated || rec.Header().Get("RateLimit-Limit") != "" {
+		t.Fatalf("unlimited route mismatch: status=%d headers=%v", rec.Code, rec.Header())
+	}
+
+	body := scrapeMetrics(t, metrics)
+	if !strings.Contains(body, "test_rate_limit_rejected_total 1") {
+		t.Fatalf("rejection metric missing: %q", body)
+	}
+}
+
+func TestGateway_RateLimitByPrincipal(t *testing.T) {
+	t.Parallel()
+
+	key := newTestRSAKey(t)
+	jwksFile := writeJWKSFile(t, map[string]*rsa.PrivateKey{"k1": key})
+	client := &http.Client{
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusCreated, Body: io.NopCloser(strings.NewReader(`{}`))}, nil
+		}),
+	}
+	r := newRouterWithConfig(gatewayConfig{
+		OrdersURL: "http://orders:8081",
+		Client:    client,
+		JWT:       jwtConfig{JWKSFile: jwksFile, Issuer: "pulsecart-auth", Audience: "pulsecart"},
+		RateLimit: rateLimitConfig{
+			Rules:   []rateLimitRule{{Pattern: "/v1/*", Limit: 1, Period: time.Minute}},
+			Limiter: newMemoryRateLimiter(),
+		},
+	}, nil)
+
+	send := func(sub, remoteAddr string) int {
+		req := httptest.NewRequest(http.MethodPost, ordersPath, strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("Authorization", "Bearer "+signTestToken(t, key, "k1", testClaims(sub, time.Hour)))
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if got := send("u_1", "10.0.0.1:5000"); got != http.StatusCreated {
+		t.Fatalf("first request status mismatch: got=%d want=%d", got, http.StatusCreated)
+	}
+	// Same user from another address shares the bucket.
+	if got := send("u_1", "10.0.0.2:5000"); got != http.StatusTooManyRequests {
+		t.Fatalf("same user status mismatch: got=%d want=%d", got, http.StatusTooManyRequests)
+	}
+	// Another user behind the same address does not.
+	if got := send("u_2", "10.0.0.1:5000"); got != http.StatusCreated {
+		t.Fatalf("other user status mismatch: got=%d want=%d", got, http.StatusCreated)
+	}
+}
+
+func TestGateway_RateLimitFailsOpen(t *testing.T) {
+	t.Parallel()
+
+	client := &http.Client{
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusCreated, Body: io.NopCloser(strings.NewReader(`{}`))}, nil
+		}),
+	}
+	r := newRouterWithConfig(gatewayConfig{
+		OrdersURL: "http://orders:8081",
+		Client:    client,
+		RateLimit: rateLimitConfig{
+			Rules:   []rateLimitRule{{Pattern: "/v1/*", Limit: 1, Period: time.Minute}},
+			Limiter: failingRateLimiter{},
+		},
+	}, nil)
+
+	req := httptest.NewRequest(http.MethodPost, ordersPath, strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status code mismatch: got=%d want=%d", rec.Code, http.StatusCreated)
+	}
+}
+
+func TestMemoryRateLimiter_Refill(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	limiter := newMemoryRateLimiter()
+	limiter.now = func() time.Time { return now }
+	rule := rateLimitRule{Pattern: "/v1/*", Limit: 2, Period: time.Second}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if ok, _, _ := limiter.take(ctx, "k", rule); !ok {
+			t.Fatalf("take %d should be allowed", i)
+		}
+	}
+	if ok, tokens, _ := limiter.take(ctx, "k", rule); ok || tokens != 0 {
+		t.Fatalf("empty bucket mismatch: allowed=%v tokens=%v", ok, tokens)
+	}
+
+	now = now.Add(250 * time.Millisecond)
+	if ok, tokens, _ := limiter.take(ctx, "k", rule); ok || tokens != 0.5 {
+		t.Fatalf("partial refill mismatch: allowed=%v tokens=%v want tokens=0.5", ok, tokens)
+	}
+	now = now.Add(time.Hour)
+	if ok, tokens, _ := limiter.take(ctx, "k", rule); !ok || tokens != 1 {
+		t.Fatalf("refill should cap at burst: allowed=%v tokens=%v want tokens=1", ok, tokens)
+	}
+}
+
+func TestRedisRateLimiter(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr, DialTimeout: 500 * time.Millisecond, MaxRetries: -1})
+	defer client.Close()
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		t.Skipf("skipping integration test; Redis not reachable at %s: %v", addr, err)
+	}
+
+	ctx := context.Background()
+	limiter := newRedisRateLimiter(client, fmt.Sprintf("gateway:ratelimit:test:%d:", time.Now().UnixNano()))
+	rule := rateLimitRule{Pattern: "/v1/*", Limit: 2, Period: time.Minute}
+	defer client.Del(ctx, limiter.prefix+"k")
+
+	for i := 0; i < 2; i++ {
+		ok, _, err := limiter.take(ctx, "k", rule)
+		if err != nil || !ok {
+			t.Fatalf("take %d mismatch: allowed=%v err=%v", i, ok, err)
+		}
+	}
+	ok, tokens, err := limiter.take(ctx, "k", rule)
+	if err != nil || ok || tokens >= 1 {
+		t.Fatalf("empty bucket mismatch: allowed=%v tokens=%v err=%v", ok, tokens, err)
+	}
+	if ttl := client.PTTL(ctx, limiter.prefix+"k").Val(); ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("bucket ttl mismatch: got=%v", ttl)
+	}
+}
+
+func TestParseRateLimits(t *testing.T) {
+	t.Parallel()
+
+	rules, err := parseRateLimits(defaultRateLimits)
+	if err != nil {
+		t.Fatalf("parseRateLimits(default) error = %v", err)
+	}
+	want := []rateLimitRule{
+		{Method: http.MethodPost, Pattern: ordersPath, Limit: 60, Period: time.Minute, Burst: 20},
+		{Method: "*", Pattern: "/v1/*", Limit: 600, Period: time.Minute},
+	}
+	if fmt.Sprint(rules) != fmt.Sprint(want) {
+		t.Fatalf("rules mismatch: got=%+v want=%+v", rules, want)
+	}
+
+	for _, spec := range []string{"POST /v1/orders", "POST /v1/orders 0/1m", "POST /v1/orders 10/x", "POST /v1/orders 10/1m -1", "POST /v1/orders 10"} {
+		if _, err := parseRateLimits(spec); err == nil {
+			t.Fatalf("parseRateLimits(%q) should fail", spec)
+		}
+	}
+}
+
+func TestRateLimitSettings(t *testing.T) {
+	t.Setenv("RATE_LIMITS", "get /v1/orders/{orderID} 5/1s")
+	t.Setenv("RATE_LIMIT_BACKEND", "memory")
+	t.Setenv("RATE_LIMIT_TRUST_FORWARDED_FOR", "true")
+
+	cfg, err := rateLimitSettings()
+	if err != nil {
+		t.Fatalf("rateLimitSettings() error = %v", err)
+	}
+	if !cfg.enabled() || !cfg.TrustForwardedFor {
+		t.Fatalf("config mismatch: %+v", cfg)
+	}
+	if rule, ok := cfg.lookup(http.MethodGet, "/v1/orders/o-1"); !ok || rule.Limit != 5 {
+		t.Fatalf("lookup mismatch: rule=%+v ok=%v", rule, ok)
+	}
+
+	t.Setenv("RATE_LIMIT_BACKEND", "off")
+	if cfg, err := rateLimitSettings(); err != nil || cfg.enabled() {
+		t.Fatalf("off backend mismatch: enabled=%v err=%v", cfg.enabled(), err)
+	}
+
+	t.Setenv("RATE_LIMIT_BACKEND", "memcached")
+	if _, err := rateLimitSettings(); err == nil {
+		t.Fatal("expected unsupported backend error")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.9:443"
+	req.Header.Set("X-Forwarded-For", "1.1.1.1, 203.0.113.7")
+
+	if got := clientIP(req, false); got != "10.0.0.9" {
+		t.Fatalf("untrusted clientIP mismatch: got=%q want=%q", got, "10.0.0.9")
+	}
+	if got := clientIP(req, true); got != "203.0.113.7" {
+		t.Fatalf("trusted clientIP mismatch: got=%q want=%q", got, "203.0.113.7")
+	}
+}
+
+type failingRateLimiter struct{}
+
+func (failingRateLimiter) take(context.Context, string, rateLimitRule) (bool, float64, error) {
+	return false, 0, errors.New("redis down")
+}
+
+func scrapeMetrics(t *testing.T, metrics *metricsx.Registry) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	return rec.Body.String()
+}
@@ -0,0 +1,705 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/triad-platform/triad-app/pkg/httpx"
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+)
+
+const (
+	routeAuthRequired = "required"
+	routeAuthNone     = "none"
+
+	defaultMaxBodyBytes = 1 << 20
+)
+
+// routeFile is the JSON route table read from ROUTES_FILE. Upstream URLs may
+// reference environment variables as ${NAME}.
+type routeFile struct {
+	Upstreams map[string]upstreamSpec `json:"upstreams"`
+	Routes    []routeSpec             `json:"routes"`
+}
+
+// upstreamSpec gives a backend's endpoints as a single url, a list of
+// endpoints, or a dns name whose addresses are re-resolved every
+// dns_refresh. All of a backend's endpoints share one path.
+type upstreamSpec struct {
+	URL        string   `json:"url,omitempty"`
+	Endpoints  []string `json:"endpoints,omitempty"`
+	DNS        string   `json:"dns,omitempty"`
+	DNSRefresh string   `json:"dns_refresh,omitempty"`
+	// Balancer is round_robin (the default) or least_request.
+	Balancer    string          `json:"balancer,omitempty"`
+	HealthCheck healthCheckSpec `json:"health_check"`
+	Outlier     outlierSpec     `json:"outlier_detection"`
+}
+
+// sameBackend reports whether s and other name the same endpoints, so a
+// mirror cannot send its copies to the backend that serves the original.
+func (s upstreamSpec) sameBackend(other upstreamSpec) bool {
+	return s.URL == other.URL && s.DNS == other.DNS && slices.Equal(s.Endpoints, other.Endpoints)
+}
+
+// healthCheckSpec defaults to GET /readyz every 5s with a 1s timeout; two
+// failures in a row mark an endpoint unhealthy and one pass restores it.
+type healthCheckSpec struct {
+	Disabled           bool   `json:"disabled,omitempty"`
+	Path               string `json:"path,omitempty"`
+	Interval           string `json:"interval,omitempty"`
+	Timeout            string `json:"timeout,omitempty"`
+	UnhealthyThreshold int    `json:"unhealthy_threshold,omitempty"`
+	HealthyThreshold   int    `json:"healthy_threshold,omitempty"`
+}
+
+// outlierSpec defaults to ejecting an endpoint for 30s after 5 consecutive
+// failures, with at most half of the endpoints ejected at once.
+type outlierSpec struct {
+	ConsecutiveFailures int    `json:"consecutive_failures,omitempty"`
+	EjectionTime        string `json:"ejection_time,omitempty"`
+	MaxEjectionPercent  int    `json:"max_ejection_percent,omitempty"`
+}
+
+// poolConfig validates spec and returns the pool settings along with the
+// base URL whose path proxied requests are joined to.
+func (spec upstreamSpec) poolConfig() (poolConfig, *url.URL, error) {
+	cfg := poolConfig{balancer: spec.Balancer}
+	switch cfg.balancer {
+	case "":
+		cfg.balancer = balancerRoundRobin
+	case balancerRoundRobin, balancerLeastRequest:
+	default:
+		return poolConfig{}, nil, fmt.Errorf("balancer must be %q or %q", balancerRoundRobin, balancerLeastRequest)
+	}
+
+	sources := 0
+	for _, set := range []bool{spec.URL != "", len(spec.Endpoints) > 0, spec.DNS != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return poolConfig{}, nil, errors.New("set exactly one of url, endpoints, and dns")
+	}
+
+	var base *url.URL
+	var err error
+	if spec.DNS != "" {
+		if cfg.dns, err = parseUpstreamURL(spec.DNS); err != nil {
+			return poolConfig{}, nil, err
+		}
+		if cfg.dnsRefresh, err = parseDuration("dns_refresh", spec.DNSRefresh, 30*time.Second); err != nil {
+			return poolConfig{}, nil, err
+		}
+		base = cfg.dns
+	} else {
+		raw := spec.Endpoints
+		if spec.URL != "" {
+			raw = []string{spec.URL}
+		}
+		for _, r := range raw {
+			u, err := parseUpstreamURL(r)
+			if err != nil {
+				return poolConfig{}, nil, err
+			}
+			if base == nil {
+				base = u
+			} else if u.Path != base.Path {
+				return poolConfig{}, nil, fmt.Errorf("endpoint %q: all endpoints must share the path %q", r, base.Path)
+			}
+			cfg.endpoints = append(cfg.endpoints, u)
+		}
+	}
+
+	hc := spec.HealthCheck
+	cfg.health = healthCheckConfig{
+		enabled:            !hc.Disabled,
+		path:               hc.Path,
+		unhealthyThreshold: hc.UnhealthyThreshold,
+		healthyThreshold:   hc.HealthyThreshold,
+	}
+	if cfg.health.path == "" {
+		cfg.health.path = "/readyz"
+	}
+	if cfg.health.unhealthyThreshold <= 0 {
+		cfg.health.unhealthyThreshold = 2
+	}
+	if cfg.health.healthyThreshold <= 0 {
+		cfg.health.healthyThreshold = 1
+	}
+	if cfg.health.interval, err = parseDuration("health_check.interval", hc.Interval, 5*time.Second); err != nil {
+		return poolConfig{}, nil, err
+	}
+	if cfg.health.timeout, err = parseDuration("health_check.timeout", hc.Timeout, time.Second); err != nil {
+		return poolConfig{}, nil, err
+	}
+
+	od := spec.Outlier
+	cfg.outlier = outlierConfig{consecutiveFailures: od.ConsecutiveFailures, maxEjectionPercent: od.MaxEjectionPercent}
+	if cfg.outlier.consecutiveFailures <= 0 {
+		cfg.outlier.consecutiveFailures = 5
+	}
+	if cfg.outlier.maxEjectionPercent <= 0 || cfg.outlier.maxEjectionPercent > 100 {
+		cfg.outlier.maxEjectionPercent = 50
+	}
+	if cfg.outlier.ejectionTime, err = parseDuration("outlier_detection.ejection_time", od.EjectionTime, 30*time.Second); err != nil {
+		return poolConfig{}, nil, err
+	}
+	return cfg, base, nil
+}
+
+func parseUpstreamURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(os.ExpandEnv(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid url %q", raw)
+	}
+	return u, nil
+}
+
+func parseDuration(field, raw string, def time.Duration) (time.Duration, error) {
+	if raw == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid %s %q", field, raw)
+	}
+	return d, nil
+}
+
+type routeSpec struct {
+	Name string `json:"name"`
+	// Methods is empty to match every method.
+	Methods []string `json:"methods,omitempty"`
+	// Path uses the route policy pattern syntax: "{name}" matches one
+	// segment and a trailing "*" makes it a prefix.
+	Path     string `json:"path"`
+	Upstream string `json:"upstream"`
+	// Timeout covers the whole upstream exchange, retries included.
+	Timeout      string `json:"timeout,omitempty"`
+	MaxBodyBytes int64  `json:"max_body_bytes,omitempty"`
+	// RequestHeaders are the client headers forwarded upstream (default
+	// Accept and Content-Type). The request ID, X-Forwarded-* and the
+	// verified principal are always sent.
+	RequestHeaders []string    `json:"request_headers,omitempty"`
+	Auth           authSpec    `json:"auth"`
+	Canary         *canarySpec `json:"canary,omitempty"`
+	Mirror         *mirrorSpec `json:"mirror,omitempty"`
+}
+
+// authSpec is a route's authentication mode and, when required, the
+// httpx.Policy a caller must satisfy.
+type authSpec struct {
+	Mode      string   `json:"mode,omitempty"`
+	AnyRole   []string `json:"any_role,omitempty"`
+	AnyScope  []string `json:"any_scope,omitempty"`
+	AllScopes []string `json:"all_scopes,omitempty"`
+}
+
+// defaultRouteFile is the table served when ROUTES_FILE is unset. ordersURL
+// may be a comma-separated list of endpoints. The admin catalog and the
+// notification preferences are only routed when auth is enabled, since
+// without it their policies are not enforced; preferences also need
+// notificationsURL.
+func defaultRouteFile(ordersURL, notificationsURL string, timeout time.Duration, auth bool) routeFile {
+	if ordersURL == "" {
+		ordersURL = "http://localhost:8081"
+	}
+	if timeout <= 0 {
+		timeout = 3 * time.Second
+	}
+	orders := upstreamSpec{URL: ordersURL}
+	if endpoints := strings.Split(ordersURL, ","); len(endpoints) > 1 {
+		orders = upstreamSpec{}
+		for _, e := range endpoints {
+			orders.Endpoints = append(orders.Endpoints, strings.TrimSpace(e))
+		}
+	}
+	customerOrAdmin := []string{httpx.RoleCustomer, httpx.RoleAdmin}
+	table := routeFile{
+		Upstreams: map[string]upstreamSpec{"orders": orders},
+		Routes: []routeSpec{
+			{
+				Name: "orders-create", Methods: []string{http.MethodPost}, Path: ordersPath, Upstream: "orders",
+				Timeout: timeout.String(), RequestHeaders: []string{"Accept", "Content-Type", "Idempotency-Key"},
+				Auth: authSpec{AnyRole: customerOrAdmin, AnyScope: []string{"orders:write"}},
+			},
+			{
+				Name: "orders-read", Methods: []string{http.MethodGet}, Path: ordersPath + "/{orderID}", Upstream: "orders",
+				Timeout: timeout.String(),
+				Auth:    authSpec{AnyRole: customerOrAdmin, AnyScope: []string{"orders:read"}},
+			},
+		},
+	}
+	if auth {
+		table.Routes = append(table.Routes, routeSpec{
+			Name: "admin-catalog", Path: adminCatalogPath + "/*", Upstream: "orders",
+			Timeout: timeout.String(),
+			Auth:    authSpec{AnyRole: []string{httpx.RoleAdmin}},
+		})
+	}
+	// Notifications only lets customers touch their own preferences, which it
+	// checks against the principal headers the gateway sets here.
+	if auth && notificationsURL != "" {
+		table.Upstreams["notifications"] = upstreamSpec{URL: notificationsURL}
+		table.Routes = append(table.Routes, routeSpec{
+			Name: "notification-preferences", Methods: []string{http.MethodGet, http.MethodPut, http.MethodDelete},
+			Path: preferencesPath + "/{userID}", Upstream: "notifications",
+			Timeout: timeout.String(), RequestHeaders: []string{"Accept", "Content-Type"},
+			Auth: authSpec{AnyRole: customerOrAdmin},
+		})
+	}
+	return table
+}
+
+type proxyRoute struct {
+	name     string
+	methods  []string
+	pattern  string
+	upstream *upstream
+	timeout  time.Duration
+	maxBody  int64
+	public   bool
+	policy   httpx.Policy
+	proxy    *httputil.ReverseProxy
+	canary   *routeCanary
+	mirror   *routeMirror
+	// metricName is name in metric-safe form.
+	metricName string
+}
+
+func (pr *proxyRoute) allowsMethod(method string) bool {
+	return len(pr.methods) == 0 || slices.Contains(pr.methods, method)
+}
+
+type routeTable struct {
+	routes []*proxyRoute
+}
+
+// lookup returns the first route matching method and path. pathMatched tells
+// a wrong method (405) from an unknown path (404).
+func (t *routeTable) lookup(method, path string) (route *proxyRoute, pathMatched bool) {
+	for _, pr := range t.routes {
+		if !httpx.MatchPattern(pr.pattern, path) {
+			continue
+		}
+		pathMatched = true
+		if pr.allowsMethod(method) {
+			return pr, true
+		}
+	}
+	return nil, pathMatched
+}
+
+type routeContextKey struct{}
+
+func routeFromContext(ctx context.Context) (*proxyRoute, bool) {
+	pr, ok := ctx.Value(routeContextKey{}).(*proxyRoute)
+	return pr, ok
+}
+
+// routeLoader holds the live route table and swaps it atomically on reload.
+// Upstreams are kept by name across reloads, so a reload does not reset
+// their circuit breakers or endpoint health.
+type routeLoader struct {
+	transport   http.RoundTripper
+	upstreamCfg upstreamConfig
+	metrics     *metricsx.Registry
+	log         zerolog.Logger
+
+	table atomic.Pointer[routeTable]
+
+	mu        sync.Mutex
+	upstreams map[string]*upstream
+	modTime   time.Time
+	// ctx is set by start; each upstream's health checks and DNS refresh
+	// then run until its stop func is called or ctx is done.
+	ctx   context.Context
+	stops map[string]context.CancelFunc
+}
+
+func newRouteLoader(transport http.RoundTripper, upstreamCfg upstreamConfig, metrics *metricsx.Registry, log zerolog.Logger) *routeLoader {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &routeLoader{
+		transport:   transport,
+		upstreamCfg: upstreamCfg,
+		metrics:     metrics,
+		log:         log,
+		upstreams:   map[string]*upstream{},
+		stops:       map[string]context.CancelFunc{},
+	}
+}
+
+// start runs health checks and DNS refresh for the upstreams of the current
+// table and of every table loaded later.
+func (l *routeLoader) start(ctx context.Context) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ctx = ctx
+	for name, up := range l.upstreams {
+		l.startPool(name, up)
+	}
+}
+
+// startPool is called with mu held.
+func (l *routeLoader) startPool(name string, up *upstream) {
+	ctx, cancel := context.WithCancel(l.ctx)
+	l.stops[name] = cancel
+	go up.pool.run(ctx, &http.Client{Transport: l.transport})
+}
+
+// load validates f and makes it the live table. On error the previous table
+// stays in place.
+func (l *routeLoader) load(f routeFile) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	type pendingUpstream struct {
+		up   *upstream
+		cfg  poolConfig
+		base *url.URL
+	}
+	upstreams := map[string]pendingUpstream{}
+	for name, spec := range f.Upstreams {
+		cfg, base, err := spec.poolConfig()
+		if err != nil {
+			return fmt.Errorf("upstream %q: %w", name, err)
+		}
+		up, ok := l.upstreams[name]
+		if !ok {
+			up = newUpstream(name, l.transport, l.upstreamCfg, l.metrics, l.log)
+		}
+		upstreams[name] = pendingUpstream{up: up, cfg: cfg, base: base}
+	}
+
+	table := &routeTable{}
+	names := map[string]bool{}
+	for i, spec := range f.Routes {
+		if spec.Name == "" || names[spec.Name] {
+			return fmt.Errorf("route %d: name %q is empty or repeated", i, spec.Name)
+		}
+		names[spec.Name] = true
+		if !strings.HasPrefix(spec.Path, "/") {
+			return fmt.Errorf("route %q: path must start with /", spec.Name)
+		}
+		pending, ok := upstreams[spec.Upstream]
+		if !ok {
+			return fmt.Errorf("route %q: unknown upstream %q", spec.Name, spec.Upstream)
+		}
+		timeout, err := parseDuration("timeout", spec.Timeout, 3*time.Second)
+		if err != nil {
+			return fmt.Errorf("route %q: %w", spec.Name, err)
+		}
+		if spec.MaxBodyBytes < 0 {
+			return fmt.Errorf("route %q: max_body_bytes must not be negative", spec.Name)
+		}
+		maxBody := spec.MaxBodyBytes
+		if maxBody == 0 {
+			maxBody = defaultMaxBodyBytes
+		}
+		switch spec.Auth.Mode {
+		case "", routeAuthRequired, routeAuthNone:
+		default:
+			return fmt.Errorf("route %q: auth mode must be %q or %q", spec.Name, routeAuthRequired, routeAuthNone)
+		}
+		headers := spec.RequestHeaders
+		if len(headers) == 0 {
+			headers = []string{"Accept", "Content-Type"}
+		}
+
+		up := pending.up
+		route := &proxyRoute{
+			name:       spec.Name,
+			pattern:    spec.Path,
+			upstream:   up,
+			timeout:    timeout,
+			maxBody:    maxBody,
+			public:     spec.Auth.Mode == routeAuthNone,
+			policy:     httpx.Policy{AnyRole: spec.Auth.AnyRole, AnyScope: spec.Auth.AnyScope, AllScopes: spec.Auth.AllScopes},
+			metricName: metricID(spec.Name),
+		}
+		for _, m := range spec.Methods {
+			route.methods = append(route.methods, strings.ToUpper(m))
+		}
+		route.proxy = newRouteProxy(pending.base, headers, up, l.metrics)
+		if c := spec.Canary; c != nil {
+			variant, ok := upstreams[c.Upstream]
+			if !ok {
+				return fmt.Errorf("route %q: unknown canary upstream %q", spec.Name, c.Upstream)
+			}
+			if c.Weight < 0 || c.Weight > 100 {
+				return fmt.Errorf("route %q: canary weight must be between 0 and 100", spec.Name)
+			}
+			route.canary = &routeCanary{
+				upstream: variant.up,
+				proxy:    newRouteProxy(variant.base, headers, variant.up, l.metrics),
+				weight:   c.Weight,
+				header:   c.Header,
+				cookie:   c.Cookie,
+			}
+		}
+		if m := spec.Mirror; m != nil {
+			shadow, ok := upstreams[m.Upstream]
+			if !ok {
+				return fmt.Errorf("route %q: unknown mirror upstream %q", spec.Name, m.Upstream)
+			}
+			if m.Upstream == spec.Upstream || f.Upstreams[m.Upstream].sameBackend(f.Upstreams[spec.Upstream]) {
+				return fmt.Errorf("route %q: mirror upstream %q is the route's own backend", spec.Name, m.Upstream)
+			}
+			if m.Percent < 0 || m.Percent > 100 {
+				return fmt.Errorf("route %q: mirror percent must be between 0 and 100", spec.Name)
+			}
+			percent := m.Percent
+			if percent == 0 {
+				percent = 100
+			}
+			shadowHeaders := append(slices.Clone(headers), "Idempotency-Key", shadowHeader)
+			route.mirror = &routeMirror{
+				upstream: shadow.up,
+				proxy:    newRouteProxy(shadow.base, shadowHeaders, shadow.up, l.metrics),
+				percent:  percent,
+				slots:    make(chan struct{}, maxMirrorsInFlight),
+			}
+		}
+		table.routes = append(table.routes, route)
+	}
+
+	for name := range l.upstreams {
+		if _, ok := upstreams[name]; !ok {
+			if stop, ok := l.stops[name]; ok {
+				stop()
+				delete(l.stops, name)
+			}
+			delete(l.upstreams, name)
+		}
+	}
+	for name, pending := range upstreams {
+		pending.up.pool.configure(pending.cfg)
+		if _, ok := l.upstreams[name]; !ok {
+			l.upstreams[name] = pending.up
+			if l.ctx != nil {
+				l.startPool(name, pending.up)
+			}
+		}
+	}
+	l.table.Store(table)
+	return nil
+}
+
+// loadFile loads path if it changed since the last successful load.
+func (l *routeLoader) loadFile(path string) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	l.mu.Lock()
+	unchanged := info.ModTime().Equal(l.modTime)
+	l.mu.Unlock()
+	if unchanged {
+		return false, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	var f routeFile
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		return false, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := l.load(f); err != nil {
+		return false, err
+	}
+	l.mu.Lock()
+	l.modTime = info.ModTime()
+	l.mu.Unlock()
+	return true, nil
+}
+
+// watch reloads path every interval until ctx is done. A bad file is logged
+// and counted, and the last good table keeps serving.
+func (l *routeLoader) watch(ctx context.Context, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := l.loadFile(path)
+			if err != nil {
+				l.metrics.Inc("routes_reload_errors_total")
+				l.log.Error().Err(err).Str("routes_file", path).Msg("route table reload failed; keeping previous table")
+				continue
+			}
+			if changed {
+				l.metrics.Inc("routes_reloads_total")
+				l.log.Info().Str("routes_file", path).Int("routes", len(l.table.Load().routes)).Msg("route table reloaded")
+			}
+		}
+	}
+}
+
+// resolve stores the matching route in the request context for the auth,
+// rate limit and proxy handlers that follow. Internal handlers are checked
+// first, so a broad route pattern cannot replace their policy. Paths that
+// match neither are answered here, before authentication.
+func (l *routeLoader) resolve(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, internal := internalPolicies.Lookup(r.Method, r.URL.Path); internal {
+			next.ServeHTTP(w, r)
+			return
+		}
+		route, pathMatched := l.table.Load().lookup(r.Method, r.URL.Path)
+		if route != nil {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), routeContextKey{}, route)))
+			return
+		}
+		if pathMatched {
+			httpx.MethodNotAllowed(w, r)
+			return
+		}
+		httpx.NotFound(w, r)
+	})
+}
+
+// ServeHTTP proxies a request resolved to a route.
+func (l *routeLoader) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	route, ok := routeFromContext(r.Context())
+	if !ok {
+		httpx.NotFound(w, r)
+		return
+	}
+
+	up, proxy, variant := route.upstream, route.proxy, variantStable
+	if route.canary != nil && route.canary.selected(r) {
+		up, proxy, variant = route.canary.upstream, route.canary.proxy, variantCanary
+	}
+	name := up.name
+	l.metrics.Inc(name + "_forward_requests_total")
+	if !httpx.LimitBody(w, r, route.maxBody) {
+		l.metrics.Inc(name + "_forward_errors_total")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), route.timeout)
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	// Keyed requests may be retried and mirrored requests are sent twice,
+	// both of which need a replayable body. They are bounded by maxBody;
+	// everything else is streamed.
+	var body []byte
+	if (r.Header.Get("Idempotency-Key") != "" || route.mirror != nil) && r.Body != nil && r.ContentLength != 0 {
+		var err error
+		body, err = io.ReadAll(r.Body)
+		if err != nil {
+			l.metrics.Inc(name + "_forward_errors_total")
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				httpx.Error(w, r, http.StatusRequestEntityTooLarge, httpx.CodeBodyTooLarge, "request body too large")
+				return
+			}
+			httpx.Error(w, r, http.StatusBadRequest, httpx.CodeInvalidRequest, "failed to read request body")
+			return
+		}
+		setBufferedBody(r, body)
+	}
+	if route.mirror != nil {
+		route.mirror.send(route.metricName, r, body, route.timeout, l.metrics)
+	}
+
+	if route.canary == nil && route.mirror == nil {
+		proxy.ServeHTTP(w, r)
+		return
+	}
+	serveVariant(proxy, w, r, route.metricName, variant, l.metrics)
+}
+
+// setBufferedBody makes body the replayable body of r.
+func setBufferedBody(r *http.Request, body []byte) {
+	if body == nil {
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+}
+
+func newRouteProxy(target *url.URL, allowedHeaders []string, up *upstream, metrics *metricsx.Registry) *httputil.ReverseProxy {
+	allowed := make([]string, 0, len(allowedHeaders))
+	for _, h := range allowedHeaders {
+		allowed = append(allowed, http.CanonicalHeaderKey(h))
+	}
+
+	return &httputil.ReverseProxy{
+		// Rewrite receives Out without hop-by-hop headers (RFC 9110 7.6.1
+		// and any listed in Connection) or X-Forwarded-*.
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			header := http.Header{}
+			for _, key := range allowed {
+				if v, ok := pr.Out.Header[key]; ok {
+					header[key] = v
+				}
+			}
+			if v := pr.In.Header.Get(requestIDHeader); v != "" {
+				header.Set(requestIDHeader, v)
+			}
+			// Keep the chain from the load balancer; SetXForwarded appends.
+			if v, ok := pr.In.Header["X-Forwarded-For"]; ok {
+				header["X-Forwarded-For"] = v
+			}
+			if p, ok := httpx.PrincipalFromContext(pr.In.Context()); ok {
+				p.SetHeaders(header)
+			}
+			pr.Out.Header = header
+			pr.SetXForwarded()
+		},
+		Transport: up,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			var openErr *circuitOpenError
+			var maxErr *http.MaxBytesError
+			switch {
+			case errors.As(err, &openErr):
+				w.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(openErr.retryIn.Seconds())))))
+				httpx.Error(w, r, http.StatusServiceUnavailable, httpx.CodeUpstreamUnavailable, "upstream unavailable")
+			case errors.Is(err, errNoEndpoints):
+				metrics.Inc(up.name + "_forward_errors_total")
+				httpx.Error(w, r, http.StatusServiceUnavailable, httpx.CodeUpstreamUnavailable, "upstream unavailable")
+			case errors.As(err, &maxErr):
+				metrics.Inc(up.name + "_forward_errors_total")
+				httpx.Error(w, r, http.StatusRequestEntityTooLarge, httpx.CodeBodyTooLarge, "request body too large")
+			case isTimeout(err) || errors.Is(r.Context().Err(), context.DeadlineExceeded):
+				metrics.Inc(up.name + "_forward_timeouts_total")
+				httpx.Error(w, r, http.StatusGatewayTimeout, httpx.CodeUpstreamTimeout, "upstream timeout")
+			default:
+				metrics.Inc(up.name + "_forward_errors_total")
+				httpx.Error(w, r, http.StatusBadGateway, httpx.CodeUpstreamError, "upstream request failed")
+			}
+		},
+	}
+}
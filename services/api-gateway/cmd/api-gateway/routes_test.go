@@ -0,0 +1,329 @@
+package main
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/triad-platform/triad-app/pkg/httpx"
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+)
+
+func TestGateway_RouteTable(t *testing.T) {
+	t.Parallel()
+
+	key := newTestRSAKey(t)
+	jwksFile := writeJWKSFile(t, map[string]*rsa.PrivateKey{"k1": key})
+
+	var captured *http.Request
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		captured = req
+		if req.URL.Path == "/v1/slow" {
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		}
+		var body []byte
+		if req.Body != nil {
+			var err error
+			if body, err = io.ReadAll(req.Body); err != nil {
+				return nil, err
+			}
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"X-Upstream": []string{"catalog"}, "Connection": []string{"X-Hop"}, "X-Hop": []string{"1"}},
+			Body:       io.NopCloser(strings.NewReader(string(body))),
+		}, nil
+	})
+	metrics := metricsx.NewRegistry("test")
+	routes := newRouteLoader(transport, upstreamConfig{}, metrics, zerolog.Nop())
+	err := routes.load(routeFile{
+		Upstreams: map[string]upstreamSpec{"catalog": {URL: "http://catalog:9000"}},
+		Routes: []routeSpec{
+			{Name: "catalog-public", Methods: []string{"get"}, Path: "/v1/catalog/*", Upstream: "catalog", RequestHeaders: []string{"X-Allowed"}, Auth: authSpec{Mode: routeAuthNone}},
+			{Name: "catalog-upload", Methods: []string{"POST"}, Path: "/v1/catalog/uploads", Upstream: "catalog", MaxBodyBytes: 8, Auth: authSpec{AnyRole: []string{"customer"}}},
+			{Name: "slow", Path: "/v1/slow", Upstream: "catalog", Timeout: "20ms", Auth: authSpec{Mode: routeAuthNone}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	r := newRouterWithConfig(gatewayConfig{
+		JWT:    jwtConfig{JWKSFile: jwksFile, Issuer: "pulsecart-auth", Audience: "pulsecart"},
+		Routes: routes,
+	}, metrics)
+	token := signTestToken(t, key, "k1", testClaims("u_1", time.Hour))
+
+	t.Run("public route streams with header allow-list", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/v1/catalog/skus?page=2", nil)
+		req.RemoteAddr = "10.0.0.1:5000"
+		req.Header.Set("X-Allowed", "yes")
+		req.Header.Set("X-Not-Allowed", "no")
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+		req.Header.Set(requestIDHeader, "req-1")
+		req.Header.Set(httpx.AuthenticatedUserHeader, "spoofed")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status code mismatch: got=%d want=%d body=%q", rec.Code, http.StatusOK, rec.Body.String())
+		}
+		if got, want := captured.URL.String(), "http://catalog:9000/v1/catalog/skus?page=2"; got != want {
+			t.Fatalf("upstream url mismatch: got=%q want=%q", got, want)
+		}
+		wantHeaders := map[string]string{
+			"X-Allowed":                   "yes",
+			"X-Not-Allowed":               "",
+			requestIDHeader:               "req-1",
+			"X-Forwarded-For":             "203.0.113.7, 10.0.0.1",
+			httpx.AuthenticatedUserHeader: "",
+		}
+		for name, want := range wantHeaders {
+			if got := captured.Header.Get(name); got != want {
+				t.Fatalf("upstream %s mismatch: got=%q want=%q", name, got, want)
+			}
+		}
+		if rec.Header().Get("X-Upstream") != "catalog" || rec.Header().Get("X-Hop") != "" {
+			t.Fatalf("response headers mismatch: %v", rec.Header())
+		}
+	})
+
+	t.Run("authenticated route needs a token", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/v1/catalog/uploads", strings.NewReader("tiny"))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("status code mismatch: got=%d want=%d", rec.Code, http.StatusUnauthorized)
+		}
+
+		req = httptest.NewRequest(http.MethodPost, "/v1/catalog/uploads", strings.NewReader("tiny"))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || rec.Body.String() != "tiny" {
+			t.Fatalf("authenticated mismatch: status=%d body=%q", rec.Code, rec.Body.String())
+		}
+		if got := captured.Header.Get(httpx.AuthenticatedUserHeader); got != "u_1" {
+			t.Fatalf("upstream user mismatch: got=%q want=%q", got, "u_1")
+		}
+	})
+
+	t.Run("body over the route limit", func(t *testing.T) {
+		for _, chunked := range []bool{false, true} {
+			req := httptest.NewRequest(http.MethodPost, "/v1/catalog/uploads", strings.NewReader("much too large"))
+			req.Header.Set("Content-Type", "application/json")
+			if chunked {
+				req.ContentLength = -1
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			if rec.Code != http.StatusRequestEntityTooLarge {
+				t.Fatalf("chunked=%v status code mismatch: got=%d want=%d", chunked, rec.Code, http.StatusRequestEntityTooLarge)
+			}
+		}
+	})
+
+	t.Run("route timeout", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/v1/slow", nil)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != http.StatusGatewayTimeout {
+			t.Fatalf("status code mismatch: got=%d want=%d", rec.Code, http.StatusGatewayTimeout)
+		}
+	})
+
+	t.Run("unknown path and wrong method", func(t *testing.T) {
+		for path, want := range map[string]int{"/v1/nothing": http.StatusNotFound, "/v1/catalog/uploads": http.StatusMethodNotAllowed} {
+			req := httptest.NewRequest(http.MethodDelete, path, nil)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			if rec.Code != want {
+				t.Fatalf("%s status code mismatch: got=%d want=%d", path, rec.Code, want)
+			}
+		}
+	})
+}
+
+func TestGateway_AdminCatalogRequiresAuth(t *testing.T) {
+	t.Parallel()
+
+	var upstreamCalls int
+	client := &http.Client{
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			upstreamCalls++
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(`[]`))}, nil
+		}),
+	}
+	r := newRouterWithConfig(gatewayConfig{
+		OrdersURL:       "http://orders:8081",
+		RequestTimeout:  time.Second,
+		UpstreamTimeout: time.Second,
+		Client:          client,
+	}, nil)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, adminCatalogPath+"/skus", nil))
+	if rec.Code != http.StatusNotFound || upstreamCalls != 0 {
+		t.Fatalf("admin catalog without auth mismatch: got=%d upstream_calls=%d want=%d", rec.Code, upstreamCalls, http.StatusNotFound)
+	}
+}
+
+func TestGateway_RoutesCannotOverrideInternalPolicies(t *testing.T) {
+	t.Parallel()
+
+	key := newTestRSAKey(t)
+	jwksFile := writeJWKSFile(t, map[string]*rsa.PrivateKey{"k1": key})
+	customer := signTestToken(t, key, "k1", testClaims("u_1", time.Hour))
+
+	var upstreamCalls []string
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		upstreamCalls = append(upstreamCalls, req.URL.Host+req.URL.Path)
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(`{}`))}, nil
+	})
+	metrics := metricsx.NewRegistry("test")
+	routes := newRouteLoader(transport, upstreamConfig{}, metrics, zerolog.Nop())
+	err := routes.load(routeFile{
+		Upstreams: map[string]upstreamSpec{"orders": {URL: "http://orders:8081"}},
+		Routes: []routeSpec{
+			{Name: "orders-all", Methods: []string{"GET"}, Path: "/v1/orders/*", Upstream: "orders", Auth: authSpec{AnyRole: []string{"customer"}}},
+			{Name: "dev-open", Methods: []string{"GET"}, Path: "/v1/dev/*", Upstream: "orders", Auth: authSpec{Mode: routeAuthNone}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	r := newRouterWithConfig(gatewayConfig{
+		JWT:                  jwtConfig{JWKSFile: jwksFile, Issuer: "pulsecart-auth", Audience: "pulsecart"},
+		Routes:               routes,
+		WorkerURL:            "http://worker:9091",
+		EnableDevDiagnostics: true,
+		Client:               &http.Client{Transport: transport},
+	}, metrics)
+
+	tests := []struct {
+		name       string
+		path       string
+		token      string
+		wantStatus int
+	}{
+		{name: "customer route cannot read processing records", path: "/v1/orders/o-1/processing", token: customer, wantStatus: http.StatusForbidden},
+		{name: "public route cannot open dev diagnostics", path: "/v1/dev/async-status", wantStatus: http.StatusUnauthorized},
+		{name: "route still serves its own paths", path: "/v1/orders/o-1", token: customer, wantStatus: http.StatusOK},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != tc.wantStatus {
+			t.Fatalf("%s: status code mismatch: got=%d want=%d body=%q", tc.name, rec.Code, tc.wantStatus, rec.Body.String())
+		}
+	}
+	if strings.Join(upstreamCalls, ",") != "orders:8081/v1/orders/o-1" {
+		t.Fatalf("upstream calls mismatch: got=%v", upstreamCalls)
+	}
+}
+
+func TestRouteLoader_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.json")
+	write := func(f any, mtime time.Time) {
+		raw, err := json.Marshal(f)
+		if err != nil {
+			t.Fatalf("marshal routes: %v", err)
+		}
+		if err := os.WriteFile(path, raw, 0o600); err != nil {
+			t.Fatalf("write routes: %v", err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+	}
+	t.Setenv("TEST_ORDERS_URL", "http://orders:8081")
+
+	routes := newRouteLoader(nil, upstreamConfig{}, metricsx.NewRegistry("test"), zerolog.Nop())
+	mtime := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	table := defaultRouteFile("${TEST_ORDERS_URL}", "", time.Second, true)
+	write(table, mtime)
+	if changed, err := routes.loadFile(path); err != nil || !changed {
+		t.Fatalf("initial load mismatch: changed=%v err=%v", changed, err)
+	}
+	if changed, err := routes.loadFile(path); err != nil || changed {
+		t.Fatalf("unchanged reload mismatch: changed=%v err=%v", changed, err)
+	}
+	first, _ := routes.table.Load().lookup(http.MethodPost, ordersPath)
+
+	// A new route is picked up and the orders upstream (and its breaker) kept.
+	table.Routes = append(table.Routes, routeSpec{Name: "orders-list", Methods: []string{http.MethodGet}, Path: ordersPath, Upstream: "orders"})
+	write(table, mtime.Add(time.Minute))
+	if changed, err := routes.loadFile(path); err != nil || !changed {
+		t.Fatalf("reload mismatch: changed=%v err=%v", changed, err)
+	}
+	list, _ := routes.table.Load().lookup(http.MethodGet, ordersPath)
+	if list == nil || list.upstream != first.upstream {
+		t.Fatalf("reloaded route mismatch: %+v", list)
+	}
+
+	// An invalid table is rejected and the previous one keeps serving.
+	write(map[string]any{"routes": []map[string]any{{"name": "bad", "path": "/v1/x", "upstream": "missing"}}}, mtime.Add(2*time.Minute))
+	if _, err := routes.loadFile(path); err == nil {
+		t.Fatal("expected invalid table error")
+	}
+	if route, _ := routes.table.Load().lookup(http.MethodGet, ordersPath); route != list {
+		t.Fatal("previous table should stay live after a failed reload")
+	}
+}
+
+func TestRouteLoader_Validation(t *testing.T) {
+	t.Parallel()
+
+	upstreams := map[string]upstreamSpec{"orders": {URL: "http://orders:8081"}}
+	tests := []struct {
+		name string
+		file routeFile
+	}{
+		{name: "bad upstream url", file: routeFile{Upstreams: map[string]upstreamSpec{"orders": {URL: "orders:8081"}}}},
+		{name: "unknown upstream", file: routeFile{Upstreams: upstreams, Routes: []routeSpec{{Name: "a", Path: "/v1/a", Upstream: "inventory"}}}},
+		{name: "duplicate name", file: routeFile{Upstreams: upstreams, Routes: []routeSpec{{Name: "a", Path: "/v1/a", Upstream: "orders"}, {Name: "a", Path: "/v1/b", Upstream: "orders"}}}},
+		{name: "relative path", file: routeFile{Upstreams: upstreams, Routes: []routeSpec{{Name: "a", Path: "v1/a", Upstream: "orders"}}}},
+		{name: "bad timeout", file: routeFile{Upstreams: upstreams, Routes: []routeSpec{{Name: "a", Path: "/v1/a", Upstream: "orders", Timeout: "soon"}}}},
+		{name: "negative body limit", file: routeFile{Upstreams: upstreams, Routes: []routeSpec{{Name: "a", Path: "/v1/a", Upstream: "orders", MaxBodyBytes: -1}}}},
+		{name: "url and endpoints", file: routeFile{Upstreams: map[string]upstreamSpec{"orders": {URL: "http://a:8081", Endpoints: []string{"http://b:8081"}}}}},
+		{name: "endpoint paths differ", file: routeFile{Upstreams: map[string]upstreamSpec{"orders": {Endpoints: []string{"http://a:8081/v1", "http://b:8081"}}}}},
+		{name: "unknown balancer", file: routeFile{Upstreams: map[string]upstreamSpec{"orders": {URL: "http://a:8081", Balancer: "random"}}}},
+		{name: "bad health check interval", file: routeFile{Upstreams: map[string]upstreamSpec{"orders": {URL: "http://a:8081", HealthCheck: healthCheckSpec{Interval: "often"}}}}},
+		{name: "unknown canary upstream", file: routeFile{Upstreams: upstreams, Routes: []routeSpec{{Name: "a", Path: "/v1/a", Upstream: "orders", Canary: &canarySpec{Upstream: "orders-canary"}}}}},
+		{name: "canary weight over 100", file: routeFile{Upstreams: upstreams, Routes: []routeSpec{{Name: "a", Path: "/v1/a", Upstream: "orders", Canary: &canarySpec{Upstream: "orders", Weight: 101}}}}},
+		{name: "unknown mirror upstream", file: routeFile{Upstreams: upstreams, Routes: []routeSpec{{Name: "a", Path: "/v1/a", Upstream: "orders", Mirror: &mirrorSpec{Upstream: "orders-shadow"}}}}},
+		{name: "mirror to own upstream", file: routeFile{Upstreams: upstreams, Routes: []routeSpec{{Name: "a", Path: "/v1/a", Upstream: "orders", Mirror: &mirrorSpec{Upstream: "orders"}}}}},
+		{name: "mirror to same backend", file: routeFile{Upstreams: map[string]upstreamSpec{"orders": {URL: "http://orders:8081"}, "orders-shadow": {URL: "http://orders:8081"}}, Routes: []routeSpec{{Name: "a", Path: "/v1/a", Upstream: "orders", Mirror: &mirrorSpec{Upstream: "orders-shadow"}}}}},
+		{name: "unknown auth mode", file: routeFile{Upstreams: upstreams, Routes: []routeSpec{{Name: "a", Path: "/v1/a", Upstream: "orders", Auth: authSpec{Mode: "optional"}}}}},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			routes := newRouteLoader(nil, upstreamConfig{}, metricsx.NewRegistry("test"), zerolog.Nop())
+			if err := routes.load(tc.file); err == nil {
+				t.Fatal("expected validation error")
+			}
+			if routes.table.Load() != nil {
+				t.Fatal("invalid table should not be stored")
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+)
+
+// upstreamConfig holds the breaker and retry settings shared by upstreams.
+// Zero fields take the defaults noted.
+type upstreamConfig struct {
+	Breaker breakerConfig
+	// MaxAttempts per request, including the first (3).
+	MaxAttempts int
+	// RetryBackoff before the first retry, doubled for each later one and
+	// jittered (50ms).
+	RetryBackoff time.Duration
+	// RetryBudgetRatio of requests that may be retried (0.2), with a floor of
+	// RetryMinPerWindow (10) retries per breaker window.
+	RetryBudgetRatio  float64
+	RetryMinPerWindow int
+}
+
+func (c upstreamConfig) withDefaults() upstreamConfig {
+	c.Breaker = c.Breaker.withDefaults()
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 3
+	}
+	if c.RetryBackoff <= 0 {
+		c.RetryBackoff = 50 * time.Millisecond
+	}
+	if c.RetryBudgetRatio <= 0 {
+		c.RetryBudgetRatio = 0.2
+	}
+	if c.RetryMinPerWindow <= 0 {
+		c.RetryMinPerWindow = 10
+	}
+	return c
+}
+
+// upstream sends requests to one backend through its circuit breaker,
+// spreading them over the backend's endpoints. It is the Transport of every
+// proxy route pointing at that backend, and survives route table reloads so
+// breaker and endpoint state are kept.
+type upstream struct {
+	name      string
+	transport http.RoundTripper
+	pool      *endpointPool
+	breaker   *circuitBreaker
+	budget    *retryBudget
+	cfg       upstreamConfig
+	metrics   *metricsx.Registry
+}
+
+func newUpstream(name string, transport http.RoundTripper, cfg upstreamConfig, metrics *metricsx.Registry, log zerolog.Logger) *upstream {
+	cfg = cfg.withDefaults()
+	return &upstream{
+		name:      name,
+		transport: transport,
+		pool:      newEndpointPool(name, metrics, log),
+		breaker:   newCircuitBreaker(name, cfg.Breaker, metrics),
+		budget:    newRetryBudget(cfg.RetryBudgetRatio, cfg.RetryMinPerWindow, cfg.Breaker.Window),
+		cfg:       cfg,
+		metrics:   metrics,
+	}
+}
+
+// RoundTrip retries requests that carry an Idempotency-Key and have a
+// replayable body (see do).
+func (u *upstream) RoundTrip(req *http.Request) (*http.Response, error) {
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	retryable := req.Header.Get("Idempotency-Key") != "" && replayable
+
+	first := true
+	newReq := func() (*http.Request, error) {
+		if first {
+			first = false
+			return req, nil
+		}
+		retry := req.Clone(req.Context())
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			retry.Body = body
+		}
+		return retry, nil
+	}
+	return u.do(req.Context(), newReq, retryable)
+}
+
+// do sends the request built by newReq to an endpoint picked from the pool,
+// preferring a different one on each retry. Connection errors are retried
+// only when retryable is set, which callers do for requests that are safe to
+// repeat, and only while the retry budget lasts. Timeouts are never retried:
+// the upstream may still be working on the first attempt. A 5xx response or
+// a transport error counts as a breaker and outlier failure; an open circuit
+// returns a *circuitOpenError without sending anything.
+func (u *upstream) do(ctx context.Context, newReq func() (*http.Request, error), retryable bool) (*http.Response, error) {
+	u.budget.recordRequest()
+	var tried *endpoint
+	for attempt := 1; ; attempt++ {
+		done, err := u.breaker.allow()
+		if err != nil {
+			u.metrics.Inc(u.name + "_circuit_breaker_rejected_total")
+			return nil, err
+		}
+		req, err := newReq()
+		if err != nil {
+			done(true)
+			return nil, err
+		}
+		ep, err := u.pool.pick(tried)
+		if err != nil {
+			done(false)
+			return nil, err
+		}
+		tried = ep
+		target := *req.URL
+		target.Scheme, target.Host = ep.url.Scheme, ep.url.Host
+		req.URL = &target
+
+		finish := u.pool.begin(ep)
+		resp, err := u.transport.RoundTrip(req)
+		success := err == nil && resp.StatusCode < http.StatusInternalServerError
+		done(success)
+		if err == nil {
+			resp.Body = &endpointBody{ReadCloser: resp.Body, success: success, done: finish}
+			return resp, nil
+		}
+		finish(false)
+
+		if !retryable || attempt >= u.cfg.MaxAttempts || isTimeout(err) || ctx.Err() != nil {
+			return nil, err
+		}
+		if !u.budget.tryRetry() {
+			u.metrics.Inc(u.name + "_retry_budget_exhausted_total")
+			return nil, err
+		}
+		u.metrics.Inc(u.name + "_forward_retries_total")
+
+		backoff := u.cfg.RetryBackoff << (attempt - 1)
+		backoff = backoff/2 + rand.N(backoff/2+1)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, err
+		}
+	}
+}
+
+func isTimeout(err error) bool {
+	var netErr interface{ Timeout() bool }
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
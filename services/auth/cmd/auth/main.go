@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/triad-platform/triad-app/pkg/config"
+	"github.com/triad-platform/triad-app/pkg/httpx"
+	"github.com/triad-platform/triad-app/pkg/logx"
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+	"github.com/triad-platform/triad-app/services/auth/internal/auth"
+)
+
+func main() {
+	log := logx.New()
+	metrics := metricsx.NewRegistry("triad_auth")
+
+	port := config.Getenv("PORT", "8084")
+
+	var keys *auth.KeySet
+	var err error
+	if dir := strings.TrimSpace(config.Getenv("AUTH_KEYS_DIR", "")); dir != "" {
+		keys, err = auth.LoadKeySet(dir, strings.TrimSpace(config.Getenv("AUTH_ACTIVE_KID", "")))
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to load signing keys")
+		}
+	} else {
+		keys, err = auth.GenerateKeySet("dev-ephemeral")
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to generate signing key")
+		}
+		log.Warn().Msg("AUTH_KEYS_DIR not set; signing with an ephemeral key, tokens will not survive a restart")
+	}
+	log.Info().Str("kid", keys.ActiveKID()).Msg("signing key loaded")
+
+	dbPool, err := pgxpool.New(context.Background(), databaseURL())
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to connect to Postgres")
+	}
+	defer dbPool.Close()
+
+	store := auth.NewPostgresStore(dbPool)
+	schemaCtx, schemaCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if err := store.EnsureSchema(schemaCtx); err != nil {
+		schemaCancel()
+		log.Fatal().Err(err).Msg("failed to ensure auth schema")
+	}
+	schemaCancel()
+
+	r := chi.NewRouter()
+	r.Get("/healthz", httpx.Healthz)
+	r.Get("/readyz", httpx.Readyz)
+	r.Get("/metrics", metrics.Handler().ServeHTTP)
+
+	h := &auth.Handler{
+		Store:   store,
+		APIKeys: store,
+		Tokens: &auth.TokenIssuer{
+			Keys:      keys,
+			Issuer:    config.Getenv("AUTH_ISSUER", "pulsecart-auth"),
+			Audience:  config.Getenv("AUTH_AUDIENCE", "pulsecart"),
+			AccessTTL: durationEnv("ACCESS_TOKEN_TTL", 15*time.Minute),
+		},
+		Metrics:    metrics,
+		RefreshTTL: durationEnv("REFRESH_TOKEN_TTL", 720*time.Hour),
+		Log:        log,
+	}
+	r.Mount("/", auth.Routes(h))
+
+	srv := &http.Server{
+		Addr:              ":" + port,
+		Handler:           r,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		log.Info().Msgf("auth listening on :%s", port)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal().Err(err).Msg("server failed")
+		}
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	<-stop
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_ = srv.Shutdown(ctx)
+	log.Info().Msg("auth shutdown complete")
+}
+
+func durationEnv(key string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(config.Getenv(key, fallback.String()))
+	if err != nil || d <= 0 {
+		return fallback
+	}
+	return d
+}
+
+func databaseURL() string {
+	if explicit := strings.TrimSpace(config.Getenv("DATABASE_URL", "")); explicit != "" {
+		return explicit
+	}
+
+	host := config.Getenv("DB_HOST", "localhost")
+	port := config.Getenv("DB_PORT", "5432")
+	name := config.Getenv("DB_NAME", "pulsecart")
+	user := config.Getenv("DB_USER", "pulsecart")
+	password := config.Getenv("DB_PASSWORD", "pulsecart")
+	sslmode := config.Getenv("DB_SSLMODE", "disable")
+
+	return (&url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(user, password),
+		Host:     net.JoinHostPort(host, port),
+		Path:     name,
+		RawQuery: "sslmode=" + url.QueryEscape(sslmode),
+	}).String()
+}
@@ -0,0 +1,240 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/triad-platform/triad-app/pkg/httpx"
+)
+
+type APIKeyStore interface {
+	CreateAPIKey(ctx context.Context, key APIKey) (APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, hash string) (APIKey, error)
+	RevokeAPIKey(ctx context.Context, id string) (APIKey, error)
+	RotateAPIKey(ctx context.Context, id string, next APIKey, grace time.Duration) (APIKey, error)
+}
+
+type adminContextKey struct{}
+
+// requireAdmin admits callers presenting an access token with the admin role.
+func (h *Handler) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw := strings.TrimSpace(r.Header.Get("Authorization"))
+		scheme, token, _ := strings.Cut(raw, " ")
+		if !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+			httpx.AuditDenied(h.Log, r, httpx.Principal{}, "missing bearer token")
+			w.Header().Set("WWW-Authenticate", `Bearer realm="pulsecart"`)
+			httpx.Error(w, r, http.StatusUnauthorized, httpx.CodeUnauthenticated, "missing bearer token")
+			return
+		}
+		claims, err := h.Tokens.VerifyAccessToken(strings.TrimSpace(token))
+		if err != nil {
+			httpx.AuditDenied(h.Log, r, httpx.Principal{}, "invalid token")
+			w.Header().Set("WWW-Authenticate", `Bearer realm="pulsecart", error="invalid_token"`)
+			httpx.Error(w, r, http.StatusUnauthorized, httpx.CodeUnauthenticated, "invalid bearer token")
+			return
+		}
+		principal := httpx.Principal{Subject: claims.Subject, Roles: claims.Roles}
+		if !principal.HasRole(RoleAdmin) {
+			httpx.AuditDenied(h.Log, r, principal, "missing role: admin")
+			httpx.Error(w, r, http.StatusForbidden, httpx.CodeForbidden, "missing role: admin")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), adminContextKey{}, claims.Subject)))
+	})
+}
+
+func adminSubject(ctx context.Context) string {
+	subject, _ := ctx.Value(adminContextKey{}).(string)
+	return subject
+}
+
+func (h *Handler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if h.APIKeys == nil {
+		httpx.Error(w, r, http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "api keys not configured")
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.inc("auth_validation_errors_total")
+		httpx.Error(w, r, http.StatusBadRequest, httpx.CodeInvalidJSON, "invalid json")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if fields := validateCreateAPIKey(req, time.Now()); len(fields) > 0 {
+		h.inc("auth_validation_errors_total")
+		httpx.ValidationError(w, r, "invalid api key", fields)
+		return
+	}
+
+	secret, hash := newAPIKey()
+	key, err := h.APIKeys.CreateAPIKey(r.Context(), APIKey{
+		ID:        newID(),
+		Name:      req.Name,
+		Subject:   "partner_" + newID(),
+		Prefix:    apiKeyPrefix(secret),
+		Hash:      hash,
+		Scopes:    req.Scopes,
+		CreatedBy: adminSubject(r.Context()),
+		ExpiresAt: req.ExpiresAt,
+	})
+	if err != nil {
+		h.inc("auth_store_errors_total")
+		httpx.Error(w, r, http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "api key creation failed")
+		return
+	}
+
+	h.inc("auth_api_keys_created_total")
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusCreated, APIKeyWithSecret{APIKey: key, Key: secret})
+}
+
+func (h *Handler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if h.APIKeys == nil {
+		httpx.Error(w, r, http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "api keys not configured")
+		return
+	}
+	keys, err := h.APIKeys.ListAPIKeys(r.Context())
+	if err != nil {
+		h.inc("auth_store_errors_total")
+		httpx.Error(w, r, http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "api key listing failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, keys)
+}
+
+func (h *Handler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	if h.APIKeys == nil {
+		httpx.Error(w, r, http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "api keys not configured")
+		return
+	}
+	key, err := h.APIKeys.RevokeAPIKey(r.Context(), chi.URLParam(r, "keyID"))
+	if errors.Is(err, ErrNotFound) {
+		httpx.Error(w, r, http.StatusNotFound, httpx.CodeNotFound, "api key not found")
+		return
+	}
+	if err != nil {
+		h.inc("auth_store_errors_total")
+		httpx.Error(w, r, http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "api key revocation failed")
+		return
+	}
+	h.inc("auth_api_keys_revoked_total")
+	writeJSON(w, http.StatusOK, key)
+}
+
+func (h *Handler) RotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if h.APIKeys == nil {
+		httpx.Error(w, r, http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "api keys not configured")
+		return
+	}
+
+	var req RotateAPIKeyRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.inc("auth_validation_errors_total")
+			httpx.Error(w, r, http.StatusBadRequest, httpx.CodeInvalidJSON, "invalid json")
+			return
+		}
+	}
+	var grace time.Duration
+	if req.GracePeriod != "" {
+		d, err := time.ParseDuration(req.GracePeriod)
+		if err != nil || d < 0 {
+			h.inc("auth_validation_errors_total")
+			httpx.ValidationError(w, r, "invalid rotation", []httpx.FieldError{{Field: "grace_period", Code: httpx.FieldInvalid}})
+			return
+		}
+		grace = d
+	}
+
+	secret, hash := newAPIKey()
+	key, err := h.APIKeys.RotateAPIKey(r.Context(), chi.URLParam(r, "keyID"), APIKey{
+		ID:        newID(),
+		Prefix:    apiKeyPrefix(secret),
+		Hash:      hash,
+		CreatedBy: adminSubject(r.Context()),
+	}, grace)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		httpx.Error(w, r, http.StatusNotFound, httpx.CodeNotFound, "api key not found")
+		return
+	case errors.Is(err, ErrAPIKeyInactive):
+		httpx.Error(w, r, http.StatusConflict, codeAPIKeyInactive, err.Error())
+		return
+	case err != nil:
+		h.inc("auth_store_errors_total")
+		httpx.Error(w, r, http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "api key rotation failed")
+		return
+	}
+
+	h.inc("auth_api_keys_rotated_total")
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusCreated, APIKeyWithSecret{APIKey: key, Key: secret})
+}
+
+func validateCreateAPIKey(req CreateAPIKeyRequest, now time.Time) []httpx.FieldError {
+	var fields []httpx.FieldError
+	if req.Name == "" {
+		fields = append(fields, httpx.FieldError{Field: "name", Code: httpx.FieldRequired})
+	}
+	if len(req.Scopes) == 0 {
+		fields = append(fields, httpx.FieldError{Field: "scopes", Code: httpx.FieldRequired})
+	}
+	for i, scope := range req.Scopes {
+		if !knownScopes[scope] {
+			fields = append(fields, httpx.FieldError{Field: fmt.Sprintf("scopes[%d]", i), Code: httpx.FieldUnsupported})
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		fields = append(fields, httpx.FieldError{Field: "expires_at", Code: httpx.FieldOutOfRange})
+	}
+	return fields
+}
+
+// IntrospectAPIKey resolves a presented key for the gateway. Unknown, revoked
+// and expired keys all answer {"active":false}.
+func (h *Handler) IntrospectAPIKey(w http.ResponseWriter, r *http.Request) {
+	if h.APIKeys == nil {
+		httpx.Error(w, r, http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "api keys not configured")
+		return
+	}
+	h.inc("auth_api_key_introspections_total")
+
+	var req IntrospectAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.APIKey == "" {
+		h.inc("auth_validation_errors_total")
+		httpx.ValidationError(w, r, "missing api_key", []httpx.FieldError{{Field: "api_key", Code: httpx.FieldRequired}})
+		return
+	}
+
+	key, err := h.APIKeys.GetAPIKeyByHash(r.Context(), hashAPIKey(req.APIKey))
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		h.inc("auth_store_errors_total")
+		httpx.Error(w, r, http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "api key lookup failed")
+		return
+	}
+	if errors.Is(err, ErrNotFound) || !key.Active(time.Now()) {
+		writeJSON(w, http.StatusOK, IntrospectAPIKeyResponse{Active: false})
+		return
+	}
+	writeJSON(w, http.StatusOK, IntrospectAPIKeyResponse{
+		Active:    true,
+		KeyID:     key.ID,
+		Subject:   key.Subject,
+		Scopes:    key.Scopes,
+		ExpiresAt: key.ExpiresAt,
+	})
+}
+
+// apiKeyPrefix is the part of a key that is safe to show in listings.
+func apiKeyPrefix(key string) string {
+	return key[:12]
+}
@@ -0,0 +1,271 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAPIKeyManagementRequiresAdmin(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t, newStubStore())
+	h.APIKeys = newStubAPIKeyStore()
+	customer := tokenWithRole(t, h, RoleCustomer)
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+	}{
+		{name: "no token", wantStatus: http.StatusUnauthorized},
+		{name: "garbage token", authorization: "Bearer nope", wantStatus: http.StatusUnauthorized},
+		{name: "customer token", authorization: "Bearer " + customer, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tc := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/v1/auth/api-keys", nil)
+		if tc.authorization != "" {
+			req.Header.Set("Authorization", tc.authorization)
+		}
+		rec := httptest.NewRecorder()
+		Routes(h).ServeHTTP(rec, req)
+		if rec.Code != tc.wantStatus {
+			t.Fatalf("%s: status code mismatch: got=%d want=%d", tc.name, rec.Code, tc.wantStatus)
+		}
+	}
+}
+
+func TestCreateAPIKeyValidation(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t, newStubStore())
+	h.APIKeys = newStubAPIKeyStore()
+	admin := tokenWithRole(t, h, RoleAdmin)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "missing name", body: `{"scopes":["orders:read"]}`, wantStatus: http.StatusBadRequest, wantBody: `{"field":"name","code":"required"}`},
+		{name: "missing scopes", body: `{"name":"acme"}`, wantStatus: http.StatusBadRequest, wantBody: `{"field":"scopes","code":"required"}`},
+		{name: "unknown scope", body: `{"name":"acme","scopes":["orders:delete"]}`, wantStatus: http.StatusBadRequest, wantBody: `{"field":"scopes[0]","code":"unsupported"}`},
+		{name: "expiry in the past", body: `{"name":"acme","scopes":["orders:read"],"expires_at":"2001-01-01T00:00:00Z"}`, wantStatus: http.StatusBadRequest, wantBody: `{"field":"expires_at","code":"out_of_range"}`},
+		{name: "valid", body: `{"name":"acme","scopes":["orders:read","orders:write"]}`, wantStatus: http.StatusCreated},
+	}
+
+	for _, tc := range tests {
+		rec := adminRequest(t, h, admin, http.MethodPost, "/v1/auth/api-keys", tc.body)
+		if rec.Code != tc.wantStatus {
+			t.Fatalf("%s: status code mismatch: got=%d want=%d body=%q", tc.name, rec.Code, tc.wantStatus, rec.Body.String())
+		}
+		if !strings.Contains(rec.Body.String(), tc.wantBody) {
+			t.Fatalf("%s: body mismatch: got=%q want substring %q", tc.name, rec.Body.String(), tc.wantBody)
+		}
+	}
+}
+
+func TestAPIKeyLifecycle(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t, newStubStore())
+	store := newStubAPIKeyStore()
+	h.APIKeys = store
+	admin := tokenWithRole(t, h, RoleAdmin)
+
+	rec := adminRequest(t, h, admin, http.MethodPost, "/v1/auth/api-keys", `{"name":"acme","scopes":["orders:write"]}`)
+	var created APIKeyWithSecret
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("decode created key: %v", err)
+	}
+	if !strings.HasPrefix(created.Key, "pck_") || created.Prefix != created.Key[:12] || created.CreatedBy != "u_test" {
+		t.Fatalf("created key mismatch: %+v", created)
+	}
+	if stored := store.keys[created.ID]; stored.Hash == created.Key || stored.Hash != hashAPIKey(created.Key) {
+		t.Fatal("store must hold the key hash, not the key")
+	}
+
+	intro := introspect(t, h, created.Key)
+	if !intro.Active || intro.Subject != created.Subject || !strings.HasPrefix(created.Subject, "partner_") || len(intro.Scopes) != 1 || intro.Scopes[0] != "orders:write" {
+		t.Fatalf("introspection mismatch: %+v", intro)
+	}
+
+	// Listing never shows the key itself.
+	rec = adminRequest(t, h, admin, http.MethodGet, "/v1/auth/api-keys", "")
+	if strings.Contains(rec.Body.String(), created.Key) || strings.Contains(rec.Body.String(), store.keys[created.ID].Hash) {
+		t.Fatalf("listing leaked key material: %s", rec.Body.String())
+	}
+
+	rec = adminRequest(t, h, admin, http.MethodPost, "/v1/auth/api-keys/"+created.ID+"/rotate", `{"grace_period":"1h"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("rotate status mismatch: got=%d want=%d body=%q", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+	var rotated APIKeyWithSecret
+	if err := json.Unmarshal(rec.Body.Bytes(), &rotated); err != nil {
+		t.Fatalf("decode rotated key: %v", err)
+	}
+	if rotated.Key == created.Key || rotated.Name != "acme" || rotated.Subject != created.Subject {
+		t.Fatalf("rotated key mismatch: %+v", rotated)
+	}
+	if !introspect(t, h, created.Key).Active || !introspect(t, h, rotated.Key).Active {
+		t.Fatal("both keys should be active during the grace period")
+	}
+
+	rec = adminRequest(t, h, admin, http.MethodDelete, "/v1/auth/api-keys/"+created.ID, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("revoke status mismatch: got=%d want=%d", rec.Code, http.StatusOK)
+	}
+	if introspect(t, h, created.Key).Active {
+		t.Fatal("revoked key should be inactive")
+	}
+	rec = adminRequest(t, h, admin, http.MethodPost, "/v1/auth/api-keys/"+created.ID+"/rotate", "")
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("rotating a revoked key: got=%d want=%d", rec.Code, http.StatusConflict)
+	}
+	rec = adminRequest(t, h, admin, http.MethodDelete, "/v1/auth/api-keys/missing", "")
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("revoke missing key: got=%d want=%d", rec.Code, http.StatusNotFound)
+	}
+	if introspect(t, h, "pck_unknown").Active {
+		t.Fatal("unknown key should be inactive")
+	}
+}
+
+func TestAPIKeyActive(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Second), now.Add(time.Second)
+	tests := []struct {
+		name string
+		key  APIKey
+		want bool
+	}{
+		{name: "no expiry", key: APIKey{}, want: true},
+		{name: "future expiry", key: APIKey{ExpiresAt: &future}, want: true},
+		{name: "expired", key: APIKey{ExpiresAt: &past}},
+		{name: "revoked", key: APIKey{RevokedAt: &past}},
+	}
+	for _, tc := range tests {
+		if got := tc.key.Active(now); got != tc.want {
+			t.Fatalf("%s: active mismatch: got=%v want=%v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func tokenWithRole(t *testing.T, h *Handler, role string) string {
+	t.Helper()
+
+	token, _, err := h.Tokens.IssueAccessToken(User{ID: "u_test", Email: "ops@example.com", Roles: []string{role}})
+	if err != nil {
+		t.Fatalf("IssueAccessToken() error = %v", err)
+	}
+	return token
+}
+
+func adminRequest(t *testing.T, h *Handler, token, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	Routes(h).ServeHTTP(rec, req)
+	return rec
+}
+
+func introspect(t *testing.T, h *Handler, key string) IntrospectAPIKeyResponse {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	Routes(h).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/auth/api-keys/introspect", strings.NewReader(`{"api_key":"`+key+`"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("introspect status mismatch: got=%d want=%d", rec.Code, http.StatusOK)
+	}
+	var resp IntrospectAPIKeyResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode introspection: %v", err)
+	}
+	return resp
+}
+
+type stubAPIKeyStore struct {
+	mu   sync.Mutex
+	keys map[string]APIKey
+}
+
+func newStubAPIKeyStore() *stubAPIKeyStore {
+	return &stubAPIKeyStore{keys: map[string]APIKey{}}
+}
+
+func (s *stubAPIKeyStore) CreateAPIKey(_ context.Context, key APIKey) (APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key.CreatedAt = time.Now()
+	s.keys[key.ID] = key
+	return key, nil
+}
+
+func (s *stubAPIKeyStore) ListAPIKeys(_ context.Context) ([]APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]APIKey, 0, len(s.keys))
+	for _, k := range s.keys {
+		out = append(out, k)
+	}
+	return out, nil
+}
+
+func (s *stubAPIKeyStore) GetAPIKeyByHash(_ context.Context, hash string) (APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range s.keys {
+		if k.Hash == hash {
+			return k, nil
+		}
+	}
+	return APIKey{}, ErrNotFound
+}
+
+func (s *stubAPIKeyStore) RevokeAPIKey(_ context.Context, id string) (APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.keys[id]
+	if !ok {
+		return APIKey{}, ErrNotFound
+	}
+	if k.RevokedAt == nil {
+		now := time.Now()
+		k.RevokedAt = &now
+		s.keys[id] = k
+	}
+	return k, nil
+}
+
+func (s *stubAPIKeyStore) RotateAPIKey(_ context.Context, id string, next APIKey, grace time.Duration) (APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.keys[id]
+	if !ok {
+		return APIKey{}, ErrNotFound
+	}
+	if !old.Active(time.Now()) {
+		return APIKey{}, ErrAPIKeyInactive
+	}
+	next.Name, next.Subject, next.Scopes, next.ExpiresAt, next.CreatedAt = old.Name, old.Subject, old.Scopes, old.ExpiresAt, time.Now()
+	s.keys[next.ID] = next
+	cutoff := time.Now().Add(grace)
+	if grace > 0 {
+		old.ExpiresAt = &cutoff
+	} else {
+		old.RevokedAt = &cutoff
+	}
+	s.keys[id] = old
+	return next, nil
+}
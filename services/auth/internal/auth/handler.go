@@ -0,0 +1,295 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog"
+	"github.com/triad-platform/triad-app/pkg/httpx"
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Problem codes for auth errors. Codes shared by every service live in httpx.
+const (
+	codeEmailTaken          = "email_taken"
+	codeInvalidCredentials  = "invalid_credentials"
+	codeInvalidRefreshToken = "invalid_refresh_token"
+	codeAPIKeyInactive      = "api_key_inactive"
+)
+
+const (
+	minPasswordLen = 8
+	// bcrypt ignores everything past 72 bytes, so longer passwords are refused
+	// rather than silently truncated.
+	maxPasswordLen = 72
+)
+
+type Store interface {
+	CreateUser(ctx context.Context, user User) (User, error)
+	GetUserByEmail(ctx context.Context, email string) (User, error)
+	GetUser(ctx context.Context, id string) (User, error)
+	CreateRefreshToken(ctx context.Context, token RefreshToken) error
+	RotateRefreshToken(ctx context.Context, oldHash string, next RefreshToken) (string, error)
+	RevokeRefreshFamily(ctx context.Context, hash string) error
+}
+
+type Handler struct {
+	Store      Store
+	APIKeys    APIKeyStore
+	Tokens     *TokenIssuer
+	Metrics    *metricsx.Registry
+	RefreshTTL time.Duration
+	BcryptCost int
+	// Log receives audit lines for denied admin requests.
+	Log zerolog.Logger
+}
+
+func Routes(h *Handler) chi.Router {
+	r := chi.NewRouter()
+	r.Post("/v1/auth/register", h.Register)
+	r.Post("/v1/auth/login", h.Login)
+	r.Post("/v1/auth/refresh", h.Refresh)
+	r.Post("/v1/auth/logout", h.Logout)
+	r.Get("/.well-known/jwks.json", h.JWKS)
+	r.Post("/v1/auth/api-keys/introspect", h.IntrospectAPIKey)
+	r.Group(func(r chi.Router) {
+		r.Use(h.requireAdmin)
+		r.Post("/v1/auth/api-keys", h.CreateAPIKey)
+		r.Get("/v1/auth/api-keys", h.ListAPIKeys)
+		r.Delete("/v1/auth/api-keys/{keyID}", h.RevokeAPIKey)
+		r.Post("/v1/auth/api-keys/{keyID}/rotate", h.RotateAPIKey)
+	})
+	return r
+}
+
+func (h *Handler) refreshTTL() time.Duration {
+	if h.RefreshTTL <= 0 {
+		return 30 * 24 * time.Hour
+	}
+	return h.RefreshTTL
+}
+
+func (h *Handler) bcryptCost() int {
+	if h.BcryptCost == 0 {
+		return bcrypt.DefaultCost
+	}
+	return h.BcryptCost
+}
+
+func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
+	h.inc("auth_register_requests_total")
+
+	req, ok := h.decodeCredentials(w, r)
+	if !ok {
+		return
+	}
+	if len(req.Password) < minPasswordLen || len(req.Password) > maxPasswordLen {
+		h.inc("auth_validation_errors_total")
+		httpx.ValidationError(w, r, "invalid registration", []httpx.FieldError{{Field: "password", Code: httpx.FieldOutOfRange}})
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), h.bcryptCost())
+	if err != nil {
+		httpx.Error(w, r, http.StatusInternalServerError, httpx.CodeInternal, "registration failed")
+		return
+	}
+	user, err := h.Store.CreateUser(r.Context(), User{
+		ID:           newID(),
+		Email:        req.Email,
+		PasswordHash: string(hash),
+		Roles:        []string{RoleCustomer},
+	})
+	if errors.Is(err, ErrEmailTaken) {
+		h.inc("auth_register_conflicts_total")
+		httpx.Error(w, r, http.StatusConflict, codeEmailTaken, err.Error())
+		return
+	}
+	if err != nil {
+		h.inc("auth_store_errors_total")
+		httpx.Error(w, r, http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "registration failed")
+		return
+	}
+
+	h.inc("auth_users_registered_total")
+	writeJSON(w, http.StatusCreated, user)
+}
+
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() { h.observeDuration("auth_login_duration", time.Since(start)) }()
+	h.inc("auth_login_requests_total")
+
+	req, ok := h.decodeCredentials(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.Store.GetUserByEmail(r.Context(), req.Email)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		h.inc("auth_store_errors_total")
+		httpx.Error(w, r, http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "login failed")
+		return
+	}
+	hash := user.PasswordHash
+	if errors.Is(err, ErrNotFound) {
+		// Compare against a fixed hash so unknown emails take as long as wrong
+		// passwords and cannot be enumerated by timing.
+		hash = dummyPasswordHash
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(req.Password)) != nil || errors.Is(err, ErrNotFound) {
+		h.inc("auth_login_failures_total")
+		httpx.Error(w, r, http.StatusUnauthorized, codeInvalidCredentials, "invalid credentials")
+		return
+	}
+
+	refresh, refreshHash := newRefreshToken()
+	if err := h.Store.CreateRefreshToken(r.Context(), RefreshToken{
+		ID:        newID(),
+		TokenHash: refreshHash,
+		UserID:    user.ID,
+		FamilyID:  newID(),
+		ExpiresAt: time.Now().Add(h.refreshTTL()),
+	}); err != nil {
+		h.inc("auth_store_errors_total")
+		httpx.Error(w, r, http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "login failed")
+		return
+	}
+
+	h.inc("auth_logins_total")
+	h.writeTokens(w, r, user, refresh)
+}
+
+func (h *Handler) Refresh(w http.ResponseWriter, r *http.Request) {
+	h.inc("auth_refresh_requests_total")
+
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		h.inc("auth_validation_errors_total")
+		httpx.ValidationError(w, r, "missing refresh_token", []httpx.FieldError{{Field: "refresh_token", Code: httpx.FieldRequired}})
+		return
+	}
+
+	refresh, refreshHash := newRefreshToken()
+	userID, err := h.Store.RotateRefreshToken(r.Context(), hashRefreshToken(req.RefreshToken), RefreshToken{
+		ID:        newID(),
+		TokenHash: refreshHash,
+		ExpiresAt: time.Now().Add(h.refreshTTL()),
+	})
+	switch {
+	case errors.Is(err, ErrRefreshTokenReused):
+		h.inc("auth_refresh_reuse_detected_total")
+		httpx.Error(w, r, http.StatusUnauthorized, codeInvalidRefreshToken, "invalid refresh token")
+		return
+	case errors.Is(err, ErrRefreshTokenInvalid):
+		h.inc("auth_refresh_failures_total")
+		httpx.Error(w, r, http.StatusUnauthorized, codeInvalidRefreshToken, "invalid refresh token")
+		return
+	case err != nil:
+		h.inc("auth_store_errors_total")
+		httpx.Error(w, r, http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "refresh failed")
+		return
+	}
+
+	user, err := h.Store.GetUser(r.Context(), userID)
+	if err != nil {
+		h.inc("auth_store_errors_total")
+		httpx.Error(w, r, http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "refresh failed")
+		return
+	}
+
+	h.inc("auth_refreshes_total")
+	h.writeTokens(w, r, user, refresh)
+}
+
+// Logout revokes the refresh token family. Access tokens stay valid until they
+// expire, which is why they are kept short-lived.
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		h.inc("auth_validation_errors_total")
+		httpx.ValidationError(w, r, "missing refresh_token", []httpx.FieldError{{Field: "refresh_token", Code: httpx.FieldRequired}})
+		return
+	}
+	if err := h.Store.RevokeRefreshFamily(r.Context(), hashRefreshToken(req.RefreshToken)); err != nil {
+		h.inc("auth_store_errors_total")
+		httpx.Error(w, r, http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "logout failed")
+		return
+	}
+	h.inc("auth_logouts_total")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) JWKS(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, h.Tokens.Keys.JWKS())
+}
+
+func (h *Handler) decodeCredentials(w http.ResponseWriter, r *http.Request) (CredentialsRequest, bool) {
+	var req CredentialsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.inc("auth_validation_errors_total")
+		httpx.Error(w, r, http.StatusBadRequest, httpx.CodeInvalidJSON, "invalid json")
+		return CredentialsRequest{}, false
+	}
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+	var fields []httpx.FieldError
+	if !strings.Contains(req.Email, "@") {
+		fields = append(fields, httpx.FieldError{Field: "email", Code: httpx.FieldInvalid})
+	}
+	if req.Password == "" {
+		fields = append(fields, httpx.FieldError{Field: "password", Code: httpx.FieldRequired})
+	}
+	if len(fields) > 0 {
+		h.inc("auth_validation_errors_total")
+		httpx.ValidationError(w, r, "email and password required", fields)
+		return CredentialsRequest{}, false
+	}
+	return req, true
+}
+
+func (h *Handler) writeTokens(w http.ResponseWriter, r *http.Request, user User, refresh string) {
+	access, ttl, err := h.Tokens.IssueAccessToken(user)
+	if err != nil {
+		httpx.Error(w, r, http.StatusInternalServerError, httpx.CodeInternal, "token issue failed")
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, TokenResponse{
+		AccessToken:  access,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(ttl.Seconds()),
+		RefreshToken: refresh,
+	})
+}
+
+// dummyPasswordHash is a bcrypt hash of a random string, used only to spend the
+// same time on unknown emails as on real ones.
+var dummyPasswordHash = func() string {
+	hash, _ := bcrypt.GenerateFromPassword([]byte(newID()), bcrypt.DefaultCost)
+	return string(hash)
+}()
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (h *Handler) inc(name string) {
+	if h.Metrics != nil {
+		h.Metrics.Inc(name)
+	}
+}
+
+func (h *Handler) observeDuration(name string, d time.Duration) {
+	if h.Metrics != nil {
+		h.Metrics.ObserveDuration(name, d)
+	}
+}
@@ -0,0 +1,328 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/triad-platform/triad-app/pkg/httpx"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRegister(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		body       string
+		store      *stubStore
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "invalid json", body: "{", store: newStubStore(), wantStatus: http.StatusBadRequest, wantBody: `"code":"invalid_json"`},
+		{name: "missing email", body: `{"password":"correct-horse"}`, store: newStubStore(), wantStatus: http.StatusBadRequest, wantBody: `{"field":"email","code":"invalid"}`},
+		{name: "short password", body: `{"email":"a@example.com","password":"short"}`, store: newStubStore(), wantStatus: http.StatusBadRequest, wantBody: `{"field":"password","code":"out_of_range"}`},
+		{name: "valid registration", body: `{"email":"a@example.com","password":"correct-horse"}`, store: newStubStore(), wantStatus: http.StatusCreated},
+		{name: "store error", body: `{"email":"a@example.com","password":"correct-horse"}`, store: &stubStore{err: errors.New("db down")}, wantStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := Routes(newTestHandler(t, tc.store))
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/auth/register", strings.NewReader(tc.body)))
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status code mismatch: got=%d want=%d body=%q", rec.Code, tc.wantStatus, rec.Body.String())
+			}
+			if rec.Code >= 400 && rec.Header().Get("Content-Type") != httpx.ProblemContentType {
+				t.Fatalf("content type mismatch: got=%q want=%q", rec.Header().Get("Content-Type"), httpx.ProblemContentType)
+			}
+			if !strings.Contains(rec.Body.String(), tc.wantBody) {
+				t.Fatalf("body mismatch: got=%q want substring %q", rec.Body.String(), tc.wantBody)
+			}
+		})
+	}
+}
+
+func TestRegisterAssignsCustomerRole(t *testing.T) {
+	t.Parallel()
+
+	store := newStubStore()
+	rec := httptest.NewRecorder()
+	Routes(newTestHandler(t, store)).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/auth/register", strings.NewReader(`{"email":"a@example.com","password":"correct-horse"}`)))
+
+	var user User
+	if err := json.Unmarshal(rec.Body.Bytes(), &user); err != nil {
+		t.Fatalf("decode user: %v", err)
+	}
+	if len(user.Roles) != 1 || user.Roles[0] != RoleCustomer {
+		t.Fatalf("roles mismatch: got=%v want=[%s]", user.Roles, RoleCustomer)
+	}
+}
+
+func TestRegisterDuplicateEmail(t *testing.T) {
+	t.Parallel()
+
+	r := Routes(newTestHandler(t, newStubStore()))
+	for i, want := range []int{http.StatusCreated, http.StatusConflict} {
+		rec := httptest.NewRecorder()
+		body := `{"email":"  A@Example.com ","password":"correct-horse"}`
+		if i == 1 {
+			body = `{"email":"a@example.com","password":"correct-horse"}`
+		}
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/auth/register", strings.NewReader(body)))
+		if rec.Code != want {
+			t.Fatalf("attempt %d status code mismatch: got=%d want=%d", i, rec.Code, want)
+		}
+	}
+}
+
+func TestLogin(t *testing.T) {
+	t.Parallel()
+
+	store := newStubStore()
+	hash, _ := bcrypt.GenerateFromPassword([]byte("correct-horse"), bcrypt.MinCost)
+	store.users["u_1"] = User{ID: "u_1", Email: "a@example.com", PasswordHash: string(hash), Roles: []string{RoleAdmin}}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "valid credentials", body: `{"email":"A@example.com","password":"correct-horse"}`, wantStatus: http.StatusOK},
+		{name: "wrong password", body: `{"email":"a@example.com","password":"battery-staple"}`, wantStatus: http.StatusUnauthorized},
+		{name: "unknown email", body: `{"email":"b@example.com","password":"correct-horse"}`, wantStatus: http.StatusUnauthorized},
+	}
+
+	h := newTestHandler(t, store)
+	for _, tc := range tests {
+		rec := httptest.NewRecorder()
+		Routes(h).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/auth/login", strings.NewReader(tc.body)))
+		if rec.Code != tc.wantStatus {
+			t.Fatalf("%s: status code mismatch: got=%d want=%d body=%q", tc.name, rec.Code, tc.wantStatus, rec.Body.String())
+		}
+		if tc.wantStatus != http.StatusOK {
+			continue
+		}
+		var resp TokenResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode token response: %v", err)
+		}
+		claims, err := h.Tokens.VerifyAccessToken(resp.AccessToken)
+		if err != nil {
+			t.Fatalf("VerifyAccessToken() error = %v", err)
+		}
+		if claims.Subject != "u_1" {
+			t.Fatalf("subject mismatch: got=%q want=%q", claims.Subject, "u_1")
+		}
+		if len(claims.Roles) != 1 || claims.Roles[0] != RoleAdmin {
+			t.Fatalf("roles mismatch: got=%v want=[%s]", claims.Roles, RoleAdmin)
+		}
+		if resp.RefreshToken == "" || resp.TokenType != "Bearer" || resp.ExpiresIn != 60 {
+			t.Fatalf("token response mismatch: got=%+v", resp)
+		}
+		if rec.Header().Get("Cache-Control") != "no-store" {
+			t.Fatalf("cache-control mismatch: got=%q want=%q", rec.Header().Get("Cache-Control"), "no-store")
+		}
+	}
+}
+
+func TestRefreshRotationAndReuse(t *testing.T) {
+	t.Parallel()
+
+	store := newStubStore()
+	hash, _ := bcrypt.GenerateFromPassword([]byte("correct-horse"), bcrypt.MinCost)
+	store.users["u_1"] = User{ID: "u_1", Email: "a@example.com", PasswordHash: string(hash)}
+	r := Routes(newTestHandler(t, store))
+
+	login := postTokens(t, r, "/v1/auth/login", `{"email":"a@example.com","password":"correct-horse"}`, http.StatusOK)
+	first := postTokens(t, r, "/v1/auth/refresh", `{"refresh_token":"`+login.RefreshToken+`"}`, http.StatusOK)
+	if first.RefreshToken == login.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+
+	// Replaying the rotated token revokes the whole family, including the
+	// token that replaced it.
+	postTokens(t, r, "/v1/auth/refresh", `{"refresh_token":"`+login.RefreshToken+`"}`, http.StatusUnauthorized)
+	postTokens(t, r, "/v1/auth/refresh", `{"refresh_token":"`+first.RefreshToken+`"}`, http.StatusUnauthorized)
+
+	postTokens(t, r, "/v1/auth/refresh", `{"refresh_token":"unknown"}`, http.StatusUnauthorized)
+	postTokens(t, r, "/v1/auth/refresh", `{}`, http.StatusBadRequest)
+}
+
+func TestLogout(t *testing.T) {
+	t.Parallel()
+
+	store := newStubStore()
+	hash, _ := bcrypt.GenerateFromPassword([]byte("correct-horse"), bcrypt.MinCost)
+	store.users["u_1"] = User{ID: "u_1", Email: "a@example.com", PasswordHash: string(hash)}
+	r := Routes(newTestHandler(t, store))
+
+	login := postTokens(t, r, "/v1/auth/login", `{"email":"a@example.com","password":"correct-horse"}`, http.StatusOK)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/auth/logout", strings.NewReader(`{"refresh_token":"`+login.RefreshToken+`"}`)))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status code mismatch: got=%d want=%d", rec.Code, http.StatusNoContent)
+	}
+	postTokens(t, r, "/v1/auth/refresh", `{"refresh_token":"`+login.RefreshToken+`"}`, http.StatusUnauthorized)
+}
+
+func TestJWKS(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t, newStubStore())
+	rec := httptest.NewRecorder()
+	Routes(h).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status code mismatch: got=%d want=%d", rec.Code, http.StatusOK)
+	}
+	var set JWKS
+	if err := json.Unmarshal(rec.Body.Bytes(), &set); err != nil {
+		t.Fatalf("decode jwks: %v", err)
+	}
+	if len(set.Keys) != 1 || set.Keys[0].Kid != "test" || set.Keys[0].Alg != "RS256" || set.Keys[0].E != "AQAB" {
+		t.Fatalf("jwks mismatch: got=%+v", set)
+	}
+}
+
+func newTestHandler(t *testing.T, store Store) *Handler {
+	t.Helper()
+
+	keys, err := GenerateKeySet("test")
+	if err != nil {
+		t.Fatalf("GenerateKeySet() error = %v", err)
+	}
+	return &Handler{
+		Store:      store,
+		Tokens:     &TokenIssuer{Keys: keys, Issuer: "pulsecart-auth", Audience: "pulsecart", AccessTTL: time.Minute},
+		RefreshTTL: time.Hour,
+		BcryptCost: bcrypt.MinCost,
+	}
+}
+
+func postTokens(t *testing.T, r http.Handler, path, body string, wantStatus int) TokenResponse {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	if rec.Code != wantStatus {
+		t.Fatalf("%s status code mismatch: got=%d want=%d body=%q", path, rec.Code, wantStatus, rec.Body.String())
+	}
+	var resp TokenResponse
+	if wantStatus == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode token response: %v", err)
+		}
+	}
+	return resp
+}
+
+// stubStore mirrors the rotation semantics of PostgresStore in memory.
+type stubStore struct {
+	mu      sync.Mutex
+	err     error
+	users   map[string]User
+	refresh map[string]*stubRefresh
+}
+
+type stubRefresh struct {
+	RefreshToken
+	revoked bool
+}
+
+func newStubStore() *stubStore {
+	return &stubStore{users: map[string]User{}, refresh: map[string]*stubRefresh{}}
+}
+
+func (s *stubStore) CreateUser(_ context.Context, user User) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return User{}, s.err
+	}
+	for _, existing := range s.users {
+		if existing.Email == user.Email {
+			return User{}, ErrEmailTaken
+		}
+	}
+	user.CreatedAt = time.Now()
+	s.users[user.ID] = user
+	return user, nil
+}
+
+func (s *stubStore) GetUserByEmail(_ context.Context, email string) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, user := range s.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return User{}, ErrNotFound
+}
+
+func (s *stubStore) GetUser(_ context.Context, id string) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[id]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+	return user, nil
+}
+
+func (s *stubStore) CreateRefreshToken(_ context.Context, token RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh[token.TokenHash] = &stubRefresh{RefreshToken: token}
+	return nil
+}
+
+func (s *stubStore) RotateRefreshToken(_ context.Context, oldHash string, next RefreshToken) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.refresh[oldHash]
+	if !ok {
+		return "", ErrRefreshTokenInvalid
+	}
+	if old.revoked {
+		s.revokeFamily(old.FamilyID)
+		return "", ErrRefreshTokenReused
+	}
+	if !old.ExpiresAt.After(time.Now()) {
+		return "", ErrRefreshTokenInvalid
+	}
+	old.revoked = true
+	next.UserID = old.UserID
+	next.FamilyID = old.FamilyID
+	s.refresh[next.TokenHash] = &stubRefresh{RefreshToken: next}
+	return old.UserID, nil
+}
+
+func (s *stubStore) RevokeRefreshFamily(_ context.Context, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if token, ok := s.refresh[hash]; ok {
+		s.revokeFamily(token.FamilyID)
+	}
+	return nil
+}
+
+func (s *stubStore) revokeFamily(familyID string) {
+	for _, token := range s.refresh {
+		if token.FamilyID == familyID {
+			token.revoked = true
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type signingKey struct {
+	kid string
+	key *rsa.PrivateKey
+}
+
+// KeySet holds every RSA key that may have signed a live token. Only the
+// active key signs; all of them are published in the JWKS so tokens signed
+// before a rotation keep verifying until they expire.
+type KeySet struct {
+	keys   []signingKey
+	active signingKey
+}
+
+// LoadKeySet reads PEM-encoded RSA private keys (PKCS#1 or PKCS#8) from dir.
+// The file name without extension is the key ID. activeKID selects the signing
+// key; when empty, the last key ID in lexical order is used, so date-prefixed
+// names such as 2026-03-01.pem rotate by adding a file.
+func LoadKeySet(dir, activeKID string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	set := &KeySet{}
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := parseRSAPrivateKey(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		kid := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		set.keys = append(set.keys, signingKey{kid: kid, key: key})
+	}
+	if len(set.keys) == 0 {
+		return nil, fmt.Errorf("no *.pem signing keys in %s", dir)
+	}
+
+	if activeKID == "" {
+		set.active = set.keys[len(set.keys)-1]
+		return set, nil
+	}
+	for _, k := range set.keys {
+		if k.kid == activeKID {
+			set.active = k
+			return set, nil
+		}
+	}
+	return nil, fmt.Errorf("active key %q not found in %s", activeKID, dir)
+}
+
+// GenerateKeySet creates a single in-memory key. Tokens it signs stop
+// verifying when the process restarts, so it is only meant for local runs.
+func GenerateKeySet(kid string) (*KeySet, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	k := signingKey{kid: kid, key: key}
+	return &KeySet{keys: []signingKey{k}, active: k}, nil
+}
+
+func (s *KeySet) ActiveKID() string {
+	return s.active.kid
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func (s *KeySet) JWKS() JWKS {
+	out := JWKS{Keys: make([]JWK, 0, len(s.keys))}
+	for _, k := range s.keys {
+		pub := k.key.PublicKey
+		out.Keys = append(out.Keys, JWK{
+			Kty: "RSA",
+			Use: "sig",
+			Alg: "RS256",
+			Kid: k.kid,
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		})
+	}
+	return out
+}
+
+func (s *KeySet) publicKey(kid string) (*rsa.PublicKey, bool) {
+	for _, k := range s.keys {
+		if k.kid == kid {
+			return &k.key.PublicKey, true
+		}
+	}
+	return nil, false
+}
+
+func parseRSAPrivateKey(raw []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.New("not an RSA private key in PKCS#1 or PKCS#8 form")
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("PKCS#8 key is not RSA")
+	}
+	return key, nil
+}
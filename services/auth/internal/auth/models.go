@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrEmailTaken = errors.New("email already registered")
+	// ErrRefreshTokenInvalid covers unknown, expired and revoked refresh tokens.
+	ErrRefreshTokenInvalid = errors.New("refresh token invalid")
+	// ErrRefreshTokenReused means an already-rotated token was presented again;
+	// the whole token family is revoked when it is returned.
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+	// ErrAPIKeyInactive is returned when rotating a revoked or expired key.
+	ErrAPIKeyInactive = errors.New("api key revoked or expired")
+)
+
+// Roles understood by the gateway's route policies. New users are customers;
+// admins are granted by updating users.roles directly.
+const (
+	RoleCustomer = "customer"
+	RoleAdmin    = "admin"
+)
+
+type User struct {
+	ID           string    `json:"user_id"`
+	Email        string    `json:"email"`
+	PasswordHash string    `json:"-"`
+	Roles        []string  `json:"roles"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// RefreshToken is the stored form of a refresh token: only its SHA-256 hash is
+// kept. Tokens issued from one login share a FamilyID.
+type RefreshToken struct {
+	ID        string
+	TokenHash string
+	UserID    string
+	FamilyID  string
+	ExpiresAt time.Time
+}
+
+type CredentialsRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Scopes an API key may carry. They are checked by the gateway's route
+// policies.
+var knownScopes = map[string]bool{
+	"orders:read":  true,
+	"orders:write": true,
+}
+
+// APIKey is a partner credential. Only the SHA-256 hash of the key is stored;
+// the plaintext is returned once, on creation or rotation.
+type APIKey struct {
+	ID   string `json:"key_id"`
+	Name string `json:"name"`
+	// Subject is the principal the gateway forwards for requests made with the
+	// key. Rotation keeps it, so a partner's orders stay theirs.
+	Subject   string     `json:"subject"`
+	Prefix    string     `json:"prefix"`
+	Hash      string     `json:"-"`
+	Scopes    []string   `json:"scopes"`
+	CreatedBy string     `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+func (k APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type RotateAPIKeyRequest struct {
+	// GracePeriod keeps the old key valid for a while so partners can roll
+	// out the new one; zero revokes it immediately.
+	GracePeriod string `json:"grace_period"`
+}
+
+type APIKeyWithSecret struct {
+	APIKey
+	Key string `json:"api_key"`
+}
+
+type IntrospectAPIKeyRequest struct {
+	APIKey string `json:"api_key"`
+}
+
+type IntrospectAPIKeyResponse struct {
+	Active    bool       `json:"active"`
+	KeyID     string     `json:"key_id,omitempty"`
+	Subject   string     `json:"subject,omitempty"`
+	Scopes    []string   `json:"scopes,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
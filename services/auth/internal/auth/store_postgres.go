@@ -0,0 +1,287 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const pgUniqueViolation = "23505"
+
+type PostgresStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresStore(pool *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{pool: pool}
+}
+
+func (s *PostgresStore) EnsureSchema(ctx context.Context) error {
+	_, err := s.pool.Exec(ctx, `
+CREATE TABLE IF NOT EXISTS users (
+	id TEXT PRIMARY KEY,
+	email TEXT NOT NULL UNIQUE,
+	password_hash TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS roles TEXT[] NOT NULL DEFAULT '{customer}';
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+	id TEXT PRIMARY KEY,
+	token_hash TEXT NOT NULL UNIQUE,
+	user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	family_id TEXT NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	revoked_at TIMESTAMPTZ,
+	replaced_by TEXT
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+CREATE INDEX IF NOT EXISTS refresh_tokens_expires_at_idx ON refresh_tokens (expires_at);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	subject TEXT NOT NULL,
+	prefix TEXT NOT NULL,
+	key_hash TEXT NOT NULL UNIQUE,
+	scopes TEXT[] NOT NULL,
+	created_by TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	expires_at TIMESTAMPTZ,
+	revoked_at TIMESTAMPTZ
+);
+`)
+	return err
+}
+
+func (s *PostgresStore) CreateUser(ctx context.Context, user User) (User, error) {
+	err := s.pool.QueryRow(
+		ctx,
+		`INSERT INTO users (id, email, password_hash, roles) VALUES ($1, $2, $3, $4) RETURNING created_at`,
+		user.ID, user.Email, user.PasswordHash, user.Roles,
+	).Scan(&user.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+			return User{}, ErrEmailTaken
+		}
+		return User{}, err
+	}
+	return user, nil
+}
+
+func (s *PostgresStore) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	return s.getUser(ctx, `SELECT id, email, password_hash, roles, created_at FROM users WHERE email = $1`, email)
+}
+
+func (s *PostgresStore) GetUser(ctx context.Context, id string) (User, error) {
+	return s.getUser(ctx, `SELECT id, email, password_hash, roles, created_at FROM users WHERE id = $1`, id)
+}
+
+func (s *PostgresStore) getUser(ctx context.Context, query, arg string) (User, error) {
+	var user User
+	err := s.pool.QueryRow(ctx, query, arg).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.Roles, &user.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return User{}, ErrNotFound
+	}
+	if err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+func (s *PostgresStore) CreateRefreshToken(ctx context.Context, token RefreshToken) error {
+	_, err := s.pool.Exec(
+		ctx,
+		`INSERT INTO refresh_tokens (id, token_hash, user_id, family_id, expires_at) VALUES ($1, $2, $3, $4, $5)`,
+		token.ID, token.TokenHash, token.UserID, token.FamilyID, token.ExpiresAt,
+	)
+	return err
+}
+
+// RotateRefreshToken revokes the token identified by oldHash and stores next
+// in the same family. Presenting a token that was already rotated revokes the
+// entire family, since either the client or an attacker holds a stolen copy.
+func (s *PostgresStore) RotateRefreshToken(ctx context.Context, oldHash string, next RefreshToken) (string, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	var (
+		id        string
+		userID    string
+		familyID  string
+		expiresAt time.Time
+		revokedAt *time.Time
+	)
+	err = tx.QueryRow(ctx, `
+SELECT id, user_id, family_id, expires_at, revoked_at
+FROM refresh_tokens WHERE token_hash = $1
+FOR UPDATE`, oldHash,
+	).Scan(&id, &userID, &familyID, &expiresAt, &revokedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if revokedAt != nil {
+		if _, err := tx.Exec(
+			ctx,
+			`UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL`,
+			familyID,
+		); err != nil {
+			return "", err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return "", fmt.Errorf("commit refresh token revocation: %w", err)
+		}
+		return "", ErrRefreshTokenReused
+	}
+	if !expiresAt.After(time.Now()) {
+		return "", ErrRefreshTokenInvalid
+	}
+
+	next.UserID = userID
+	next.FamilyID = familyID
+	if _, err := tx.Exec(
+		ctx,
+		`INSERT INTO refresh_tokens (id, token_hash, user_id, family_id, expires_at) VALUES ($1, $2, $3, $4, $5)`,
+		next.ID, next.TokenHash, next.UserID, next.FamilyID, next.ExpiresAt,
+	); err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec(
+		ctx,
+		`UPDATE refresh_tokens SET revoked_at = NOW(), replaced_by = $2 WHERE id = $1`,
+		id, next.ID,
+	); err != nil {
+		return "", err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return "", fmt.Errorf("commit refresh token rotation: %w", err)
+	}
+	return userID, nil
+}
+
+// RevokeRefreshFamily revokes every token descended from the same login as the
+// token identified by hash. Unknown tokens are ignored.
+func (s *PostgresStore) RevokeRefreshFamily(ctx context.Context, hash string) error {
+	_, err := s.pool.Exec(ctx, `
+UPDATE refresh_tokens SET revoked_at = NOW()
+WHERE revoked_at IS NULL
+	AND family_id = (SELECT family_id FROM refresh_tokens WHERE token_hash = $1)`, hash)
+	return err
+}
+
+func (s *PostgresStore) DeleteExpiredRefreshTokens(ctx context.Context) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM refresh_tokens WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+const apiKeyColumns = `id, name, subject, prefix, key_hash, scopes, created_by, created_at, expires_at, revoked_at`
+
+func scanAPIKey(row pgx.Row) (APIKey, error) {
+	var k APIKey
+	err := row.Scan(&k.ID, &k.Name, &k.Subject, &k.Prefix, &k.Hash, &k.Scopes, &k.CreatedBy, &k.CreatedAt, &k.ExpiresAt, &k.RevokedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return APIKey{}, ErrNotFound
+	}
+	return k, err
+}
+
+func (s *PostgresStore) CreateAPIKey(ctx context.Context, key APIKey) (APIKey, error) {
+	return scanAPIKey(s.pool.QueryRow(ctx, `
+INSERT INTO api_keys (id, name, subject, prefix, key_hash, scopes, created_by, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING `+apiKeyColumns,
+		key.ID, key.Name, key.Subject, key.Prefix, key.Hash, key.Scopes, key.CreatedBy, key.ExpiresAt,
+	))
+}
+
+func (s *PostgresStore) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	rows, err := s.pool.Query(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := []APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+func (s *PostgresStore) GetAPIKeyByHash(ctx context.Context, hash string) (APIKey, error) {
+	return scanAPIKey(s.pool.QueryRow(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = $1`, hash))
+}
+
+// RevokeAPIKey is idempotent: revoking a revoked key keeps the original
+// revocation time.
+func (s *PostgresStore) RevokeAPIKey(ctx context.Context, id string) (APIKey, error) {
+	return scanAPIKey(s.pool.QueryRow(ctx, `
+UPDATE api_keys SET revoked_at = COALESCE(revoked_at, NOW())
+WHERE id = $1
+RETURNING `+apiKeyColumns, id))
+}
+
+// RotateAPIKey stores next with the old key's name, subject, scopes and expiry, and
+// makes the old key stop working after grace (immediately when zero).
+func (s *PostgresStore) RotateAPIKey(ctx context.Context, id string, next APIKey, grace time.Duration) (APIKey, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return APIKey{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	old, err := scanAPIKey(tx.QueryRow(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE id = $1 FOR UPDATE`, id))
+	if err != nil {
+		return APIKey{}, err
+	}
+	if !old.Active(time.Now()) {
+		return APIKey{}, ErrAPIKeyInactive
+	}
+
+	created, err := scanAPIKey(tx.QueryRow(ctx, `
+INSERT INTO api_keys (id, name, subject, prefix, key_hash, scopes, created_by, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING `+apiKeyColumns,
+		next.ID, old.Name, old.Subject, next.Prefix, next.Hash, old.Scopes, next.CreatedBy, old.ExpiresAt,
+	))
+	if err != nil {
+		return APIKey{}, err
+	}
+
+	if grace > 0 {
+		_, err = tx.Exec(ctx, `
+UPDATE api_keys SET expires_at = LEAST(COALESCE(expires_at, 'infinity'), $2)
+WHERE id = $1`, id, time.Now().Add(grace))
+	} else {
+		_, err = tx.Exec(ctx, `UPDATE api_keys SET revoked_at = NOW() WHERE id = $1`, id)
+	}
+	if err != nil {
+		return APIKey{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return APIKey{}, fmt.Errorf("commit api key rotation: %w", err)
+	}
+	return created, nil
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// AccessClaims are the claims carried by access tokens. Downstream services
+// should rely on Subject (the user ID) rather than anything the client sends.
+type AccessClaims struct {
+	Email string   `json:"email"`
+	Roles []string `json:"roles,omitempty"`
+	jwt.RegisteredClaims
+}
+
+type TokenIssuer struct {
+	Keys      *KeySet
+	Issuer    string
+	Audience  string
+	AccessTTL time.Duration
+	// now is overridden in tests.
+	now func() time.Time
+}
+
+func (t *TokenIssuer) clock() time.Time {
+	if t.now != nil {
+		return t.now()
+	}
+	return time.Now()
+}
+
+func (t *TokenIssuer) accessTTL() time.Duration {
+	if t.AccessTTL <= 0 {
+		return 15 * time.Minute
+	}
+	return t.AccessTTL
+}
+
+// IssueAccessToken signs an RS256 access token for user with the active key.
+func (t *TokenIssuer) IssueAccessToken(user User) (string, time.Duration, error) {
+	now := t.clock()
+	ttl := t.accessTTL()
+	claims := AccessClaims{
+		Email: user.Email,
+		Roles: user.Roles,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    t.Issuer,
+			Subject:   user.ID,
+			Audience:  jwt.ClaimStrings{t.Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			ID:        newID(),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = t.Keys.active.kid
+	signed, err := token.SignedString(t.Keys.active.key)
+	if err != nil {
+		return "", 0, fmt.Errorf("sign access token: %w", err)
+	}
+	return signed, ttl, nil
+}
+
+// VerifyAccessToken checks signature, issuer, audience and expiry against the
+// key set. Other services verify through the JWKS endpoint instead.
+func (t *TokenIssuer) VerifyAccessToken(raw string) (AccessClaims, error) {
+	var claims AccessClaims
+	_, err := jwt.ParseWithClaims(raw, &claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := t.Keys.publicKey(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		return key, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(t.Issuer),
+		jwt.WithAudience(t.Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(t.clock),
+	)
+	if err != nil {
+		return AccessClaims{}, err
+	}
+	return claims, nil
+}
+
+// newRefreshToken returns the opaque token handed to the client and the hash
+// that is stored server-side.
+func newRefreshToken() (token, hash string) {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b) // crypto/rand.Read never returns an error since Go 1.24.
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashRefreshToken(token)
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// newAPIKey returns a partner key and the hash that is stored server-side. The
+// "pck_" prefix makes leaked keys easy to find with secret scanners.
+func newAPIKey() (key, hash string) {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	key = "pck_" + base64.RawURLEncoding.EncodeToString(b)
+	return key, hashAPIKey(key)
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
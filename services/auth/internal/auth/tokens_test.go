@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadKeySet(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeTestKey(t, dir, "2026-01-01")
+	writeTestKey(t, dir, "2026-03-01")
+
+	tests := []struct {
+		name       string
+		activeKID  string
+		wantActive string
+		wantErr    bool
+	}{
+		{name: "defaults to newest key", wantActive: "2026-03-01"},
+		{name: "explicit active key", activeKID: "2026-01-01", wantActive: "2026-01-01"},
+		{name: "unknown active key", activeKID: "missing", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			set, err := LoadKeySet(dir, tc.activeKID)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadKeySet() error = %v", err)
+			}
+			if set.ActiveKID() != tc.wantActive {
+				t.Fatalf("active kid mismatch: got=%q want=%q", set.ActiveKID(), tc.wantActive)
+			}
+			if got := len(set.JWKS().Keys); got != 2 {
+				t.Fatalf("jwks size mismatch: got=%d want=2", got)
+			}
+		})
+	}
+}
+
+func TestLoadKeySetEmptyDir(t *testing.T) {
+	t.Parallel()
+
+	if _, err := LoadKeySet(t.TempDir(), ""); err == nil {
+		t.Fatal("expected error for empty key dir")
+	}
+}
+
+func TestAccessTokenRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeTestKey(t, dir, "old")
+	writeTestKey(t, dir, "new")
+	oldKeys, err := LoadKeySet(dir, "old")
+	if err != nil {
+		t.Fatalf("LoadKeySet() error = %v", err)
+	}
+	newKeys, err := LoadKeySet(dir, "new")
+	if err != nil {
+		t.Fatalf("LoadKeySet() error = %v", err)
+	}
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	issuer := &TokenIssuer{Keys: oldKeys, Issuer: "pulsecart-auth", Audience: "pulsecart", AccessTTL: time.Minute, now: func() time.Time { return now }}
+	raw, ttl, err := issuer.IssueAccessToken(User{ID: "u_1", Email: "a@example.com"})
+	if err != nil {
+		t.Fatalf("IssueAccessToken() error = %v", err)
+	}
+	if ttl != time.Minute {
+		t.Fatalf("ttl mismatch: got=%s want=%s", ttl, time.Minute)
+	}
+
+	// A token signed before rotation must keep verifying after it.
+	rotated := &TokenIssuer{Keys: newKeys, Issuer: "pulsecart-auth", Audience: "pulsecart", now: func() time.Time { return now }}
+	claims, err := rotated.VerifyAccessToken(raw)
+	if err != nil {
+		t.Fatalf("VerifyAccessToken() error = %v", err)
+	}
+	if claims.Subject != "u_1" || claims.Email != "a@example.com" {
+		t.Fatalf("claims mismatch: got=%+v", claims)
+	}
+
+	wrongAudience := &TokenIssuer{Keys: newKeys, Issuer: "pulsecart-auth", Audience: "other", now: func() time.Time { return now }}
+	if _, err := wrongAudience.VerifyAccessToken(raw); err == nil {
+		t.Fatal("expected audience mismatch error")
+	}
+
+	expired := &TokenIssuer{Keys: newKeys, Issuer: "pulsecart-auth", Audience: "pulsecart", now: func() time.Time { return now.Add(2 * time.Minute) }}
+	if _, err := expired.VerifyAccessToken(raw); err == nil {
+		t.Fatal("expected expiry error")
+	}
+
+	other, err := GenerateKeySet("old")
+	if err != nil {
+		t.Fatalf("GenerateKeySet() error = %v", err)
+	}
+	forged := &TokenIssuer{Keys: other, Issuer: "pulsecart-auth", Audience: "pulsecart", now: func() time.Time { return now }}
+	if _, err := forged.VerifyAccessToken(raw); err == nil {
+		t.Fatal("expected signature error for a different key with the same kid")
+	}
+}
+
+func writeTestKey(t *testing.T, dir, kid string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nats-io/nats.go"
+	"github.com/triad-platform/triad-app/pkg/config"
+	"github.com/triad-platform/triad-app/pkg/httpx"
+	"github.com/triad-platform/triad-app/pkg/logx"
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+	"github.com/triad-platform/triad-app/services/inventory/internal/inventory"
+)
+
+func main() {
+	log := logx.New()
+	metrics := metricsx.NewRegistry("triad_inventory")
+
+	port := config.Getenv("PORT", "8083")
+
+	dbPool, err := pgxpool.New(context.Background(), databaseURL())
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to connect to Postgres")
+	}
+	defer dbPool.Close()
+
+	store := inventory.NewPostgresStore(dbPool)
+	schemaCtx, schemaCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if err := store.EnsureSchema(schemaCtx); err != nil {
+		schemaCancel()
+		log.Fatal().Err(err).Msg("failed to ensure inventory schema")
+	}
+	schemaCancel()
+
+	nc, err := nats.Connect(config.Getenv("NATS_URL", "nats://localhost:4222"))
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to connect to NATS")
+	}
+	defer nc.Close()
+
+	bgCtx, bgCancel := context.WithCancel(context.Background())
+	defer bgCancel()
+	go inventory.RunExpiry(bgCtx, store, reservationExpiryInterval(), 100, metrics, log)
+
+	r := chi.NewRouter()
+	r.Get("/healthz", httpx.Healthz)
+	r.Get("/readyz", httpx.Readyz)
+	r.Get("/metrics", metrics.Handler().ServeHTTP)
+
+	h := &inventory.Handler{
+		Store:          store,
+		Events:         inventory.NewNATSEventPublisher(nc),
+		Metrics:        metrics,
+		ReservationTTL: reservationTTL(),
+	}
+	r.Mount("/", inventory.Routes(h))
+
+	srv := &http.Server{
+		Addr:              ":" + port,
+		Handler:           r,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		log.Info().Msgf("inventory listening on :%s", port)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal().Err(err).Msg("server failed")
+		}
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	<-stop
+
+	bgCancel()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_ = srv.Shutdown(ctx)
+	log.Info().Msg("inventory shutdown complete")
+}
+
+func reservationTTL() time.Duration {
+	d, err := time.ParseDuration(config.Getenv("RESERVATION_TTL", "15m"))
+	if err != nil || d <= 0 {
+		return 15 * time.Minute
+	}
+	return d
+}
+
+func reservationExpiryInterval() time.Duration {
+	d, err := time.ParseDuration(config.Getenv("RESERVATION_EXPIRY_INTERVAL", "30s"))
+	if err != nil || d <= 0 {
+		return 30 * time.Second
+	}
+	return d
+}
+
+func databaseURL() string {
+	if explicit := strings.TrimSpace(config.Getenv("DATABASE_URL", "")); explicit != "" {
+		return explicit
+	}
+
+	host := config.Getenv("DB_HOST", "localhost")
+	port := config.Getenv("DB_PORT", "5432")
+	name := config.Getenv("DB_NAME", "pulsecart")
+	user := config.Getenv("DB_USER", "pulsecart")
+	password := config.Getenv("DB_PASSWORD", "pulsecart")
+	sslmode := config.Getenv("DB_SSLMODE", "disable")
+
+	return (&url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(user, password),
+		Host:     net.JoinHostPort(host, port),
+		Path:     name,
+		RawQuery: "sslmode=" + url.QueryEscape(sslmode),
+	}).String()
+}
@@ -0,0 +1,65 @@
+package inventory
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	stockLowEventType = "StockLow"
+	stockOutEventType = "StockOut"
+)
+
+type EventPublisher interface {
+	PublishStockEvent(ctx context.Context, event StockEvent) error
+}
+
+// stockEvents reports SKUs whose availability crossed a boundary. Events fire
+// only on the transition, so a SKU that stays low does not emit on every
+// reservation.
+func stockEvents(changes []StockChange, now time.Time) []StockEvent {
+	var events []StockEvent
+	for _, c := range changes {
+		eventType := ""
+		switch {
+		case c.After <= 0 && c.Before > 0:
+			eventType = stockOutEventType
+		case c.LowStockThreshold > 0 && c.After <= c.LowStockThreshold && c.Before > c.LowStockThreshold:
+			eventType = stockLowEventType
+		default:
+			continue
+		}
+		events = append(events, StockEvent{
+			Type:              eventType,
+			Version:           1,
+			SKU:               c.SKU,
+			Available:         c.After,
+			LowStockThreshold: c.LowStockThreshold,
+			OccurredAt:        now.UTC().Format(time.RFC3339),
+		})
+	}
+	return events
+}
+
+type NATSEventPublisher struct {
+	conn *nats.Conn
+}
+
+func NewNATSEventPublisher(conn *nats.Conn) *NATSEventPublisher {
+	return &NATSEventPublisher{conn: conn}
+}
+
+func (p *NATSEventPublisher) PublishStockEvent(_ context.Context, event StockEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	subject := StockLowSubject
+	if event.Type == stockOutEventType {
+		subject = StockOutSubject
+	}
+	return p.conn.Publish(subject, payload)
+}
@@ -0,0 +1,53 @@
+package inventory
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+)
+
+type ReservationExpirer interface {
+	ExpireDue(ctx context.Context, limit int) (int, error)
+}
+
+// RunExpiry releases overdue reservations every interval until ctx is
+// cancelled. A full batch is followed immediately by another pass.
+func RunExpiry(ctx context.Context, store ReservationExpirer, interval time.Duration, batchSize int, metrics *metricsx.Registry, log zerolog.Logger) {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for ctx.Err() == nil {
+			expired, err := store.ExpireDue(ctx, batchSize)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Error().Err(err).Msg("reservation expiry failed")
+				}
+				break
+			}
+			if expired > 0 {
+				if metrics != nil {
+					metrics.Add("inventory_reservations_expired_total", int64(expired))
+				}
+				log.Info().Int("expired", expired).Msg("expired reservations released")
+			}
+			if expired < batchSize {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package inventory
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/triad-platform/triad-app/pkg/httpx"
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+)
+
+// Problem codes for inventory errors. Codes shared by every service live in
+// httpx.
+const (
+	codeInsufficientStock = "insufficient_stock"
+	codeReservationClosed = "reservation_closed"
+	codeBelowReserved     = "below_reserved"
+)
+
+type Store interface {
+	GetStock(ctx context.Context, sku string) (StockLevel, error)
+	SetStock(ctx context.Context, sku string, req SetStockRequest) (StockLevel, StockChange, error)
+	Reserve(ctx context.Context, params ReserveParams) (Reservation, []StockChange, error)
+	GetReservation(ctx context.Context, id string) (Reservation, error)
+	Commit(ctx context.Context, id string) (Reservation, error)
+	Release(ctx context.Context, id string) (Reservation, error)
+}
+
+type Handler struct {
+	Store          Store
+	Events         EventPublisher
+	Metrics        *metricsx.Registry
+	ReservationTTL time.Duration
+}
+
+func Routes(h *Handler) chi.Router {
+	r := chi.NewRouter()
+	r.Post("/v1/reservations", h.Reserve)
+	r.Get("/v1/reservations/{id}", h.GetReservation)
+	r.Post("/v1/reservations/{id}/commit", h.Commit)
+	r.Post("/v1/reservations/{id}/release", h.Release)
+	r.Get("/v1/stock/{sku}", h.GetStock)
+	r.Put("/v1/stock/{sku}", h.SetStock)
+	return r
+}
+
+func (h *Handler) reservationTTL() time.Duration {
+	if h.ReservationTTL <= 0 {
+		return 15 * time.Minute
+	}
+	return h.ReservationTTL
+}
+
+func (h *Handler) Reserve(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() { h.observeDuration("inventory_reserve_duration", time.Since(start)) }()
+	h.inc("inventory_reserve_requests_total")
+
+	var req ReserveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.inc("inventory_validation_errors_total")
+		httpx.Error(w, r, http.StatusBadRequest, httpx.CodeInvalidJSON, "invalid json")
+		return
+	}
+	req.OrderID = strings.TrimSpace(req.OrderID)
+	if errs := validateReserveRequest(req); len(errs) > 0 {
+		h.inc("inventory_validation_errors_total")
+		httpx.ValidationError(w, r, "invalid reservation", errs)
+		return
+	}
+	items := normalizeItems(req.Items)
+
+	reservation, changes, err := h.Store.Reserve(r.Context(), ReserveParams{
+		ReservationID: newReservationID(),
+		OrderID:       req.OrderID,
+		Items:         items,
+		TTL:           h.reservationTTL(),
+	})
+	var insufficient *InsufficientStockError
+	if errors.As(err, &insufficient) {
+		h.inc("inventory_reserve_insufficient_total")
+		httpx.WriteProblem(w, r, httpx.Problem{
+			Status:     http.StatusConflict,
+			Code:       codeInsufficientStock,
+			Detail:     "insufficient stock",
+			Extensions: map[string]any{"shortages": insufficient.Shortages},
+		})
+		return
+	}
+	if err != nil {
+		h.inc("inventory_store_errors_total")
+		httpx.Error(w, r, http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "reservation failed")
+		return
+	}
+
+	h.inc("inventory_reservations_created_total")
+	h.publishStockEvents(r.Context(), changes)
+	writeJSON(w, http.StatusCreated, reservation)
+}
+
+func (h *Handler) GetReservation(w http.ResponseWriter, r *http.Request) {
+	reservation, err := h.Store.GetReservation(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeStoreError(w, r, err, "reservation lookup failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, reservation)
+}
+
+func (h *Handler) Commit(w http.ResponseWriter, r *http.Request) {
+	reservation, err := h.Store.Commit(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeStoreError(w, r, err, "reservation commit failed")
+		return
+	}
+	h.inc("inventory_reservations_committed_total")
+	writeJSON(w, http.StatusOK, reservation)
+}
+
+func (h *Handler) Release(w http.ResponseWriter, r *http.Request) {
+	reservation, err := h.Store.Release(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeStoreError(w, r, err, "reservation release failed")
+		return
+	}
+	h.inc("inventory_reservations_released_total")
+	writeJSON(w, http.StatusOK, reservation)
+}
+
+func (h *Handler) GetStock(w http.ResponseWriter, r *http.Request) {
+	level, err := h.Store.GetStock(r.Context(), chi.URLParam(r, "sku"))
+	if err != nil {
+		h.writeStoreError(w, r, err, "stock lookup failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, level)
+}
+
+func (h *Handler) SetStock(w http.ResponseWriter, r *http.Request) {
+	var req SetStockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.inc("inventory_validation_errors_total")
+		httpx.Error(w, r, http.StatusBadRequest, httpx.CodeInvalidJSON, "invalid json")
+		return
+	}
+	sku := strings.TrimSpace(chi.URLParam(r, "sku"))
+	var errs []httpx.FieldError
+	if sku == "" {
+		errs = append(errs, httpx.FieldError{Field: "sku", Code: httpx.FieldRequired})
+	}
+	if req.OnHand < 0 {
+		errs = append(errs, httpx.FieldError{Field: "on_hand", Code: httpx.FieldOutOfRange})
+	}
+	if req.LowStockThreshold < 0 {
+		errs = append(errs, httpx.FieldError{Field: "low_stock_threshold", Code: httpx.FieldOutOfRange})
+	}
+	if len(errs) > 0 {
+		h.inc("inventory_validation_errors_total")
+		httpx.ValidationError(w, r, "invalid stock level", errs)
+		return
+	}
+
+	level, change, err := h.Store.SetStock(r.Context(), sku, req)
+	if err != nil {
+		h.writeStoreError(w, r, err, "stock update failed")
+		return
+	}
+	h.inc("inventory_stock_updates_total")
+	h.publishStockEvents(r.Context(), []StockChange{change})
+	writeJSON(w, http.StatusOK, level)
+}
+
+func (h *Handler) writeStoreError(w http.ResponseWriter, r *http.Request, err error, msg string) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		httpx.Error(w, r, http.StatusNotFound, httpx.CodeNotFound, err.Error())
+	case errors.Is(err, ErrReservationClosed):
+		h.inc("inventory_conflicts_total")
+		httpx.Error(w, r, http.StatusConflict, codeReservationClosed, err.Error())
+	case errors.Is(err, ErrBelowReserved):
+		h.inc("inventory_conflicts_total")
+		httpx.Error(w, r, http.StatusConflict, codeBelowReserved, err.Error())
+	default:
+		h.inc("inventory_store_errors_total")
+		httpx.Error(w, r, http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, msg)
+	}
+}
+
+// publishStockEvents is best effort: the stock write has already committed, so
+// a publish failure is counted rather than failing the request.
+func (h *Handler) publishStockEvents(ctx context.Context, changes []StockChange) {
+	if h.Events == nil {
+		return
+	}
+	for _, event := range stockEvents(changes, time.Now()) {
+		if err := h.Events.PublishStockEvent(ctx, event); err != nil {
+			h.inc("inventory_event_publish_errors_total")
+			continue
+		}
+		h.inc("inventory_events_published_total")
+	}
+}
+
+func validateReserveRequest(req ReserveRequest) []httpx.FieldError {
+	var errs []httpx.FieldError
+	if req.OrderID == "" {
+		errs = append(errs, httpx.FieldError{Field: "order_id", Code: httpx.FieldRequired})
+	}
+	if len(req.Items) == 0 {
+		errs = append(errs, httpx.FieldError{Field: "items", Code: httpx.FieldRequired})
+	}
+	for i, item := range req.Items {
+		prefix := "items[" + strconv.Itoa(i) + "]"
+		if strings.TrimSpace(item.SKU) == "" {
+			errs = append(errs, httpx.FieldError{Field: prefix + ".sku", Code: httpx.FieldRequired})
+		}
+		if item.Qty <= 0 {
+			errs = append(errs, httpx.FieldError{Field: prefix + ".qty", Code: httpx.FieldNotPositive})
+		}
+	}
+	return errs
+}
+
+// normalizeItems merges repeated SKUs and sorts by SKU, which is the lock order
+// the store relies on. Items must already be valid.
+func normalizeItems(items []ReservationItem) []ReservationItem {
+	qty := make(map[string]int, len(items))
+	for _, item := range items {
+		qty[strings.TrimSpace(item.SKU)] += item.Qty
+	}
+	out := make([]ReservationItem, 0, len(qty))
+	for sku, n := range qty {
+		out = append(out, ReservationItem{SKU: sku, Qty: n})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].SKU < out[j].SKU })
+	return out
+}
+
+func newReservationID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "r-fallback"
+	}
+	return hex.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (h *Handler) inc(name string) {
+	if h.Metrics != nil {
+		h.Metrics.Inc(name)
+	}
+}
+
+func (h *Handler) observeDuration(name string, d time.Duration) {
+	if h.Metrics != nil {
+		h.Metrics.ObserveDuration(name, d)
+	}
+}
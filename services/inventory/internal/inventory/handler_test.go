@@ -0,0 +1,298 @@
+package inventory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/triad-platform/triad-app/pkg/httpx"
+)
+
+func TestReserve(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		body          string
+		store         *stubStore
+		wantStatus    int
+		wantBody      string
+		wantItems     []ReservationItem
+		wantShortages int
+	}{
+		{
+			name:       "invalid json",
+			body:       "{",
+			store:      &stubStore{},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "missing order_id",
+			body:       `{"items":[{"sku":"sku_1","qty":1}]}`,
+			store:      &stubStore{},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `"field":"order_id"`,
+		},
+		{
+			name:       "non positive qty",
+			body:       `{"order_id":"o-1","items":[{"sku":"sku_1","qty":0}]}`,
+			store:      &stubStore{},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"field":"items[0].qty","code":"must_be_positive"}`,
+		},
+		{
+			name: "insufficient stock",
+			body: `{"order_id":"o-1","items":[{"sku":"sku_1","qty":5}]}`,
+			store: &stubStore{reserveErr: &InsufficientStockError{Shortages: []Shortage{
+				{SKU: "sku_1", Requested: 5, Available: 2},
+			}}},
+			wantStatus:    http.StatusConflict,
+			wantShortages: 1,
+		},
+		{
+			name:       "store error",
+			body:       `{"order_id":"o-1","items":[{"sku":"sku_1","qty":1}]}`,
+			store:      &stubStore{reserveErr: errors.New("db down")},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "merges and sorts items",
+			body:       `{"order_id":"o-1","items":[{"sku":"sku_b","qty":1},{"sku":"sku_a","qty":2},{"sku":"sku_b","qty":3}]}`,
+			store:      &stubStore{},
+			wantStatus: http.StatusCreated,
+			wantItems:  []ReservationItem{{SKU: "sku_a", Qty: 2}, {SKU: "sku_b", Qty: 4}},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := Routes(&Handler{Store: tc.store, ReservationTTL: time.Minute})
+			req := httptest.NewRequest(http.MethodPost, "/v1/reservations", strings.NewReader(tc.body))
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status code mismatch: got=%d want=%d body=%q", rec.Code, tc.wantStatus, rec.Body.String())
+			}
+			if tc.wantStatus >= http.StatusBadRequest && rec.Header().Get("Content-Type") != httpx.ProblemContentType {
+				t.Fatalf("content type mismatch: got=%q want=%q", rec.Header().Get("Content-Type"), httpx.ProblemContentType)
+			}
+			if !strings.Contains(rec.Body.String(), tc.wantBody) {
+				t.Fatalf("body mismatch: got=%q want containing %q", rec.Body.String(), tc.wantBody)
+			}
+			if tc.wantShortages > 0 {
+				var resp struct {
+					Code      string     `json:"code"`
+					Shortages []Shortage `json:"shortages"`
+				}
+				if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+					t.Fatalf("decode conflict body: %v", err)
+				}
+				if resp.Code != codeInsufficientStock {
+					t.Fatalf("code mismatch: got=%q want=%q", resp.Code, codeInsufficientStock)
+				}
+				if len(resp.Shortages) != tc.wantShortages {
+					t.Fatalf("shortages mismatch: got=%d want=%d", len(resp.Shortages), tc.wantShortages)
+				}
+			}
+			if tc.wantItems == nil {
+				return
+			}
+			got := tc.store.lastReserve.Items
+			if len(got) != len(tc.wantItems) {
+				t.Fatalf("reserved items mismatch: got=%v want=%v", got, tc.wantItems)
+			}
+			for i := range got {
+				if got[i] != tc.wantItems[i] {
+					t.Fatalf("reserved items mismatch: got=%v want=%v", got, tc.wantItems)
+				}
+			}
+			if tc.store.lastReserve.TTL != time.Minute {
+				t.Fatalf("ttl mismatch: got=%s want=%s", tc.store.lastReserve.TTL, time.Minute)
+			}
+		})
+	}
+}
+
+func TestReservationTransitions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		path       string
+		store      *stubStore
+		wantStatus int
+	}{
+		{name: "commit", path: "/v1/reservations/r-1/commit", store: &stubStore{}, wantStatus: http.StatusOK},
+		{name: "commit missing", path: "/v1/reservations/r-1/commit", store: &stubStore{transitionErr: ErrNotFound}, wantStatus: http.StatusNotFound},
+		{name: "commit closed", path: "/v1/reservations/r-1/commit", store: &stubStore{transitionErr: ErrReservationClosed}, wantStatus: http.StatusConflict},
+		{name: "release", path: "/v1/reservations/r-1/release", store: &stubStore{}, wantStatus: http.StatusOK},
+		{name: "release store error", path: "/v1/reservations/r-1/release", store: &stubStore{transitionErr: errors.New("db down")}, wantStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := Routes(&Handler{Store: tc.store})
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tc.path, nil))
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status code mismatch: got=%d want=%d body=%q", rec.Code, tc.wantStatus, rec.Body.String())
+			}
+			if tc.store.lastTransitionID != "r-1" {
+				t.Fatalf("reservation id mismatch: got=%q want=%q", tc.store.lastTransitionID, "r-1")
+			}
+		})
+	}
+}
+
+func TestSetStock(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		body       string
+		store      *stubStore
+		wantStatus int
+		wantEvents int
+	}{
+		{name: "negative on_hand", body: `{"on_hand":-1}`, store: &stubStore{}, wantStatus: http.StatusBadRequest},
+		{name: "below reserved", body: `{"on_hand":1}`, store: &stubStore{setErr: ErrBelowReserved}, wantStatus: http.StatusConflict},
+		{
+			name:       "drop to zero emits stock out",
+			body:       `{"on_hand":0,"low_stock_threshold":5}`,
+			store:      &stubStore{setChange: StockChange{SKU: "sku_1", Before: 10, After: 0, LowStockThreshold: 5}},
+			wantStatus: http.StatusOK,
+			wantEvents: 1,
+		},
+		{
+			name:       "restock emits nothing",
+			body:       `{"on_hand":50,"low_stock_threshold":5}`,
+			store:      &stubStore{setChange: StockChange{SKU: "sku_1", Before: 0, After: 50, LowStockThreshold: 5}},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			events := &stubPublisher{}
+			r := Routes(&Handler{Store: tc.store, Events: events})
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/v1/stock/sku_1", strings.NewReader(tc.body)))
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status code mismatch: got=%d want=%d body=%q", rec.Code, tc.wantStatus, rec.Body.String())
+			}
+			if len(events.events) != tc.wantEvents {
+				t.Fatalf("published events mismatch: got=%d want=%d", len(events.events), tc.wantEvents)
+			}
+		})
+	}
+}
+
+func TestStockEvents(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		change   StockChange
+		wantType string
+	}{
+		{name: "crosses threshold", change: StockChange{SKU: "a", Before: 12, After: 8, LowStockThreshold: 10}, wantType: stockLowEventType},
+		{name: "lands on threshold", change: StockChange{SKU: "a", Before: 12, After: 10, LowStockThreshold: 10}, wantType: stockLowEventType},
+		{name: "already low", change: StockChange{SKU: "a", Before: 8, After: 6, LowStockThreshold: 10}},
+		{name: "runs out", change: StockChange{SKU: "a", Before: 3, After: 0, LowStockThreshold: 10}, wantType: stockOutEventType},
+		{name: "runs out without threshold", change: StockChange{SKU: "a", Before: 1, After: 0}, wantType: stockOutEventType},
+		{name: "already out", change: StockChange{SKU: "a", Before: 0, After: 0, LowStockThreshold: 10}},
+		{name: "no threshold configured", change: StockChange{SKU: "a", Before: 5, After: 1}},
+	}
+
+	for _, tc := range tests {
+		events := stockEvents([]StockChange{tc.change}, now)
+		if tc.wantType == "" {
+			if len(events) != 0 {
+				t.Fatalf("%s: expected no events, got %+v", tc.name, events)
+			}
+			continue
+		}
+		if len(events) != 1 || events[0].Type != tc.wantType {
+			t.Fatalf("%s: event mismatch: got=%+v want type %s", tc.name, events, tc.wantType)
+		}
+		if events[0].OccurredAt != "2026-03-01T00:00:00Z" || events[0].Available != tc.change.After {
+			t.Fatalf("%s: event fields mismatch: %+v", tc.name, events[0])
+		}
+	}
+}
+
+type stubStore struct {
+	reserveErr       error
+	reserveChanges   []StockChange
+	lastReserve      ReserveParams
+	transitionErr    error
+	lastTransitionID string
+	setErr           error
+	setChange        StockChange
+}
+
+func (s *stubStore) GetStock(_ context.Context, sku string) (StockLevel, error) {
+	return StockLevel{SKU: sku}, nil
+}
+
+func (s *stubStore) SetStock(_ context.Context, sku string, req SetStockRequest) (StockLevel, StockChange, error) {
+	if s.setErr != nil {
+		return StockLevel{}, StockChange{}, s.setErr
+	}
+	return StockLevel{SKU: sku, OnHand: req.OnHand, Available: req.OnHand, LowStockThreshold: req.LowStockThreshold}, s.setChange, nil
+}
+
+func (s *stubStore) Reserve(_ context.Context, params ReserveParams) (Reservation, []StockChange, error) {
+	s.lastReserve = params
+	if s.reserveErr != nil {
+		return Reservation{}, nil, s.reserveErr
+	}
+	return Reservation{ID: params.ReservationID, OrderID: params.OrderID, Status: ReservationPending, Items: params.Items}, s.reserveChanges, nil
+}
+
+func (s *stubStore) GetReservation(_ context.Context, id string) (Reservation, error) {
+	return Reservation{ID: id, Status: ReservationPending}, nil
+}
+
+func (s *stubStore) Commit(_ context.Context, id string) (Reservation, error) {
+	s.lastTransitionID = id
+	if s.transitionErr != nil {
+		return Reservation{}, s.transitionErr
+	}
+	return Reservation{ID: id, Status: ReservationCommitted}, nil
+}
+
+func (s *stubStore) Release(_ context.Context, id string) (Reservation, error) {
+	s.lastTransitionID = id
+	if s.transitionErr != nil {
+		return Reservation{}, s.transitionErr
+	}
+	return Reservation{ID: id, Status: ReservationReleased}, nil
+}
+
+type stubPublisher struct {
+	events []StockEvent
+}
+
+func (p *stubPublisher) PublishStockEvent(_ context.Context, event StockEvent) error {
+	p.events = append(p.events, event)
+	return nil
+}
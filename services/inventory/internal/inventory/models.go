@@ -0,0 +1,101 @@
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	StockLowSubject = "inventory.stock_low.v1"
+	StockOutSubject = "inventory.stock_out.v1"
+)
+
+const (
+	ReservationPending   = "pending"
+	ReservationCommitted = "committed"
+	ReservationReleased  = "released"
+	ReservationExpired   = "expired"
+)
+
+var (
+	ErrNotFound = errors.New("not found")
+	// ErrReservationClosed is returned when a reservation can no longer move to
+	// the requested state, e.g. committing one that was already released.
+	ErrReservationClosed = errors.New("reservation is no longer pending")
+	// ErrBelowReserved is returned when on_hand would drop below the quantity
+	// currently held by pending reservations.
+	ErrBelowReserved = errors.New("on_hand is below reserved quantity")
+)
+
+type StockLevel struct {
+	SKU               string    `json:"sku"`
+	OnHand            int       `json:"on_hand"`
+	Reserved          int       `json:"reserved"`
+	Available         int       `json:"available"`
+	LowStockThreshold int       `json:"low_stock_threshold"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+type SetStockRequest struct {
+	OnHand            int `json:"on_hand"`
+	LowStockThreshold int `json:"low_stock_threshold"`
+}
+
+type ReservationItem struct {
+	SKU string `json:"sku"`
+	Qty int    `json:"qty"`
+}
+
+type Reservation struct {
+	ID        string            `json:"reservation_id"`
+	OrderID   string            `json:"order_id"`
+	Status    string            `json:"status"`
+	Items     []ReservationItem `json:"items"`
+	ExpiresAt time.Time         `json:"expires_at"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+type ReserveRequest struct {
+	OrderID string            `json:"order_id"`
+	Items   []ReservationItem `json:"items"`
+}
+
+type Shortage struct {
+	SKU       string `json:"sku"`
+	Requested int    `json:"requested"`
+	Available int    `json:"available"`
+}
+
+// InsufficientStockError lists every SKU that could not be reserved; nothing
+// is reserved when it is returned.
+type InsufficientStockError struct {
+	Shortages []Shortage
+}
+
+func (e *InsufficientStockError) Error() string {
+	parts := make([]string, 0, len(e.Shortages))
+	for _, s := range e.Shortages {
+		parts = append(parts, fmt.Sprintf("%s (requested %d, available %d)", s.SKU, s.Requested, s.Available))
+	}
+	return "insufficient stock: " + strings.Join(parts, ", ")
+}
+
+// StockChange is the availability of a SKU before and after a write, used to
+// detect low-stock and out-of-stock transitions.
+type StockChange struct {
+	SKU               string
+	Before            int
+	After             int
+	LowStockThreshold int
+}
+
+type StockEvent struct {
+	Type              string `json:"type"`
+	Version           int    `json:"version"`
+	SKU               string `json:"sku"`
+	Available         int    `json:"available"`
+	LowStockThreshold int    `json:"low_stock_threshold"`
+	OccurredAt        string `json:"occurred_at"`
+}
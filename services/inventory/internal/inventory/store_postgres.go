@@ -0,0 +1,358 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const pgCheckViolation = "23514"
+
+type ReserveParams struct {
+	ReservationID string
+	OrderID       string
+	// Items must have unique SKUs; the store locks rows in the given order, so
+	// callers sort by SKU to avoid deadlocks between concurrent reservations.
+	Items []ReservationItem
+	TTL   time.Duration
+}
+
+type PostgresStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresStore(pool *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{pool: pool}
+}
+
+func (s *PostgresStore) EnsureSchema(ctx context.Context) error {
+	_, err := s.pool.Exec(ctx, `
+CREATE TABLE IF NOT EXISTS stock_levels (
+	sku TEXT PRIMARY KEY,
+	on_hand INTEGER NOT NULL CHECK (on_hand >= 0),
+	reserved INTEGER NOT NULL DEFAULT 0 CHECK (reserved >= 0),
+	low_stock_threshold INTEGER NOT NULL DEFAULT 0 CHECK (low_stock_threshold >= 0),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	CONSTRAINT stock_levels_reserved_le_on_hand CHECK (reserved <= on_hand)
+);
+
+CREATE TABLE IF NOT EXISTS reservations (
+	id TEXT PRIMARY KEY,
+	order_id TEXT NOT NULL,
+	status TEXT NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS reservations_pending_expires_at_idx ON reservations (expires_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS reservations_order_id_idx ON reservations (order_id);
+
+CREATE TABLE IF NOT EXISTS reservation_items (
+	reservation_id TEXT NOT NULL REFERENCES reservations(id) ON DELETE CASCADE,
+	sku TEXT NOT NULL,
+	qty INTEGER NOT NULL CHECK (qty > 0),
+	PRIMARY KEY (reservation_id, sku)
+);
+`)
+	return err
+}
+
+func (s *PostgresStore) GetStock(ctx context.Context, sku string) (StockLevel, error) {
+	var level StockLevel
+	err := s.pool.QueryRow(
+		ctx,
+		`SELECT sku, on_hand, reserved, low_stock_threshold, updated_at FROM stock_levels WHERE sku = $1`,
+		sku,
+	).Scan(&level.SKU, &level.OnHand, &level.Reserved, &level.LowStockThreshold, &level.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return StockLevel{}, ErrNotFound
+	}
+	if err != nil {
+		return StockLevel{}, err
+	}
+	level.Available = level.OnHand - level.Reserved
+	return level, nil
+}
+
+// SetStock sets the absolute on-hand quantity and threshold for sku, creating
+// the row if needed. It fails with ErrBelowReserved rather than invalidating
+// pending reservations.
+func (s *PostgresStore) SetStock(ctx context.Context, sku string, req SetStockRequest) (StockLevel, StockChange, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return StockLevel{}, StockChange{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	before := 0
+	err = tx.QueryRow(ctx, `SELECT on_hand - reserved FROM stock_levels WHERE sku = $1 FOR UPDATE`, sku).Scan(&before)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return StockLevel{}, StockChange{}, err
+	}
+
+	level := StockLevel{SKU: sku}
+	err = tx.QueryRow(ctx, `
+INSERT INTO stock_levels (sku, on_hand, low_stock_threshold) VALUES ($1, $2, $3)
+ON CONFLICT (sku) DO UPDATE
+SET on_hand = EXCLUDED.on_hand, low_stock_threshold = EXCLUDED.low_stock_threshold, updated_at = NOW()
+RETURNING on_hand, reserved, low_stock_threshold, updated_at`,
+		sku, req.OnHand, req.LowStockThreshold,
+	).Scan(&level.OnHand, &level.Reserved, &level.LowStockThreshold, &level.UpdatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgCheckViolation {
+			return StockLevel{}, StockChange{}, ErrBelowReserved
+		}
+		return StockLevel{}, StockChange{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return StockLevel{}, StockChange{}, fmt.Errorf("commit stock transaction: %w", err)
+	}
+	level.Available = level.OnHand - level.Reserved
+	return level, StockChange{SKU: sku, Before: before, After: level.Available, LowStockThreshold: level.LowStockThreshold}, nil
+}
+
+// Reserve holds every item or none. Rows are locked with FOR UPDATE so two
+// concurrent reservations can never both take the last unit.
+func (s *PostgresStore) Reserve(ctx context.Context, params ReserveParams) (Reservation, []StockChange, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return Reservation{}, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	type lockedRow struct {
+		available int
+		threshold int
+	}
+	locked := make(map[string]lockedRow, len(params.Items))
+	for _, item := range params.Items {
+		var row lockedRow
+		err := tx.QueryRow(
+			ctx,
+			`SELECT on_hand - reserved, low_stock_threshold FROM stock_levels WHERE sku = $1 FOR UPDATE`,
+			item.SKU,
+		).Scan(&row.available, &row.threshold)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return Reservation{}, nil, err
+		}
+		locked[item.SKU] = row
+	}
+
+	var shortages []Shortage
+	for _, item := range params.Items {
+		if available := locked[item.SKU].available; available < item.Qty {
+			shortages = append(shortages, Shortage{SKU: item.SKU, Requested: item.Qty, Available: available})
+		}
+	}
+	if len(shortages) > 0 {
+		return Reservation{}, nil, &InsufficientStockError{Shortages: shortages}
+	}
+
+	reservation := Reservation{
+		ID:      params.ReservationID,
+		OrderID: params.OrderID,
+		Status:  ReservationPending,
+		Items:   params.Items,
+	}
+	err = tx.QueryRow(ctx, `
+INSERT INTO reservations (id, order_id, status, expires_at)
+VALUES ($1, $2, $3, NOW() + make_interval(secs => $4))
+RETURNING expires_at, created_at`,
+		reservation.ID, reservation.OrderID, ReservationPending, params.TTL.Seconds(),
+	).Scan(&reservation.ExpiresAt, &reservation.CreatedAt)
+	if err != nil {
+		return Reservation{}, nil, err
+	}
+
+	changes := make([]StockChange, 0, len(params.Items))
+	for _, item := range params.Items {
+		if _, err := tx.Exec(
+			ctx,
+			`INSERT INTO reservation_items (reservation_id, sku, qty) VALUES ($1, $2, $3)`,
+			reservation.ID, item.SKU, item.Qty,
+		); err != nil {
+			return Reservation{}, nil, err
+		}
+		if _, err := tx.Exec(
+			ctx,
+			`UPDATE stock_levels SET reserved = reserved + $2, updated_at = NOW() WHERE sku = $1`,
+			item.SKU, item.Qty,
+		); err != nil {
+			return Reservation{}, nil, err
+		}
+		row := locked[item.SKU]
+		changes = append(changes, StockChange{
+			SKU:               item.SKU,
+			Before:            row.available,
+			After:             row.available - item.Qty,
+			LowStockThreshold: row.threshold,
+		})
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return Reservation{}, nil, fmt.Errorf("commit reservation transaction: %w", err)
+	}
+	return reservation, changes, nil
+}
+
+func (s *PostgresStore) GetReservation(ctx context.Context, id string) (Reservation, error) {
+	return getReservation(ctx, s.pool, id, false)
+}
+
+// Commit turns held stock into shipped stock: both on_hand and reserved drop
+// by the reserved quantity, so availability is unchanged. Committing twice is
+// a no-op.
+func (s *PostgresStore) Commit(ctx context.Context, id string) (Reservation, error) {
+	return s.transition(ctx, id, ReservationCommitted,
+		`UPDATE stock_levels SET on_hand = on_hand - $2, reserved = reserved - $2, updated_at = NOW() WHERE sku = $1`)
+}
+
+// Release returns held stock to availability. Releasing twice, or releasing
+// an expired reservation, is a no-op.
+func (s *PostgresStore) Release(ctx context.Context, id string) (Reservation, error) {
+	return s.transition(ctx, id, ReservationReleased,
+		`UPDATE stock_levels SET reserved = reserved - $2, updated_at = NOW() WHERE sku = $1`)
+}
+
+func (s *PostgresStore) transition(ctx context.Context, id, target, stockSQL string) (Reservation, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return Reservation{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	reservation, err := getReservation(ctx, tx, id, true)
+	if err != nil {
+		return Reservation{}, err
+	}
+	switch {
+	case reservation.Status == target:
+		return reservation, nil
+	case target == ReservationReleased && reservation.Status == ReservationExpired:
+		return reservation, nil
+	case reservation.Status != ReservationPending:
+		return Reservation{}, ErrReservationClosed
+	case target == ReservationCommitted && !reservation.ExpiresAt.After(time.Now()):
+		// Past its expiry the stock may already be promised elsewhere once the
+		// expiry loop runs, so a late commit is refused.
+		return Reservation{}, ErrReservationClosed
+	}
+
+	for _, item := range reservation.Items {
+		if _, err := tx.Exec(ctx, stockSQL, item.SKU, item.Qty); err != nil {
+			return Reservation{}, err
+		}
+	}
+	if _, err := tx.Exec(ctx, `UPDATE reservations SET status = $2, updated_at = NOW() WHERE id = $1`, id, target); err != nil {
+		return Reservation{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Reservation{}, fmt.Errorf("commit reservation transaction: %w", err)
+	}
+	reservation.Status = target
+	return reservation, nil
+}
+
+// ExpireDue releases up to limit pending reservations whose expiry has passed.
+// SKIP LOCKED lets several replicas run the loop without blocking each other.
+func (s *PostgresStore) ExpireDue(ctx context.Context, limit int) (int, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+SELECT id FROM reservations
+WHERE status = 'pending' AND expires_at <= NOW()
+ORDER BY expires_at
+LIMIT $1
+FOR UPDATE SKIP LOCKED`, limit)
+	if err != nil {
+		return 0, err
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	// Lock stock rows in SKU order, matching Reserve, before the bulk update
+	// whose join order Postgres is free to choose.
+	if _, err := tx.Exec(ctx, `
+SELECT sku FROM stock_levels
+WHERE sku IN (SELECT sku FROM reservation_items WHERE reservation_id = ANY($1))
+ORDER BY sku
+FOR UPDATE`, ids); err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec(ctx, `
+UPDATE stock_levels sl
+SET reserved = sl.reserved - due.qty, updated_at = NOW()
+FROM (
+	SELECT sku, SUM(qty)::INTEGER AS qty
+	FROM reservation_items
+	WHERE reservation_id = ANY($1)
+	GROUP BY sku
+) due
+WHERE sl.sku = due.sku`, ids)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(
+		ctx,
+		`UPDATE reservations SET status = 'expired', updated_at = NOW() WHERE id = ANY($1)`,
+		ids,
+	); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit expiry transaction: %w", err)
+	}
+	return len(ids), nil
+}
+
+type queryer interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+func getReservation(ctx context.Context, q queryer, id string, forUpdate bool) (Reservation, error) {
+	query := `SELECT id, order_id, status, expires_at, created_at FROM reservations WHERE id = $1`
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+	var reservation Reservation
+	err := q.QueryRow(ctx, query, id).Scan(
+		&reservation.ID, &reservation.OrderID, &reservation.Status, &reservation.ExpiresAt, &reservation.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Reservation{}, ErrNotFound
+	}
+	if err != nil {
+		return Reservation{}, err
+	}
+
+	rows, err := q.Query(ctx, `SELECT sku, qty FROM reservation_items WHERE reservation_id = $1 ORDER BY sku`, id)
+	if err != nil {
+		return Reservation{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item ReservationItem
+		if err := rows.Scan(&item.SKU, &item.Qty); err != nil {
+			return Reservation{}, err
+		}
+		reservation.Items = append(reservation.Items, item)
+	}
+	return reservation, rows.Err()
+}
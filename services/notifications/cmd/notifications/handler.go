@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/triad-platform/triad-app/pkg/httpx"
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+	"github.com/triad-platform/triad-app/pkg/money"
+	"github.com/triad-platform/triad-app/services/notifications/internal/notify"
+)
+
+const (
+	// codeDeliveryFailed means no target could be notified.
+	codeDeliveryFailed = "delivery_failed"
+
+	// statusDuplicate answers a notify call for an event already sent.
+	statusDuplicate = "duplicate"
+	// statusDeferred answers a notify call held back by quiet hours.
+	statusDeferred = "deferred"
+	// statusSkipped answers a notify call for a user who opted out.
+	statusSkipped = "skipped"
+
+	defaultListLimit = 50
+	maxListLimit     = 100
+)
+
+func notifyHandler(cfg notifyConfig, log zerolog.Logger, metrics *metricsx.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		metrics.Inc("requests_total")
+		var req notifyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			metrics.Inc("validation_errors_total")
+			if errors.Is(err, money.ErrUnknownCurrency) {
+				httpx.ValidationError(w, r, "unsupported currency", []httpx.FieldError{{Field: "currency", Code: httpx.FieldUnsupported}})
+				return
+			}
+			httpx.Error(w, r, http.StatusBadRequest, httpx.CodeInvalidJSON, "invalid json")
+			return
+		}
+		if req.EventType == "" {
+			req.EventType = notify.EventOrderCreated
+		}
+		errs := req.validate()
+		if !cfg.Dispatcher.Supports(req.EventType) {
+			errs = append(errs, httpx.FieldError{Field: "event_type", Code: httpx.FieldUnsupported})
+		}
+		if len(errs) > 0 {
+			metrics.Inc("validation_errors_total")
+			httpx.ValidationError(w, r, "invalid notification", errs)
+			return
+		}
+
+		// Redelivered calls for an event that was already sent get the
+		// earlier outcome instead of a second message.
+		if cfg.Store != nil {
+			claimed, err := cfg.Store.Claim(r.Context(), req.OrderID, req.EventType, req.UserID)
+			if err != nil {
+				metrics.Inc("store_errors_total")
+				httpx.Error(w, r, http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "notification store unavailable")
+				return
+			}
+			if !claimed {
+				metrics.Inc("duplicates_total")
+				writeDuplicate(w, r, cfg.Store, req, log)
+				return
+			}
+		}
+
+		log.Info().
+			Str("event_type", req.EventType).
+			Str("order_id", req.OrderID).
+			Str("user_id", req.UserID).
+			Str("request_id", req.RequestID).
+			Int64("total_cents", req.TotalCents).
+			Str("currency", req.Currency.String()).
+			Msg("notification accepted")
+		metrics.Inc("accepted_total")
+
+		plan, err := cfg.plan(r.Context(), req.event(), cfg.now())
+		if err != nil {
+			metrics.Inc("preference_errors_total")
+			cfg.settle(r.Context(), req.OrderID, req.EventType, false, log, metrics)
+			httpx.Error(w, r, http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "notification preferences unavailable")
+			return
+		}
+		if len(plan.targets) == 0 {
+			metrics.Inc("skipped_total")
+			cfg.settle(r.Context(), req.OrderID, req.EventType, true, log, metrics)
+			writeNotifyResponse(w, notifyResponse{Status: statusSkipped, Deliveries: []notify.Result{}})
+			return
+		}
+		if !plan.deliverAt.IsZero() {
+			err := cfg.Deferrals.Defer(r.Context(), notify.Deferred{Event: plan.event, DeliverAt: plan.deliverAt})
+			if err != nil {
+				metrics.Inc("store_errors_total")
+				cfg.settle(r.Context(), req.OrderID, req.EventType, false, log, metrics)
+				httpx.Error(w, r, http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "notification store unavailable")
+				return
+			}
+			metrics.Inc("deferred_total")
+			deliverAt := plan.deliverAt.UTC()
+			writeNotifyResponse(w, notifyResponse{Status: statusDeferred, DeliverAt: &deliverAt, Deliveries: []notify.Result{}})
+			return
+		}
+
+		results, err := cfg.Dispatcher.Deliver(r.Context(), plan.event, plan.targets)
+		status := notify.StatusFailed
+		if err == nil {
+			status = deliveryStatus(results)
+		}
+		// A failed event is released so a retry can send it; a partial one
+		// is not, so the targets that got it are not sent it again.
+		cfg.settle(r.Context(), req.OrderID, req.EventType, status != notify.StatusFailed, log, metrics)
+		if err != nil {
+			metrics.Inc("delivery_errors_total")
+			httpx.Error(w, r, http.StatusInternalServerError, httpx.CodeInternal, "failed to render notification")
+			return
+		}
+		if status == notify.StatusFailed {
+			metrics.Inc("delivery_failures_total")
+			httpx.WriteProblem(w, r, httpx.Problem{
+				Status:     http.StatusBadGateway,
+				Code:       codeDeliveryFailed,
+				Detail:     "no channel delivered the notification",
+				Extensions: map[string]any{"deliveries": results},
+			})
+			return
+		}
+		writeNotifyResponse(w, notifyResponse{Status: status, Deliveries: results})
+	}
+}
+
+// deliveryPlan is where an event goes and, when DeliverAt is set, that it
+// waits for the user's quiet hours to end.
+type deliveryPlan struct {
+	event     notify.Event
+	targets   []notify.Target
+	deliverAt time.Time
+}
+
+// plan resolves the event's targets from the user's preferences. Users
+// without preferences get the configured targets and no quiet hours.
+func (cfg notifyConfig) plan(ctx context.Context, event notify.Event, now time.Time) (deliveryPlan, error) {
+	plan := deliveryPlan{event: event, targets: cfg.Targets}
+	if cfg.Preferences == nil {
+		return plan, nil
+	}
+	prefs, err := cfg.Preferences.GetPreferences(ctx, event.UserID)
+	if errors.Is(err, notify.ErrPreferencesNotFound) {
+		return plan, nil
+	}
+	if err != nil {
+		return deliveryPlan{}, err
+	}
+	plan.targets = prefs.Targets(event.Type)
+	plan.event.Locale = prefs.Locale
+	if until, quiet := prefs.QuietUntil(now); quiet && cfg.Deferrals != nil {
+		plan.deliverAt = until
+	}
+	return plan, nil
+}
+
+// settle completes the event's claim, or releases it when the event was not
+// sent so a retry can send it.
+func (cfg notifyConfig) settle(ctx context.Context, orderID, eventType string, done bool, log zerolog.Logger, metrics *metricsx.Registry) {
+	if cfg.Store == nil {
+		return
+	}
+	settle := cfg.Store.Complete
+	if !done {
+		settle = cfg.Store.Release
+	}
+	if err := settle(ctx, orderID, eventType); err != nil {
+		metrics.Inc("store_errors_total")
+		log.Error().Err(err).Str("order_id", orderID).Str("event_type", eventType).Msg("failed to settle notification claim")
+	}
+}
+
+func (cfg notifyConfig) now() time.Time {
+	if cfg.Now != nil {
+		return cfg.Now()
+	}
+	return time.Now()
+}
+
+// writeDuplicate answers with the deliveries recorded for the event, which
+// are still running if the first call has not finished.
+func writeDuplicate(w http.ResponseWriter, r *http.Request, store notify.Store, req notifyRequest, log zerolog.Logger) {
+	resp := notifyResponse{Status: statusDuplicate, Deliveries: []notify.Result{}}
+	history, err := store.List(r.Context(), notify.ListFilter{OrderID: req.OrderID, EventType: req.EventType, Limit: maxListLimit})
+	if err != nil {
+		log.Error().Err(err).Str("order_id", req.OrderID).Msg("failed to load notification history")
+	}
+	for _, n := range history {
+		resp.Deliveries = append(resp.Deliveries, notify.Result{
+			Channel:    n.Channel,
+			Recipient:  n.Recipient,
+			Status:     n.Status,
+			Attempts:   n.Attempts,
+			ProviderID: n.ProviderMessageID,
+			Error:      n.LastError,
+		})
+	}
+	writeNotifyResponse(w, resp)
+}
+
+func writeNotifyResponse(w http.ResponseWriter, resp notifyResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+type listNotificationsResponse struct {
+	Notifications []notify.Notification `json:"notifications"`
+}
+
+// listNotificationsHandler serves GET /v1/notifications?order_id=...|user_id=...
+// with optional event_type and limit (1-100, default 50), newest first.
+func listNotificationsHandler(store notify.Store, metrics *metricsx.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		metrics.Inc("list_requests_total")
+		q := r.URL.Query()
+		filter := notify.ListFilter{
+			OrderID:   q.Get("order_id"),
+			UserID:    q.Get("user_id"),
+			EventType: q.Get("event_type"),
+			Limit:     defaultListLimit,
+		}
+		var errs []httpx.FieldError
+		if filter.OrderID == "" && filter.UserID == "" {
+			errs = append(errs, httpx.FieldError{Field: "order_id", Code: httpx.FieldRequired, Detail: "order_id or user_id is required"})
+		}
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxListLimit {
+				errs = append(errs, httpx.FieldError{Field: "limit", Code: httpx.FieldOutOfRange})
+			}
+			filter.Limit = n
+		}
+		if len(errs) > 0 {
+			metrics.Inc("validation_errors_total")
+			httpx.ValidationError(w, r, "invalid query", errs)
+			return
+		}
+
+		notifications, err := store.List(r.Context(), filter)
+		if err != nil {
+			metrics.Inc("store_errors_total")
+			httpx.Error(w, r, http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "notification store unavailable")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(listNotificationsResponse{Notifications: notifications})
+	}
+}
+
+type notifyRequest struct {
+	// EventType selects the templates (order.created).
+	EventType  string         `json:"event_type"`
+	OrderID    string         `json:"order_id"`
+	UserID     string         `json:"user_id"`
+	RequestID  string         `json:"request_id"`
+	TotalCents int64          `json:"total_cents"`
+	Currency   money.Currency `json:"currency"`
+	CreatedAt  string         `json:"created_at"`
+}
+
+func (r notifyRequest) validate() []httpx.FieldError {
+	var errs []httpx.FieldError
+	if r.OrderID == "" {
+		errs = append(errs, httpx.FieldError{Field: "order_id", Code: httpx.FieldRequired})
+	}
+	if r.UserID == "" {
+		errs = append(errs, httpx.FieldError{Field: "user_id", Code: httpx.FieldRequired})
+	}
+	if r.Currency.IsZero() {
+		errs = append(errs, httpx.FieldError{Field: "currency", Code: httpx.FieldRequired})
+	}
+	if r.TotalCents <= 0 {
+		errs = append(errs, httpx.FieldError{Field: "total_cents", Code: httpx.FieldNotPositive})
+	}
+	return errs
+}
+
+func (r notifyRequest) event() notify.Event {
+	return notify.Event{
+		Type:      r.EventType,
+		OrderID:   r.OrderID,
+		UserID:    r.UserID,
+		RequestID: r.RequestID,
+		Total:     money.New(r.TotalCents, r.Currency),
+		CreatedAt: r.CreatedAt,
+	}
+}
+
+// notifyResponse status is delivered when every target was notified and
+// partial when only some were. A deferred notification is sent at
+// DeliverAt; a skipped one is not sent because the user opted out.
+type notifyResponse struct {
+	Status     string          `json:"status"`
+	DeliverAt  *time.Time      `json:"deliver_at,omitempty"`
+	Deliveries []notify.Result `json:"deliveries"`
+}
+
+func deliveryStatus(results []notify.Result) string {
+	sent := 0
+	for _, r := range results {
+		if r.Status == notify.StatusSent {
+			sent++
+		}
+	}
+	switch {
+	case sent == 0:
+		return notify.StatusFailed
+	case sent < len(results):
+		return "partial"
+	default:
+		return "delivered"
+	}
+}
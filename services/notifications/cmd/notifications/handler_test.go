@@ -0,0 +1,386 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/triad-platform/triad-app/services/notifications/internal/notify"
+)
+
+func TestNotifyEndpoint_Dedupe(t *testing.T) {
+	t.Parallel()
+
+	templates, err := notify.LoadTemplates(notify.DefaultTemplates())
+	if err != nil {
+		t.Fatalf("load templates: %v", err)
+	}
+	store := newMemoryStore()
+	channel := &countingChannel{errs: []error{errors.New("connection refused")}}
+	dispatcher := notify.NewDispatcher(templates, store, nil, channel)
+	dispatcher.MaxAttempts = 1
+	r := newRouter(zerolog.Nop(), nil, notifyConfig{
+		Dispatcher: dispatcher,
+		Targets:    []notify.Target{{Channel: "count", Recipient: "customer@example.com"}},
+		Store:      store,
+	})
+
+	notifyOnce := func() (int, notifyResponse) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/notify", strings.NewReader(`{"order_id":"o-1","user_id":"u-1","total_cents":1500,"currency":"USD"}`)))
+		var resp notifyResponse
+		_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp
+	}
+
+	// The first call fails, so the event is released for the retry.
+	if code, _ := notifyOnce(); code != http.StatusBadGateway {
+		t.Fatalf("failed delivery status mismatch: got=%d want=%d", code, http.StatusBadGateway)
+	}
+	if code, resp := notifyOnce(); code != http.StatusAccepted || resp.Status != "delivered" {
+		t.Fatalf("retry mismatch: got=%d %+v", code, resp)
+	}
+	code, resp := notifyOnce()
+	if code != http.StatusAccepted || resp.Status != statusDuplicate {
+		t.Fatalf("redelivery mismatch: got=%d %+v", code, resp)
+	}
+	if len(resp.Deliveries) != 2 || resp.Deliveries[0].Status != notify.StatusSent || resp.Deliveries[1].Status != notify.StatusFailed {
+		t.Fatalf("duplicate deliveries mismatch: got=%+v", resp.Deliveries)
+	}
+	if channel.calls != 2 {
+		t.Fatalf("channel calls mismatch: got=%d want=2", channel.calls)
+	}
+}
+
+func TestNotifyEndpoint_Preferences(t *testing.T) {
+	t.Parallel()
+
+	store := newMemoryStore()
+	for _, p := range []notify.Preferences{
+		{UserID: "u-1", Email: "u1@example.com", WebhookURL: "https://hooks.example.com/u-1", Channels: map[string][]string{notify.EventOrderCreated: {"email"}}, Locale: "de-DE"},
+		{UserID: "u-2", Email: "u2@example.com", Channels: map[string][]string{}},
+		{UserID: "u-3", Email: "u3@example.com", Channels: map[string][]string{notify.EventOrderCreated: {"email"}}, TimeZone: "Europe/Berlin", QuietHours: &notify.QuietHours{Start: "22:00", End: "07:00"}},
+	} {
+		_, _ = store.PutPreferences(context.Background(), p)
+	}
+	email := &countingChannel{name: "email"}
+	r := newRouter(zerolog.Nop(), nil, notifyConfig{
+		Dispatcher:  newTestDispatcher(t, store, email),
+		Targets:     []notify.Target{{Channel: "email", Recipient: "ops@example.com"}},
+		Store:       store,
+		Preferences: store,
+		Deferrals:   store,
+		// 23:30 in Berlin.
+		Now: func() time.Time { return time.Date(2026, 3, 1, 22, 30, 0, 0, time.UTC) },
+	})
+
+	tests := []struct {
+		name      string
+		orderID   string
+		userID    string
+		wantBody  string
+		wantEvent string
+	}{
+		{name: "preferred channel", orderID: "o-1", userID: "u-1", wantBody: `"status":"delivered"`, wantEvent: "done"},
+		{name: "opted out", orderID: "o-2", userID: "u-2", wantBody: `"status":"skipped"`, wantEvent: "done"},
+		{name: "quiet hours", orderID: "o-3", userID: "u-3", wantBody: `"status":"deferred","deliver_at":"2026-03-02T06:00:00Z"`, wantEvent: "deferred"},
+		{name: "deferred redelivery", orderID: "o-3", userID: "u-3", wantBody: `"status":"duplicate"`, wantEvent: "deferred"},
+		{name: "no preferences", orderID: "o-4", userID: "u-4", wantBody: `"status":"delivered"`, wantEvent: "done"},
+	}
+	for _, tc := range tests {
+		rec := httptest.NewRecorder()
+		body := `{"order_id":"` + tc.orderID + `","user_id":"` + tc.userID + `","total_cents":1500,"currency":"EUR"}`
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/notify", strings.NewReader(body)))
+		if rec.Code != http.StatusAccepted || !strings.Contains(rec.Body.String(), tc.wantBody) {
+			t.Fatalf("%s: response mismatch: got=%d %q want body containing %q", tc.name, rec.Code, rec.Body.String(), tc.wantBody)
+		}
+		if got := store.event(tc.orderID, notify.EventOrderCreated); got != tc.wantEvent {
+			t.Fatalf("%s: event state mismatch: got=%q want=%q", tc.name, got, tc.wantEvent)
+		}
+	}
+
+	if got := email.recipients(); got != "u1@example.com,ops@example.com" {
+		t.Fatalf("recipients mismatch: got=%s", got)
+	}
+	if !strings.Contains(email.sent[0].Subject, "Bestellung") || email.sent[0].Template != "order.created.de" {
+		t.Fatalf("localized message mismatch: got=%q from %q", email.sent[0].Subject, email.sent[0].Template)
+	}
+}
+
+func newTestDispatcher(t *testing.T, recorder notify.DeliveryRecorder, channels ...notify.Channel) *notify.Dispatcher {
+	t.Helper()
+	templates, err := notify.LoadTemplates(notify.DefaultTemplates())
+	if err != nil {
+		t.Fatalf("load templates: %v", err)
+	}
+	d := notify.NewDispatcher(templates, recorder, nil, channels...)
+	d.MaxAttempts = 1
+	return d
+}
+
+func TestListNotificationsEndpoint(t *testing.T) {
+	t.Parallel()
+
+	store := newMemoryStore()
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i, a := range []notify.Attempt{
+		{MessageID: "m-1", OrderID: "o-1", UserID: "u-1", EventType: notify.EventOrderCreated, Channel: "email", Status: notify.StatusFailed, Attempt: 1, Error: "timeout"},
+		{MessageID: "m-1", OrderID: "o-1", UserID: "u-1", EventType: notify.EventOrderCreated, Channel: "email", Status: notify.StatusSent, Attempt: 2, ProviderID: "<m-1@pulsecart>"},
+		{MessageID: "m-2", OrderID: "o-2", UserID: "u-1", EventType: notify.EventOrderCreated, Channel: "webhook", Status: notify.StatusSent, Attempt: 1},
+		{MessageID: "m-3", OrderID: "o-3", UserID: "u-2", EventType: notify.EventOrderCreated, Channel: "email", Status: notify.StatusSent, Attempt: 1},
+	} {
+		a.At = at.Add(time.Duration(i) * time.Minute)
+		_ = store.RecordAttempt(context.Background(), a)
+	}
+	r := newRouter(zerolog.Nop(), nil, notifyConfig{Store: store})
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantIDs    string
+	}{
+		{name: "by order", query: "?order_id=o-1", wantStatus: http.StatusOK, wantIDs: "m-1"},
+		{name: "by user", query: "?user_id=u-1", wantStatus: http.StatusOK, wantIDs: "m-2,m-1"},
+		{name: "limit", query: "?user_id=u-1&limit=1", wantStatus: http.StatusOK, wantIDs: "m-2"},
+		{name: "nothing yet", query: "?order_id=o-9", wantStatus: http.StatusOK},
+		{name: "no filter", query: "", wantStatus: http.StatusBadRequest},
+		{name: "bad limit", query: "?order_id=o-1&limit=500", wantStatus: http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/notifications"+tc.query, nil))
+		if rec.Code != tc.wantStatus {
+			t.Fatalf("%s: status mismatch: got=%d want=%d body=%q", tc.name, rec.Code, tc.wantStatus, rec.Body.String())
+		}
+		if tc.wantStatus != http.StatusOK {
+			continue
+		}
+		var resp listNotificationsResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Notifications == nil {
+			t.Fatalf("%s: decode response: %v body=%q", tc.name, err, rec.Body.String())
+		}
+		var ids []string
+		for _, n := range resp.Notifications {
+			ids = append(ids, n.ID)
+		}
+		if got := strings.Join(ids, ","); got != tc.wantIDs {
+			t.Fatalf("%s: notifications mismatch: got=%s want=%s", tc.name, got, tc.wantIDs)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/notifications?order_id=o-1", nil))
+	var resp listNotificationsResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+	got := resp.Notifications[0]
+	if got.Status != notify.StatusSent || got.Attempts != 2 || got.ProviderMessageID != "<m-1@pulsecart>" || got.SentAt == nil || !got.CreatedAt.Equal(at) {
+		t.Fatalf("notification mismatch: got=%+v", got)
+	}
+}
+
+// countingChannel fails with errs in turn, then succeeds. It is named
+// count unless name is set.
+type countingChannel struct {
+	mu    sync.Mutex
+	name  string
+	calls int
+	errs  []error
+	sent  []notify.Message
+}
+
+func (c *countingChannel) Name() string {
+	if c.name == "" {
+		return "count"
+	}
+	return c.name
+}
+
+func (c *countingChannel) Send(_ context.Context, msg notify.Message) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls++
+	c.sent = append(c.sent, msg)
+	if c.calls <= len(c.errs) {
+		return "", c.errs[c.calls-1]
+	}
+	return msg.ID, nil
+}
+
+// memoryStore mirrors notify.PostgresStore in memory.
+type memoryStore struct {
+	mu            sync.Mutex
+	notifications map[string]notify.Notification
+	events        map[string]string
+	preferences   map[string]notify.Preferences
+	deferred      map[string]notify.Deferred
+	leased        map[string]time.Time
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		notifications: map[string]notify.Notification{},
+		events:        map[string]string{},
+		preferences:   map[string]notify.Preferences{},
+		deferred:      map[string]notify.Deferred{},
+		leased:        map[string]time.Time{},
+	}
+}
+
+func (s *memoryStore) RecordAttempt(_ context.Context, a notify.Attempt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := s.notifications[a.MessageID]
+	if ok && n.Status == notify.StatusSent {
+		return nil
+	}
+	if !ok {
+		n = notify.Notification{ID: a.MessageID, OrderID: a.OrderID, UserID: a.UserID, EventType: a.EventType, Channel: a.Channel, Recipient: a.Recipient, Template: a.Template, CreatedAt: a.At}
+	}
+	n.Status, n.Attempts, n.ProviderMessageID, n.LastError, n.UpdatedAt = a.Status, a.Attempt, a.ProviderID, a.Error, a.At
+	if a.Status == notify.StatusSent {
+		sentAt := a.At
+		n.SentAt = &sentAt
+	}
+	s.notifications[a.MessageID] = n
+	return nil
+}
+
+func (s *memoryStore) Claim(_ context.Context, orderID, eventType, _ string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.events[orderID+"|"+eventType]; ok {
+		return false, nil
+	}
+	s.events[orderID+"|"+eventType] = "sending"
+	return true, nil
+}
+
+func (s *memoryStore) Complete(_ context.Context, orderID, eventType string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events[orderID+"|"+eventType] = "done"
+	return nil
+}
+
+func (s *memoryStore) Release(_ context.Context, orderID, eventType string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.events, orderID+"|"+eventType)
+	return nil
+}
+
+func (s *memoryStore) List(_ context.Context, f notify.ListFilter) ([]notify.Notification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := []notify.Notification{}
+	for _, n := range s.notifications {
+		if (f.OrderID == "" || n.OrderID == f.OrderID) && (f.UserID == "" || n.UserID == f.UserID) && (f.EventType == "" || n.EventType == f.EventType) {
+			out = append(out, n)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	if len(out) > f.Limit {
+		out = out[:f.Limit]
+	}
+	return out, nil
+}
+
+func (s *memoryStore) GetPreferences(_ context.Context, userID string) (notify.Preferences, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.preferences[userID]
+	if !ok {
+		return notify.Preferences{}, notify.ErrPreferencesNotFound
+	}
+	return p, nil
+}
+
+func (s *memoryStore) PutPreferences(_ context.Context, p notify.Preferences) (notify.Preferences, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	p.CreatedAt, p.UpdatedAt = now, now
+	if old, ok := s.preferences[p.UserID]; ok {
+		p.CreatedAt = old.CreatedAt
+	}
+	s.preferences[p.UserID] = p
+	return p, nil
+}
+
+func (s *memoryStore) DeletePreferences(_ context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.preferences[userID]; !ok {
+		return notify.ErrPreferencesNotFound
+	}
+	delete(s.preferences, userID)
+	return nil
+}
+
+func (s *memoryStore) Defer(_ context.Context, d notify.Deferred) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d.ID = d.Event.OrderID + "|" + d.Event.Type
+	s.deferred[d.ID] = d
+	s.events[d.ID] = "deferred"
+	return nil
+}
+
+func (s *memoryStore) Due(_ context.Context, now time.Time, limit int, lease time.Duration) ([]notify.Deferred, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []notify.Deferred
+	for id, d := range s.deferred {
+		if len(out) == limit || d.DeliverAt.After(now) || s.leased[id].After(now) {
+			continue
+		}
+		d.Attempts++
+		s.deferred[id] = d
+		s.leased[id] = now.Add(lease)
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+func (s *memoryStore) Reschedule(_ context.Context, id string, at time.Time, lastError string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := s.deferred[id]
+	d.DeliverAt, d.LastError = at, lastError
+	s.deferred[id] = d
+	delete(s.leased, id)
+	return nil
+}
+
+func (s *memoryStore) Finish(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.deferred, id)
+	delete(s.leased, id)
+	return nil
+}
+
+func (s *memoryStore) event(orderID, eventType string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.events[orderID+"|"+eventType]
+}
+
+func (c *countingChannel) recipients() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var out []string
+	for _, msg := range c.sent {
+		out = append(out, msg.Recipient)
+	}
+	return strings.Join(out, ",")
+}
@@ -2,57 +2,68 @@ package main
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
+	_ "time/tzdata" // quiet hours need zones the distroless image lacks
 
 	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog"
 	"github.com/triad-platform/triad-app/pkg/config"
 	"github.com/triad-platform/triad-app/pkg/httpx"
 	"github.com/triad-platform/triad-app/pkg/logx"
 	"github.com/triad-platform/triad-app/pkg/metricsx"
+	"github.com/triad-platform/triad-app/services/notifications/internal/notify"
 )
 
-func newRouter(log zerolog.Logger, metrics *metricsx.Registry) http.Handler {
+// notifyConfig is who gets notified and how. Without a Dispatcher, messages
+// only go to the log channel; without a Store nothing is deduplicated or
+// kept. Targets is for users without Preferences, and quiet hours need
+// Deferrals.
+type notifyConfig struct {
+	Dispatcher  *notify.Dispatcher
+	Targets     []notify.Target
+	Store       notify.Store
+	Preferences notify.PreferenceStore
+	Deferrals   notify.DeferralStore
+	// Now defaults to time.Now.
+	Now func() time.Time
+	// Resolver checks webhook hosts on save; it defaults to net.DefaultResolver.
+	Resolver notify.HostResolver
+}
+
+func newRouter(log zerolog.Logger, metrics *metricsx.Registry, cfg notifyConfig) http.Handler {
 	if metrics == nil {
 		metrics = metricsx.NewRegistry("triad_notifications")
 	}
+	if cfg.Dispatcher == nil {
+		templates, err := notify.LoadTemplates(notify.DefaultTemplates())
+		if err != nil {
+			panic(err)
+		}
+		cfg.Dispatcher = notify.NewDispatcher(templates, notify.LogRecorder{Log: log}, metrics, &notify.LogChannel{Log: log})
+		cfg.Targets = []notify.Target{{Channel: "log"}}
+	}
 	r := chi.NewRouter()
+	r.NotFound(httpx.NotFound)
+	r.MethodNotAllowed(httpx.MethodNotAllowed)
 	r.Get("/healthz", httpx.Healthz)
 	r.Get("/readyz", httpx.Readyz)
 	r.Get("/metrics", metrics.Handler().ServeHTTP)
-	r.Post("/v1/notify", func(w http.ResponseWriter, r *http.Request) {
-		metrics.Inc("requests_total")
-		var req notifyRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			metrics.Inc("validation_errors_total")
-			http.Error(w, "invalid json", http.StatusBadRequest)
-			return
-		}
-		if err := req.validate(); err != nil {
-			metrics.Inc("validation_errors_total")
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-
-		log.Info().
-			Str("order_id", req.OrderID).
-			Str("user_id", req.UserID).
-			Str("request_id", req.RequestID).
-			Int("total_cents", req.TotalCents).
-			Str("currency", req.Currency).
-			Msg("notification accepted")
-		metrics.Inc("accepted_total")
-
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusAccepted)
-		_ = json.NewEncoder(w).Encode(notifyResponse{Status: "accepted"})
-	})
+	r.Post("/v1/notify", notifyHandler(cfg, log, metrics))
+	if cfg.Store != nil {
+		r.Get("/v1/notifications", listNotificationsHandler(cfg.Store, metrics))
+	}
+	if cfg.Preferences != nil {
+		prefs := preferencesHandler{Store: cfg.Preferences, Dispatcher: cfg.Dispatcher, Resolver: cfg.Resolver, Metrics: metrics}
+		r.Get(preferencesPath, prefs.get)
+		r.Put(preferencesPath, prefs.put)
+		r.Delete(preferencesPath, prefs.delete)
+	}
 	return r
 }
 
@@ -62,9 +73,36 @@ func main() {
 
 	port := config.Getenv("PORT", "8082")
 
+	dbPool, err := pgxpool.New(context.Background(), databaseURL())
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to connect to Postgres")
+	}
+	defer dbPool.Close()
+	store := notify.NewPostgresStore(dbPool)
+	schemaCtx, schemaCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if err := store.EnsureSchema(schemaCtx); err != nil {
+		schemaCancel()
+		log.Fatal().Err(err).Msg("failed to ensure notifications schema")
+	}
+	schemaCancel()
+
+	notifyCfg, err := notifySettings(log, metrics, store)
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid notification config")
+	}
+	sched := newScheduler(notifyCfg, log, metrics)
+	sched.Interval = durationEnv("NOTIFY_SCHEDULER_INTERVAL", sched.Interval)
+	if n, err := strconv.Atoi(config.Getenv("NOTIFY_DEFERRED_MAX_ATTEMPTS", "5")); err == nil && n > 0 {
+		sched.MaxAttempts = n
+	}
+	sched.Backoff = durationEnv("NOTIFY_DEFERRED_RETRY_BACKOFF", sched.Backoff)
+	schedCtx, schedCancel := context.WithCancel(context.Background())
+	defer schedCancel()
+	go sched.Run(schedCtx)
+
 	srv := &http.Server{
 		Addr:              ":" + port,
-		Handler:           newRouter(log, metrics),
+		Handler:           newRouter(log, metrics, notifyCfg),
 		ReadHeaderTimeout: 5 * time.Second,
 	}
 
@@ -79,37 +117,9 @@ func main() {
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 	<-stop
 
+	schedCancel()
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	_ = srv.Shutdown(ctx)
 	log.Info().Msg("notifications shutdown complete")
 }
-
-type notifyRequest struct {
-	OrderID    string `json:"order_id"`
-	UserID     string `json:"user_id"`
-	RequestID  string `json:"request_id"`
-	TotalCents int    `json:"total_cents"`
-	Currency   string `json:"currency"`
-	CreatedAt  string `json:"created_at"`
-}
-
-func (r notifyRequest) validate() error {
-	if r.OrderID == "" {
-		return errors.New("missing order_id")
-	}
-	if r.UserID == "" {
-		return errors.New("missing user_id")
-	}
-	if r.Currency == "" {
-		return errors.New("missing currency")
-	}
-	if r.TotalCents <= 0 {
-		return errors.New("missing or invalid total_cents")
-	}
-	return nil
-}
-
-type notifyResponse struct {
-	Status string `json:"status"`
-}
@@ -2,35 +2,53 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/rs/zerolog"
+	"github.com/triad-platform/triad-app/pkg/httpx"
 	"github.com/triad-platform/triad-app/pkg/metricsx"
+	"github.com/triad-platform/triad-app/services/notifications/internal/notify"
 )
 
 func TestNotifyEndpoint(t *testing.T) {
 	t.Parallel()
 
 	metrics := metricsx.NewRegistry("triad_notifications")
-	r := newRouter(zerolog.Nop(), metrics)
+	r := newRouter(zerolog.Nop(), metrics, notifyConfig{})
 
 	tests := []struct {
 		name       string
 		body       string
 		wantStatus int
+		wantCode   string
 	}{
 		{
 			name:       "invalid json",
 			body:       "{",
 			wantStatus: http.StatusBadRequest,
+			wantCode:   httpx.CodeInvalidJSON,
 		},
 		{
 			name:       "missing order_id",
 			body:       `{"user_id":"u-1","total_cents":1500,"currency":"USD","created_at":"2026-02-27T00:00:00Z"}`,
 			wantStatus: http.StatusBadRequest,
+			wantCode:   httpx.CodeValidationFailed,
+		},
+		{
+			name:       "unsupported currency",
+			body:       `{"order_id":"o-1","user_id":"u-1","total_cents":1500,"currency":"ABC","created_at":"2026-02-27T00:00:00Z"}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   httpx.CodeValidationFailed,
+		},
+		{
+			name:       "amount above int32",
+			body:       `{"order_id":"o-1","user_id":"u-1","total_cents":5000000000,"currency":"JPY","created_at":"2026-02-27T00:00:00Z"}`,
+			wantStatus: http.StatusAccepted,
 		},
 		{
 			name:       "valid payload",
@@ -49,6 +67,17 @@ func TestNotifyEndpoint(t *testing.T) {
 			if rec.Code != tc.wantStatus {
 				t.Fatalf("status mismatch: got=%d want=%d body=%q", rec.Code, tc.wantStatus, rec.Body.String())
 			}
+			if tc.wantCode != "" {
+				var problem struct {
+					Code string `json:"code"`
+				}
+				if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+					t.Fatalf("decode problem: %v", err)
+				}
+				if problem.Code != tc.wantCode {
+					t.Fatalf("code mismatch: got=%q want=%q", problem.Code, tc.wantCode)
+				}
+			}
 		})
 	}
 }
@@ -57,7 +86,7 @@ func TestMetricsEndpoint(t *testing.T) {
 	t.Parallel()
 
 	metrics := metricsx.NewRegistry("triad_notifications")
-	r := newRouter(zerolog.Nop(), metrics)
+	r := newRouter(zerolog.Nop(), metrics, notifyConfig{})
 
 	req := httptest.NewRequest(http.MethodPost, "/v1/notify", bytes.NewBufferString(`{"order_id":"o-1","user_id":"u-1","total_cents":1500,"currency":"USD","created_at":"2026-02-27T00:00:00Z"}`))
 	rec := httptest.NewRecorder()
@@ -80,3 +109,78 @@ func TestMetricsEndpoint(t *testing.T) {
 		t.Fatalf("expected accepted counter in metrics body, got=%q", body)
 	}
 }
+
+func TestNotifyEndpoint_DeliveryOutcome(t *testing.T) {
+	t.Parallel()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	templates, err := notify.LoadTemplates(notify.DefaultTemplates())
+	if err != nil {
+		t.Fatalf("load templates: %v", err)
+	}
+	dispatcher := notify.NewDispatcher(templates, nil, nil, &notify.LogChannel{Log: zerolog.Nop()}, notify.NewWebhookChannel(time.Second, ""))
+	dispatcher.MaxAttempts = 1
+	webhook := notify.Target{Channel: "webhook", Recipient: failing.URL}
+	logTarget := notify.Target{Channel: "log"}
+
+	tests := []struct {
+		name       string
+		targets    []notify.Target
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "delivered", targets: []notify.Target{logTarget}, wantStatus: http.StatusAccepted, wantBody: `"status":"delivered"`},
+		{name: "partial", targets: []notify.Target{logTarget, webhook}, wantStatus: http.StatusAccepted, wantBody: `"status":"partial"`},
+		{name: "failed", targets: []notify.Target{webhook}, wantStatus: http.StatusBadGateway, wantBody: `"code":"delivery_failed"`},
+		{name: "unsupported event type", targets: []notify.Target{logTarget}, body: `{"event_type":"order.lost","order_id":"o-1","user_id":"u-1","total_cents":1500,"currency":"USD"}`, wantStatus: http.StatusBadRequest, wantBody: `"field":"event_type"`},
+	}
+	for _, tc := range tests {
+		body := tc.body
+		if body == "" {
+			body = `{"order_id":"o-1","user_id":"u-1","total_cents":1500,"currency":"USD","created_at":"2026-02-27T00:00:00Z"}`
+		}
+		r := newRouter(zerolog.Nop(), nil, notifyConfig{Dispatcher: dispatcher, Targets: tc.targets})
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/notify", strings.NewReader(body)))
+		if rec.Code != tc.wantStatus || !strings.Contains(rec.Body.String(), tc.wantBody) {
+			t.Fatalf("%s: response mismatch: got=%d %q want=%d containing %q", tc.name, rec.Code, rec.Body.String(), tc.wantStatus, tc.wantBody)
+		}
+	}
+}
+
+func TestNotifySettings(t *testing.T) {
+	t.Setenv("SMTP_ADDR", "localhost:1025")
+	t.Setenv("NOTIFY_EMAIL_TO", "")
+	cfg, err := notifySettings(zerolog.Nop(), nil, nil)
+	if err != nil {
+		t.Fatalf("notify settings without recipients: %v", err)
+	}
+	// Email is still there for users who save an address.
+	if _, ok := cfg.Dispatcher.Channels["email"]; !ok || len(cfg.Targets) != 1 || cfg.Targets[0].Channel != "log" {
+		t.Fatalf("settings without recipients mismatch: channels=%v targets=%+v", cfg.Dispatcher.Channels, cfg.Targets)
+	}
+
+	t.Setenv("NOTIFY_EMAIL_TO", "a@example.com, b@example.com")
+	t.Setenv("NOTIFY_WEBHOOK_URL", "https://hooks.example.com/pulsecart")
+	cfg, err = notifySettings(zerolog.Nop(), nil, nil)
+	if err != nil {
+		t.Fatalf("notify settings: %v", err)
+	}
+	want := []notify.Target{
+		{Channel: "email", Recipient: "a@example.com"},
+		{Channel: "email", Recipient: "b@example.com"},
+		{Channel: "webhook", Recipient: "https://hooks.example.com/pulsecart"},
+	}
+	if len(cfg.Targets) != len(want) {
+		t.Fatalf("targets mismatch: got=%+v want=%+v", cfg.Targets, want)
+	}
+	for i := range want {
+		if cfg.Targets[i] != want[i] {
+			t.Fatalf("target %d mismatch: got=%+v want=%+v", i, cfg.Targets[i], want[i])
+		}
+	}
+}
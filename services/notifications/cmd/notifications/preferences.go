@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/triad-platform/triad-app/pkg/httpx"
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+	"github.com/triad-platform/triad-app/services/notifications/internal/notify"
+)
+
+const (
+	preferencesPath = "/v1/preferences/{userID}"
+
+	codePreferencesNotFound = "preferences_not_found"
+)
+
+// preferencesHandler serves GET, PUT and DELETE of a user's notification
+// preferences. PUT creates or replaces them.
+type preferencesHandler struct {
+	Store      notify.PreferenceStore
+	Dispatcher *notify.Dispatcher
+	Resolver   notify.HostResolver
+	Metrics    *metricsx.Registry
+}
+
+// preferencesRequest is the PUT body; the user comes from the path.
+type preferencesRequest struct {
+	Email      string              `json:"email"`
+	WebhookURL string              `json:"webhook_url"`
+	Channels   map[string][]string `json:"channels"`
+	Locale     string              `json:"locale"`
+	TimeZone   string              `json:"time_zone"`
+	QuietHours *notify.QuietHours  `json:"quiet_hours"`
+}
+
+func (h preferencesHandler) get(w http.ResponseWriter, r *http.Request) {
+	h.Metrics.Inc("preferences_get_requests_total")
+	userID, ok := h.authorize(w, r)
+	if !ok {
+		return
+	}
+	prefs, err := h.Store.GetPreferences(r.Context(), userID)
+	if err != nil {
+		h.writeStoreError(w, r, err)
+		return
+	}
+	writePreferences(w, http.StatusOK, prefs)
+}
+
+func (h preferencesHandler) put(w http.ResponseWriter, r *http.Request) {
+	h.Metrics.Inc("preferences_put_requests_total")
+	userID, ok := h.authorize(w, r)
+	if !ok {
+		return
+	}
+	var req preferencesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.Metrics.Inc("validation_errors_total")
+		httpx.Error(w, r, http.StatusBadRequest, httpx.CodeInvalidJSON, "invalid json")
+		return
+	}
+	prefs := notify.Preferences{
+		UserID:     userID,
+		Email:      req.Email,
+		WebhookURL: req.WebhookURL,
+		Channels:   req.Channels,
+		Locale:     req.Locale,
+		TimeZone:   req.TimeZone,
+		QuietHours: req.QuietHours,
+	}
+	if prefs.Channels == nil {
+		prefs.Channels = map[string][]string{}
+	}
+	errs := prefs.Validate(h.Dispatcher.Supports, h.Dispatcher.Channels)
+	if len(errs) == 0 && prefs.WebhookURL != "" {
+		if err := notify.CheckWebhookHost(r.Context(), h.resolver(), prefs.WebhookURL); err != nil {
+			errs = append(errs, httpx.FieldError{Field: "webhook_url", Code: httpx.FieldInvalid, Detail: "must resolve to public addresses"})
+		}
+	}
+	if len(errs) > 0 {
+		h.Metrics.Inc("validation_errors_total")
+		httpx.ValidationError(w, r, "invalid preferences", errs)
+		return
+	}
+
+	saved, err := h.Store.PutPreferences(r.Context(), prefs)
+	if err != nil {
+		h.writeStoreError(w, r, err)
+		return
+	}
+	writePreferences(w, http.StatusOK, saved)
+}
+
+func (h preferencesHandler) delete(w http.ResponseWriter, r *http.Request) {
+	h.Metrics.Inc("preferences_delete_requests_total")
+	userID, ok := h.authorize(w, r)
+	if !ok {
+		return
+	}
+	if err := h.Store.DeletePreferences(r.Context(), userID); err != nil {
+		h.writeStoreError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h preferencesHandler) resolver() notify.HostResolver {
+	if h.Resolver == nil {
+		return net.DefaultResolver
+	}
+	return h.Resolver
+}
+
+// authorize returns the path's user. Callers must send the gateway's
+// identity headers and may only touch their own preferences, unless they
+// are an admin.
+func (h preferencesHandler) authorize(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userID := chi.URLParam(r, "userID")
+	principal, ok := httpx.PrincipalFromHeaders(r.Header)
+	if !ok {
+		h.Metrics.Inc("preferences_unauthenticated_total")
+		httpx.Error(w, r, http.StatusUnauthorized, httpx.CodeUnauthenticated, "missing caller identity")
+		return "", false
+	}
+	if principal.Subject != userID && !principal.HasRole(httpx.RoleAdmin) {
+		h.Metrics.Inc("preferences_forbidden_total")
+		httpx.Error(w, r, http.StatusForbidden, httpx.CodeForbidden, "cannot access another user's preferences")
+		return "", false
+	}
+	return userID, true
+}
+
+func (h preferencesHandler) writeStoreError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, notify.ErrPreferencesNotFound) {
+		httpx.Error(w, r, http.StatusNotFound, codePreferencesNotFound, "no notification preferences for user")
+		return
+	}
+	h.Metrics.Inc("store_errors_total")
+	httpx.Error(w, r, http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "notification store unavailable")
+}
+
+func writePreferences(w http.ResponseWriter, status int, prefs notify.Preferences) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(prefs)
+}
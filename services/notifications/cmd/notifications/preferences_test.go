@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/triad-platform/triad-app/pkg/httpx"
+	"github.com/triad-platform/triad-app/services/notifications/internal/notify"
+)
+
+func TestPreferencesEndpoints(t *testing.T) {
+	t.Parallel()
+
+	store := newMemoryStore()
+	r := newRouter(zerolog.Nop(), nil, notifyConfig{
+		Dispatcher:  newTestDispatcher(t, nil, &countingChannel{name: "email"}, &countingChannel{name: "webhook"}, &countingChannel{name: "log"}),
+		Preferences: store,
+		Resolver: stubResolver{
+			"hooks.example.com":    {{IP: net.ParseIP("93.184.216.34")}},
+			"internal.example.com": {{IP: net.ParseIP("93.184.216.34")}, {IP: net.ParseIP("10.0.0.7")}},
+		},
+	})
+
+	valid := `{"email":"u1@example.com","channels":{"order.created":["email"]},"locale":"de-DE","time_zone":"Europe/Berlin","quiet_hours":{"start":"22:00","end":"07:00"}}`
+	tests := []struct {
+		name       string
+		method     string
+		user       string
+		caller     string
+		roles      string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "no caller", method: http.MethodGet, user: "u-1", wantStatus: http.StatusUnauthorized, wantBody: `"code":"unauthenticated"`},
+		{name: "get before put", method: http.MethodGet, user: "u-1", caller: "u-1", wantStatus: http.StatusNotFound, wantBody: `"code":"preferences_not_found"`},
+		{name: "create", method: http.MethodPut, user: "u-1", caller: "u-1", body: valid, wantStatus: http.StatusOK, wantBody: `"quiet_hours":{"start":"22:00","end":"07:00"}`},
+		{name: "get", method: http.MethodGet, user: "u-1", caller: "u-1", wantStatus: http.StatusOK, wantBody: `"channels":{"order.created":["email"]}`},
+		{name: "owner", method: http.MethodGet, user: "u-1", caller: "u-1", wantStatus: http.StatusOK},
+		{name: "another user", method: http.MethodGet, user: "u-1", caller: "u-2", wantStatus: http.StatusForbidden, wantBody: `"code":"forbidden"`},
+		{name: "admin", method: http.MethodGet, user: "u-1", caller: "ops", roles: httpx.RoleAdmin, wantStatus: http.StatusOK},
+		{name: "replace", method: http.MethodPut, user: "u-1", caller: "u-1", body: `{"webhook_url":"https://hooks.example.com/u-1","channels":{"order.created":["webhook"]}}`, wantStatus: http.StatusOK, wantBody: `"webhook_url":"https://hooks.example.com/u-1"`},
+		{name: "loopback webhook", method: http.MethodPut, user: "u-1", caller: "u-1", body: `{"webhook_url":"https://127.0.0.1/hook"}`, wantStatus: http.StatusBadRequest, wantBody: `"field":"webhook_url"`},
+		{name: "webhook resolving to a private address", method: http.MethodPut, user: "u-1", caller: "u-1", body: `{"webhook_url":"https://internal.example.com/hook"}`, wantStatus: http.StatusBadRequest, wantBody: `"field":"webhook_url"`},
+		{name: "unresolvable webhook", method: http.MethodPut, user: "u-1", caller: "u-1", body: `{"webhook_url":"https://missing.example.com/hook"}`, wantStatus: http.StatusBadRequest, wantBody: `"field":"webhook_url"`},
+		{name: "invalid json", method: http.MethodPut, user: "u-1", caller: "u-1", body: `{`, wantStatus: http.StatusBadRequest, wantBody: `"code":"invalid_json"`},
+		{name: "missing contact", method: http.MethodPut, user: "u-1", caller: "u-1", body: `{"channels":{"order.created":["email"]}}`, wantStatus: http.StatusBadRequest, wantBody: `"field":"email"`},
+		{name: "log channel", method: http.MethodPut, user: "u-1", caller: "u-1", body: `{"channels":{"order.created":["log"]}}`, wantStatus: http.StatusBadRequest, wantBody: `"field":"channels.order.created"`},
+		{name: "bad time zone", method: http.MethodPut, user: "u-1", caller: "u-1", body: `{"time_zone":"Mars/Olympus"}`, wantStatus: http.StatusBadRequest, wantBody: `"field":"time_zone"`},
+		{name: "delete", method: http.MethodDelete, user: "u-1", caller: "u-1", wantStatus: http.StatusNoContent},
+		{name: "delete again", method: http.MethodDelete, user: "u-1", caller: "u-1", wantStatus: http.StatusNotFound},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest(tc.method, "/v1/preferences/"+tc.user, strings.NewReader(tc.body))
+		if tc.caller != "" {
+			httpx.Principal{Subject: tc.caller, Roles: strings.Split(tc.roles, ",")}.SetHeaders(req.Header)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != tc.wantStatus || !strings.Contains(rec.Body.String(), tc.wantBody) {
+			t.Fatalf("%s: response mismatch: got=%d %q want=%d containing %q", tc.name, rec.Code, rec.Body.String(), tc.wantStatus, tc.wantBody)
+		}
+	}
+}
+
+func TestPreferencesEndpoints_Replace(t *testing.T) {
+	t.Parallel()
+
+	store := newMemoryStore()
+	r := newRouter(zerolog.Nop(), nil, notifyConfig{
+		Dispatcher:  newTestDispatcher(t, nil, &countingChannel{name: "email"}),
+		Preferences: store,
+	})
+	put := func(body string) notify.Preferences {
+		req := httptest.NewRequest(http.MethodPut, "/v1/preferences/u-1", strings.NewReader(body))
+		httpx.Principal{Subject: "u-1"}.SetHeaders(req.Header)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		var got notify.Preferences
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("put: got=%d %q", rec.Code, rec.Body.String())
+		}
+		return got
+	}
+
+	first := put(`{"email":"u1@example.com","channels":{"order.created":["email"]},"quiet_hours":{"start":"22:00","end":"07:00"}}`)
+	second := put(`{"email":"u1@example.com"}`)
+	if second.UserID != "u-1" || second.QuietHours != nil || len(second.Channels[notify.EventOrderCreated]) != 0 {
+		t.Fatalf("replace should drop omitted settings: got=%+v", second)
+	}
+	if !second.CreatedAt.Equal(first.CreatedAt) {
+		t.Fatalf("created_at mismatch: got=%v want=%v", second.CreatedAt, first.CreatedAt)
+	}
+}
+
+// stubResolver answers lookups from a fixed table; other hosts do not resolve.
+type stubResolver map[string][]net.IPAddr
+
+func (s stubResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	addrs, ok := s[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return addrs, nil
+}
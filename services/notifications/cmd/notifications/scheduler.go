@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+	"github.com/triad-platform/triad-app/services/notifications/internal/notify"
+)
+
+// scheduler releases notifications deferred by quiet hours once they are
+// due. Each release re-reads the user's preferences, so a user who changed
+// channels or quiet hours meanwhile gets the new ones. A release that
+// reaches nobody is retried with backoff until MaxAttempts, then given up
+// and its claim released.
+type scheduler struct {
+	cfg     notifyConfig
+	log     zerolog.Logger
+	metrics *metricsx.Registry
+
+	// Interval between polls (30s). Batch events are leased per poll (20),
+	// each for Lease (10m), which must outlast delivering the batch.
+	Interval time.Duration
+	Batch    int
+	Lease    time.Duration
+	// MaxAttempts releases per event (5); Backoff doubles after each (1m).
+	MaxAttempts int
+	Backoff     time.Duration
+}
+
+func newScheduler(cfg notifyConfig, log zerolog.Logger, metrics *metricsx.Registry) *scheduler {
+	return &scheduler{
+		cfg:         cfg,
+		log:         log,
+		metrics:     metrics,
+		Interval:    30 * time.Second,
+		Batch:       20,
+		Lease:       10 * time.Minute,
+		MaxAttempts: 5,
+		Backoff:     time.Minute,
+	}
+}
+
+// Run polls until ctx is done.
+func (s *scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		s.releaseDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// releaseDue releases one batch of due events and returns how many it
+// picked up.
+func (s *scheduler) releaseDue(ctx context.Context) int {
+	due, err := s.cfg.Deferrals.Due(ctx, s.cfg.now(), s.Batch, s.Lease)
+	if err != nil {
+		s.metrics.Inc("scheduler_errors_total")
+		s.log.Error().Err(err).Msg("failed to load deferred notifications")
+		return 0
+	}
+	for _, d := range due {
+		s.release(ctx, d)
+	}
+	return len(due)
+}
+
+func (s *scheduler) release(ctx context.Context, d notify.Deferred) {
+	e := d.Event
+	log := s.log.With().
+		Str("deferred_id", d.ID).
+		Str("event_type", e.Type).
+		Str("order_id", e.OrderID).
+		Str("user_id", e.UserID).
+		Int("attempt", d.Attempts).
+		Logger()
+	now := s.cfg.now()
+
+	plan, err := s.cfg.plan(ctx, e, now)
+	if err != nil {
+		s.metrics.Inc("preference_errors_total")
+		s.retry(ctx, d, now, err.Error(), log)
+		return
+	}
+	if len(plan.targets) == 0 {
+		s.metrics.Inc("skipped_total")
+		s.cfg.settle(ctx, e.OrderID, e.Type, true, log, s.metrics)
+		s.finish(ctx, d, log)
+		return
+	}
+	if !plan.deliverAt.IsZero() {
+		s.metrics.Inc("deferred_total")
+		if err := s.cfg.Deferrals.Reschedule(ctx, d.ID, plan.deliverAt, ""); err != nil {
+			s.metrics.Inc("scheduler_errors_total")
+			log.Error().Err(err).Msg("failed to reschedule deferred notification")
+		}
+		return
+	}
+
+	results, err := s.cfg.Dispatcher.Deliver(ctx, plan.event, plan.targets)
+	status := notify.StatusFailed
+	if err == nil {
+		status = deliveryStatus(results)
+	}
+	if status == notify.StatusFailed {
+		lastError := "no channel delivered the notification"
+		if err != nil {
+			lastError = err.Error()
+		}
+		s.retry(ctx, d, now, lastError, log)
+		return
+	}
+	s.metrics.Inc("scheduler_released_total")
+	log.Info().Str("status", status).Msg("deferred notification released")
+	s.cfg.settle(ctx, e.OrderID, e.Type, true, log, s.metrics)
+	s.finish(ctx, d, log)
+}
+
+// retry reschedules d with backoff, or gives it up after MaxAttempts.
+func (s *scheduler) retry(ctx context.Context, d notify.Deferred, now time.Time, lastError string, log zerolog.Logger) {
+	if d.Attempts >= s.MaxAttempts {
+		s.metrics.Inc("scheduler_dropped_total")
+		log.Error().Str("error", lastError).Msg("giving up on deferred notification")
+		s.cfg.settle(ctx, d.Event.OrderID, d.Event.Type, false, log, s.metrics)
+		s.finish(ctx, d, log)
+		return
+	}
+	s.metrics.Inc("scheduler_retries_total")
+	backoff := s.Backoff << max(d.Attempts-1, 0)
+	if err := s.cfg.Deferrals.Reschedule(ctx, d.ID, now.Add(backoff), lastError); err != nil {
+		s.metrics.Inc("scheduler_errors_total")
+		log.Error().Err(err).Msg("failed to reschedule deferred notification")
+	}
+}
+
+func (s *scheduler) finish(ctx context.Context, d notify.Deferred, log zerolog.Logger) {
+	if err := s.cfg.Deferrals.Finish(ctx, d.ID); err != nil {
+		s.metrics.Inc("scheduler_errors_total")
+		log.Error().Err(err).Msg("failed to remove released notification")
+	}
+}
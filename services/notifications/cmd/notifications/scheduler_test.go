@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+	"github.com/triad-platform/triad-app/pkg/money"
+	"github.com/triad-platform/triad-app/services/notifications/internal/notify"
+)
+
+func TestScheduler_Release(t *testing.T) {
+	t.Parallel()
+
+	store := newMemoryStore()
+	ctx := context.Background()
+	_, _ = store.PutPreferences(ctx, notify.Preferences{
+		UserID:     "u-1",
+		Email:      "u1@example.com",
+		Channels:   map[string][]string{notify.EventOrderCreated: {"email"}},
+		QuietHours: &notify.QuietHours{Start: "22:00", End: "07:00"},
+	})
+	event := notify.Event{Type: notify.EventOrderCreated, OrderID: "o-1", UserID: "u-1", Total: money.New(1500, money.MustParseCurrency("USD"))}
+	_, _ = store.Claim(ctx, "o-1", event.Type, "u-1")
+	_ = store.Defer(ctx, notify.Deferred{Event: event, DeliverAt: time.Date(2026, 3, 2, 7, 0, 0, 0, time.UTC)})
+
+	email := &countingChannel{name: "email"}
+	now := time.Date(2026, 3, 2, 6, 59, 0, 0, time.UTC)
+	s := newScheduler(notifyConfig{
+		Dispatcher:  newTestDispatcher(t, store, email),
+		Store:       store,
+		Preferences: store,
+		Deferrals:   store,
+		Now:         func() time.Time { return now },
+	}, zerolog.Nop(), metricsx.NewRegistry("triad_notifications"))
+
+	if n := s.releaseDue(ctx); n != 0 || email.calls != 0 {
+		t.Fatalf("released before quiet hours end: got=%d sends=%d", n, email.calls)
+	}
+	now = now.Add(time.Minute)
+	if n := s.releaseDue(ctx); n != 1 {
+		t.Fatalf("released mismatch: got=%d want=1", n)
+	}
+	if got := email.recipients(); got != "u1@example.com" {
+		t.Fatalf("recipients mismatch: got=%s", got)
+	}
+	if got := store.event("o-1", event.Type); got != "done" {
+		t.Fatalf("event state mismatch: got=%q want=done", got)
+	}
+	if len(store.deferred) != 0 {
+		t.Fatalf("released event should be removed: got=%+v", store.deferred)
+	}
+}
+
+func TestScheduler_RetryThenGiveUp(t *testing.T) {
+	t.Parallel()
+
+	store := newMemoryStore()
+	ctx := context.Background()
+	_, _ = store.PutPreferences(ctx, notify.Preferences{
+		UserID:   "u-1",
+		Email:    "u1@example.com",
+		Channels: map[string][]string{notify.EventOrderCreated: {"email"}},
+	})
+	event := notify.Event{Type: notify.EventOrderCreated, OrderID: "o-1", UserID: "u-1", Total: money.New(1500, money.MustParseCurrency("USD"))}
+	_, _ = store.Claim(ctx, "o-1", event.Type, "u-1")
+	now := time.Date(2026, 3, 2, 7, 0, 0, 0, time.UTC)
+	_ = store.Defer(ctx, notify.Deferred{Event: event, DeliverAt: now})
+
+	down := errors.New("connection refused")
+	email := &countingChannel{name: "email", errs: []error{down, down}}
+	s := newScheduler(notifyConfig{
+		Dispatcher:  newTestDispatcher(t, store, email),
+		Store:       store,
+		Preferences: store,
+		Deferrals:   store,
+		Now:         func() time.Time { return now },
+	}, zerolog.Nop(), metricsx.NewRegistry("triad_notifications"))
+	s.MaxAttempts = 2
+
+	s.releaseDue(ctx)
+	d := store.deferred["o-1|"+event.Type]
+	if want := now.Add(s.Backoff); !d.DeliverAt.Equal(want) || d.LastError == "" {
+		t.Fatalf("reschedule mismatch: got=%+v want deliver_at=%v", d, want)
+	}
+	if n := s.releaseDue(ctx); n != 0 {
+		t.Fatalf("released before backoff: got=%d", n)
+	}
+
+	now = now.Add(s.Backoff)
+	s.releaseDue(ctx)
+	if email.calls != 2 {
+		t.Fatalf("send calls mismatch: got=%d want=2", email.calls)
+	}
+	if len(store.deferred) != 0 {
+		t.Fatalf("given up event should be removed: got=%+v", store.deferred)
+	}
+	// The claim is released, so a redelivered notify call can send it.
+	if got := store.event("o-1", event.Type); got != "" {
+		t.Fatalf("event state mismatch: got=%q want released", got)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/triad-platform/triad-app/pkg/config"
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+	"github.com/triad-platform/triad-app/services/notifications/internal/notify"
+)
+
+// notifySettings builds the channels and targets from the environment.
+// Email needs SMTP_ADDR; NOTIFY_EMAIL_TO and NOTIFY_WEBHOOK_URL are the
+// targets for users without preferences, and with neither those users'
+// notifications go to the log. When store is set, attempts are recorded in
+// it and it also keeps preferences and deferred notifications.
+func notifySettings(log zerolog.Logger, metrics *metricsx.Registry, store *notify.PostgresStore) (notifyConfig, error) {
+	templatesFS := notify.DefaultTemplates()
+	if dir := strings.TrimSpace(os.Getenv("NOTIFY_TEMPLATES_DIR")); dir != "" {
+		templatesFS = os.DirFS(dir)
+	}
+	templates, err := notify.LoadTemplates(templatesFS)
+	if err != nil {
+		return notifyConfig{}, err
+	}
+
+	var channels []notify.Channel
+	var targets []notify.Target
+	if addr := strings.TrimSpace(os.Getenv("SMTP_ADDR")); addr != "" {
+		channels = append(channels, &notify.SMTPChannel{
+			Addr:       addr,
+			From:       config.Getenv("SMTP_FROM", "PulseCart <orders@pulsecart.local>"),
+			Username:   os.Getenv("SMTP_USERNAME"),
+			Password:   os.Getenv("SMTP_PASSWORD"),
+			Timeout:    durationEnv("SMTP_TIMEOUT", 10*time.Second),
+			RequireTLS: strings.EqualFold(config.Getenv("SMTP_REQUIRE_TLS", "false"), "true"),
+		})
+		for _, to := range splitList(os.Getenv("NOTIFY_EMAIL_TO")) {
+			targets = append(targets, notify.Target{Channel: "email", Recipient: to})
+		}
+	}
+	// Users can always add their own webhooks.
+	channels = append(channels, notify.NewWebhookChannel(durationEnv("NOTIFY_WEBHOOK_TIMEOUT", 5*time.Second), os.Getenv("NOTIFY_WEBHOOK_SECRET")))
+	if url := strings.TrimSpace(os.Getenv("NOTIFY_WEBHOOK_URL")); url != "" {
+		targets = append(targets, notify.Target{Channel: "webhook", Recipient: url})
+	}
+	if len(targets) == 0 {
+		channels = append(channels, &notify.LogChannel{Log: log})
+		targets = append(targets, notify.Target{Channel: "log"})
+	}
+
+	recorder := notify.DeliveryRecorder(notify.LogRecorder{Log: log})
+	if store != nil {
+		recorder = notify.Recorders{recorder, store}
+	}
+	dispatcher := notify.NewDispatcher(templates, recorder, metrics, channels...)
+	if n, err := strconv.Atoi(config.Getenv("NOTIFY_MAX_ATTEMPTS", "3")); err == nil && n > 0 {
+		dispatcher.MaxAttempts = n
+	}
+	dispatcher.Backoff = durationEnv("NOTIFY_RETRY_BACKOFF", 200*time.Millisecond)
+	cfg := notifyConfig{Dispatcher: dispatcher, Targets: targets}
+	if store != nil {
+		cfg.Store, cfg.Preferences, cfg.Deferrals = store, store, store
+	}
+	return cfg, nil
+}
+
+func databaseURL() string {
+	if explicit := strings.TrimSpace(config.Getenv("DATABASE_URL", "")); explicit != "" {
+		return explicit
+	}
+
+	host := config.Getenv("DB_HOST", "localhost")
+	port := config.Getenv("DB_PORT", "5432")
+	name := config.Getenv("DB_NAME", "pulsecart")
+	user := config.Getenv("DB_USER", "pulsecart")
+	password := config.Getenv("DB_PASSWORD", "pulsecart")
+	sslmode := config.Getenv("DB_SSLMODE", "disable")
+
+	return (&url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(user, password),
+		Host:     net.JoinHostPort(host, port),
+		Path:     name,
+		RawQuery: "sslmode=" + url.QueryEscape(sslmode),
+	}).String()
+}
+
+func durationEnv(key string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(config.Getenv(key, fallback.String()))
+	if err != nil || d <= 0 {
+		return fallback
+	}
+	return d
+}
+
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
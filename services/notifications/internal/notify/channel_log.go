@@ -0,0 +1,27 @@
+package notify
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+)
+
+// LogChannel writes messages to the log. It is the fallback when no real
+// channel is configured.
+type LogChannel struct {
+	Log zerolog.Logger
+}
+
+func (c *LogChannel) Name() string { return "log" }
+
+func (c *LogChannel) Send(_ context.Context, msg Message) (string, error) {
+	c.Log.Info().
+		Str("message_id", msg.ID).
+		Str("event_type", msg.Event.Type).
+		Str("order_id", msg.Event.OrderID).
+		Str("user_id", msg.Event.UserID).
+		Str("recipient", msg.Recipient).
+		Str("subject", msg.Subject).
+		Msg("notification logged")
+	return msg.ID, nil
+}
@@ -0,0 +1,208 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+)
+
+// Attempt is the outcome of one try at sending one message.
+type Attempt struct {
+	MessageID  string
+	EventType  string
+	OrderID    string
+	UserID     string
+	RequestID  string
+	Channel    string
+	Recipient  string
+	Template   string
+	Attempt    int
+	Status     string
+	ProviderID string
+	Error      string
+	At         time.Time
+	Duration   time.Duration
+}
+
+// DeliveryRecorder keeps every attempt's outcome.
+type DeliveryRecorder interface {
+	RecordAttempt(ctx context.Context, attempt Attempt) error
+}
+
+// Result is the final outcome for one target.
+type Result struct {
+	Channel    string `json:"channel"`
+	Recipient  string `json:"recipient"`
+	Status     string `json:"status"`
+	Attempts   int    `json:"attempts"`
+	ProviderID string `json:"provider_message_id,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Dispatcher renders an event and sends it to each target, retrying
+// failures that are not permanent.
+type Dispatcher struct {
+	Templates *Templates
+	Channels  map[string]Channel
+	// Recorder is optional.
+	Recorder DeliveryRecorder
+	Metrics  *metricsx.Registry
+	// MaxAttempts per target (3); Backoff doubles after each (200ms).
+	MaxAttempts int
+	Backoff     time.Duration
+}
+
+// NewDispatcher indexes channels by name.
+func NewDispatcher(templates *Templates, recorder DeliveryRecorder, metrics *metricsx.Registry, channels ...Channel) *Dispatcher {
+	d := &Dispatcher{Templates: templates, Channels: map[string]Channel{}, Recorder: recorder, Metrics: metrics}
+	for _, c := range channels {
+		d.Channels[c.Name()] = c
+	}
+	return d
+}
+
+// Supports reports whether eventType can be rendered.
+func (d *Dispatcher) Supports(eventType string) bool {
+	return d.Templates.Has(eventType)
+}
+
+// Deliver sends event to every target and returns one result per target.
+// Targets are tried in order; a failing one does not stop the rest.
+func (d *Dispatcher) Deliver(ctx context.Context, event Event, targets []Target) ([]Result, error) {
+	rendered, err := d.Templates.RenderLocale(event.Type, event.Locale, event)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]Result, 0, len(targets))
+	for _, target := range targets {
+		msg := Message{
+			ID:        newMessageID(),
+			Event:     event,
+			Template:  rendered.Template,
+			Recipient: target.Recipient,
+			Subject:   rendered.Subject,
+			Text:      rendered.Text,
+			HTML:      rendered.HTML,
+		}
+		results = append(results, d.send(ctx, target, msg))
+	}
+	return results, nil
+}
+
+func (d *Dispatcher) send(ctx context.Context, target Target, msg Message) Result {
+	result := Result{Channel: target.Channel, Recipient: target.Recipient, Status: StatusFailed}
+	channel, ok := d.Channels[target.Channel]
+	if !ok {
+		result.Error = fmt.Sprintf("unknown channel %q", target.Channel)
+		d.inc("delivery_errors_total")
+		return result
+	}
+
+	backoff := d.backoff()
+	for attempt := 1; attempt <= d.maxAttempts(); attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return result
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+		start := time.Now()
+		providerID, err := channel.Send(ctx, msg)
+		result.Attempts = attempt
+		record := Attempt{
+			MessageID: msg.ID,
+			EventType: msg.Event.Type,
+			OrderID:   msg.Event.OrderID,
+			UserID:    msg.Event.UserID,
+			RequestID: msg.Event.RequestID,
+			Channel:   target.Channel,
+			Recipient: target.Recipient,
+			Template:  msg.Template,
+			Attempt:   attempt,
+			At:        start.UTC(),
+			Duration:  time.Since(start),
+		}
+		d.observeDuration("delivery_"+target.Channel+"_duration", record.Duration)
+		if err == nil {
+			record.Status, record.ProviderID = StatusSent, providerID
+			d.record(ctx, record)
+			d.inc("delivery_" + target.Channel + "_sent_total")
+			result.Status, result.ProviderID, result.Error = StatusSent, providerID, ""
+			return result
+		}
+		record.Status, record.Error = StatusFailed, err.Error()
+		d.record(ctx, record)
+		d.inc("delivery_" + target.Channel + "_failed_total")
+		result.Error = err.Error()
+		if IsPermanent(err) {
+			return result
+		}
+	}
+	return result
+}
+
+// record is best effort: a lost record must not resend a message.
+func (d *Dispatcher) record(ctx context.Context, attempt Attempt) {
+	if d.Recorder == nil {
+		return
+	}
+	if err := d.Recorder.RecordAttempt(ctx, attempt); err != nil {
+		d.inc("delivery_record_errors_total")
+	}
+}
+
+func (d *Dispatcher) maxAttempts() int {
+	if d.MaxAttempts <= 0 {
+		return 3
+	}
+	return d.MaxAttempts
+}
+
+func (d *Dispatcher) backoff() time.Duration {
+	if d.Backoff <= 0 {
+		return 200 * time.Millisecond
+	}
+	return d.Backoff
+}
+
+func (d *Dispatcher) inc(name string) {
+	if d.Metrics != nil {
+		d.Metrics.Inc(name)
+	}
+}
+
+func (d *Dispatcher) observeDuration(name string, v time.Duration) {
+	if d.Metrics != nil {
+		d.Metrics.ObserveDuration(name, v)
+	}
+}
+
+// LogRecorder writes each attempt as a structured log line.
+type LogRecorder struct {
+	Log zerolog.Logger
+}
+
+func (r LogRecorder) RecordAttempt(_ context.Context, a Attempt) error {
+	ev := r.Log.Info()
+	if a.Status != StatusSent {
+		ev = r.Log.Warn().Str("error", a.Error)
+	}
+	ev.Str("message_id", a.MessageID).
+		Str("event_type", a.EventType).
+		Str("order_id", a.OrderID).
+		Str("user_id", a.UserID).
+		Str("request_id", a.RequestID).
+		Str("channel", a.Channel).
+		Str("recipient", a.Recipient).
+		Int("attempt", a.Attempt).
+		Str("status", a.Status).
+		Str("provider_message_id", a.ProviderID).
+		Dur("duration", a.Duration).
+		Msg("notification delivery attempt")
+	return nil
+}
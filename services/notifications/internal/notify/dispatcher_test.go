@@ -0,0 +1,124 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/triad-platform/triad-app/pkg/money"
+)
+
+func TestTemplates_Render(t *testing.T) {
+	t.Parallel()
+
+	templates, err := LoadTemplates(DefaultTemplates())
+	if err != nil {
+		t.Fatalf("load templates: %v", err)
+	}
+	event := Event{Type: EventOrderCreated, OrderID: "o-<1>", Total: money.New(1299, money.MustParseCurrency("USD"))}
+	got, err := templates.Render(EventOrderCreated, event)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if got.Subject != "Your PulseCart order o-<1> is confirmed" {
+		t.Fatalf("subject mismatch: got=%q", got.Subject)
+	}
+	if !strings.Contains(got.Text, "Total:  12.99 USD") || !strings.Contains(got.Text, "o-<1>") {
+		t.Fatalf("text mismatch: got=%q", got.Text)
+	}
+	if !strings.Contains(got.HTML, "o-&lt;1&gt;") || !strings.Contains(got.HTML, "12.99 USD") {
+		t.Fatalf("html mismatch: got=%q", got.HTML)
+	}
+
+	for locale, want := range map[string]string{"de": "order.created.de", "de_DE": "order.created.de", "fr-FR": EventOrderCreated, "": EventOrderCreated} {
+		got, err := templates.RenderLocale(EventOrderCreated, locale, event)
+		if err != nil || got.Template != want {
+			t.Fatalf("locale %q template mismatch: got=%q want=%q err=%v", locale, got.Template, want, err)
+		}
+	}
+	if _, err := templates.Render("order.cancelled", event); !errors.Is(err, ErrNoTemplate) {
+		t.Fatalf("unknown type error mismatch: got=%v want=%v", err, ErrNoTemplate)
+	}
+	if _, err := LoadTemplates(fstest.MapFS{"order.created.subject.tmpl": {Data: []byte("s")}}); err == nil {
+		t.Fatal("expected an error for a missing text template")
+	}
+}
+
+func TestDispatcher_Deliver(t *testing.T) {
+	t.Parallel()
+
+	templates, err := LoadTemplates(DefaultTemplates())
+	if err != nil {
+		t.Fatalf("load templates: %v", err)
+	}
+	flaky := &stubChannel{name: "email", errs: []error{errors.New("connection reset")}}
+	rejecting := &stubChannel{name: "webhook", errs: []error{Permanent(errors.New("status 410"))}}
+	recorder := &stubRecorder{}
+	d := NewDispatcher(templates, recorder, nil, flaky, rejecting)
+	d.Backoff = 1
+
+	results, err := d.Deliver(context.Background(), Event{Type: EventOrderCreated, OrderID: "o-1", UserID: "u-1"}, []Target{
+		{Channel: "email", Recipient: "customer@example.com"},
+		{Channel: "webhook", Recipient: "https://hooks.example.com"},
+		{Channel: "sms", Recipient: "+15550100"},
+	})
+	if err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+
+	want := []Result{
+		{Channel: "email", Recipient: "customer@example.com", Status: StatusSent, Attempts: 2, ProviderID: "email-2"},
+		{Channel: "webhook", Recipient: "https://hooks.example.com", Status: StatusFailed, Attempts: 1, Error: "status 410"},
+		{Channel: "sms", Recipient: "+15550100", Status: StatusFailed, Error: `unknown channel "sms"`},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("results mismatch: got=%+v", results)
+	}
+	for i := range want {
+		if results[i] != want[i] {
+			t.Fatalf("result %d mismatch: got=%+v want=%+v", i, results[i], want[i])
+		}
+	}
+
+	var outcomes []string
+	for _, a := range recorder.attempts {
+		outcomes = append(outcomes, a.Channel+":"+a.Status)
+		if a.OrderID != "o-1" || a.Template != EventOrderCreated || a.MessageID == "" {
+			t.Fatalf("attempt mismatch: got=%+v", a)
+		}
+	}
+	if got := strings.Join(outcomes, ","); got != "email:failed,email:sent,webhook:failed" {
+		t.Fatalf("recorded attempts mismatch: got=%s", got)
+	}
+	if flaky.sent[0].Subject == "" || flaky.sent[0].ID != flaky.sent[1].ID {
+		t.Fatalf("retries should resend the same message: got=%+v", flaky.sent)
+	}
+}
+
+// stubChannel fails with errs in turn, then succeeds.
+type stubChannel struct {
+	name string
+	errs []error
+	sent []Message
+}
+
+func (c *stubChannel) Name() string { return c.name }
+
+func (c *stubChannel) Send(_ context.Context, msg Message) (string, error) {
+	c.sent = append(c.sent, msg)
+	if len(c.sent) <= len(c.errs) {
+		return "", c.errs[len(c.sent)-1]
+	}
+	return c.name + "-" + string(rune('0'+len(c.sent))), nil
+}
+
+type stubRecorder struct {
+	attempts []Attempt
+}
+
+func (r *stubRecorder) RecordAttempt(_ context.Context, a Attempt) error {
+	r.attempts = append(r.attempts, a)
+	return nil
+}
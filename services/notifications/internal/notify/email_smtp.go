@@ -0,0 +1,186 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// SMTPChannel sends email through an SMTP relay, upgrading to TLS when the
+// server offers STARTTLS.
+type SMTPChannel struct {
+	Addr     string
+	From     string
+	Username string
+	Password string
+	// Timeout bounds one delivery when ctx has no earlier deadline (10s).
+	Timeout time.Duration
+	// RequireTLS refuses servers that do not offer STARTTLS.
+	RequireTLS bool
+}
+
+func (c *SMTPChannel) Name() string { return "email" }
+
+// Send returns the Message-ID header it sent. 5xx replies, such as an
+// unknown mailbox, are permanent; anything else may be retried.
+func (c *SMTPChannel) Send(ctx context.Context, msg Message) (string, error) {
+	to, err := mail.ParseAddress(msg.Recipient)
+	if err != nil {
+		return "", Permanent(fmt.Errorf("invalid recipient: %w", err))
+	}
+	from, err := mail.ParseAddress(c.From)
+	if err != nil {
+		return "", Permanent(fmt.Errorf("invalid sender: %w", err))
+	}
+	messageID := "<" + msg.ID + "@" + addressDomain(from.Address) + ">"
+	body, err := buildEmail(from, to, msg, messageID)
+	if err != nil {
+		return "", Permanent(err)
+	}
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", c.Addr)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	host, _, _ := net.SplitHostPort(c.Addr)
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return "", smtpError(err)
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}); err != nil {
+			return "", smtpError(err)
+		}
+	} else if c.RequireTLS {
+		return "", errors.New("smtp server does not offer STARTTLS")
+	}
+	if c.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", c.Username, c.Password, host)); err != nil {
+			return "", smtpError(err)
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return "", smtpError(err)
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return "", smtpError(err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return "", smtpError(err)
+	}
+	if _, err := w.Write(body); err != nil {
+		return "", smtpError(err)
+	}
+	if err := w.Close(); err != nil {
+		return "", smtpError(err)
+	}
+	_ = client.Quit()
+	return messageID, nil
+}
+
+// smtpError marks 5xx replies permanent.
+func smtpError(err error) error {
+	var reply *textproto.Error
+	if errors.As(err, &reply) && reply.Code >= 500 {
+		return Permanent(err)
+	}
+	return err
+}
+
+// buildEmail renders a multipart/alternative message, or plain text when
+// there is no HTML body.
+func buildEmail(from, to *mail.Address, msg Message, messageID string) ([]byte, error) {
+	var buf bytes.Buffer
+	header := textproto.MIMEHeader{}
+	header.Set("From", from.String())
+	header.Set("To", to.String())
+	header.Set("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header.Set("Date", time.Now().UTC().Format(time.RFC1123Z))
+	header.Set("Message-ID", messageID)
+	header.Set("MIME-Version", "1.0")
+
+	if msg.HTML == "" {
+		header.Set("Content-Type", "text/plain; charset=utf-8")
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		writeHeader(&buf, header)
+		if err := writeQuotedPrintable(&buf, msg.Text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	var parts bytes.Buffer
+	mw := multipart.NewWriter(&parts)
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	header.Set("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+	writeHeader(&buf, header)
+	buf.Write(parts.Bytes())
+	return buf.Bytes(), nil
+}
+
+func writeHeader(buf *bytes.Buffer, header textproto.MIMEHeader) {
+	for _, key := range []string{"From", "To", "Subject", "Date", "Message-ID", "MIME-Version", "Content-Type", "Content-Transfer-Encoding"} {
+		if v := header.Get(key); v != "" {
+			fmt.Fprintf(buf, "%s: %s\r\n", key, v)
+		}
+	}
+	buf.WriteString("\r\n")
+}
+
+func writeQuotedPrintable(w io.Writer, s string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(strings.ReplaceAll(s, "\n", "\r\n"))); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+func addressDomain(addr string) string {
+	if _, domain, ok := strings.Cut(addr, "@"); ok && domain != "" {
+		return domain
+	}
+	return "localhost"
+}
@@ -0,0 +1,200 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSMTPChannel_Send(t *testing.T) {
+	t.Parallel()
+
+	server := newSMTPStandIn(t)
+	channel := &SMTPChannel{Addr: server.addr, From: "PulseCart <orders@pulsecart.test>", Timeout: 2 * time.Second}
+	msg := Message{
+		ID:        "m-1",
+		Recipient: "Customer <customer@example.com>",
+		Subject:   "Your order ü is confirmed",
+		Text:      "Order o-1\nTotal 12.99 USD\n",
+		HTML:      "<p>Order o-1</p>",
+	}
+
+	id, err := channel.Send(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if id != "<m-1@pulsecart.test>" {
+		t.Fatalf("message id mismatch: got=%q", id)
+	}
+
+	got := server.last()
+	if got.from != "orders@pulsecart.test" || strings.Join(got.rcpt, ",") != "customer@example.com" {
+		t.Fatalf("envelope mismatch: from=%q rcpt=%v", got.from, got.rcpt)
+	}
+	parsed, err := mail.ReadMessage(strings.NewReader(got.data))
+	if err != nil {
+		t.Fatalf("parse message: %v", err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if subject != msg.Subject || parsed.Header.Get("Message-Id") != id {
+		t.Fatalf("header mismatch: subject=%q message-id=%q", subject, parsed.Header.Get("Message-Id"))
+	}
+	mediaType, params, _ := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("content type mismatch: got=%q", mediaType)
+	}
+	parts := multipart.NewReader(parsed.Body, params["boundary"])
+	var bodies []string
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read part: %v", err)
+		}
+		body, _ := io.ReadAll(quotedprintable.NewReader(part))
+		bodies = append(bodies, part.Header.Get("Content-Type")+"|"+strings.ReplaceAll(string(body), "\r\n", "\n"))
+	}
+	want := []string{"text/plain; charset=utf-8|" + msg.Text, "text/html; charset=utf-8|" + msg.HTML}
+	if strings.Join(bodies, ";") != strings.Join(want, ";") {
+		t.Fatalf("parts mismatch: got=%q want=%q", bodies, want)
+	}
+}
+
+func TestSMTPChannel_Errors(t *testing.T) {
+	t.Parallel()
+
+	server := newSMTPStandIn(t)
+	tests := []struct {
+		name          string
+		addr          string
+		recipient     string
+		wantPermanent bool
+	}{
+		{name: "rejected mailbox", addr: server.addr, recipient: "unknown@example.com", wantPermanent: true},
+		{name: "invalid address", addr: server.addr, recipient: "not an address", wantPermanent: true},
+		{name: "busy mailbox", addr: server.addr, recipient: "busy@example.com"},
+		{name: "server down", addr: "127.0.0.1:1", recipient: "customer@example.com"},
+	}
+	for _, tc := range tests {
+		channel := &SMTPChannel{Addr: tc.addr, From: "orders@pulsecart.test", Timeout: time.Second}
+		_, err := channel.Send(context.Background(), Message{ID: "m-1", Recipient: tc.recipient, Subject: "s", Text: "t"})
+		if err == nil {
+			t.Fatalf("%s: expected an error", tc.name)
+		}
+		if IsPermanent(err) != tc.wantPermanent {
+			t.Fatalf("%s: permanent mismatch: got=%v want=%v err=%v", tc.name, IsPermanent(err), tc.wantPermanent, err)
+		}
+	}
+}
+
+type smtpMessage struct {
+	from string
+	rcpt []string
+	data string
+}
+
+// smtpStandIn is a minimal SMTP server: "unknown@" mailboxes are rejected
+// with 550 and "busy@" ones with 450.
+type smtpStandIn struct {
+	addr     string
+	mu       sync.Mutex
+	messages []smtpMessage
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	s := &smtpStandIn{addr: ln.Addr().String()}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = io.WriteString(conn, line+"\r\n") }
+	reply("220 stand-in ESMTP")
+	var msg smtpMessage
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimRight(line, "\r\n")
+		upper := strings.ToUpper(cmd)
+		switch {
+		case strings.HasPrefix(upper, "EHLO"), strings.HasPrefix(upper, "HELO"):
+			reply("250 stand-in")
+		case strings.HasPrefix(upper, "MAIL FROM:"):
+			msg = smtpMessage{from: strings.Trim(cmd[len("MAIL FROM:"):], "<> ")}
+			reply("250 OK")
+		case strings.HasPrefix(upper, "RCPT TO:"):
+			rcpt := strings.Trim(cmd[len("RCPT TO:"):], "<> ")
+			switch {
+			case strings.HasPrefix(rcpt, "unknown@"):
+				reply("550 no such mailbox")
+			case strings.HasPrefix(rcpt, "busy@"):
+				reply("450 mailbox busy")
+			default:
+				msg.rcpt = append(msg.rcpt, rcpt)
+				reply("250 OK")
+			}
+		case upper == "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			msg.data = data.String()
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			reply("250 queued")
+		case upper == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func (s *smtpStandIn) last() smtpMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.messages) == 0 {
+		return smtpMessage{}
+	}
+	return s.messages[len(s.messages)-1]
+}
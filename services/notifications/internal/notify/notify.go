@@ -0,0 +1,84 @@
+package notify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/triad-platform/triad-app/pkg/money"
+)
+
+// EventOrderCreated is sent once an order has been placed.
+const EventOrderCreated = "order.created"
+
+// Delivery outcomes.
+const (
+	StatusSent   = "sent"
+	StatusFailed = "failed"
+)
+
+// Event is what happened; templates render it into a Message.
+type Event struct {
+	Type      string
+	OrderID   string
+	UserID    string
+	RequestID string
+	Total     money.Money
+	CreatedAt string
+	// Locale picks localized templates; empty uses the default ones.
+	Locale string
+}
+
+// Message is one rendered notification for one recipient.
+type Message struct {
+	ID        string
+	Event     Event
+	Template  string
+	Recipient string
+	Subject   string
+	Text      string
+	HTML      string
+}
+
+// Channel delivers messages, for example by email. Send returns the
+// provider's message ID when it has one.
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, msg Message) (string, error)
+}
+
+// Target is a recipient on a channel: an email address, a webhook URL.
+type Target struct {
+	Channel   string
+	Recipient string
+}
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying, such as a rejected recipient.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+func newMessageID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("msg-%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
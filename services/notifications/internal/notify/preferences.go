@@ -0,0 +1,193 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/triad-platform/triad-app/pkg/httpx"
+)
+
+// ErrPreferencesNotFound is returned for users who never saved preferences.
+var ErrPreferencesNotFound = errors.New("notification preferences not found")
+
+// Channels a user can address with their own contacts.
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// Preferences say which channels a user wants per event type, where to reach
+// them, and when not to. An event type missing from Channels, or mapped to
+// no channels, is not sent to the user.
+type Preferences struct {
+	UserID     string              `json:"user_id"`
+	Email      string              `json:"email,omitempty"`
+	WebhookURL string              `json:"webhook_url,omitempty"`
+	Channels   map[string][]string `json:"channels"`
+	// Locale picks localized templates, like de or de-DE.
+	Locale string `json:"locale,omitempty"`
+	// TimeZone is an IANA name; quiet hours are in this zone (UTC if empty).
+	TimeZone   string      `json:"time_zone,omitempty"`
+	QuietHours *QuietHours `json:"quiet_hours,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+}
+
+// QuietHours is a daily window, "HH:MM" to "HH:MM" local time, that may
+// span midnight. Start is inclusive and End exclusive.
+type QuietHours struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// PreferenceStore keeps one Preferences per user. Put creates or replaces.
+type PreferenceStore interface {
+	GetPreferences(ctx context.Context, userID string) (Preferences, error)
+	PutPreferences(ctx context.Context, prefs Preferences) (Preferences, error)
+	DeletePreferences(ctx context.Context, userID string) error
+}
+
+// Targets addresses eventType's opted-in channels with the user's contacts.
+func (p Preferences) Targets(eventType string) []Target {
+	var targets []Target
+	for _, channel := range p.Channels[eventType] {
+		switch channel {
+		case ChannelEmail:
+			if p.Email != "" {
+				targets = append(targets, Target{Channel: channel, Recipient: p.Email})
+			}
+		case ChannelWebhook:
+			if p.WebhookURL != "" {
+				targets = append(targets, Target{Channel: channel, Recipient: p.WebhookURL})
+			}
+		}
+	}
+	return targets
+}
+
+// QuietUntil reports whether now falls in the user's quiet hours and, if so,
+// when they end.
+func (p Preferences) QuietUntil(now time.Time) (time.Time, bool) {
+	if p.QuietHours == nil {
+		return time.Time{}, false
+	}
+	start, errStart := parseClock(p.QuietHours.Start)
+	end, errEnd := parseClock(p.QuietHours.End)
+	if errStart != nil || errEnd != nil || start == end {
+		return time.Time{}, false
+	}
+	loc := time.UTC
+	if p.TimeZone != "" {
+		l, err := time.LoadLocation(p.TimeZone)
+		if err != nil {
+			return time.Time{}, false
+		}
+		loc = l
+	}
+
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	quiet := minute >= start && minute < end
+	if start > end {
+		quiet = minute >= start || minute < end
+	}
+	if !quiet {
+		return time.Time{}, false
+	}
+	until := time.Date(local.Year(), local.Month(), local.Day(), end/60, end%60, 0, 0, loc)
+	if !until.After(local) {
+		// Day+1 keeps the wall clock across DST changes.
+		until = time.Date(local.Year(), local.Month(), local.Day()+1, end/60, end%60, 0, 0, loc)
+	}
+	return until, true
+}
+
+var localePattern = regexp.MustCompile(`^[a-zA-Z]{2,3}([-_][a-zA-Z0-9]{2,8})*$`)
+
+// Validate checks p before it is saved. supports reports whether an event
+// type has templates and channels lists the configured channels.
+func (p Preferences) Validate(supports func(eventType string) bool, channels map[string]Channel) []httpx.FieldError {
+	var errs []httpx.FieldError
+	if p.Email != "" {
+		if addr, err := mail.ParseAddress(p.Email); err != nil || addr.Address != p.Email {
+			errs = append(errs, httpx.FieldError{Field: "email", Code: httpx.FieldInvalid, Detail: "must be a bare email address"})
+		}
+	}
+	if p.WebhookURL != "" {
+		if u, err := url.Parse(p.WebhookURL); err != nil || u.Scheme != "https" || u.Host == "" {
+			errs = append(errs, httpx.FieldError{Field: "webhook_url", Code: httpx.FieldInvalid, Detail: "must be an https URL"})
+		} else if ip := net.ParseIP(u.Hostname()); ip != nil && !publicIP(ip) {
+			errs = append(errs, httpx.FieldError{Field: "webhook_url", Code: httpx.FieldInvalid, Detail: "must be a public address"})
+		}
+	}
+
+	eventTypes := make([]string, 0, len(p.Channels))
+	for eventType := range p.Channels {
+		eventTypes = append(eventTypes, eventType)
+	}
+	sort.Strings(eventTypes)
+	for _, eventType := range eventTypes {
+		field := "channels." + eventType
+		if !supports(eventType) {
+			errs = append(errs, httpx.FieldError{Field: field, Code: httpx.FieldUnsupported, Detail: "unknown event type"})
+			continue
+		}
+		seen := map[string]bool{}
+		for _, channel := range p.Channels[eventType] {
+			if seen[channel] {
+				errs = append(errs, httpx.FieldError{Field: field, Code: httpx.FieldInvalid, Detail: fmt.Sprintf("%s is listed twice", channel)})
+				continue
+			}
+			seen[channel] = true
+			if _, ok := channels[channel]; !ok || (channel != ChannelEmail && channel != ChannelWebhook) {
+				errs = append(errs, httpx.FieldError{Field: field, Code: httpx.FieldUnsupported, Detail: fmt.Sprintf("channel %q is not available", channel)})
+				continue
+			}
+			if channel == ChannelEmail && p.Email == "" {
+				errs = append(errs, httpx.FieldError{Field: "email", Code: httpx.FieldRequired, Detail: "required by " + field})
+			}
+			if channel == ChannelWebhook && p.WebhookURL == "" {
+				errs = append(errs, httpx.FieldError{Field: "webhook_url", Code: httpx.FieldRequired, Detail: "required by " + field})
+			}
+		}
+	}
+
+	if p.Locale != "" && !localePattern.MatchString(p.Locale) {
+		errs = append(errs, httpx.FieldError{Field: "locale", Code: httpx.FieldInvalid, Detail: "must be a language tag like en or de-DE"})
+	}
+	if p.TimeZone != "" {
+		if _, err := time.LoadLocation(p.TimeZone); err != nil || p.TimeZone == "Local" {
+			errs = append(errs, httpx.FieldError{Field: "time_zone", Code: httpx.FieldInvalid, Detail: "must be an IANA time zone like Europe/Berlin"})
+		}
+	}
+	if q := p.QuietHours; q != nil {
+		start, errStart := parseClock(q.Start)
+		end, errEnd := parseClock(q.End)
+		if errStart != nil {
+			errs = append(errs, httpx.FieldError{Field: "quiet_hours.start", Code: httpx.FieldInvalid, Detail: "must be HH:MM"})
+		}
+		if errEnd != nil {
+			errs = append(errs, httpx.FieldError{Field: "quiet_hours.end", Code: httpx.FieldInvalid, Detail: "must be HH:MM"})
+		}
+		if errStart == nil && errEnd == nil && start == end {
+			errs = append(errs, httpx.FieldError{Field: "quiet_hours.end", Code: httpx.FieldInvalid, Detail: "must differ from start"})
+		}
+	}
+	return errs
+}
+
+// parseClock turns "HH:MM" into minutes after midnight.
+func parseClock(v string) (int, error) {
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,114 @@
+package notify
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPreferences_QuietUntil(t *testing.T) {
+	t.Parallel()
+
+	berlin := &QuietHours{Start: "22:00", End: "07:00"}
+	tests := []struct {
+		name      string
+		prefs     Preferences
+		now       time.Time
+		wantQuiet bool
+		wantUntil time.Time
+	}{
+		{name: "no quiet hours", prefs: Preferences{}, now: time.Date(2026, 3, 1, 23, 0, 0, 0, time.UTC)},
+		{name: "before window", prefs: Preferences{TimeZone: "Europe/Berlin", QuietHours: berlin}, now: time.Date(2026, 3, 1, 20, 59, 0, 0, time.UTC)},
+		{name: "start is quiet", prefs: Preferences{TimeZone: "Europe/Berlin", QuietHours: berlin}, now: time.Date(2026, 3, 1, 21, 0, 0, 0, time.UTC), wantQuiet: true, wantUntil: time.Date(2026, 3, 2, 6, 0, 0, 0, time.UTC)},
+		{name: "after midnight", prefs: Preferences{TimeZone: "Europe/Berlin", QuietHours: berlin}, now: time.Date(2026, 3, 2, 1, 0, 0, 0, time.UTC), wantQuiet: true, wantUntil: time.Date(2026, 3, 2, 6, 0, 0, 0, time.UTC)},
+		{name: "end is not quiet", prefs: Preferences{TimeZone: "Europe/Berlin", QuietHours: berlin}, now: time.Date(2026, 3, 2, 6, 0, 0, 0, time.UTC)},
+		{name: "same day window in UTC", prefs: Preferences{QuietHours: &QuietHours{Start: "12:00", End: "14:00"}}, now: time.Date(2026, 3, 1, 13, 0, 0, 0, time.UTC), wantQuiet: true, wantUntil: time.Date(2026, 3, 1, 14, 0, 0, 0, time.UTC)},
+		// Clocks go forward on 29 March 2026, so 07:00 is 05:00 UTC.
+		{name: "across DST", prefs: Preferences{TimeZone: "Europe/Berlin", QuietHours: berlin}, now: time.Date(2026, 3, 28, 23, 0, 0, 0, time.UTC), wantQuiet: true, wantUntil: time.Date(2026, 3, 29, 5, 0, 0, 0, time.UTC)},
+	}
+	for _, tc := range tests {
+		until, quiet := tc.prefs.QuietUntil(tc.now)
+		if quiet != tc.wantQuiet || !until.Equal(tc.wantUntil) {
+			t.Fatalf("%s: quiet mismatch: got=%v %v want=%v %v", tc.name, quiet, until, tc.wantQuiet, tc.wantUntil)
+		}
+	}
+}
+
+func TestPreferences_Targets(t *testing.T) {
+	t.Parallel()
+
+	p := Preferences{
+		Email:      "u1@example.com",
+		WebhookURL: "https://hooks.example.com/u-1",
+		Channels:   map[string][]string{EventOrderCreated: {ChannelWebhook, ChannelEmail}},
+	}
+	got := p.Targets(EventOrderCreated)
+	want := []Target{{Channel: ChannelWebhook, Recipient: "https://hooks.example.com/u-1"}, {Channel: ChannelEmail, Recipient: "u1@example.com"}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("targets mismatch: got=%+v want=%+v", got, want)
+	}
+	if got := p.Targets("order.shipped"); len(got) != 0 {
+		t.Fatalf("event type without channels should have no targets: got=%+v", got)
+	}
+}
+
+func TestPreferences_Validate(t *testing.T) {
+	t.Parallel()
+
+	supports := func(eventType string) bool { return eventType == EventOrderCreated }
+	channels := map[string]Channel{ChannelEmail: &stubChannel{name: ChannelEmail}, "log": &stubChannel{name: "log"}}
+	tests := []struct {
+		name       string
+		prefs      Preferences
+		wantFields string
+	}{
+		{name: "valid", prefs: Preferences{Email: "u1@example.com", Channels: map[string][]string{EventOrderCreated: {ChannelEmail}}, Locale: "pt_BR", TimeZone: "America/Sao_Paulo", QuietHours: &QuietHours{Start: "23:00", End: "06:30"}}},
+		{name: "display name", prefs: Preferences{Email: "Ann <u1@example.com>"}, wantFields: "email"},
+		{name: "plain http webhook", prefs: Preferences{WebhookURL: "http://hooks.example.com"}, wantFields: "webhook_url"},
+		{name: "link-local webhook", prefs: Preferences{WebhookURL: "https://169.254.169.254/latest"}, wantFields: "webhook_url"},
+		{name: "private ipv6 webhook", prefs: Preferences{WebhookURL: "https://[fd00::1]/hook"}, wantFields: "webhook_url"},
+		{name: "unknown event type", prefs: Preferences{Channels: map[string][]string{"order.lost": {ChannelEmail}}}, wantFields: "channels.order.lost"},
+		{name: "unconfigured channel", prefs: Preferences{WebhookURL: "https://hooks.example.com", Channels: map[string][]string{EventOrderCreated: {ChannelWebhook}}}, wantFields: "channels.order.created"},
+		{name: "operator channel", prefs: Preferences{Channels: map[string][]string{EventOrderCreated: {"log"}}}, wantFields: "channels.order.created"},
+		{name: "missing contact", prefs: Preferences{Channels: map[string][]string{EventOrderCreated: {ChannelEmail}}}, wantFields: "email"},
+		{name: "duplicate channel", prefs: Preferences{Email: "u1@example.com", Channels: map[string][]string{EventOrderCreated: {ChannelEmail, ChannelEmail}}}, wantFields: "channels.order.created"},
+		{name: "bad locale", prefs: Preferences{Locale: "german!"}, wantFields: "locale"},
+		{name: "bad time zone", prefs: Preferences{TimeZone: "Local"}, wantFields: "time_zone"},
+		{name: "bad quiet hours", prefs: Preferences{QuietHours: &QuietHours{Start: "25:00", End: "7am"}}, wantFields: "quiet_hours.start,quiet_hours.end"},
+		{name: "empty quiet hours", prefs: Preferences{QuietHours: &QuietHours{Start: "07:00", End: "07:00"}}, wantFields: "quiet_hours.end"},
+	}
+	for _, tc := range tests {
+		var fields []string
+		for _, e := range tc.prefs.Validate(supports, channels) {
+			fields = append(fields, e.Field)
+		}
+		if got := strings.Join(fields, ","); got != tc.wantFields {
+			t.Fatalf("%s: field errors mismatch: got=%s want=%s", tc.name, got, tc.wantFields)
+		}
+	}
+}
+
+// The dispatcher renders with the event's locale and records which
+// templates it used.
+func TestDispatcher_DeliverLocale(t *testing.T) {
+	t.Parallel()
+
+	templates, err := LoadTemplates(DefaultTemplates())
+	if err != nil {
+		t.Fatalf("load templates: %v", err)
+	}
+	email := &stubChannel{name: ChannelEmail}
+	recorder := &stubRecorder{}
+	d := NewDispatcher(templates, recorder, nil, email)
+
+	if _, err := d.Deliver(context.Background(), Event{Type: EventOrderCreated, OrderID: "o-1", Locale: "de-AT"}, []Target{{Channel: ChannelEmail, Recipient: "u1@example.com"}}); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	if got := email.sent[0].Subject; got != "Deine PulseCart-Bestellung o-1 ist bestätigt" {
+		t.Fatalf("subject mismatch: got=%q", got)
+	}
+	if got := recorder.attempts[0].Template; got != "order.created.de" {
+		t.Fatalf("template mismatch: got=%q want=%q", got, "order.created.de")
+	}
+}
@@ -0,0 +1,82 @@
+package notify
+
+import (
+	"context"
+	"time"
+)
+
+// Notification is one message to one recipient, with its latest outcome.
+type Notification struct {
+	ID                string     `json:"id"`
+	OrderID           string     `json:"order_id"`
+	UserID            string     `json:"user_id"`
+	EventType         string     `json:"event_type"`
+	RequestID         string     `json:"request_id,omitempty"`
+	Channel           string     `json:"channel"`
+	Recipient         string     `json:"recipient"`
+	Template          string     `json:"template"`
+	Status            string     `json:"status"`
+	Attempts          int        `json:"attempts"`
+	ProviderMessageID string     `json:"provider_message_id,omitempty"`
+	LastError         string     `json:"last_error,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+	SentAt            *time.Time `json:"sent_at,omitempty"`
+}
+
+// ListFilter selects notifications by order or user, newest first.
+type ListFilter struct {
+	OrderID   string
+	UserID    string
+	EventType string
+	Limit     int
+}
+
+// Store keeps notification history and makes sure each order event is sent
+// once. Claim returns false when the event was already sent, or is being
+// sent; Release gives up a claim whose delivery failed so it can be retried.
+type Store interface {
+	DeliveryRecorder
+	Claim(ctx context.Context, orderID, eventType, userID string) (bool, error)
+	Complete(ctx context.Context, orderID, eventType string) error
+	Release(ctx context.Context, orderID, eventType string) error
+	List(ctx context.Context, filter ListFilter) ([]Notification, error)
+}
+
+// Deferred is an event held back by quiet hours until DeliverAt. Attempts
+// counts how often it has been picked up for release.
+type Deferred struct {
+	ID        string
+	Event     Event
+	DeliverAt time.Time
+	Attempts  int
+	LastError string
+}
+
+// DeferralStore holds events until they are due.
+//
+// Defer also marks the event's claim as deferred, so redeliveries are
+// answered as duplicates and the claim is not taken over as stale. Due
+// leases up to limit due events, so schedulers on other replicas skip them
+// until the lease runs out. Reschedule moves an event to a later time and
+// Finish drops it.
+type DeferralStore interface {
+	Defer(ctx context.Context, d Deferred) error
+	Due(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]Deferred, error)
+	Reschedule(ctx context.Context, id string, at time.Time, lastError string) error
+	Finish(ctx context.Context, id string) error
+}
+
+// Recorders sends each attempt to every recorder and returns the first
+// error.
+type Recorders []DeliveryRecorder
+
+func (rs Recorders) RecordAttempt(ctx context.Context, attempt Attempt) error {
+	var first error
+	for _, r := range rs {
+		if err := r.RecordAttempt(ctx, attempt); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
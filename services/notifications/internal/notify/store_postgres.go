@@ -0,0 +1,345 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresStore keeps notifications in Postgres. Claims stuck in sending,
+// for example after a crash, can be taken over once ClaimTimeout passes.
+type PostgresStore struct {
+	pool         *pgxpool.Pool
+	ClaimTimeout time.Duration
+}
+
+func NewPostgresStore(pool *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{pool: pool, ClaimTimeout: 5 * time.Minute}
+}
+
+func (s *PostgresStore) EnsureSchema(ctx context.Context) error {
+	_, err := s.pool.Exec(ctx, `
+CREATE TABLE IF NOT EXISTS notifications (
+	id TEXT PRIMARY KEY,
+	order_id TEXT NOT NULL,
+	user_id TEXT NOT NULL,
+	event_type TEXT NOT NULL,
+	request_id TEXT NOT NULL DEFAULT '',
+	channel TEXT NOT NULL,
+	recipient TEXT NOT NULL,
+	template TEXT NOT NULL,
+	status TEXT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	provider_message_id TEXT NOT NULL DEFAULT '',
+	last_error TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL,
+	sent_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS notifications_order_idx ON notifications (order_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS notifications_user_idx ON notifications (user_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS notification_events (
+	order_id TEXT NOT NULL,
+	event_type TEXT NOT NULL,
+	user_id TEXT NOT NULL,
+	status TEXT NOT NULL,
+	claimed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	completed_at TIMESTAMPTZ,
+	PRIMARY KEY (order_id, event_type)
+);
+
+CREATE TABLE IF NOT EXISTS notification_preferences (
+	user_id TEXT PRIMARY KEY,
+	email TEXT NOT NULL DEFAULT '',
+	webhook_url TEXT NOT NULL DEFAULT '',
+	channels JSONB NOT NULL DEFAULT '{}',
+	locale TEXT NOT NULL DEFAULT '',
+	time_zone TEXT NOT NULL DEFAULT '',
+	quiet_start TEXT NOT NULL DEFAULT '',
+	quiet_end TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS notification_deferred (
+	id TEXT PRIMARY KEY,
+	order_id TEXT NOT NULL,
+	event_type TEXT NOT NULL,
+	user_id TEXT NOT NULL,
+	request_id TEXT NOT NULL DEFAULT '',
+	total_minor BIGINT NOT NULL,
+	currency TEXT NOT NULL,
+	order_created_at TEXT NOT NULL DEFAULT '',
+	deliver_at TIMESTAMPTZ NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	leased_until TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	UNIQUE (order_id, event_type)
+);
+
+CREATE INDEX IF NOT EXISTS notification_deferred_due_idx ON notification_deferred (deliver_at);
+`)
+	return err
+}
+
+// RecordAttempt upserts the notification with the attempt's outcome. A sent
+// notification stays sent.
+func (s *PostgresStore) RecordAttempt(ctx context.Context, a Attempt) error {
+	var sentAt *time.Time
+	if a.Status == StatusSent {
+		at := a.At.Add(a.Duration)
+		sentAt = &at
+	}
+	_, err := s.pool.Exec(ctx, `
+INSERT INTO notifications (
+	id, order_id, user_id, event_type, request_id, channel, recipient, template,
+	status, attempts, provider_message_id, last_error, created_at, updated_at, sent_at
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $13, $14)
+ON CONFLICT (id) DO UPDATE
+SET status = EXCLUDED.status,
+	attempts = GREATEST(notifications.attempts, EXCLUDED.attempts),
+	provider_message_id = EXCLUDED.provider_message_id,
+	last_error = EXCLUDED.last_error,
+	updated_at = EXCLUDED.updated_at,
+	sent_at = EXCLUDED.sent_at
+WHERE notifications.status <> 'sent'`,
+		a.MessageID, a.OrderID, a.UserID, a.EventType, a.RequestID, a.Channel, a.Recipient, a.Template,
+		a.Status, a.Attempt, a.ProviderID, a.Error, a.At, sentAt,
+	)
+	return err
+}
+
+// Claim takes the event unless it was completed or another claim is still
+// fresh.
+func (s *PostgresStore) Claim(ctx context.Context, orderID, eventType, userID string) (bool, error) {
+	var claimed string
+	err := s.pool.QueryRow(ctx, `
+INSERT INTO notification_events (order_id, event_type, user_id, status)
+VALUES ($1, $2, $3, 'sending')
+ON CONFLICT (order_id, event_type) DO UPDATE
+SET claimed_at = NOW()
+WHERE notification_events.status = 'sending'
+	AND notification_events.claimed_at <= NOW() - make_interval(secs => $4)
+RETURNING order_id`,
+		orderID, eventType, userID, s.claimTimeout().Seconds(),
+	).Scan(&claimed)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *PostgresStore) Complete(ctx context.Context, orderID, eventType string) error {
+	_, err := s.pool.Exec(ctx,
+		`UPDATE notification_events SET status = 'done', completed_at = NOW() WHERE order_id = $1 AND event_type = $2`,
+		orderID, eventType,
+	)
+	return err
+}
+
+func (s *PostgresStore) Release(ctx context.Context, orderID, eventType string) error {
+	_, err := s.pool.Exec(ctx,
+		`DELETE FROM notification_events WHERE order_id = $1 AND event_type = $2 AND status IN ('sending', 'deferred')`,
+		orderID, eventType,
+	)
+	return err
+}
+
+func (s *PostgresStore) List(ctx context.Context, f ListFilter) ([]Notification, error) {
+	rows, err := s.pool.Query(ctx, `
+SELECT id, order_id, user_id, event_type, request_id, channel, recipient, template,
+	status, attempts, provider_message_id, last_error, created_at, updated_at, sent_at
+FROM notifications
+WHERE ($1 = '' OR order_id = $1)
+	AND ($2 = '' OR user_id = $2)
+	AND ($3 = '' OR event_type = $3)
+ORDER BY created_at DESC, id
+LIMIT $4`,
+		f.OrderID, f.UserID, f.EventType, f.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []Notification{}
+	for rows.Next() {
+		var n Notification
+		if err := rows.Scan(
+			&n.ID, &n.OrderID, &n.UserID, &n.EventType, &n.RequestID, &n.Channel, &n.Recipient, &n.Template,
+			&n.Status, &n.Attempts, &n.ProviderMessageID, &n.LastError, &n.CreatedAt, &n.UpdatedAt, &n.SentAt,
+		); err != nil {
+			return nil, err
+		}
+		out = append(out, n)
+	}
+	return out, rows.Err()
+}
+
+func (s *PostgresStore) GetPreferences(ctx context.Context, userID string) (Preferences, error) {
+	p := Preferences{UserID: userID}
+	var channels []byte
+	var quietStart, quietEnd string
+	err := s.pool.QueryRow(ctx, `
+SELECT email, webhook_url, channels, locale, time_zone, quiet_start, quiet_end, created_at, updated_at
+FROM notification_preferences
+WHERE user_id = $1`,
+		userID,
+	).Scan(&p.Email, &p.WebhookURL, &channels, &p.Locale, &p.TimeZone, &quietStart, &quietEnd, &p.CreatedAt, &p.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Preferences{}, ErrPreferencesNotFound
+	}
+	if err != nil {
+		return Preferences{}, err
+	}
+	if err := json.Unmarshal(channels, &p.Channels); err != nil {
+		return Preferences{}, fmt.Errorf("decode channels: %w", err)
+	}
+	if quietStart != "" {
+		p.QuietHours = &QuietHours{Start: quietStart, End: quietEnd}
+	}
+	return p, nil
+}
+
+// PutPreferences creates or replaces the user's preferences.
+func (s *PostgresStore) PutPreferences(ctx context.Context, p Preferences) (Preferences, error) {
+	if p.Channels == nil {
+		p.Channels = map[string][]string{}
+	}
+	channels, err := json.Marshal(p.Channels)
+	if err != nil {
+		return Preferences{}, err
+	}
+	var quietStart, quietEnd string
+	if p.QuietHours != nil {
+		quietStart, quietEnd = p.QuietHours.Start, p.QuietHours.End
+	}
+	err = s.pool.QueryRow(ctx, `
+INSERT INTO notification_preferences (user_id, email, webhook_url, channels, locale, time_zone, quiet_start, quiet_end)
+VALUES ($1, $2, $3, $4::jsonb, $5, $6, $7, $8)
+ON CONFLICT (user_id) DO UPDATE
+SET email = EXCLUDED.email,
+	webhook_url = EXCLUDED.webhook_url,
+	channels = EXCLUDED.channels,
+	locale = EXCLUDED.locale,
+	time_zone = EXCLUDED.time_zone,
+	quiet_start = EXCLUDED.quiet_start,
+	quiet_end = EXCLUDED.quiet_end,
+	updated_at = NOW()
+RETURNING created_at, updated_at`,
+		p.UserID, p.Email, p.WebhookURL, string(channels), p.Locale, p.TimeZone, quietStart, quietEnd,
+	).Scan(&p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return Preferences{}, err
+	}
+	return p, nil
+}
+
+func (s *PostgresStore) DeletePreferences(ctx context.Context, userID string) error {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM notification_preferences WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrPreferencesNotFound
+	}
+	return nil
+}
+
+// Defer stores the event and marks its claim deferred in one transaction.
+// Deferring an event again only moves its delivery time.
+func (s *PostgresStore) Defer(ctx context.Context, d Deferred) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	e := d.Event
+	if _, err := tx.Exec(ctx, `
+INSERT INTO notification_deferred (
+	id, order_id, event_type, user_id, request_id, total_minor, currency, order_created_at, deliver_at
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+ON CONFLICT (order_id, event_type) DO UPDATE
+SET deliver_at = EXCLUDED.deliver_at, leased_until = NULL`,
+		d.ID, e.OrderID, e.Type, e.UserID, e.RequestID, e.Total.Minor, e.Total.Currency, e.CreatedAt, d.DeliverAt,
+	); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx,
+		`UPDATE notification_events SET status = 'deferred' WHERE order_id = $1 AND event_type = $2`,
+		e.OrderID, e.Type,
+	); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Due leases the oldest due events. Rows locked by another replica's Due
+// are skipped rather than waited on.
+func (s *PostgresStore) Due(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]Deferred, error) {
+	rows, err := s.pool.Query(ctx, `
+UPDATE notification_deferred
+SET leased_until = $1 + make_interval(secs => $2), attempts = attempts + 1
+WHERE id IN (
+	SELECT id FROM notification_deferred
+	WHERE deliver_at <= $1 AND (leased_until IS NULL OR leased_until <= $1)
+	ORDER BY deliver_at
+	LIMIT $3
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING id, order_id, event_type, user_id, request_id, total_minor, currency, order_created_at,
+	deliver_at, attempts, last_error`,
+		now, lease.Seconds(), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Deferred
+	for rows.Next() {
+		var d Deferred
+		if err := rows.Scan(
+			&d.ID, &d.Event.OrderID, &d.Event.Type, &d.Event.UserID, &d.Event.RequestID, &d.Event.Total.Minor, &d.Event.Total.Currency, &d.Event.CreatedAt,
+			&d.DeliverAt, &d.Attempts, &d.LastError,
+		); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+func (s *PostgresStore) Reschedule(ctx context.Context, id string, at time.Time, lastError string) error {
+	_, err := s.pool.Exec(ctx,
+		`UPDATE notification_deferred SET deliver_at = $2, last_error = $3, leased_until = NULL WHERE id = $1`,
+		id, at, lastError,
+	)
+	return err
+}
+
+func (s *PostgresStore) Finish(ctx context.Context, id string) error {
+	_, err := s.pool.Exec(ctx, `DELETE FROM notification_deferred WHERE id = $1`, id)
+	return err
+}
+
+func (s *PostgresStore) claimTimeout() time.Duration {
+	if s.ClaimTimeout <= 0 {
+		return 5 * time.Minute
+	}
+	return s.ClaimTimeout
+}
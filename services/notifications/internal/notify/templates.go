@@ -0,0 +1,139 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates/*.tmpl
+var defaultTemplates embed.FS
+
+// DefaultTemplates are the templates built into the service.
+func DefaultTemplates() fs.FS {
+	sub, _ := fs.Sub(defaultTemplates, "templates")
+	return sub
+}
+
+// ErrNoTemplate is returned for event types without templates.
+var ErrNoTemplate = errors.New("no template for event type")
+
+// Templates renders messages per event type from three files each:
+// <type>.subject.tmpl and <type>.text.tmpl (text/template) and
+// <type>.html.tmpl (html/template, optional). Localized sets add the
+// lower-case locale to the type, like order.created.de.subject.tmpl.
+type Templates struct {
+	sets map[string]templateSet
+}
+
+type templateSet struct {
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+}
+
+// Rendered is a message body before it is addressed. Template names the
+// set it came from.
+type Rendered struct {
+	Template string
+	Subject  string
+	Text     string
+	HTML     string
+}
+
+// LoadTemplates parses every event type's templates in fsys.
+func LoadTemplates(fsys fs.FS) (*Templates, error) {
+	subjects, err := fs.Glob(fsys, "*.subject.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	t := &Templates{sets: map[string]templateSet{}}
+	for _, name := range subjects {
+		eventType := strings.TrimSuffix(name, ".subject.tmpl")
+		var set templateSet
+		if set.subject, err = texttemplate.ParseFS(fsys, name); err != nil {
+			return nil, err
+		}
+		if set.text, err = texttemplate.ParseFS(fsys, eventType+".text.tmpl"); err != nil {
+			return nil, fmt.Errorf("%s: %w", eventType, err)
+		}
+		if _, statErr := fs.Stat(fsys, eventType+".html.tmpl"); statErr == nil {
+			if set.html, err = htmltemplate.ParseFS(fsys, eventType+".html.tmpl"); err != nil {
+				return nil, err
+			}
+		}
+		t.sets[eventType] = set
+	}
+	if len(t.sets) == 0 {
+		return nil, errors.New("no templates found")
+	}
+	return t, nil
+}
+
+// Has reports whether eventType has templates.
+func (t *Templates) Has(eventType string) bool {
+	_, ok := t.sets[eventType]
+	return ok
+}
+
+// Render fills in eventType's templates with data.
+func (t *Templates) Render(eventType string, data any) (Rendered, error) {
+	return t.RenderLocale(eventType, "", data)
+}
+
+// RenderLocale prefers eventType's templates for locale, falling back from
+// de-DE to de and then to the unlocalized set.
+func (t *Templates) RenderLocale(eventType, locale string, data any) (Rendered, error) {
+	if !t.Has(eventType) {
+		return Rendered{}, fmt.Errorf("%w: %q", ErrNoTemplate, eventType)
+	}
+	name := eventType
+	for _, l := range localeFallbacks(locale) {
+		if _, ok := t.sets[eventType+"."+l]; ok {
+			name = eventType + "." + l
+			break
+		}
+	}
+	set := t.sets[name]
+	out := Rendered{Template: name}
+	var buf bytes.Buffer
+	if err := set.subject.Execute(&buf, data); err != nil {
+		return Rendered{}, err
+	}
+	// A subject is a single header line.
+	out.Subject = strings.Join(strings.Fields(buf.String()), " ")
+	buf.Reset()
+	if err := set.text.Execute(&buf, data); err != nil {
+		return Rendered{}, err
+	}
+	out.Text = buf.String()
+	if set.html != nil {
+		buf.Reset()
+		if err := set.html.Execute(&buf, data); err != nil {
+			return Rendered{}, err
+		}
+		out.HTML = buf.String()
+	}
+	return out, nil
+}
+
+// localeFallbacks lists the locales to try for locale, most specific first:
+// de-DE gives de-de, then de.
+func localeFallbacks(locale string) []string {
+	locale = strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+	var out []string
+	for locale != "" {
+		out = append(out, locale)
+		i := strings.LastIndex(locale, "-")
+		if i < 0 {
+			break
+		}
+		locale = locale[:i]
+	}
+	return out
+}
@@ -0,0 +1,190 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/triad-platform/triad-app/pkg/money"
+)
+
+// Webhook request headers. The signature is the hex HMAC-SHA256 of
+// "<timestamp>.<body>" with the shared secret, so receivers can reject
+// forged and replayed calls.
+const (
+	WebhookIDHeader        = "X-PulseCart-Delivery"
+	WebhookTimestampHeader = "X-PulseCart-Timestamp"
+	WebhookSignatureHeader = "X-PulseCart-Signature"
+)
+
+// WebhookChannel POSTs a JSON message to the recipient URL.
+type WebhookChannel struct {
+	Client *http.Client
+	// Secret signs each request when set.
+	Secret string
+}
+
+// ErrPrivateWebhookHost means a webhook URL points at an address inside the
+// network the service runs in.
+var ErrPrivateWebhookHost = errors.New("webhook host is not a public address")
+
+// NewWebhookChannel returns a channel whose client only connects to public
+// addresses, ignores proxy settings and does not follow redirects, so a
+// user's webhook URL cannot reach internal services.
+func NewWebhookChannel(timeout time.Duration, secret string) *WebhookChannel {
+	dialer := &net.Dialer{Timeout: timeout, Control: refusePrivateAddr}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &WebhookChannel{
+		Client: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		Secret: secret,
+	}
+}
+
+// HostResolver looks up a host's addresses; *net.Resolver implements it.
+type HostResolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// CheckWebhookHost resolves the host of rawURL and returns
+// ErrPrivateWebhookHost if any of its addresses is not public. The client
+// from NewWebhookChannel checks again when it connects, which also covers
+// hosts that resolve differently later.
+func CheckWebhookHost(ctx context.Context, resolver HostResolver, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	host := u.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if !publicIP(ip) {
+			return ErrPrivateWebhookHost
+		}
+		return nil
+	}
+	addrs, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !publicIP(addr.IP) {
+			return ErrPrivateWebhookHost
+		}
+	}
+	return nil
+}
+
+func refusePrivateAddr(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+		return fmt.Errorf("%w: %s", ErrPrivateWebhookHost, host)
+	}
+	return nil
+}
+
+func publicIP(ip net.IP) bool {
+	return !ip.IsPrivate() && !ip.IsLoopback() && !ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() && !ip.IsUnspecified() && !ip.IsMulticast()
+}
+
+func (c *WebhookChannel) Name() string { return "webhook" }
+
+type webhookPayload struct {
+	ID        string      `json:"id"`
+	EventType string      `json:"event_type"`
+	OrderID   string      `json:"order_id"`
+	UserID    string      `json:"user_id"`
+	RequestID string      `json:"request_id,omitempty"`
+	Total     money.Money `json:"total"`
+	CreatedAt string      `json:"created_at,omitempty"`
+	Subject   string      `json:"subject"`
+	Text      string      `json:"text"`
+}
+
+// Send returns the message ID, which is also the delivery header, so
+// receivers can deduplicate retries. Redirects, 4xx responses other than
+// 408 and 429, and non-public hosts are permanent.
+func (c *WebhookChannel) Send(ctx context.Context, msg Message) (string, error) {
+	body, err := json.Marshal(webhookPayload{
+		ID:        msg.ID,
+		EventType: msg.Event.Type,
+		OrderID:   msg.Event.OrderID,
+		UserID:    msg.Event.UserID,
+		RequestID: msg.Event.RequestID,
+		Total:     msg.Event.Total,
+		CreatedAt: msg.Event.CreatedAt,
+		Subject:   msg.Subject,
+		Text:      msg.Text,
+	})
+	if err != nil {
+		return "", Permanent(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, msg.Recipient, bytes.NewReader(body))
+	if err != nil {
+		return "", Permanent(fmt.Errorf("invalid webhook url: %w", err))
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookIDHeader, msg.ID)
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	if c.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhook(c.Secret, timestamp, body))
+	}
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if errors.Is(err, ErrPrivateWebhookHost) {
+		return "", Permanent(err)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return msg.ID, nil
+	}
+	err = fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		// Redirects are not followed; the receiver has to fix its URL.
+		return "", Permanent(err)
+	}
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return "", Permanent(err)
+	}
+	return "", err
+}
+
+// SignWebhook computes the signature sent in WebhookSignatureHeader.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,151 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/triad-platform/triad-app/pkg/money"
+)
+
+func TestWebhookChannel_Send(t *testing.T) {
+	t.Parallel()
+
+	var gotPayload webhookPayload
+	var gotSignature, wantSignature string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &gotPayload)
+		gotSignature = r.Header.Get(WebhookSignatureHeader)
+		wantSignature = "sha256=" + SignWebhook("s3cret", r.Header.Get(WebhookTimestampHeader), body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	channel := testWebhookChannel(srv, "s3cret")
+	msg := Message{
+		ID:        "m-1",
+		Event:     Event{Type: EventOrderCreated, OrderID: "o-1", UserID: "u-1", Total: money.New(1299, money.MustParseCurrency("USD"))},
+		Recipient: srv.URL,
+		Subject:   "Order confirmed",
+		Text:      "Thanks",
+	}
+	id, err := channel.Send(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if id != "m-1" {
+		t.Fatalf("provider id mismatch: got=%q want=%q", id, "m-1")
+	}
+	if gotSignature == "" || gotSignature != wantSignature {
+		t.Fatalf("signature mismatch: got=%q want=%q", gotSignature, wantSignature)
+	}
+	if gotPayload.ID != "m-1" || gotPayload.EventType != EventOrderCreated || gotPayload.OrderID != "o-1" || gotPayload.Total.Minor != 1299 || gotPayload.Subject != "Order confirmed" {
+		t.Fatalf("payload mismatch: got=%+v", gotPayload)
+	}
+}
+
+func TestWebhookChannel_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		status        int
+		wantPermanent bool
+	}{
+		{name: "bad request", status: http.StatusBadRequest, wantPermanent: true},
+		{name: "gone", status: http.StatusGone, wantPermanent: true},
+		{name: "redirect", status: http.StatusFound, wantPermanent: true},
+		{name: "rate limited", status: http.StatusTooManyRequests},
+		{name: "server error", status: http.StatusBadGateway},
+	}
+	for _, tc := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Location", "/elsewhere")
+			w.WriteHeader(tc.status)
+		}))
+		_, err := testWebhookChannel(srv, "").Send(context.Background(), Message{ID: "m-1", Recipient: srv.URL})
+		srv.Close()
+		if err == nil {
+			t.Fatalf("%s: expected an error", tc.name)
+		}
+		if IsPermanent(err) != tc.wantPermanent {
+			t.Fatalf("%s: permanent mismatch: got=%v want=%v", tc.name, IsPermanent(err), tc.wantPermanent)
+		}
+	}
+}
+
+func TestWebhookChannel_RefusesPrivateHosts(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	_, err := NewWebhookChannel(time.Second, "").Send(context.Background(), Message{ID: "m-1", Recipient: srv.URL})
+	if !errors.Is(err, ErrPrivateWebhookHost) || !IsPermanent(err) {
+		t.Fatalf("error mismatch: got=%v want permanent %v", err, ErrPrivateWebhookHost)
+	}
+	if calls != 0 {
+		t.Fatalf("loopback receiver was called %d times", calls)
+	}
+}
+
+func TestCheckWebhookHost(t *testing.T) {
+	t.Parallel()
+
+	resolver := stubResolver{
+		"hooks.example.com": {{IP: net.ParseIP("93.184.216.34")}},
+		"localhost":         {{IP: net.ParseIP("127.0.0.1")}, {IP: net.ParseIP("::1")}},
+		"mixed.example.com": {{IP: net.ParseIP("93.184.216.34")}, {IP: net.ParseIP("192.168.1.5")}},
+	}
+	tests := []struct {
+		name    string
+		url     string
+		wantErr error
+	}{
+		{name: "public host", url: "https://hooks.example.com/u-1"},
+		{name: "public ip", url: "https://93.184.216.34/u-1"},
+		{name: "localhost", url: "https://localhost/u-1", wantErr: ErrPrivateWebhookHost},
+		{name: "one private address", url: "https://mixed.example.com/u-1", wantErr: ErrPrivateWebhookHost},
+		{name: "metadata ip", url: "https://169.254.169.254/latest", wantErr: ErrPrivateWebhookHost},
+		{name: "unspecified ip", url: "https://0.0.0.0/u-1", wantErr: ErrPrivateWebhookHost},
+	}
+	for _, tc := range tests {
+		err := CheckWebhookHost(context.Background(), resolver, tc.url)
+		if !errors.Is(err, tc.wantErr) {
+			t.Fatalf("%s: error mismatch: got=%v want=%v", tc.name, err, tc.wantErr)
+		}
+	}
+	if err := CheckWebhookHost(context.Background(), resolver, "https://missing.example.com"); err == nil {
+		t.Fatalf("unresolvable host: expected an error")
+	}
+}
+
+// testWebhookChannel keeps the production client settings but connects
+// through srv's transport, which the private address guard would refuse.
+func testWebhookChannel(srv *httptest.Server, secret string) *WebhookChannel {
+	channel := NewWebhookChannel(time.Second, secret)
+	channel.Client.Transport = srv.Client().Transport
+	return channel
+}
+
+// stubResolver answers lookups from a fixed table; other hosts do not resolve.
+type stubResolver map[string][]net.IPAddr
+
+func (s stubResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	addrs, ok := s[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return addrs, nil
+}
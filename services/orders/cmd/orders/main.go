@@ -8,19 +8,26 @@ import (
 	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/nats-io/nats.go"
 	"github.com/redis/go-redis/v9"
+	ordersv1 "github.com/triad-platform/triad-app/contracts/proto/orders/v1"
 	"github.com/triad-platform/triad-app/pkg/config"
+	"github.com/triad-platform/triad-app/pkg/grpcx"
 	"github.com/triad-platform/triad-app/pkg/httpx"
 	"github.com/triad-platform/triad-app/pkg/logx"
 	"github.com/triad-platform/triad-app/pkg/metricsx"
+	"github.com/triad-platform/triad-app/services/orders/internal/catalog"
 	"github.com/triad-platform/triad-app/services/orders/internal/orders"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 func main() {
@@ -28,24 +35,38 @@ func main() {
 	metrics := metricsx.NewRegistry("triad_orders")
 
 	port := config.Getenv("PORT", "8081")
+	grpcPort := config.Getenv("GRPC_PORT", "9081")
 	dbURL := databaseURL()
 	redisAddr := config.Getenv("REDIS_ADDR", "localhost:6379")
-	natsURL := config.Getenv("NATS_URL", "nats://localhost:4222")
+	transport := eventTransport()
 
-	dbConn, err := pgx.Connect(context.Background(), dbURL)
+	dbPool, err := pgxpool.New(context.Background(), dbURL)
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed to connect to Postgres")
 	}
-	defer dbConn.Close(context.Background())
+	defer dbPool.Close()
 
-	orderStore := orders.NewPostgresOrderStore(dbConn)
+	orderStore := orders.NewPostgresOrderStore(dbPool)
+	pgIdempotencyStore := orders.NewPostgresIdempotencyStore(dbPool)
+	catalogStore := catalog.NewPostgresStore(dbPool)
 	schemaCtx, schemaCancel := context.WithTimeout(context.Background(), 10*time.Second)
 	if err := orderStore.EnsureSchema(schemaCtx); err != nil {
 		schemaCancel()
 		log.Fatal().Err(err).Msg("failed to ensure orders schema")
 	}
+	if err := pgIdempotencyStore.EnsureSchema(schemaCtx); err != nil {
+		schemaCancel()
+		log.Fatal().Err(err).Msg("failed to ensure idempotency schema")
+	}
+	if err := catalogStore.EnsureSchema(schemaCtx); err != nil {
+		schemaCancel()
+		log.Fatal().Err(err).Msg("failed to ensure catalog schema")
+	}
 	schemaCancel()
 
+	bgCtx, bgCancel := context.WithCancel(context.Background())
+	defer bgCancel()
+
 	redisOptions := &redis.Options{
 		Addr: redisAddr,
 	}
@@ -62,24 +83,58 @@ func main() {
 	redisClient := redis.NewClient(redisOptions)
 	defer redisClient.Close()
 
-	nc, err := nats.Connect(natsURL)
-	if err != nil {
-		log.Fatal().Err(err).Msg("failed to connect to NATS")
+	redisIdempotencyStore := orders.NewRedisIdempotencyStore(redisClient, "orders:idempotency:")
+	var idempotencyStore orders.IdempotencyStore
+	switch mode := idempotencyStoreMode(); mode {
+	case idempotencyModeRedis:
+		idempotencyStore = redisIdempotencyStore
+	case idempotencyModePostgres:
+		idempotencyStore = pgIdempotencyStore
+	case idempotencyModeRedisPostgres:
+		idempotencyStore = &orders.FallbackIdempotencyStore{
+			Primary:  redisIdempotencyStore,
+			Fallback: pgIdempotencyStore,
+			Metrics:  metrics,
+		}
+	default:
+		log.Fatal().Str("mode", mode).Msg("unsupported IDEMPOTENCY_STORE")
+	}
+	go pgIdempotencyStore.RunCleanup(bgCtx, idempotencyCleanupInterval(), log)
+
+	var publisher orders.EventPublisher
+	switch transport {
+	case transportNATS:
+		nc, err := nats.Connect(config.Getenv("NATS_URL", "nats://localhost:4222"))
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to connect to NATS")
+		}
+		defer nc.Close()
+		publisher = orders.NewNATSEventPublisher(nc, orders.OrdersCreatedSubject)
+	case transportRedisStreams:
+		publisher = orders.NewRedisStreamEventPublisher(redisClient, orders.OrdersCreatedSubject, redisStreamMaxLen())
+	default:
+		log.Fatal().Str("transport", transport).Msg("unsupported EVENT_TRANSPORT")
 	}
-	defer nc.Close()
 
 	r := chi.NewRouter()
+	r.NotFound(httpx.NotFound)
+	r.MethodNotAllowed(httpx.MethodNotAllowed)
 	r.Get("/healthz", httpx.Healthz)
 	r.Get("/readyz", httpx.Readyz)
 	r.Get("/metrics", metrics.Handler().ServeHTTP)
 
 	h := &orders.Handler{
-		IdempotencyStore: orders.NewRedisIdempotencyStore(redisClient, "orders:idempotency:"),
-		EventPublisher:   orders.NewNATSEventPublisher(nc, orders.OrdersCreatedSubject),
-		OrderStore:       orderStore,
-		Metrics:          metrics,
-		IdempotencyTTL:   24 * time.Hour,
+		IdempotencyStore:         idempotencyStore,
+		EventPublisher:           publisher,
+		OrderStore:               orderStore,
+		Catalog:                  catalogStore,
+		Inventory:                orders.NewHTTPInventoryClient(inventoryURL(), 3*time.Second),
+		Metrics:                  metrics,
+		IdempotencyTTL:           24 * time.Hour,
+		RequireAuthenticatedUser: requireAuthenticatedUser(),
+		Log:                      log,
 	}
+	r.Mount("/v1/admin/catalog", catalog.Routes(&catalog.Handler{Store: catalogStore, Metrics: metrics}))
 	r.Mount("/", orders.Routes(h))
 
 	srv := &http.Server{
@@ -88,23 +143,86 @@ func main() {
 		ReadHeaderTimeout: 5 * time.Second,
 	}
 
+	grpcSrv := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		grpcx.RequestID,
+		grpcx.Logging(log),
+		grpcx.Metrics(metrics),
+	))
+	ordersv1.RegisterOrdersServiceServer(grpcSrv, orders.NewGRPCServer(h))
+	healthpb.RegisterHealthServer(grpcSrv, health.NewServer())
+	grpcListener, err := net.Listen("tcp", ":"+grpcPort)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to listen for gRPC")
+	}
+
 	go func() {
-		log.Info().Msgf("orders listening on :%s", port)
+		log.Info().Str("event_transport", transport).Msgf("orders listening on :%s", port)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatal().Err(err).Msg("server failed")
 		}
 	}()
+	go func() {
+		log.Info().Msgf("orders gRPC listening on :%s", grpcPort)
+		if err := grpcSrv.Serve(grpcListener); err != nil {
+			log.Fatal().Err(err).Msg("gRPC server failed")
+		}
+	}()
 
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 	<-stop
 
+	bgCancel()
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	_ = srv.Shutdown(ctx)
+	grpcSrv.GracefulStop()
 	log.Info().Msg("orders shutdown complete")
 }
 
+func requireAuthenticatedUser() bool {
+	return strings.EqualFold(config.Getenv("REQUIRE_AUTHENTICATED_USER", "false"), "true")
+}
+
+func inventoryURL() string {
+	return config.Getenv("INVENTORY_URL", "http://localhost:8083")
+}
+
+const (
+	transportNATS         = "nats"
+	transportRedisStreams = "redis-streams"
+)
+
+func eventTransport() string {
+	return strings.ToLower(strings.TrimSpace(config.Getenv("EVENT_TRANSPORT", transportNATS)))
+}
+
+func redisStreamMaxLen() int64 {
+	n, err := strconv.ParseInt(config.Getenv("REDIS_STREAM_MAXLEN", "100000"), 10, 64)
+	if err != nil || n < 0 {
+		return 100000
+	}
+	return n
+}
+
+const (
+	idempotencyModeRedis         = "redis"
+	idempotencyModePostgres      = "postgres"
+	idempotencyModeRedisPostgres = "redis-postgres"
+)
+
+func idempotencyStoreMode() string {
+	return strings.ToLower(strings.TrimSpace(config.Getenv("IDEMPOTENCY_STORE", idempotencyModeRedis)))
+}
+
+func idempotencyCleanupInterval() time.Duration {
+	d, err := time.ParseDuration(config.Getenv("IDEMPOTENCY_CLEANUP_INTERVAL", "10m"))
+	if err != nil || d <= 0 {
+		return 10 * time.Minute
+	}
+	return d
+}
+
 func databaseURL() string {
 	if explicit := strings.TrimSpace(config.Getenv("DATABASE_URL", "")); explicit != "" {
 		return explicit
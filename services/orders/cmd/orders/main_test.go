@@ -2,6 +2,7 @@ package main
 
 import (
 	"testing"
+	"time"
 )
 
 func TestDatabaseURLUsesExplicitValue(t *testing.T) {
@@ -27,3 +28,85 @@ func TestDatabaseURLBuildsFromDiscreteEnv(t *testing.T) {
 		t.Fatalf("databaseURL() = %q, want %q", got, want)
 	}
 }
+
+func TestEventTransport(t *testing.T) {
+	t.Setenv("EVENT_TRANSPORT", "")
+	if got := eventTransport(); got != transportNATS {
+		t.Fatalf("eventTransport() default = %q, want %q", got, transportNATS)
+	}
+
+	t.Setenv("EVENT_TRANSPORT", " Redis-Streams ")
+	if got := eventTransport(); got != transportRedisStreams {
+		t.Fatalf("eventTransport() = %q, want %q", got, transportRedisStreams)
+	}
+}
+
+func TestRedisStreamMaxLen(t *testing.T) {
+	t.Setenv("REDIS_STREAM_MAXLEN", "")
+	if got := redisStreamMaxLen(); got != 100000 {
+		t.Fatalf("redisStreamMaxLen() default = %d, want 100000", got)
+	}
+
+	t.Setenv("REDIS_STREAM_MAXLEN", "500")
+	if got := redisStreamMaxLen(); got != 500 {
+		t.Fatalf("redisStreamMaxLen() = %d, want 500", got)
+	}
+
+	t.Setenv("REDIS_STREAM_MAXLEN", "not-a-number")
+	if got := redisStreamMaxLen(); got != 100000 {
+		t.Fatalf("redisStreamMaxLen() invalid = %d, want fallback 100000", got)
+	}
+}
+
+func TestIdempotencyStoreMode(t *testing.T) {
+	t.Setenv("IDEMPOTENCY_STORE", "")
+	if got := idempotencyStoreMode(); got != idempotencyModeRedis {
+		t.Fatalf("idempotencyStoreMode() default = %q, want %q", got, idempotencyModeRedis)
+	}
+
+	t.Setenv("IDEMPOTENCY_STORE", "Redis-Postgres")
+	if got := idempotencyStoreMode(); got != idempotencyModeRedisPostgres {
+		t.Fatalf("idempotencyStoreMode() = %q, want %q", got, idempotencyModeRedisPostgres)
+	}
+}
+
+func TestIdempotencyCleanupInterval(t *testing.T) {
+	t.Setenv("IDEMPOTENCY_CLEANUP_INTERVAL", "")
+	if got := idempotencyCleanupInterval(); got != 10*time.Minute {
+		t.Fatalf("idempotencyCleanupInterval() default = %s, want 10m", got)
+	}
+
+	t.Setenv("IDEMPOTENCY_CLEANUP_INTERVAL", "30s")
+	if got := idempotencyCleanupInterval(); got != 30*time.Second {
+		t.Fatalf("idempotencyCleanupInterval() = %s, want 30s", got)
+	}
+
+	t.Setenv("IDEMPOTENCY_CLEANUP_INTERVAL", "-1s")
+	if got := idempotencyCleanupInterval(); got != 10*time.Minute {
+		t.Fatalf("idempotencyCleanupInterval() invalid = %s, want fallback 10m", got)
+	}
+}
+
+func TestInventoryURL(t *testing.T) {
+	t.Setenv("INVENTORY_URL", "")
+	if got, want := inventoryURL(), "http://localhost:8083"; got != want {
+		t.Fatalf("default inventory url mismatch: got=%q want=%q", got, want)
+	}
+
+	t.Setenv("INVENTORY_URL", "http://inventory:8083")
+	if got, want := inventoryURL(), "http://inventory:8083"; got != want {
+		t.Fatalf("override inventory url mismatch: got=%q want=%q", got, want)
+	}
+}
+
+func TestRequireAuthenticatedUser(t *testing.T) {
+	t.Setenv("REQUIRE_AUTHENTICATED_USER", "")
+	if requireAuthenticatedUser() {
+		t.Fatal("authenticated user should not be required by default")
+	}
+
+	t.Setenv("REQUIRE_AUTHENTICATED_USER", "TRUE")
+	if !requireAuthenticatedUser() {
+		t.Fatal("expected authenticated user to be required")
+	}
+}
@@ -0,0 +1,166 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/triad-platform/triad-app/pkg/httpx"
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+	"github.com/triad-platform/triad-app/pkg/money"
+)
+
+// codeSKUNotFound is the problem code for unknown SKUs.
+const codeSKUNotFound = "sku_not_found"
+
+type Store interface {
+	UpsertSKU(ctx context.Context, sku SKU) (SKU, error)
+	SetActive(ctx context.Context, sku string, active bool) error
+	GetSKU(ctx context.Context, sku string) (SKU, error)
+	ListSKUs(ctx context.Context) ([]SKU, error)
+}
+
+type Handler struct {
+	Store   Store
+	Metrics *metricsx.Registry
+}
+
+// Routes serves the catalog admin API; mount it under /v1/admin/catalog.
+// Every request must carry an admin principal from the gateway.
+func Routes(h *Handler) chi.Router {
+	r := chi.NewRouter()
+	r.Use(h.requireAdmin)
+	r.Get("/skus", h.ListSKUs)
+	r.Get("/skus/{sku}", h.GetSKU)
+	r.Put("/skus/{sku}", h.PutSKU)
+	r.Delete("/skus/{sku}", h.DeactivateSKU)
+	return r
+}
+
+func (h *Handler) ListSKUs(w http.ResponseWriter, r *http.Request) {
+	skus, err := h.Store.ListSKUs(r.Context())
+	if err != nil {
+		h.inc("catalog_errors_total")
+		httpx.Error(w, r, http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "catalog lookup failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string][]SKU{"skus": skus})
+}
+
+func (h *Handler) GetSKU(w http.ResponseWriter, r *http.Request) {
+	sku, err := h.Store.GetSKU(r.Context(), chi.URLParam(r, "sku"))
+	if errors.Is(err, ErrNotFound) {
+		httpx.Error(w, r, http.StatusNotFound, codeSKUNotFound, "sku not found")
+		return
+	}
+	if err != nil {
+		h.inc("catalog_errors_total")
+		httpx.Error(w, r, http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "catalog lookup failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, sku)
+}
+
+func (h *Handler) PutSKU(w http.ResponseWriter, r *http.Request) {
+	var req UpsertSKURequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.inc("catalog_validation_errors_total")
+		if errors.Is(err, money.ErrUnknownCurrency) {
+			httpx.ValidationError(w, r, "unsupported currency", []httpx.FieldError{{Field: "prices", Code: httpx.FieldUnsupported}})
+			return
+		}
+		httpx.Error(w, r, http.StatusBadRequest, httpx.CodeInvalidJSON, "invalid json")
+		return
+	}
+	sku := SKU{
+		SKU:    strings.TrimSpace(chi.URLParam(r, "sku")),
+		Name:   strings.TrimSpace(req.Name),
+		Active: req.Active == nil || *req.Active,
+		Prices: map[money.Currency]int64{},
+	}
+	var errs []httpx.FieldError
+	if sku.SKU == "" {
+		errs = append(errs, httpx.FieldError{Field: "sku", Code: httpx.FieldRequired})
+	}
+	if sku.Name == "" {
+		errs = append(errs, httpx.FieldError{Field: "name", Code: httpx.FieldRequired})
+	}
+	if len(req.Prices) == 0 {
+		errs = append(errs, httpx.FieldError{Field: "prices", Code: httpx.FieldRequired})
+	}
+	for currency, priceCents := range req.Prices {
+		if currency.IsZero() || priceCents <= 0 {
+			errs = append(errs, httpx.FieldError{Field: "prices." + currency.String(), Code: httpx.FieldNotPositive})
+			continue
+		}
+		sku.Prices[currency] = priceCents
+	}
+	if len(errs) > 0 {
+		// Map order is random; keep the response stable.
+		slices.SortFunc(errs, func(a, b httpx.FieldError) int { return strings.Compare(a.Field, b.Field) })
+		h.inc("catalog_validation_errors_total")
+		httpx.ValidationError(w, r, "invalid sku", errs)
+		return
+	}
+
+	saved, err := h.Store.UpsertSKU(r.Context(), sku)
+	if err != nil {
+		h.inc("catalog_errors_total")
+		httpx.Error(w, r, http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "catalog update failed")
+		return
+	}
+	h.inc("catalog_updates_total")
+	writeJSON(w, http.StatusOK, saved)
+}
+
+// DeactivateSKU is a soft delete: historic order_items keep referencing the
+// SKU, but new orders for it are rejected.
+func (h *Handler) DeactivateSKU(w http.ResponseWriter, r *http.Request) {
+	err := h.Store.SetActive(r.Context(), chi.URLParam(r, "sku"), false)
+	if errors.Is(err, ErrNotFound) {
+		httpx.Error(w, r, http.StatusNotFound, codeSKUNotFound, "sku not found")
+		return
+	}
+	if err != nil {
+		h.inc("catalog_errors_total")
+		httpx.Error(w, r, http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "catalog update failed")
+		return
+	}
+	h.inc("catalog_updates_total")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requireAdmin checks the principal the gateway forwards, so the catalog
+// stays closed even if the gateway serves it without authentication.
+func (h *Handler) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := httpx.PrincipalFromHeaders(r.Header)
+		if !ok {
+			h.inc("catalog_unauthenticated_total")
+			httpx.Error(w, r, http.StatusUnauthorized, httpx.CodeUnauthenticated, "authentication required")
+			return
+		}
+		if !principal.HasRole(httpx.RoleAdmin) {
+			h.inc("catalog_forbidden_total")
+			httpx.Error(w, r, http.StatusForbidden, httpx.CodeForbidden, "admin role required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (h *Handler) inc(name string) {
+	if h.Metrics != nil {
+		h.Metrics.Inc(name)
+	}
+}
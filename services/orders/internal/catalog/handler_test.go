@@ -0,0 +1,221 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/triad-platform/triad-app/pkg/httpx"
+	"github.com/triad-platform/triad-app/pkg/money"
+)
+
+func TestPutSKU(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		sku        string
+		body       string
+		store      *stubStore
+		wantStatus int
+		wantActive bool
+	}{
+		{
+			name:       "invalid json",
+			sku:        "sku_1",
+			body:       "{",
+			store:      &stubStore{},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "missing name",
+			sku:        "sku_1",
+			body:       `{"prices":{"USD":100}}`,
+			store:      &stubStore{},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "missing prices",
+			sku:        "sku_1",
+			body:       `{"name":"Widget"}`,
+			store:      &stubStore{},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "non positive price",
+			sku:        "sku_1",
+			body:       `{"name":"Widget","prices":{"USD":0}}`,
+			store:      &stubStore{},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unsupported currency",
+			sku:        "sku_1",
+			body:       `{"name":"Widget","prices":{"XYZ":100}}`,
+			store:      &stubStore{},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "store error",
+			sku:        "sku_1",
+			body:       `{"name":"Widget","prices":{"USD":100}}`,
+			store:      &stubStore{err: errors.New("db down")},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "active by default",
+			sku:        "sku_1",
+			body:       `{"name":"Widget","prices":{"usd":100}}`,
+			store:      &stubStore{},
+			wantStatus: http.StatusOK,
+			wantActive: true,
+		},
+		{
+			name:       "explicitly inactive",
+			sku:        "sku_1",
+			body:       `{"name":"Widget","active":false,"prices":{"USD":100}}`,
+			store:      &stubStore{},
+			wantStatus: http.StatusOK,
+			wantActive: false,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := Routes(&Handler{Store: tc.store})
+			req := adminRequest(http.MethodPut, "/skus/"+tc.sku, tc.body)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status code mismatch: got=%d want=%d body=%q", rec.Code, tc.wantStatus, rec.Body.String())
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+			if tc.store.upserted.SKU != tc.sku {
+				t.Fatalf("upserted sku mismatch: got=%q want=%q", tc.store.upserted.SKU, tc.sku)
+			}
+			if tc.store.upserted.Active != tc.wantActive {
+				t.Fatalf("active mismatch: got=%v want=%v", tc.store.upserted.Active, tc.wantActive)
+			}
+			if tc.store.upserted.Prices["USD"] != 100 {
+				t.Fatalf("currency should be normalized to upper case: got=%v", tc.store.upserted.Prices)
+			}
+		})
+	}
+}
+
+func TestGetAndDeactivateSKU(t *testing.T) {
+	t.Parallel()
+
+	store := &stubStore{skus: map[string]SKU{"sku_1": {SKU: "sku_1", Name: "Widget", Active: true, Prices: map[money.Currency]int64{"USD": 100}}}}
+	r := Routes(&Handler{Store: store})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, adminRequest(http.MethodGet, "/skus/sku_1", ""))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get status mismatch: got=%d want=%d", rec.Code, http.StatusOK)
+	}
+	var got SKU
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if got.Name != "Widget" || got.Prices["USD"] != 100 {
+		t.Fatalf("sku mismatch: got=%+v", got)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, adminRequest(http.MethodGet, "/skus/missing", ""))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("missing sku status mismatch: got=%d want=%d", rec.Code, http.StatusNotFound)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, adminRequest(http.MethodDelete, "/skus/sku_1", ""))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("deactivate status mismatch: got=%d want=%d", rec.Code, http.StatusNoContent)
+	}
+	if store.skus["sku_1"].Active {
+		t.Fatal("sku should be inactive after delete")
+	}
+}
+
+func TestRoutes_RequireAdmin(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		principal  *httpx.Principal
+		wantStatus int
+	}{
+		{name: "no principal", wantStatus: http.StatusUnauthorized},
+		{name: "customer", principal: &httpx.Principal{Subject: "u-1", Roles: []string{httpx.RoleCustomer}}, wantStatus: http.StatusForbidden},
+		{name: "admin", principal: &httpx.Principal{Subject: "ops", Roles: []string{httpx.RoleAdmin}}, wantStatus: http.StatusOK},
+	}
+	for _, tc := range tests {
+		store := &stubStore{skus: map[string]SKU{}}
+		req := httptest.NewRequest(http.MethodGet, "/skus", nil)
+		if tc.principal != nil {
+			tc.principal.SetHeaders(req.Header)
+		}
+		rec := httptest.NewRecorder()
+		Routes(&Handler{Store: store}).ServeHTTP(rec, req)
+		if rec.Code != tc.wantStatus {
+			t.Fatalf("%s: status code mismatch: got=%d want=%d body=%q", tc.name, rec.Code, tc.wantStatus, rec.Body.String())
+		}
+	}
+}
+
+func adminRequest(method, path, body string) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	httpx.Principal{Subject: "ops", Roles: []string{httpx.RoleAdmin}}.SetHeaders(req.Header)
+	return req
+}
+
+type stubStore struct {
+	skus     map[string]SKU
+	upserted SKU
+	err      error
+}
+
+func (s *stubStore) UpsertSKU(_ context.Context, sku SKU) (SKU, error) {
+	if s.err != nil {
+		return SKU{}, s.err
+	}
+	s.upserted = sku
+	return sku, nil
+}
+
+func (s *stubStore) SetActive(_ context.Context, sku string, active bool) error {
+	existing, ok := s.skus[sku]
+	if !ok {
+		return ErrNotFound
+	}
+	existing.Active = active
+	s.skus[sku] = existing
+	return nil
+}
+
+func (s *stubStore) GetSKU(_ context.Context, sku string) (SKU, error) {
+	existing, ok := s.skus[sku]
+	if !ok {
+		return SKU{}, ErrNotFound
+	}
+	return existing, nil
+}
+
+func (s *stubStore) ListSKUs(_ context.Context) ([]SKU, error) {
+	out := make([]SKU, 0, len(s.skus))
+	for _, sku := range s.skus {
+		out = append(out, sku)
+	}
+	return out, nil
+}
@@ -0,0 +1,33 @@
+package catalog
+
+import (
+	"errors"
+	"time"
+
+	"github.com/triad-platform/triad-app/pkg/money"
+)
+
+var ErrNotFound = errors.New("sku not found")
+
+type SKU struct {
+	SKU    string `json:"sku"`
+	Name   string `json:"name"`
+	Active bool   `json:"active"`
+	// Prices are in the minor units of each currency.
+	Prices    map[money.Currency]int64 `json:"prices"`
+	UpdatedAt time.Time                `json:"updated_at"`
+}
+
+// Price is the authoritative unit price of a SKU in one currency.
+type Price struct {
+	SKU       string
+	Name      string
+	UnitPrice money.Money
+	Active    bool
+}
+
+type UpsertSKURequest struct {
+	Name   string                   `json:"name"`
+	Active *bool                    `json:"active"`
+	Prices map[money.Currency]int64 `json:"prices"`
+}
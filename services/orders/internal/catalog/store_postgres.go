@@ -0,0 +1,192 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/triad-platform/triad-app/pkg/money"
+)
+
+type PostgresStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresStore(pool *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{pool: pool}
+}
+
+func (s *PostgresStore) EnsureSchema(ctx context.Context) error {
+	_, err := s.pool.Exec(ctx, `
+CREATE TABLE IF NOT EXISTS catalog_skus (
+	sku TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	active BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS catalog_prices (
+	sku TEXT NOT NULL REFERENCES catalog_skus(sku) ON DELETE CASCADE,
+	currency TEXT NOT NULL,
+	price_cents BIGINT NOT NULL CHECK (price_cents > 0),
+	PRIMARY KEY (sku, currency)
+);
+
+DO $$
+BEGIN
+	IF EXISTS (
+		SELECT 1 FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = 'catalog_prices'
+			AND column_name = 'price_cents' AND data_type = 'integer'
+	) THEN
+		ALTER TABLE catalog_prices ALTER COLUMN price_cents TYPE BIGINT;
+	END IF;
+END $$;
+`)
+	return err
+}
+
+// UpsertSKU replaces the SKU's name, active flag and full price list.
+func (s *PostgresStore) UpsertSKU(ctx context.Context, sku SKU) (SKU, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return SKU{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	var updatedAt time.Time
+	err = tx.QueryRow(ctx, `
+INSERT INTO catalog_skus (sku, name, active) VALUES ($1, $2, $3)
+ON CONFLICT (sku) DO UPDATE SET name = EXCLUDED.name, active = EXCLUDED.active, updated_at = NOW()
+RETURNING updated_at`,
+		sku.SKU, sku.Name, sku.Active,
+	).Scan(&updatedAt)
+	if err != nil {
+		return SKU{}, err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM catalog_prices WHERE sku = $1`, sku.SKU); err != nil {
+		return SKU{}, err
+	}
+	for currency, priceCents := range sku.Prices {
+		_, err := tx.Exec(
+			ctx,
+			`INSERT INTO catalog_prices (sku, currency, price_cents) VALUES ($1, $2, $3)`,
+			sku.SKU, currency, priceCents,
+		)
+		if err != nil {
+			return SKU{}, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return SKU{}, fmt.Errorf("commit catalog transaction: %w", err)
+	}
+	sku.UpdatedAt = updatedAt
+	return sku, nil
+}
+
+func (s *PostgresStore) SetActive(ctx context.Context, sku string, active bool) error {
+	tag, err := s.pool.Exec(ctx, `UPDATE catalog_skus SET active = $2, updated_at = NOW() WHERE sku = $1`, sku, active)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *PostgresStore) GetSKU(ctx context.Context, sku string) (SKU, error) {
+	out := SKU{SKU: sku, Prices: map[money.Currency]int64{}}
+	err := s.pool.QueryRow(
+		ctx,
+		`SELECT name, active, updated_at FROM catalog_skus WHERE sku = $1`,
+		sku,
+	).Scan(&out.Name, &out.Active, &out.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return SKU{}, ErrNotFound
+	}
+	if err != nil {
+		return SKU{}, err
+	}
+
+	rows, err := s.pool.Query(ctx, `SELECT currency, price_cents FROM catalog_prices WHERE sku = $1`, sku)
+	if err != nil {
+		return SKU{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			currency   money.Currency
+			priceCents int64
+		)
+		if err := rows.Scan(&currency, &priceCents); err != nil {
+			return SKU{}, err
+		}
+		out.Prices[currency] = priceCents
+	}
+	return out, rows.Err()
+}
+
+func (s *PostgresStore) ListSKUs(ctx context.Context) ([]SKU, error) {
+	rows, err := s.pool.Query(ctx, `
+SELECT s.sku, s.name, s.active, s.updated_at, p.currency, p.price_cents
+FROM catalog_skus s
+LEFT JOIN catalog_prices p ON p.sku = s.sku
+ORDER BY s.sku, p.currency`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []SKU{}
+	for rows.Next() {
+		var (
+			sku        SKU
+			currency   *money.Currency
+			priceCents *int64
+		)
+		if err := rows.Scan(&sku.SKU, &sku.Name, &sku.Active, &sku.UpdatedAt, &currency, &priceCents); err != nil {
+			return nil, err
+		}
+		if len(out) == 0 || out[len(out)-1].SKU != sku.SKU {
+			sku.Prices = map[money.Currency]int64{}
+			out = append(out, sku)
+		}
+		if currency != nil && priceCents != nil {
+			out[len(out)-1].Prices[*currency] = *priceCents
+		}
+	}
+	return out, rows.Err()
+}
+
+// LookupPrices returns the price of each requested SKU in currency. SKUs that
+// do not exist, or have no price in that currency, are absent from the result.
+func (s *PostgresStore) LookupPrices(ctx context.Context, currency money.Currency, skus []string) (map[string]Price, error) {
+	rows, err := s.pool.Query(ctx, `
+SELECT s.sku, s.name, s.active, p.price_cents
+FROM catalog_skus s
+JOIN catalog_prices p ON p.sku = s.sku AND p.currency = $1
+WHERE s.sku = ANY($2)`,
+		currency, skus,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[string]Price, len(skus))
+	for rows.Next() {
+		p := Price{UnitPrice: money.Zero(currency)}
+		if err := rows.Scan(&p.SKU, &p.Name, &p.Active, &p.UnitPrice.Minor); err != nil {
+			return nil, err
+		}
+		out[p.SKU] = p
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,228 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	ordersv1 "github.com/triad-platform/triad-app/contracts/proto/orders/v1"
+	"github.com/triad-platform/triad-app/pkg/grpcx"
+	"github.com/triad-platform/triad-app/pkg/httpx"
+	"github.com/triad-platform/triad-app/pkg/money"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	idempotencyMetadataKey       = "idempotency-key"
+	scopedIdempotencyMetadataKey = "idempotency-key-scoped"
+	// errorDomain is the ErrorInfo domain of every orders error.
+	errorDomain = "orders.pulsecart"
+)
+
+// GRPCServer serves ordersv1.OrdersService with the same validation,
+// idempotency and persistence as the HTTP handler.
+type GRPCServer struct {
+	ordersv1.UnimplementedOrdersServiceServer
+	h *Handler
+}
+
+func NewGRPCServer(h *Handler) *GRPCServer {
+	return &GRPCServer{h: h}
+}
+
+func (s *GRPCServer) CreateOrder(ctx context.Context, in *ordersv1.CreateOrderRequest) (*ordersv1.CreateOrderResponse, error) {
+	start := time.Now()
+	defer func() { s.h.observeDuration("create_order_duration", time.Since(start)) }()
+	s.h.inc("create_order_requests_total")
+
+	req, fieldErrs := createOrderRequestFromProto(in)
+	if len(fieldErrs) > 0 {
+		s.h.inc("create_order_validation_errors_total")
+		return nil, s.status(ctx, "CreateOrder", httpx.Principal{}, &OrderError{
+			Status: http.StatusBadRequest,
+			Code:   httpx.CodeValidationFailed,
+			Detail: "invalid field values",
+			Fields: fieldErrs,
+		})
+	}
+
+	resp, err := s.h.createOrder(ctx, createOrderCall{
+		Request:           req,
+		IdempotencyKey:    grpcx.IncomingValue(ctx, idempotencyMetadataKey),
+		AuthenticatedUser: grpcx.IncomingValue(ctx, strings.ToLower(httpx.AuthenticatedUserHeader)),
+		RequestID:         grpcx.RequestIDFromContext(ctx),
+	})
+	if resp.IdempotencyKey != "" {
+		_ = grpc.SetHeader(ctx, metadata.Pairs(scopedIdempotencyMetadataKey, resp.IdempotencyKey))
+	}
+	if err != nil {
+		return nil, s.status(ctx, "CreateOrder", httpx.Principal{}, err)
+	}
+	return &ordersv1.CreateOrderResponse{
+		OrderId:        resp.OrderID,
+		Status:         resp.Status,
+		IdempotencyKey: resp.IdempotencyKey,
+	}, nil
+}
+
+func (s *GRPCServer) GetOrder(ctx context.Context, in *ordersv1.GetOrderRequest) (*ordersv1.Order, error) {
+	principal := principalFromMetadata(ctx)
+	order, err := s.h.getOrder(ctx, principal, in.GetOrderId())
+	if err != nil {
+		return nil, s.status(ctx, "GetOrder", principal, err)
+	}
+	return orderToProto(order), nil
+}
+
+func (s *GRPCServer) ListOrders(ctx context.Context, in *ordersv1.ListOrdersRequest) (*ordersv1.ListOrdersResponse, error) {
+	principal := principalFromMetadata(ctx)
+	orders, next, err := s.h.listOrders(ctx, principal, strings.TrimSpace(in.GetUserId()), int(in.GetPageSize()), in.GetPageToken())
+	if err != nil {
+		return nil, s.status(ctx, "ListOrders", principal, err)
+	}
+	resp := &ordersv1.ListOrdersResponse{
+		Orders:        make([]*ordersv1.Order, 0, len(orders)),
+		NextPageToken: next,
+	}
+	for _, order := range orders {
+		resp.Orders = append(resp.Orders, orderToProto(order))
+	}
+	return resp, nil
+}
+
+// status converts err to a gRPC status carrying the problem code as the
+// ErrorInfo reason, auditing denials like the HTTP handler does.
+func (s *GRPCServer) status(ctx context.Context, method string, principal httpx.Principal, err error) error {
+	var oe *OrderError
+	if !errors.As(err, &oe) {
+		return status.Error(codes.Internal, "internal error")
+	}
+	if oe.Denied != "" {
+		s.h.Log.Warn().
+			Str("audit", "access_denied").
+			Str("method", method).
+			Str("subject", principal.Subject).
+			Strs("roles", principal.Roles).
+			Str("reason", oe.Denied).
+			Str("request_id", grpcx.RequestIDFromContext(ctx)).
+			Msg("request denied")
+	}
+
+	code := grpcCode(oe)
+	st := status.New(code, oe.Detail)
+	details := []protoadapt.MessageV1{&errdetails.ErrorInfo{Reason: oe.Code, Domain: errorDomain}}
+	if len(oe.Fields) > 0 {
+		br := &errdetails.BadRequest{}
+		for _, f := range oe.Fields {
+			br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       f.Field,
+				Reason:      f.Code,
+				Description: f.Detail,
+			})
+		}
+		details = append(details, br)
+	}
+	if len(oe.Shortages) > 0 {
+		pf := &errdetails.PreconditionFailure{}
+		for _, sh := range oe.Shortages {
+			pf.Violations = append(pf.Violations, &errdetails.PreconditionFailure_Violation{
+				Type:        "STOCK",
+				Subject:     sh.SKU,
+				Description: fmt.Sprintf("requested %d, available %d", sh.Requested, sh.Available),
+			})
+		}
+		details = append(details, pf)
+	}
+	if withDetails, err := st.WithDetails(details...); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// grpcCode maps the HTTP status of an order error to the matching gRPC code.
+func grpcCode(oe *OrderError) codes.Code {
+	switch oe.Status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		if oe.Code == codeInsufficientStock {
+			return codes.FailedPrecondition
+		}
+		return codes.AlreadyExists
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
+
+// principalFromMetadata reads the caller the same way the HTTP handler reads
+// the gateway's identity headers.
+func principalFromMetadata(ctx context.Context) httpx.Principal {
+	md, _ := metadata.FromIncomingContext(ctx)
+	header := http.Header{}
+	for _, key := range []string{httpx.AuthenticatedUserHeader, httpx.AuthenticatedRolesHeader} {
+		for _, v := range md.Get(key) {
+			header.Add(key, v)
+		}
+	}
+	principal, _ := httpx.PrincipalFromHeaders(header)
+	return principal
+}
+
+func createOrderRequestFromProto(in *ordersv1.CreateOrderRequest) (CreateOrderRequest, []httpx.FieldError) {
+	var fieldErrs []httpx.FieldError
+	req := CreateOrderRequest{
+		UserID: in.GetUserId(),
+		Items:  make([]OrderItem, 0, len(in.GetItems())),
+	}
+	for _, item := range in.GetItems() {
+		req.Items = append(req.Items, OrderItem{SKU: item.GetSku(), Qty: int(item.GetQty())})
+	}
+	if code := strings.TrimSpace(in.GetCurrency()); code != "" {
+		currency, err := money.ParseCurrency(code)
+		if err != nil {
+			fieldErrs = append(fieldErrs, httpx.FieldError{Field: "currency", Code: httpx.FieldUnsupported, Detail: "unsupported currency"})
+		}
+		req.Currency = currency
+	}
+	return req, fieldErrs
+}
+
+func orderToProto(order Order) *ordersv1.Order {
+	out := &ordersv1.Order{
+		OrderId:   order.OrderID,
+		UserId:    order.UserID,
+		Status:    order.Status,
+		Total:     moneyToProto(order.Total),
+		Items:     make([]*ordersv1.OrderLine, 0, len(order.Items)),
+		CreatedAt: timestamppb.New(order.CreatedAt),
+	}
+	for _, line := range order.Items {
+		out.Items = append(out.Items, &ordersv1.OrderLine{
+			Sku:       line.SKU,
+			Qty:       int32(line.Qty),
+			UnitPrice: moneyToProto(line.UnitPrice),
+		})
+	}
+	return out
+}
+
+func moneyToProto(m money.Money) *ordersv1.Money {
+	return &ordersv1.Money{Currency: m.Currency.String(), MinorUnits: m.Minor}
+}
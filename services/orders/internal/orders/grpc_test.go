@@ -0,0 +1,322 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"testing"
+	"time"
+
+	ordersv1 "github.com/triad-platform/triad-app/contracts/proto/orders/v1"
+	"github.com/triad-platform/triad-app/pkg/grpcx"
+	"github.com/triad-platform/triad-app/pkg/httpx"
+	"github.com/triad-platform/triad-app/pkg/money"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newGRPCClient(t *testing.T, h *Handler) ordersv1.OrdersServiceClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(grpcx.RequestID))
+	ordersv1.RegisterOrdersServiceServer(srv, NewGRPCServer(h))
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return ordersv1.NewOrdersServiceClient(conn)
+}
+
+func callContext(pairs ...string) context.Context {
+	return metadata.NewOutgoingContext(context.Background(), metadata.Pairs(pairs...))
+}
+
+// errorReason returns the ErrorInfo reason and the other details of err.
+func errorReason(t *testing.T, err error) (string, *errdetails.BadRequest, *errdetails.PreconditionFailure) {
+	t.Helper()
+
+	var reason string
+	var badRequest *errdetails.BadRequest
+	var precondition *errdetails.PreconditionFailure
+	for _, d := range status.Convert(err).Details() {
+		switch d := d.(type) {
+		case *errdetails.ErrorInfo:
+			reason = d.GetReason()
+		case *errdetails.BadRequest:
+			badRequest = d
+		case *errdetails.PreconditionFailure:
+			precondition = d
+		}
+	}
+	return reason, badRequest, precondition
+}
+
+func TestGRPC_CreateOrder(t *testing.T) {
+	t.Parallel()
+
+	valid := &ordersv1.CreateOrderRequest{
+		Items:    []*ordersv1.OrderItem{{Sku: "sku_1", Qty: 2}},
+		Currency: "usd",
+	}
+	tests := []struct {
+		name        string
+		req         *ordersv1.CreateOrderRequest
+		md          []string
+		idempotency IdempotencyStore
+		inventory   *stubInventory
+		wantCode    codes.Code
+		wantReason  string
+		wantField   string
+	}{
+		{
+			name:     "created",
+			req:      valid,
+			md:       []string{"idempotency-key", "idem-1", "x-authenticated-user", "u_1", "x-request-id", "req-1"},
+			wantCode: codes.OK,
+		},
+		{
+			name:       "missing idempotency key",
+			req:        valid,
+			md:         []string{"x-authenticated-user", "u_1"},
+			wantCode:   codes.InvalidArgument,
+			wantReason: codeIdempotencyKeyMissing,
+		},
+		{
+			name:       "unsupported currency",
+			req:        &ordersv1.CreateOrderRequest{Items: valid.Items, Currency: "zzz"},
+			md:         []string{"idempotency-key", "idem-1", "x-authenticated-user", "u_1"},
+			wantCode:   codes.InvalidArgument,
+			wantReason: httpx.CodeValidationFailed,
+			wantField:  "currency",
+		},
+		{
+			name:       "non-positive qty",
+			req:        &ordersv1.CreateOrderRequest{Items: []*ordersv1.OrderItem{{Sku: "sku_1"}}, Currency: "USD"},
+			md:         []string{"idempotency-key", "idem-1", "x-authenticated-user", "u_1"},
+			wantCode:   codes.InvalidArgument,
+			wantReason: httpx.CodeValidationFailed,
+			wantField:  "items[0].qty",
+		},
+		{
+			name:       "user mismatch",
+			req:        &ordersv1.CreateOrderRequest{UserId: "u_2", Items: valid.Items, Currency: "USD"},
+			md:         []string{"idempotency-key", "idem-1", "x-authenticated-user", "u_1"},
+			wantCode:   codes.PermissionDenied,
+			wantReason: codeUserMismatch,
+		},
+		{
+			name:        "duplicate",
+			req:         valid,
+			md:          []string{"idempotency-key", "idem-1", "x-authenticated-user", "u_1"},
+			idempotency: &stubIdempotencyStore{reserveResult: false},
+			wantCode:    codes.AlreadyExists,
+			wantReason:  codeDuplicateRequest,
+		},
+		{
+			name: "insufficient stock",
+			req:  valid,
+			md:   []string{"idempotency-key", "idem-1", "x-authenticated-user", "u_1"},
+			inventory: &stubInventory{reserveErr: &InsufficientStockError{
+				Shortages: []StockShortage{{SKU: "sku_1", Requested: 2, Available: 1}},
+			}},
+			wantCode:   codes.FailedPrecondition,
+			wantReason: codeInsufficientStock,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			idempotency := tc.idempotency
+			if idempotency == nil {
+				idempotency = &stubIdempotencyStore{reserveResult: true}
+			}
+			inventory := tc.inventory
+			if inventory == nil {
+				inventory = &stubInventory{}
+			}
+			publisher := &stubPublisher{}
+			store := &stubOrderStore{persisted: PersistedOrder{OrderID: "o-1", UserID: "u_1", Total: money.New(200, "USD"), CreatedAt: time.Now()}}
+			client := newGRPCClient(t, &Handler{
+				IdempotencyStore: idempotency,
+				EventPublisher:   publisher,
+				OrderStore:       store,
+				Catalog:          defaultStubCatalog(),
+				Inventory:        inventory,
+			})
+
+			var header metadata.MD
+			resp, err := client.CreateOrder(callContext(tc.md...), tc.req, grpc.Header(&header))
+			if code := status.Code(err); code != tc.wantCode {
+				t.Fatalf("code mismatch: got=%v want=%v err=%v", code, tc.wantCode, err)
+			}
+			if tc.wantCode != codes.OK {
+				reason, badRequest, precondition := errorReason(t, err)
+				if reason != tc.wantReason {
+					t.Fatalf("reason mismatch: got=%q want=%q", reason, tc.wantReason)
+				}
+				if tc.wantField != "" && (badRequest == nil || badRequest.GetFieldViolations()[0].GetField() != tc.wantField) {
+					t.Fatalf("field violation mismatch: got=%v want=%q", badRequest, tc.wantField)
+				}
+				if tc.wantCode == codes.FailedPrecondition && (precondition == nil || precondition.GetViolations()[0].GetSubject() != "sku_1") {
+					t.Fatalf("precondition failure mismatch: got=%v", precondition)
+				}
+				if store.calls != 0 {
+					t.Fatalf("refused order was persisted")
+				}
+				return
+			}
+
+			if resp.GetOrderId() != "o-1" || resp.GetIdempotencyKey() != "u_1:idem-1" {
+				t.Fatalf("response mismatch: got=%+v", resp)
+			}
+			if got := header.Get(scopedIdempotencyMetadataKey); len(got) != 1 || got[0] != "u_1:idem-1" {
+				t.Fatalf("scoped key header mismatch: got=%v", got)
+			}
+			if store.lastParams.UserID != "u_1" || store.lastParams.Total != money.New(200, "USD") {
+				t.Fatalf("persisted params mismatch: got=%+v", store.lastParams)
+			}
+			if publisher.lastEvent.RequestID != "req-1" {
+				t.Fatalf("event request id mismatch: got=%q want=%q", publisher.lastEvent.RequestID, "req-1")
+			}
+		})
+	}
+}
+
+func TestGRPC_GetOrder(t *testing.T) {
+	t.Parallel()
+
+	order := Order{OrderID: "o-1", UserID: "u_owner", Status: "created", Total: money.New(200, "USD"), CreatedAt: time.Now()}
+	client := newGRPCClient(t, &Handler{OrderStore: &stubOrderStore{order: order}})
+
+	tests := []struct {
+		name     string
+		orderID  string
+		md       []string
+		wantCode codes.Code
+	}{
+		{name: "owner", orderID: "o-1", md: []string{"x-authenticated-user", "u_owner"}, wantCode: codes.OK},
+		{name: "admin", orderID: "o-1", md: []string{"x-authenticated-user", "u_admin", "x-authenticated-roles", "admin"}, wantCode: codes.OK},
+		{name: "other customer", orderID: "o-1", md: []string{"x-authenticated-user", "u_other"}, wantCode: codes.NotFound},
+		{name: "missing order", orderID: "o-2", md: []string{"x-authenticated-user", "u_owner"}, wantCode: codes.NotFound},
+		{name: "unauthenticated", orderID: "o-1", wantCode: codes.Unauthenticated},
+	}
+	for _, tc := range tests {
+		got, err := client.GetOrder(callContext(tc.md...), &ordersv1.GetOrderRequest{OrderId: tc.orderID})
+		if code := status.Code(err); code != tc.wantCode {
+			t.Fatalf("%s: code mismatch: got=%v want=%v", tc.name, code, tc.wantCode)
+		}
+		if tc.wantCode == codes.OK && (got.GetOrderId() != "o-1" || got.GetTotal().GetMinorUnits() != 200 || got.GetTotal().GetCurrency() != "USD") {
+			t.Fatalf("%s: order mismatch: got=%+v", tc.name, got)
+		}
+	}
+}
+
+func TestGRPC_ListOrders(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := &listingOrderStore{orders: []Order{
+		{OrderID: "o-1", UserID: "u_1", CreatedAt: base},
+		{OrderID: "o-2", UserID: "u_1", CreatedAt: base.Add(time.Minute)},
+		{OrderID: "o-3", UserID: "u_1", CreatedAt: base.Add(2 * time.Minute)},
+		{OrderID: "o-4", UserID: "u_2", CreatedAt: base.Add(3 * time.Minute)},
+	}}
+	client := newGRPCClient(t, &Handler{OrderStore: store})
+	ctx := callContext("x-authenticated-user", "u_1")
+
+	var got []string
+	token := ""
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatalf("pagination did not terminate: got=%v", got)
+		}
+		resp, err := client.ListOrders(ctx, &ordersv1.ListOrdersRequest{PageSize: 2, PageToken: token})
+		if err != nil {
+			t.Fatalf("list orders: %v", err)
+		}
+		for _, o := range resp.GetOrders() {
+			got = append(got, o.GetOrderId())
+		}
+		if token = resp.GetNextPageToken(); token == "" {
+			break
+		}
+	}
+	if want := []string{"o-3", "o-2", "o-1"}; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Fatalf("listed orders mismatch: got=%v want=%v", got, want)
+	}
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		req       *ordersv1.ListOrdersRequest
+		wantCode  codes.Code
+		wantField string
+	}{
+		{name: "other user's orders", ctx: ctx, req: &ordersv1.ListOrdersRequest{UserId: "u_2"}, wantCode: codes.PermissionDenied},
+		{name: "admin lists any user", ctx: callContext("x-authenticated-user", "u_admin", "x-authenticated-roles", "admin"), req: &ordersv1.ListOrdersRequest{UserId: "u_2"}, wantCode: codes.OK},
+		{name: "page size too large", ctx: ctx, req: &ordersv1.ListOrdersRequest{PageSize: 101}, wantCode: codes.InvalidArgument, wantField: "page_size"},
+		{name: "malformed token", ctx: ctx, req: &ordersv1.ListOrdersRequest{PageToken: "not a token"}, wantCode: codes.InvalidArgument, wantField: "page_token"},
+		{name: "unauthenticated", ctx: context.Background(), req: &ordersv1.ListOrdersRequest{}, wantCode: codes.Unauthenticated},
+	}
+	for _, tc := range tests {
+		_, err := client.ListOrders(tc.ctx, tc.req)
+		if code := status.Code(err); code != tc.wantCode {
+			t.Fatalf("%s: code mismatch: got=%v want=%v err=%v", tc.name, code, tc.wantCode, err)
+		}
+		if tc.wantField != "" {
+			if _, badRequest, _ := errorReason(t, err); badRequest == nil || badRequest.GetFieldViolations()[0].GetField() != tc.wantField {
+				t.Fatalf("%s: field violation mismatch: got=%v want=%q", tc.name, badRequest, tc.wantField)
+			}
+		}
+	}
+}
+
+// listingOrderStore pages through orders in memory the way the Postgres store
+// does: newest first, ties broken by ID.
+type listingOrderStore struct {
+	writeOnlyOrderStore
+	orders []Order
+}
+
+func (s *listingOrderStore) ListOrders(_ context.Context, userID string, limit int, after *OrderCursor) ([]Order, error) {
+	if limit <= 0 {
+		return nil, errors.New("limit must be positive")
+	}
+	var out []Order
+	for _, o := range s.orders {
+		if o.UserID != userID {
+			continue
+		}
+		if after != nil && !(o.CreatedAt.Before(after.CreatedAt) || o.CreatedAt.Equal(after.CreatedAt) && o.OrderID < after.OrderID) {
+			continue
+		}
+		out = append(out, o)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.After(out[j].CreatedAt)
+		}
+		return out[i].OrderID > out[j].OrderID
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
@@ -1,47 +1,140 @@
 package orders
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog"
+	"github.com/triad-platform/triad-app/pkg/httpx"
 	"github.com/triad-platform/triad-app/pkg/metricsx"
+	"github.com/triad-platform/triad-app/pkg/money"
+	"github.com/triad-platform/triad-app/services/orders/internal/catalog"
 )
 
 const (
-	idempotencyHeader = "Idempotency-Key"
-	requestIDHeader   = "X-Request-Id"
+	idempotencyHeader       = "Idempotency-Key"
+	scopedIdempotencyHeader = "Idempotency-Key-Scoped"
+	requestIDHeader         = httpx.RequestIDHeader
+	// shadowHeader marks a copy mirrored by the gateway; it is served as a
+	// dry run.
+	shadowHeader = "X-Shadow-Request"
+
+	// orderStatusDryRun is the status of a shadow request's response.
+	orderStatusDryRun = "dry_run"
+)
+
+// Problem codes for order errors. Codes shared by every service live in httpx.
+const (
+	codeIdempotencyKeyMissing = "idempotency_key_missing"
+	codeIdempotencyKeyInvalid = "idempotency_key_invalid"
+	codeIdempotencyKeyReused  = "idempotency_key_reused"
+	codeDuplicateRequest      = "duplicate_request"
+	codeUserMismatch          = "user_mismatch"
+	codeUnknownSKU            = "unknown_sku"
+	codeTotalOverflow         = "order_total_overflow"
+	codeInsufficientStock     = "insufficient_stock"
+	codeOrderNotFound         = "order_not_found"
 )
 
 type IdempotencyStore interface {
 	Reserve(ctx context.Context, key string, ttl time.Duration) (bool, error)
 }
 
+// IdempotencyResponseRecorder is implemented by stores that keep the outcome of
+// a reserved key next to the reservation itself.
+type IdempotencyResponseRecorder interface {
+	RecordResponse(ctx context.Context, key string, resp IdempotencyResponse) error
+}
+
+// IdempotencyRequestHashLookup is implemented by stores that can report the
+// request fingerprint recorded for a key, which lets the handler tell a retry
+// apart from a different request reusing the same key.
+type IdempotencyRequestHashLookup interface {
+	LookupRequestHash(ctx context.Context, key string) (string, bool, error)
+}
+
+// IdempotencyResponseLookup is implemented by stores that can return the
+// response recorded for a key, so a retry of a finished request gets the
+// original answer instead of a conflict.
+type IdempotencyResponseLookup interface {
+	LookupResponse(ctx context.Context, key string) (IdempotencyResponse, bool, error)
+}
+
+// IdempotencyKeyReleaser is implemented by stores that can give up a reserved
+// key whose request did not complete, so a retry with the same key can.
+type IdempotencyKeyReleaser interface {
+	Release(ctx context.Context, key string) error
+}
+
+type IdempotencyResponse struct {
+	RequestHash string
+	StatusCode  int
+	Body        []byte
+}
+
 type EventPublisher interface {
 	PublishOrdersCreated(ctx context.Context, event OrdersCreatedEvent) error
 }
 
+// PriceCatalog is the source of authoritative unit prices; client-supplied
+// prices are never charged.
+type PriceCatalog interface {
+	LookupPrices(ctx context.Context, currency money.Currency, skus []string) (map[string]catalog.Price, error)
+}
+
+// InventoryClient holds stock for an order from acceptance until the order is
+// committed, or releases it when the order cannot be completed.
+type InventoryClient interface {
+	Reserve(ctx context.Context, orderID string, items []OrderItem) (string, error)
+	Commit(ctx context.Context, reservationID string) error
+	Release(ctx context.Context, reservationID string) error
+}
+
 type OrderStore interface {
 	CreateOrder(ctx context.Context, params CreateOrderParams) (PersistedOrder, error)
+	// DeleteOrder removes an order that was persisted but never announced.
+	DeleteOrder(ctx context.Context, orderID string) error
+}
+
+// OrderReader is implemented by order stores that can load a persisted order.
+type OrderReader interface {
+	GetOrder(ctx context.Context, orderID string) (Order, error)
+}
+
+// OrderLister is implemented by order stores that can page through a user's
+// orders, newest first, starting after the cursor when it is non-nil.
+type OrderLister interface {
+	ListOrders(ctx context.Context, userID string, limit int, after *OrderCursor) ([]Order, error)
 }
 
 type Handler struct {
 	IdempotencyStore IdempotencyStore
 	EventPublisher   EventPublisher
 	OrderStore       OrderStore
+	Catalog          PriceCatalog
+	Inventory        InventoryClient
 	Metrics          *metricsx.Registry
 	IdempotencyTTL   time.Duration
-	// TODO: add DB and Logger dependencies
+	// RequireAuthenticatedUser rejects requests without the gateway's
+	// authenticated user header instead of trusting the body user_id.
+	RequireAuthenticatedUser bool
+	// Log receives audit lines for denied requests.
+	Log zerolog.Logger
 }
 
 func Routes(h *Handler) chi.Router {
 	r := chi.NewRouter()
 	r.Post("/v1/orders", h.CreateOrder)
+	r.Get("/v1/orders/{orderID}", h.GetOrder)
 	return r
 }
 
@@ -52,79 +145,266 @@ func (h *Handler) idempotencyTTL() time.Duration {
 	return h.IdempotencyTTL
 }
 
+// OrderError is a refused order call. HTTP renders it as a problem body and
+// gRPC as a status whose ErrorInfo reason is Code.
+type OrderError struct {
+	Status    int
+	Code      string
+	Detail    string
+	Fields    []httpx.FieldError
+	Shortages []StockShortage
+	// Denied is the audit reason when the caller's identity was refused.
+	Denied string
+}
+
+func (e *OrderError) Error() string {
+	return e.Code + ": " + e.Detail
+}
+
+func orderError(status int, code, detail string) *OrderError {
+	return &OrderError{Status: status, Code: code, Detail: detail}
+}
+
+// writeOrderError renders err as a problem body, auditing denials.
+func (h *Handler) writeOrderError(w http.ResponseWriter, r *http.Request, principal httpx.Principal, err error) {
+	var oe *OrderError
+	if !errors.As(err, &oe) {
+		httpx.Error(w, r, http.StatusInternalServerError, httpx.CodeInternal, "internal error")
+		return
+	}
+	if oe.Denied != "" {
+		httpx.AuditDenied(h.Log, r, principal, oe.Denied)
+	}
+	problem := httpx.Problem{Status: oe.Status, Code: oe.Code, Detail: oe.Detail, Errors: oe.Fields}
+	if oe.Shortages != nil {
+		problem.Extensions = map[string]any{"shortages": oe.Shortages}
+	}
+	httpx.WriteProblem(w, r, problem)
+}
+
+// createOrderCall is a CreateOrder request with the transport metadata the
+// order logic needs.
+type createOrderCall struct {
+	Request           CreateOrderRequest
+	IdempotencyKey    string
+	AuthenticatedUser string
+	RequestID         string
+	// Shadow requests are validated and priced but take no idempotency key,
+	// stock, order or event.
+	Shadow bool
+}
+
 func (h *Handler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
-	defer h.observeDuration("create_order_duration", time.Since(start))
+	defer func() { h.observeDuration("create_order_duration", time.Since(start)) }()
 	h.inc("create_order_requests_total")
 
 	idempotencyKey := strings.TrimSpace(r.Header.Get(idempotencyHeader))
-	if idempotencyKey == "" {
-		h.inc("create_order_validation_errors_total")
-		http.Error(w, "missing Idempotency-Key header", http.StatusBadRequest)
+	if err := h.checkIdempotencyKey(idempotencyKey); err != nil {
+		h.writeOrderError(w, r, httpx.Principal{}, err)
 		return
 	}
 
-	var req CreateOrderRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	req, fieldErrs, err := decodeCreateOrderRequest(r.Body)
+	if err != nil {
 		h.inc("create_order_validation_errors_total")
-		http.Error(w, "invalid json", http.StatusBadRequest)
+		httpx.Error(w, r, http.StatusBadRequest, httpx.CodeInvalidJSON, "invalid json")
 		return
 	}
-
-	// minimal validation placeholder
-	if req.UserID == "" || len(req.Items) == 0 || req.Currency == "" {
+	if len(fieldErrs) > 0 {
 		h.inc("create_order_validation_errors_total")
-		http.Error(w, "missing fields", http.StatusBadRequest)
+		httpx.ValidationError(w, r, "invalid field types", fieldErrs)
+		return
+	}
+
+	resp, err := h.createOrder(r.Context(), createOrderCall{
+		Request:           req,
+		IdempotencyKey:    idempotencyKey,
+		AuthenticatedUser: strings.TrimSpace(r.Header.Get(httpx.AuthenticatedUserHeader)),
+		RequestID:         strings.TrimSpace(r.Header.Get(requestIDHeader)),
+		Shadow:            r.Header.Get(shadowHeader) == "true",
+	})
+	if resp.IdempotencyKey != "" {
+		w.Header().Set(scopedIdempotencyHeader, resp.IdempotencyKey)
+	}
+	if err != nil {
+		h.writeOrderError(w, r, httpx.Principal{}, err)
 		return
 	}
-	for _, item := range req.Items {
-		if item.SKU == "" || item.Qty <= 0 || item.PriceCents <= 0 {
-			h.inc("create_order_validation_errors_total")
-			http.Error(w, "invalid items", http.StatusBadRequest)
-			return
+
+	status := http.StatusCreated
+	if resp.Status == orderStatusDryRun {
+		status = http.StatusOK
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(encodeCreateOrderResponse(resp))
+}
+
+func (h *Handler) checkIdempotencyKey(key string) error {
+	if key == "" {
+		h.inc("create_order_validation_errors_total")
+		return orderError(http.StatusBadRequest, codeIdempotencyKeyMissing, "missing Idempotency-Key header")
+	}
+	if err := validateIdempotencyKey(key); err != nil {
+		h.inc("create_order_validation_errors_total")
+		h.inc("create_order_idempotency_key_invalid_total")
+		return orderError(http.StatusBadRequest, codeIdempotencyKeyInvalid, err.Error())
+	}
+	return nil
+}
+
+// createOrder prices, reserves, persists and announces an order. On refusals
+// after the key has been scoped, the returned response still carries
+// IdempotencyKey so transports can echo it.
+func (h *Handler) createOrder(ctx context.Context, call createOrderCall) (CreateOrderResponse, error) {
+	if err := h.checkIdempotencyKey(call.IdempotencyKey); err != nil {
+		return CreateOrderResponse{}, err
+	}
+	req := call.Request
+
+	// The gateway's verified subject wins over the body; a body naming a
+	// different user is refused rather than silently rewritten.
+	if call.AuthenticatedUser != "" {
+		if req.UserID != "" && req.UserID != call.AuthenticatedUser {
+			h.inc("create_order_user_mismatch_total")
+			return CreateOrderResponse{}, orderError(http.StatusForbidden, codeUserMismatch, "user_id does not match authenticated user")
+		}
+		req.UserID = call.AuthenticatedUser
+	} else if h.RequireAuthenticatedUser {
+		h.inc("create_order_unauthenticated_total")
+		return CreateOrderResponse{}, orderError(http.StatusUnauthorized, httpx.CodeUnauthenticated, "authentication required")
+	}
+
+	if fieldErrs := req.validate(); len(fieldErrs) > 0 {
+		h.inc("create_order_validation_errors_total")
+		return CreateOrderResponse{}, &OrderError{
+			Status: http.StatusBadRequest,
+			Code:   httpx.CodeValidationFailed,
+			Detail: "invalid order request",
+			Fields: fieldErrs,
+		}
+	}
+
+	if h.Catalog == nil {
+		h.inc("create_order_service_errors_total")
+		return CreateOrderResponse{}, orderError(http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "catalog not configured")
+	}
+	pricedItems, rejected, err := h.priceItems(ctx, req.Currency, req.Items)
+	if err != nil {
+		h.inc("create_order_catalog_errors_total")
+		return CreateOrderResponse{}, orderError(http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "catalog lookup failed")
+	}
+	if len(rejected) > 0 {
+		h.inc("create_order_validation_errors_total")
+		h.inc("create_order_unknown_sku_total")
+		skus := make([]string, 0, len(rejected))
+		errs := make([]httpx.FieldError, 0, len(rejected))
+		for _, i := range rejected {
+			skus = append(skus, req.Items[i].SKU)
+			errs = append(errs, httpx.FieldError{Field: fmt.Sprintf("items[%d].sku", i), Code: httpx.FieldUnsupported, Detail: "unknown or inactive sku"})
+		}
+		return CreateOrderResponse{}, &OrderError{
+			Status: http.StatusUnprocessableEntity,
+			Code:   codeUnknownSKU,
+			Detail: "unknown or inactive sku: " + strings.Join(skus, ", "),
+			Fields: errs,
 		}
 	}
+	total, err := calculateTotal(req.Currency, pricedItems)
+	if err != nil {
+		h.inc("create_order_validation_errors_total")
+		h.inc("create_order_amount_overflow_total")
+		return CreateOrderResponse{}, orderError(http.StatusUnprocessableEntity, codeTotalOverflow, "order total exceeds supported range")
+	}
+
+	if call.Shadow {
+		h.inc("create_order_shadow_total")
+		return CreateOrderResponse{
+			Status:         orderStatusDryRun,
+			IdempotencyKey: scopedIdempotencyKey(req.UserID, call.IdempotencyKey),
+		}, nil
+	}
 
 	if h.IdempotencyStore == nil {
 		h.inc("create_order_service_errors_total")
-		http.Error(w, "idempotency store not configured", http.StatusServiceUnavailable)
-		return
+		return CreateOrderResponse{}, orderError(http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "idempotency store not configured")
 	}
 
-	reserved, err := h.IdempotencyStore.Reserve(r.Context(), idempotencyKey, h.idempotencyTTL())
+	resp := CreateOrderResponse{IdempotencyKey: scopedIdempotencyKey(req.UserID, call.IdempotencyKey)}
+	requestHash := hashCreateOrderRequest(req)
+
+	// The key is taken before any stock, so a duplicate never holds
+	// inventory. Until the order is accepted, every exit gives the key (and
+	// any stock) back so a retry can complete it.
+	reserved, err := h.IdempotencyStore.Reserve(ctx, resp.IdempotencyKey, h.idempotencyTTL())
 	if err != nil {
 		h.inc("create_order_idempotency_errors_total")
-		http.Error(w, "idempotency check failed", http.StatusServiceUnavailable)
-		return
+		return resp, orderError(http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "idempotency check failed")
 	}
 	if !reserved {
 		h.inc("create_order_duplicates_total")
-		http.Error(w, "duplicate request", http.StatusConflict)
-		return
+		if original, ok := h.replayIdempotentResponse(ctx, resp.IdempotencyKey, requestHash); ok {
+			h.inc("create_order_idempotent_replays_total")
+			return original, nil
+		}
+		if h.isIdempotencyCollision(ctx, resp.IdempotencyKey, requestHash) {
+			h.inc("create_order_idempotency_collisions_total")
+			return resp, orderError(http.StatusUnprocessableEntity, codeIdempotencyKeyReused, "Idempotency-Key already used for a different request")
+		}
+		return resp, orderError(http.StatusConflict, codeDuplicateRequest, "duplicate request")
 	}
+	accepted := false
+	defer func() {
+		if !accepted {
+			h.releaseIdempotencyKey(context.WithoutCancel(ctx), resp.IdempotencyKey)
+		}
+	}()
+
+	if h.Inventory == nil {
+		h.inc("create_order_service_errors_total")
+		return resp, orderError(http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "inventory not configured")
+	}
+	orderID := newOrderID()
+	reservationID, err := h.Inventory.Reserve(ctx, orderID, pricedItems)
+	var insufficient *InsufficientStockError
+	if errors.As(err, &insufficient) {
+		h.inc("create_order_insufficient_stock_total")
+		return resp, &OrderError{
+			Status:    http.StatusConflict,
+			Code:      codeInsufficientStock,
+			Detail:    "insufficient stock",
+			Shortages: insufficient.Shortages,
+		}
+	}
+	if err != nil {
+		h.inc("create_order_inventory_errors_total")
+		return resp, orderError(http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "inventory reservation failed")
+	}
+	defer func() {
+		if !accepted {
+			h.releaseInventory(context.WithoutCancel(ctx), reservationID)
+		}
+	}()
 
 	if h.EventPublisher == nil {
 		h.inc("create_order_service_errors_total")
-		http.Error(w, "event publisher not configured", http.StatusServiceUnavailable)
-		return
+		return resp, orderError(http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "event publisher not configured")
 	}
 	if h.OrderStore == nil {
 		h.inc("create_order_service_errors_total")
-		http.Error(w, "order store not configured", http.StatusServiceUnavailable)
-		return
+		return resp, orderError(http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "order store not configured")
 	}
 
-	orderID := newOrderID()
-	persistedOrder, err := h.OrderStore.CreateOrder(r.Context(), CreateOrderParams{
-		OrderID:  orderID,
-		UserID:   req.UserID,
-		Items:    req.Items,
-		Currency: req.Currency,
+	persistedOrder, err := h.OrderStore.CreateOrder(ctx, CreateOrderParams{
+		OrderID: orderID,
+		UserID:  req.UserID,
+		Items:   pricedItems,
+		Total:   total,
 	})
 	if err != nil {
 		h.inc("create_order_persistence_errors_total")
-		http.Error(w, "order persistence failed", http.StatusServiceUnavailable)
-		return
+		return resp, orderError(http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "order persistence failed")
 	}
 
 	event := OrdersCreatedEvent{
@@ -132,35 +412,271 @@ func (h *Handler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 		Version:    1,
 		OrderID:    persistedOrder.OrderID,
 		UserID:     persistedOrder.UserID,
-		RequestID:  strings.TrimSpace(r.Header.Get(requestIDHeader)),
-		TotalCents: persistedOrder.TotalCents,
-		Currency:   persistedOrder.Currency,
+		RequestID:  call.RequestID,
+		TotalCents: persistedOrder.Total.Minor,
+		Currency:   persistedOrder.Total.Currency,
 		CreatedAt:  persistedOrder.CreatedAt.UTC().Format(time.RFC3339),
 	}
-	if err := h.EventPublisher.PublishOrdersCreated(r.Context(), event); err != nil {
+	if err := h.EventPublisher.PublishOrdersCreated(ctx, event); err != nil {
 		h.inc("create_order_publish_errors_total")
-		http.Error(w, "event publish failed", http.StatusServiceUnavailable)
-		return
+		// The order was never announced, so it is removed along with its
+		// stock and key, and a retry with the same key creates it again. An
+		// order that cannot be removed keeps the key, so the retry does not
+		// create a second one, and its stock, so the stock is not sold twice.
+		if err := h.OrderStore.DeleteOrder(context.WithoutCancel(ctx), persistedOrder.OrderID); err != nil {
+			h.inc("create_order_orphaned_total")
+			accepted = true
+			if err := h.Inventory.Commit(context.WithoutCancel(ctx), reservationID); err != nil {
+				h.inc("create_order_inventory_commit_errors_total")
+			}
+		}
+		return resp, orderError(http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "event publish failed")
 	}
 
-	// TODO: adopt transactional outbox for DB + event atomicity.
-	resp := CreateOrderResponse{
-		OrderID: persistedOrder.OrderID,
-		Status:  "created",
+	accepted = true
+	if err := h.Inventory.Commit(ctx, reservationID); err != nil {
+		// The order is persisted and announced; an uncommitted reservation
+		// only expires back into stock, so it is counted for reconciliation.
+		h.inc("create_order_inventory_commit_errors_total")
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	_ = json.NewEncoder(w).Encode(resp)
+	// TODO: adopt transactional outbox for DB + event atomicity.
+	resp.OrderID = persistedOrder.OrderID
+	resp.Status = "created"
 	h.inc("create_order_success_total")
+
+	h.recordIdempotentResponse(ctx, resp.IdempotencyKey, IdempotencyResponse{
+		RequestHash: requestHash,
+		StatusCode:  http.StatusCreated,
+		Body:        encodeCreateOrderResponse(resp),
+	})
+	return resp, nil
+}
+
+// encodeCreateOrderResponse is the HTTP body for resp; it is also what gets
+// recorded for idempotent replays, whichever transport created the order.
+func encodeCreateOrderResponse(resp CreateOrderResponse) []byte {
+	var body bytes.Buffer
+	_ = json.NewEncoder(&body).Encode(resp)
+	return body.Bytes()
+}
+
+// GetOrder returns an order to its owner or to an admin. Other callers get 404
+// so order IDs cannot be probed.
+func (h *Handler) GetOrder(w http.ResponseWriter, r *http.Request) {
+	principal, _ := httpx.PrincipalFromHeaders(r.Header)
+	order, err := h.getOrder(r.Context(), principal, chi.URLParam(r, "orderID"))
+	if err != nil {
+		h.writeOrderError(w, r, principal, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, order)
+}
+
+func (h *Handler) getOrder(ctx context.Context, principal httpx.Principal, orderID string) (Order, error) {
+	h.inc("get_order_requests_total")
+
+	if principal.Subject == "" {
+		h.inc("get_order_unauthenticated_total")
+		err := orderError(http.StatusUnauthorized, httpx.CodeUnauthenticated, "authentication required")
+		err.Denied = "unauthenticated"
+		return Order{}, err
+	}
+	reader, ok := h.OrderStore.(OrderReader)
+	if !ok {
+		h.inc("get_order_service_errors_total")
+		return Order{}, orderError(http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "order lookup not configured")
+	}
+
+	order, err := reader.GetOrder(ctx, orderID)
+	if errors.Is(err, ErrOrderNotFound) {
+		return Order{}, orderError(http.StatusNotFound, codeOrderNotFound, "order not found")
+	}
+	if err != nil {
+		h.inc("get_order_store_errors_total")
+		return Order{}, orderError(http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "order lookup failed")
+	}
+	if order.UserID != principal.Subject && !principal.HasRole(httpx.RoleAdmin) {
+		h.inc("get_order_forbidden_total")
+		err := orderError(http.StatusNotFound, codeOrderNotFound, "order not found")
+		err.Denied = "not order owner"
+		return Order{}, err
+	}
+	return order, nil
+}
+
+const (
+	defaultListPageSize = 20
+	maxListPageSize     = 100
+)
+
+// listOrders pages through userID's orders, newest first. userID defaults to
+// the caller; only admins may list someone else's orders.
+func (h *Handler) listOrders(ctx context.Context, principal httpx.Principal, userID string, pageSize int, pageToken string) ([]Order, string, error) {
+	h.inc("list_orders_requests_total")
+
+	if principal.Subject == "" {
+		h.inc("list_orders_unauthenticated_total")
+		err := orderError(http.StatusUnauthorized, httpx.CodeUnauthenticated, "authentication required")
+		err.Denied = "unauthenticated"
+		return nil, "", err
+	}
+	if userID == "" {
+		userID = principal.Subject
+	}
+	if userID != principal.Subject && !principal.HasRole(httpx.RoleAdmin) {
+		h.inc("list_orders_forbidden_total")
+		err := orderError(http.StatusForbidden, httpx.CodeForbidden, "cannot list another user's orders")
+		err.Denied = "not order owner"
+		return nil, "", err
+	}
+
+	var fieldErrs []httpx.FieldError
+	switch {
+	case pageSize == 0:
+		pageSize = defaultListPageSize
+	case pageSize < 0 || pageSize > maxListPageSize:
+		fieldErrs = append(fieldErrs, httpx.FieldError{Field: "page_size", Code: httpx.FieldOutOfRange, Detail: fmt.Sprintf("must be between 1 and %d", maxListPageSize)})
+	}
+	after, err := decodePageToken(pageToken)
+	if err != nil {
+		fieldErrs = append(fieldErrs, httpx.FieldError{Field: "page_token", Code: httpx.FieldInvalid, Detail: "malformed page token"})
+	}
+	if len(fieldErrs) > 0 {
+		h.inc("list_orders_validation_errors_total")
+		return nil, "", &OrderError{
+			Status: http.StatusBadRequest,
+			Code:   httpx.CodeValidationFailed,
+			Detail: "invalid list request",
+			Fields: fieldErrs,
+		}
+	}
+
+	lister, ok := h.OrderStore.(OrderLister)
+	if !ok {
+		h.inc("list_orders_service_errors_total")
+		return nil, "", orderError(http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "order listing not configured")
+	}
+	// One extra row tells whether another page follows.
+	orders, err := lister.ListOrders(ctx, userID, pageSize+1, after)
+	if err != nil {
+		h.inc("list_orders_store_errors_total")
+		return nil, "", orderError(http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "order listing failed")
+	}
+	var next string
+	if len(orders) > pageSize {
+		orders = orders[:pageSize]
+		last := orders[len(orders)-1]
+		next = encodePageToken(OrderCursor{CreatedAt: last.CreatedAt, OrderID: last.OrderID})
+	}
+	return orders, next, nil
+}
+
+func (h *Handler) recordIdempotentResponse(ctx context.Context, key string, resp IdempotencyResponse) {
+	recorder, ok := h.IdempotencyStore.(IdempotencyResponseRecorder)
+	if !ok {
+		return
+	}
+	// The order is already created; failing to record the response only loses
+	// replay metadata, so it is counted rather than surfaced to the client.
+	if err := recorder.RecordResponse(ctx, key, resp); err != nil {
+		h.inc("create_order_idempotency_record_errors_total")
+	}
+}
+
+// replayIdempotentResponse returns the response recorded for a finished
+// request with the same key and fingerprint. A recorded response for a
+// different fingerprint is left to isIdempotencyCollision.
+func (h *Handler) replayIdempotentResponse(ctx context.Context, key, requestHash string) (CreateOrderResponse, bool) {
+	lookup, ok := h.IdempotencyStore.(IdempotencyResponseLookup)
+	if !ok {
+		return CreateOrderResponse{}, false
+	}
+	recorded, found, err := lookup.LookupResponse(ctx, key)
+	if err != nil || !found || recorded.RequestHash != requestHash || recorded.StatusCode != http.StatusCreated {
+		return CreateOrderResponse{}, false
+	}
+	var original CreateOrderResponse
+	if err := json.Unmarshal(recorded.Body, &original); err != nil {
+		return CreateOrderResponse{}, false
+	}
+	return original, true
+}
+
+func (h *Handler) releaseIdempotencyKey(ctx context.Context, key string) {
+	releaser, ok := h.IdempotencyStore.(IdempotencyKeyReleaser)
+	if !ok {
+		return
+	}
+	if err := releaser.Release(ctx, key); err != nil {
+		h.inc("create_order_idempotency_release_errors_total")
+	}
 }
 
-func calculateTotalCents(items []OrderItem) int {
-	total := 0
+func (h *Handler) releaseInventory(ctx context.Context, reservationID string) {
+	if err := h.Inventory.Release(ctx, reservationID); err != nil {
+		h.inc("create_order_inventory_release_errors_total")
+		return
+	}
+	h.inc("create_order_inventory_released_total")
+}
+
+// isIdempotencyCollision reports whether a reserved key was first used for a
+// different request. Stores without fingerprints, lookup errors, and keys whose
+// first request is still in flight are treated as plain duplicates.
+func (h *Handler) isIdempotencyCollision(ctx context.Context, key, requestHash string) bool {
+	lookup, ok := h.IdempotencyStore.(IdempotencyRequestHashLookup)
+	if !ok {
+		return false
+	}
+	recorded, found, err := lookup.LookupRequestHash(ctx, key)
+	if err != nil || !found {
+		return false
+	}
+	return recorded != requestHash
+}
+
+// priceItems replaces each item's price with the catalog price for currency.
+// It returns the indexes of items whose SKU is unknown, inactive, or unpriced
+// in currency.
+func (h *Handler) priceItems(ctx context.Context, currency money.Currency, items []OrderItem) ([]OrderItem, []int, error) {
+	skus := make([]string, 0, len(items))
+	for _, item := range items {
+		skus = append(skus, item.SKU)
+	}
+	prices, err := h.Catalog.LookupPrices(ctx, currency, skus)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	priced := make([]OrderItem, 0, len(items))
+	var rejected []int
+	for i, item := range items {
+		price, ok := prices[item.SKU]
+		if !ok || !price.Active {
+			rejected = append(rejected, i)
+			continue
+		}
+		item.UnitPrice = price.UnitPrice
+		priced = append(priced, item)
+	}
+	return priced, rejected, nil
+}
+
+// calculateTotal sums qty*unit price, failing with money.ErrOverflow rather
+// than wrapping when an order is too large to represent.
+func calculateTotal(currency money.Currency, items []OrderItem) (money.Money, error) {
+	total := money.Zero(currency)
 	for _, item := range items {
-		total += item.Qty * item.PriceCents
+		line, err := item.UnitPrice.Mul(int64(item.Qty))
+		if err != nil {
+			return money.Money{}, err
+		}
+		if total, err = total.Add(line); err != nil {
+			return money.Money{}, err
+		}
 	}
-	return total
+	return total, nil
 }
 
 func newOrderID() string {
@@ -171,6 +687,12 @@ func newOrderID() string {
 	return hex.EncodeToString(b)
 }
 
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
 func (h *Handler) inc(name string) {
 	if h.Metrics != nil {
 		h.Metrics.Inc(name)
@@ -1,6 +1,7 @@
 package orders
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -9,6 +10,12 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/triad-platform/triad-app/pkg/httpx"
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+	"github.com/triad-platform/triad-app/pkg/money"
+	"github.com/triad-platform/triad-app/services/orders/internal/catalog"
 )
 
 func TestCreateOrder(t *testing.T) {
@@ -61,7 +68,7 @@ func TestCreateOrder(t *testing.T) {
 		},
 		{
 			name:           "invalid item values",
-			body:           `{"user_id":"u_123","items":[{"sku":"sku_1","quantity":0,"unit_price":0}],"currency":"USD"}`,
+			body:           `{"user_id":"u_123","items":[{"sku":"sku_1","qty":0,"unit_price":0}],"currency":"USD"}`,
 			idempotencyKey: "idem-invalid-items",
 			store:          &stubIdempotencyStore{reserveResult: true},
 			orderStore:     &stubOrderStore{},
@@ -130,7 +137,7 @@ func TestCreateOrder(t *testing.T) {
 			body:           `{"user_id":"u_123","items":[{"sku":"sku_1","qty":1,"price_cents":100}],"currency":"USD"}`,
 			idempotencyKey: "idem-publish-error",
 			store:          &stubIdempotencyStore{reserveResult: true},
-			orderStore:     &stubOrderStore{persisted: PersistedOrder{OrderID: "o-publish", UserID: "u_123", TotalCents: 100, Currency: "USD", CreatedAt: time.Date(2026, 2, 27, 0, 0, 0, 0, time.UTC)}},
+			orderStore:     &stubOrderStore{persisted: PersistedOrder{OrderID: "o-publish", UserID: "u_123", Total: money.New(100, "USD"), CreatedAt: time.Date(2026, 2, 27, 0, 0, 0, 0, time.UTC)}},
 			publisher:      &stubPublisher{err: errors.New("publish failed")},
 			wantStatusCode: http.StatusServiceUnavailable,
 			wantStoreCalls: 1,
@@ -138,11 +145,11 @@ func TestCreateOrder(t *testing.T) {
 		},
 		{
 			name:           "valid request",
-			body:           `{"user_id":"u_123","items":[{"sku":"sku_1","quantity":1,"unit_price":100}],"currency":"USD"}`,
+			body:           `{"user_id":"u_123","items":[{"sku":"sku_1","qty":1,"unit_price":100}],"currency":"USD"}`,
 			idempotencyKey: "idem-valid",
 			requestID:      "req-test-123",
 			store:          &stubIdempotencyStore{reserveResult: true},
-			orderStore:     &stubOrderStore{persisted: PersistedOrder{OrderID: "o-valid", UserID: "u_123", TotalCents: 100, Currency: "USD", CreatedAt: time.Date(2026, 2, 27, 0, 0, 0, 0, time.UTC)}},
+			orderStore:     &stubOrderStore{persisted: PersistedOrder{OrderID: "o-valid", UserID: "u_123", Total: money.New(100, "USD"), CreatedAt: time.Date(2026, 2, 27, 0, 0, 0, 0, time.UTC)}},
 			publisher:      &stubPublisher{},
 			wantStatusCode: http.StatusCreated,
 			wantStoreCalls: 1,
@@ -157,6 +164,8 @@ func TestCreateOrder(t *testing.T) {
 
 			h := &Handler{
 				IdempotencyStore: tc.store,
+				Catalog:          defaultStubCatalog(),
+				Inventory:        &stubInventory{},
 				OrderStore:       tc.orderStore,
 				EventPublisher:   tc.publisher,
 				IdempotencyTTL:   time.Minute,
@@ -205,8 +214,8 @@ func TestCreateOrder(t *testing.T) {
 			if tc.publisher.lastEvent.OrderID != tc.orderStore.persisted.OrderID {
 				t.Fatal("published event should include persisted order_id")
 			}
-			if tc.publisher.lastEvent.TotalCents != tc.orderStore.persisted.TotalCents {
-				t.Fatalf("published total_cents mismatch: got=%d want=%d", tc.publisher.lastEvent.TotalCents, tc.orderStore.persisted.TotalCents)
+			if tc.publisher.lastEvent.TotalCents != tc.orderStore.persisted.Total.Minor {
+				t.Fatalf("published total_cents mismatch: got=%d want=%d", tc.publisher.lastEvent.TotalCents, tc.orderStore.persisted.Total.Minor)
 			}
 			if tc.publisher.lastEvent.Type != "OrdersCreated" || tc.publisher.lastEvent.Version != 1 {
 				t.Fatalf("published event metadata mismatch: got type=%q version=%d", tc.publisher.lastEvent.Type, tc.publisher.lastEvent.Version)
@@ -218,22 +227,124 @@ func TestCreateOrder(t *testing.T) {
 	}
 }
 
+func TestCreateOrder_FieldErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		body      string
+		wantCode  string
+		wantField []httpx.FieldError
+	}{
+		{
+			name:     "invalid json",
+			body:     "{",
+			wantCode: httpx.CodeInvalidJSON,
+		},
+		{
+			name:     "missing fields",
+			body:     `{"items":[]}`,
+			wantCode: httpx.CodeValidationFailed,
+			wantField: []httpx.FieldError{
+				{Field: "user_id", Code: httpx.FieldRequired},
+				{Field: "currency", Code: httpx.FieldRequired},
+				{Field: "items", Code: httpx.FieldRequired},
+			},
+		},
+		{
+			name:     "invalid items by index",
+			body:     `{"user_id":"u_123","items":[{"sku":"sku_1","qty":1},{"sku":"","qty":0}],"currency":"USD"}`,
+			wantCode: httpx.CodeValidationFailed,
+			wantField: []httpx.FieldError{
+				{Field: "items[1].sku", Code: httpx.FieldRequired},
+				{Field: "items[1].qty", Code: httpx.FieldNotPositive},
+			},
+		},
+		{
+			name:     "wrong types",
+			body:     `{"user_id":7,"items":[{"sku":"sku_1","qty":1},{"sku":"sku_2","qty":"two"}],"currency":"XXX"}`,
+			wantCode: httpx.CodeValidationFailed,
+			wantField: []httpx.FieldError{
+				{Field: "user_id", Code: httpx.FieldInvalidType},
+				{Field: "currency", Code: httpx.FieldUnsupported},
+				{Field: "items[1].qty", Code: httpx.FieldInvalidType},
+			},
+		},
+		{
+			name:     "unknown sku",
+			body:     `{"user_id":"u_123","items":[{"sku":"sku_1","qty":1},{"sku":"sku_gone","qty":1}],"currency":"USD"}`,
+			wantCode: codeUnknownSKU,
+			wantField: []httpx.FieldError{
+				{Field: "items[1].sku", Code: httpx.FieldUnsupported},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &Handler{
+				IdempotencyStore: &stubIdempotencyStore{reserveResult: true},
+				Catalog:          defaultStubCatalog(),
+				Inventory:        &stubInventory{},
+				OrderStore:       &stubOrderStore{},
+				EventPublisher:   &stubPublisher{},
+			}
+			req := httptest.NewRequest(http.MethodPost, "/v1/orders", strings.NewReader(tc.body))
+			req.Header.Set(idempotencyHeader, "idem-fields")
+			req.Header.Set(requestIDHeader, "req-fields")
+			rec := httptest.NewRecorder()
+			h.CreateOrder(rec, req)
+
+			if got := rec.Header().Get("Content-Type"); got != httpx.ProblemContentType {
+				t.Fatalf("content type mismatch: got=%q want=%q", got, httpx.ProblemContentType)
+			}
+			var problem struct {
+				Code      string             `json:"code"`
+				RequestID string             `json:"request_id"`
+				Errors    []httpx.FieldError `json:"errors"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+				t.Fatalf("decode problem: %v", err)
+			}
+			if problem.Code != tc.wantCode {
+				t.Fatalf("code mismatch: got=%q want=%q body=%q", problem.Code, tc.wantCode, rec.Body.String())
+			}
+			if problem.RequestID != "req-fields" {
+				t.Fatalf("request id mismatch: got=%q want=%q", problem.RequestID, "req-fields")
+			}
+			if len(problem.Errors) != len(tc.wantField) {
+				t.Fatalf("field errors mismatch: got=%+v want=%+v", problem.Errors, tc.wantField)
+			}
+			for i, want := range tc.wantField {
+				if got := problem.Errors[i]; got.Field != want.Field || got.Code != want.Code {
+					t.Fatalf("field error %d mismatch: got=%+v want=%+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
 func TestCreateOrder_IdempotencyBehavior(t *testing.T) {
 	t.Parallel()
 
 	store := &statefulIdempotencyStore{
 		keys: map[string]struct{}{},
 	}
-	orderStore := &stubOrderStore{persisted: PersistedOrder{OrderID: "o-repeat", UserID: "u_123", TotalCents: 100, Currency: "USD", CreatedAt: time.Date(2026, 2, 27, 0, 0, 0, 0, time.UTC)}}
+	orderStore := &stubOrderStore{persisted: PersistedOrder{OrderID: "o-repeat", UserID: "u_123", Total: money.New(100, "USD"), CreatedAt: time.Date(2026, 2, 27, 0, 0, 0, 0, time.UTC)}}
 	publisher := &stubPublisher{}
 	h := &Handler{
 		IdempotencyStore: store,
+		Catalog:          defaultStubCatalog(),
+		Inventory:        &stubInventory{},
 		OrderStore:       orderStore,
 		EventPublisher:   publisher,
 		IdempotencyTTL:   time.Minute,
 	}
 
-	body := `{"user_id":"u_123","items":[{"sku":"sku_1","quantity":1,"unit_price":100}],"currency":"USD"}`
+	body := `{"user_id":"u_123","items":[{"sku":"sku_1","qty":1,"unit_price":100}],"currency":"USD"}`
 	key := "idem-repeat"
 
 	req1 := httptest.NewRequest(http.MethodPost, "/v1/orders", strings.NewReader(body))
@@ -248,8 +359,20 @@ func TestCreateOrder_IdempotencyBehavior(t *testing.T) {
 	req2.Header.Set(idempotencyHeader, key)
 	rec2 := httptest.NewRecorder()
 	h.CreateOrder(rec2, req2)
-	if rec2.Code != http.StatusConflict {
-		t.Fatalf("second request status mismatch: got=%d want=%d body=%q", rec2.Code, http.StatusConflict, rec2.Body.String())
+	if rec2.Code != http.StatusCreated {
+		t.Fatalf("second request status mismatch: got=%d want=%d body=%q", rec2.Code, http.StatusCreated, rec2.Body.String())
+	}
+	if rec2.Body.String() != rec1.Body.String() {
+		t.Fatalf("replayed body mismatch: got=%q want=%q", rec2.Body.String(), rec1.Body.String())
+	}
+
+	// The same key with a different body is a reuse, not a replay.
+	req3 := httptest.NewRequest(http.MethodPost, "/v1/orders", strings.NewReader(strings.Replace(body, `"qty":1`, `"qty":2`, 1)))
+	req3.Header.Set(idempotencyHeader, key)
+	rec3 := httptest.NewRecorder()
+	h.CreateOrder(rec3, req3)
+	if rec3.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("reused key status mismatch: got=%d want=%d body=%q", rec3.Code, http.StatusUnprocessableEntity, rec3.Body.String())
 	}
 	if orderStore.calls != 1 {
 		t.Fatalf("store should be called once across duplicate retries: got=%d want=1", orderStore.calls)
@@ -259,6 +382,779 @@ func TestCreateOrder_IdempotencyBehavior(t *testing.T) {
 	}
 }
 
+func TestCreateOrder_ReleasesIdempotencyKeyOnFailure(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		orderStore *stubOrderStore
+		publisher  *stubPublisher
+		wantStatus int
+	}{
+		{name: "persist fails", orderStore: &stubOrderStore{err: errors.New("db down")}, publisher: &stubPublisher{}, wantStatus: http.StatusServiceUnavailable},
+		{name: "publish fails", orderStore: &stubOrderStore{}, publisher: &stubPublisher{err: errors.New("nats down")}, wantStatus: http.StatusServiceUnavailable},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := &statefulIdempotencyStore{keys: map[string]struct{}{}}
+			tc.orderStore.persisted = PersistedOrder{OrderID: "o-retry", UserID: "u_123", Total: money.New(100, "USD")}
+			h := &Handler{
+				IdempotencyStore: store,
+				Catalog:          defaultStubCatalog(),
+				Inventory:        &stubInventory{},
+				OrderStore:       tc.orderStore,
+				EventPublisher:   tc.publisher,
+			}
+			send := func() *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodPost, "/v1/orders", strings.NewReader(`{"user_id":"u_123","items":[{"sku":"sku_1","qty":1}],"currency":"USD"}`))
+				req.Header.Set(idempotencyHeader, "idem-retry")
+				rec := httptest.NewRecorder()
+				h.CreateOrder(rec, req)
+				return rec
+			}
+
+			if rec := send(); rec.Code != tc.wantStatus {
+				t.Fatalf("first request status mismatch: got=%d want=%d body=%q", rec.Code, tc.wantStatus, rec.Body.String())
+			}
+			if len(store.released) != 1 {
+				t.Fatalf("failed request should release its key: released=%v", store.released)
+			}
+			if tc.orderStore.err == nil && (len(tc.orderStore.deleted) != 1 || tc.orderStore.deleted[0] != "o-retry") {
+				t.Fatalf("unannounced order should be deleted: deleted=%v", tc.orderStore.deleted)
+			}
+
+			tc.orderStore.err = nil
+			tc.publisher.err = nil
+			if rec := send(); rec.Code != http.StatusCreated {
+				t.Fatalf("retry status mismatch: got=%d want=%d body=%q", rec.Code, http.StatusCreated, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestCreateOrder_KeepsIdempotencyKeyForUndeletableOrder(t *testing.T) {
+	t.Parallel()
+
+	store := &statefulIdempotencyStore{keys: map[string]struct{}{}}
+	orderStore := &stubOrderStore{
+		persisted: PersistedOrder{OrderID: "o-orphan", UserID: "u_123", Total: money.New(100, "USD")},
+		deleteErr: errors.New("db down"),
+	}
+	publisher := &stubPublisher{err: errors.New("nats down")}
+	h := &Handler{
+		IdempotencyStore: store,
+		Catalog:          defaultStubCatalog(),
+		Inventory:        &stubInventory{},
+		OrderStore:       orderStore,
+		EventPublisher:   publisher,
+	}
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/orders", strings.NewReader(`{"user_id":"u_123","items":[{"sku":"sku_1","qty":1}],"currency":"USD"}`))
+		req.Header.Set(idempotencyHeader, "idem-orphan")
+		rec := httptest.NewRecorder()
+		h.CreateOrder(rec, req)
+		return rec
+	}
+
+	if rec := send(); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("first request status mismatch: got=%d want=%d body=%q", rec.Code, http.StatusServiceUnavailable, rec.Body.String())
+	}
+	if len(store.released) != 0 {
+		t.Fatalf("a persisted order must keep its key: released=%v", store.released)
+	}
+
+	publisher.err = nil
+	if rec := send(); rec.Code != http.StatusConflict {
+		t.Fatalf("retry status mismatch: got=%d want=%d body=%q", rec.Code, http.StatusConflict, rec.Body.String())
+	}
+	if orderStore.calls != 1 {
+		t.Fatalf("retry must not create a second order: calls=%d", orderStore.calls)
+	}
+}
+
+func TestCreateOrder_ShadowRequestIsDryRun(t *testing.T) {
+	t.Parallel()
+
+	store := &recordingIdempotencyStore{reserveResult: true}
+	inventory := &stubInventory{}
+	orderStore := &stubOrderStore{persisted: PersistedOrder{OrderID: "o-shadow", UserID: "u_123", Total: money.New(100, "USD")}}
+	publisher := &stubPublisher{}
+	h := &Handler{
+		IdempotencyStore: store,
+		Catalog:          defaultStubCatalog(),
+		Inventory:        inventory,
+		OrderStore:       orderStore,
+		EventPublisher:   publisher,
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/orders", strings.NewReader(`{"user_id":"u_123","items":[{"sku":"sku_1","qty":1}],"currency":"USD"}`))
+	req.Header.Set(idempotencyHeader, "mirror-1")
+	req.Header.Set(shadowHeader, "true")
+	rec := httptest.NewRecorder()
+	h.CreateOrder(rec, req)
+
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"status":"dry_run"`) {
+		t.Fatalf("shadow response mismatch: got=%d %q", rec.Code, rec.Body.String())
+	}
+	if store.reserveCalls != 0 || len(inventory.reserved) != 0 || orderStore.calls != 0 || publisher.calls != 0 {
+		t.Fatalf("shadow request had side effects: keys=%d stock=%v orders=%d events=%d", store.reserveCalls, inventory.reserved, orderStore.calls, publisher.calls)
+	}
+
+	// Validation still runs, so the shadow's responses are comparable.
+	req = httptest.NewRequest(http.MethodPost, "/v1/orders", strings.NewReader(`{"user_id":"u_123","items":[{"sku":"sku_missing","qty":1}],"currency":"USD"}`))
+	req.Header.Set(idempotencyHeader, "mirror-2")
+	req.Header.Set(shadowHeader, "true")
+	rec = httptest.NewRecorder()
+	h.CreateOrder(rec, req)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("invalid shadow status mismatch: got=%d want=%d", rec.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestCreateOrder_RecordsIdempotentResponse(t *testing.T) {
+	t.Parallel()
+
+	store := &recordingIdempotencyStore{reserveResult: true}
+	h := &Handler{
+		IdempotencyStore: store,
+		Catalog:          defaultStubCatalog(),
+		Inventory:        &stubInventory{},
+		OrderStore:       &stubOrderStore{persisted: PersistedOrder{OrderID: "o-recorded", UserID: "u_123", Total: money.New(100, "USD"), CreatedAt: time.Date(2026, 2, 27, 0, 0, 0, 0, time.UTC)}},
+		EventPublisher:   &stubPublisher{},
+		IdempotencyTTL:   time.Minute,
+	}
+
+	body := `{"user_id":"u_123","items":[{"sku":"sku_1","qty":1,"price_cents":100}],"currency":"USD"}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/orders", strings.NewReader(body))
+	req.Header.Set(idempotencyHeader, "idem-recorded")
+	rec := httptest.NewRecorder()
+	h.CreateOrder(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status code mismatch: got=%d want=%d body=%q", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+
+	got, ok := store.recorded["u_123:idem-recorded"]
+	if !ok {
+		t.Fatal("expected response to be recorded for reserved key")
+	}
+	if got.StatusCode != http.StatusCreated {
+		t.Fatalf("recorded status mismatch: got=%d want=%d", got.StatusCode, http.StatusCreated)
+	}
+	if got.RequestHash == "" {
+		t.Fatalf("recorded request hash mismatch: got=%q", got.RequestHash)
+	}
+	if string(got.Body) != rec.Body.String() {
+		t.Fatalf("recorded body mismatch: got=%q want=%q", got.Body, rec.Body.String())
+	}
+}
+
+func TestCreateOrder_IdempotencyKeyValidation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{name: "too long", key: strings.Repeat("k", maxIdempotencyKeyLength+1)},
+		{name: "scope separator", key: "u_other:idem-1"},
+		{name: "whitespace inside", key: "idem 1"},
+		{name: "non ascii", key: "idém-1"},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := &recordingIdempotencyStore{reserveResult: true}
+			h := &Handler{
+				IdempotencyStore: store,
+				Catalog:          defaultStubCatalog(),
+				Inventory:        &stubInventory{},
+				OrderStore:       &stubOrderStore{},
+				EventPublisher:   &stubPublisher{},
+			}
+			req := httptest.NewRequest(http.MethodPost, "/v1/orders", strings.NewReader(`{"user_id":"u_123","items":[{"sku":"sku_1","qty":1,"price_cents":100}],"currency":"USD"}`))
+			req.Header.Set(idempotencyHeader, tc.key)
+			rec := httptest.NewRecorder()
+			h.CreateOrder(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status code mismatch: got=%d want=%d body=%q", rec.Code, http.StatusBadRequest, rec.Body.String())
+			}
+			if store.reserveCalls != 0 {
+				t.Fatalf("invalid key should not reach the store: calls=%d", store.reserveCalls)
+			}
+		})
+	}
+}
+
+func TestCreateOrder_IdempotencyKeyScopedPerUser(t *testing.T) {
+	t.Parallel()
+
+	store := &statefulIdempotencyStore{keys: map[string]struct{}{}}
+	orderStore := &stubOrderStore{persisted: PersistedOrder{OrderID: "o-scoped", Total: money.New(100, "USD")}}
+	h := &Handler{
+		IdempotencyStore: store,
+		Catalog:          defaultStubCatalog(),
+		Inventory:        &stubInventory{},
+		OrderStore:       orderStore,
+		EventPublisher:   &stubPublisher{},
+	}
+
+	for _, userID := range []string{"u_alice", "u_bob"} {
+		body := `{"user_id":"` + userID + `","items":[{"sku":"sku_1","qty":1,"price_cents":100}],"currency":"USD"}`
+		req := httptest.NewRequest(http.MethodPost, "/v1/orders", strings.NewReader(body))
+		req.Header.Set(idempotencyHeader, "shared-key")
+		rec := httptest.NewRecorder()
+		h.CreateOrder(rec, req)
+
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("%s: status code mismatch: got=%d want=%d body=%q", userID, rec.Code, http.StatusCreated, rec.Body.String())
+		}
+		wantKey := userID + ":shared-key"
+		if got := rec.Header().Get(scopedIdempotencyHeader); got != wantKey {
+			t.Fatalf("%s: scoped key header mismatch: got=%q want=%q", userID, got, wantKey)
+		}
+		var resp CreateOrderResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if resp.IdempotencyKey != wantKey {
+			t.Fatalf("%s: response idempotency_key mismatch: got=%q want=%q", userID, resp.IdempotencyKey, wantKey)
+		}
+	}
+	if orderStore.calls != 2 {
+		t.Fatalf("both users should create orders: got=%d want=2", orderStore.calls)
+	}
+}
+
+func TestCreateOrder_AuthenticatedUser(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		body        string
+		authUser    string
+		requireAuth bool
+		wantStatus  int
+		wantUserID  string
+	}{
+		{
+			name:       "header fills missing body user",
+			body:       `{"items":[{"sku":"sku_1","qty":1}],"currency":"USD"}`,
+			authUser:   "u_token",
+			wantStatus: http.StatusCreated,
+			wantUserID: "u_token",
+		},
+		{
+			name:       "matching body user",
+			body:       `{"user_id":"u_token","items":[{"sku":"sku_1","qty":1}],"currency":"USD"}`,
+			authUser:   "u_token",
+			wantStatus: http.StatusCreated,
+			wantUserID: "u_token",
+		},
+		{
+			name:       "body names another user",
+			body:       `{"user_id":"u_victim","items":[{"sku":"sku_1","qty":1}],"currency":"USD"}`,
+			authUser:   "u_token",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:        "header required",
+			body:        `{"user_id":"u_1","items":[{"sku":"sku_1","qty":1}],"currency":"USD"}`,
+			requireAuth: true,
+			wantStatus:  http.StatusUnauthorized,
+		},
+		{
+			name:       "body user without header when not required",
+			body:       `{"user_id":"u_1","items":[{"sku":"sku_1","qty":1}],"currency":"USD"}`,
+			wantStatus: http.StatusCreated,
+			wantUserID: "u_1",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			orderStore := &stubOrderStore{persisted: PersistedOrder{OrderID: "o-auth", Total: money.New(100, "USD")}}
+			h := &Handler{
+				IdempotencyStore:         &stubIdempotencyStore{reserveResult: true},
+				Catalog:                  defaultStubCatalog(),
+				Inventory:                &stubInventory{},
+				OrderStore:               orderStore,
+				EventPublisher:           &stubPublisher{},
+				RequireAuthenticatedUser: tc.requireAuth,
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/orders", strings.NewReader(tc.body))
+			req.Header.Set(idempotencyHeader, "idem-auth")
+			if tc.authUser != "" {
+				req.Header.Set(httpx.AuthenticatedUserHeader, tc.authUser)
+			}
+			rec := httptest.NewRecorder()
+			h.CreateOrder(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status code mismatch: got=%d want=%d body=%q", rec.Code, tc.wantStatus, rec.Body.String())
+			}
+			if tc.wantUserID == "" {
+				if orderStore.calls != 0 {
+					t.Fatalf("rejected request should not persist: calls=%d", orderStore.calls)
+				}
+				return
+			}
+			if orderStore.lastParams.UserID != tc.wantUserID {
+				t.Fatalf("persisted user mismatch: got=%q want=%q", orderStore.lastParams.UserID, tc.wantUserID)
+			}
+			if got, want := rec.Header().Get(scopedIdempotencyHeader), tc.wantUserID+":idem-auth"; got != want {
+				t.Fatalf("scoped key header mismatch: got=%q want=%q", got, want)
+			}
+		})
+	}
+}
+
+func TestGetOrder(t *testing.T) {
+	t.Parallel()
+
+	order := Order{OrderID: "o-1", UserID: "u_owner", Status: "created", Total: money.New(200, "USD")}
+	tests := []struct {
+		name       string
+		path       string
+		user       string
+		roles      string
+		store      OrderStore
+		wantStatus int
+		wantAudit  bool
+	}{
+		{name: "owner", path: "/v1/orders/o-1", user: "u_owner", roles: "customer", store: &stubOrderStore{order: order}, wantStatus: http.StatusOK},
+		{name: "admin reads any order", path: "/v1/orders/o-1", user: "u_admin", roles: "admin", store: &stubOrderStore{order: order}, wantStatus: http.StatusOK},
+		{name: "other customer", path: "/v1/orders/o-1", user: "u_other", roles: "customer", store: &stubOrderStore{order: order}, wantStatus: http.StatusNotFound, wantAudit: true},
+		{name: "unauthenticated", path: "/v1/orders/o-1", store: &stubOrderStore{order: order}, wantStatus: http.StatusUnauthorized, wantAudit: true},
+		{name: "missing order", path: "/v1/orders/o-2", user: "u_owner", store: &stubOrderStore{order: order}, wantStatus: http.StatusNotFound},
+		{name: "store error", path: "/v1/orders/o-1", user: "u_owner", store: &stubOrderStore{getErr: errors.New("db down")}, wantStatus: http.StatusServiceUnavailable},
+		{name: "store cannot read", path: "/v1/orders/o-1", user: "u_owner", store: writeOnlyOrderStore{}, wantStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var logs bytes.Buffer
+			r := Routes(&Handler{OrderStore: tc.store, Log: zerolog.New(&logs)})
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.user != "" {
+				req.Header.Set(httpx.AuthenticatedUserHeader, tc.user)
+				req.Header.Set(httpx.AuthenticatedRolesHeader, tc.roles)
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status code mismatch: got=%d want=%d body=%q", rec.Code, tc.wantStatus, rec.Body.String())
+			}
+			if audited := strings.Contains(logs.String(), `"audit":"access_denied"`); audited != tc.wantAudit {
+				t.Fatalf("audit mismatch: got=%v want=%v logs=%q", audited, tc.wantAudit, logs.String())
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+			var got Order
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decode order: %v", err)
+			}
+			if got.OrderID != "o-1" || got.Total != order.Total {
+				t.Fatalf("order mismatch: got=%+v want=%+v", got, order)
+			}
+		})
+	}
+}
+
+type writeOnlyOrderStore struct{}
+
+func (writeOnlyOrderStore) CreateOrder(_ context.Context, _ CreateOrderParams) (PersistedOrder, error) {
+	return PersistedOrder{}, nil
+}
+
+func (writeOnlyOrderStore) DeleteOrder(_ context.Context, _ string) error {
+	return nil
+}
+
+func TestCreateOrder_IdempotencyCollision(t *testing.T) {
+	t.Parallel()
+
+	original := CreateOrderRequest{UserID: "u_123", Currency: "USD", Items: []OrderItem{{SKU: "sku_1", Qty: 1}}}
+
+	tests := []struct {
+		name         string
+		body         string
+		wantStatus   int
+		wantCollided bool
+	}{
+		{
+			name:       "same request replayed",
+			body:       `{"user_id":"u_123","items":[{"sku":"sku_1","qty":1,"unit_price":100}],"currency":"USD"}`,
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:         "different request reuses key",
+			body:         `{"user_id":"u_123","items":[{"sku":"sku_1","qty":5,"price_cents":100}],"currency":"USD"}`,
+			wantStatus:   http.StatusUnprocessableEntity,
+			wantCollided: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			metrics := metricsx.NewRegistry("test")
+			store := &hashLookupIdempotencyStore{hashes: map[string]string{"u_123:idem-1": hashCreateOrderRequest(original)}}
+			h := &Handler{
+				IdempotencyStore: store,
+				Catalog:          defaultStubCatalog(),
+				Inventory:        &stubInventory{},
+				OrderStore:       &stubOrderStore{},
+				EventPublisher:   &stubPublisher{},
+				Metrics:          metrics,
+			}
+			req := httptest.NewRequest(http.MethodPost, "/v1/orders", strings.NewReader(tc.body))
+			req.Header.Set(idempotencyHeader, "idem-1")
+			rec := httptest.NewRecorder()
+			h.CreateOrder(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status code mismatch: got=%d want=%d body=%q", rec.Code, tc.wantStatus, rec.Body.String())
+			}
+
+			metricsRec := httptest.NewRecorder()
+			metrics.Handler().ServeHTTP(metricsRec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			gotCollided := strings.Contains(metricsRec.Body.String(), "test_create_order_idempotency_collisions_total 1")
+			if gotCollided != tc.wantCollided {
+				t.Fatalf("collision metric mismatch: got=%v want=%v metrics=%q", gotCollided, tc.wantCollided, metricsRec.Body.String())
+			}
+		})
+	}
+}
+
+func TestCreateOrder_CatalogPricing(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		body           string
+		catalog        PriceCatalog
+		wantStatus     int
+		wantPriceCents int64
+		wantTotalCents int64
+	}{
+		{
+			name:       "catalog not configured",
+			body:       `{"user_id":"u_123","items":[{"sku":"sku_1","qty":1}],"currency":"USD"}`,
+			catalog:    nil,
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "catalog error",
+			body:       `{"user_id":"u_123","items":[{"sku":"sku_1","qty":1}],"currency":"USD"}`,
+			catalog:    &stubCatalog{err: errors.New("db down")},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "unknown sku",
+			body:       `{"user_id":"u_123","items":[{"sku":"sku_missing","qty":1,"price_cents":1}],"currency":"USD"}`,
+			catalog:    defaultStubCatalog(),
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "inactive sku",
+			body:       `{"user_id":"u_123","items":[{"sku":"sku_retired","qty":1,"price_cents":1}],"currency":"USD"}`,
+			catalog:    defaultStubCatalog(),
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "unsupported currency",
+			body:       `{"user_id":"u_123","items":[{"sku":"sku_1","qty":1}],"currency":"XYZ"}`,
+			catalog:    defaultStubCatalog(),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "total overflows",
+			body:       `{"user_id":"u_123","items":[{"sku":"sku_1","qty":9223372036854775807}],"currency":"USD"}`,
+			catalog:    defaultStubCatalog(),
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "no price in currency",
+			body:       `{"user_id":"u_123","items":[{"sku":"sku_1","qty":1}],"currency":"EUR"}`,
+			catalog:    defaultStubCatalog(),
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "client price ignored",
+			body:           `{"user_id":"u_123","items":[{"sku":"sku_1","qty":2,"price_cents":1}],"currency":"usd"}`,
+			catalog:        defaultStubCatalog(),
+			wantStatus:     http.StatusCreated,
+			wantPriceCents: 100,
+			wantTotalCents: 200,
+		},
+		{
+			name:           "client price omitted",
+			body:           `{"user_id":"u_123","items":[{"sku":"sku_1","qty":2}],"currency":"USD"}`,
+			catalog:        defaultStubCatalog(),
+			wantStatus:     http.StatusCreated,
+			wantPriceCents: 100,
+			wantTotalCents: 200,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			idempotencyStore := &recordingIdempotencyStore{reserveResult: true}
+			orderStore := &stubOrderStore{persisted: PersistedOrder{OrderID: "o-priced", UserID: "u_123", Total: money.New(200, "USD")}}
+			h := &Handler{
+				IdempotencyStore: idempotencyStore,
+				Catalog:          tc.catalog,
+				Inventory:        &stubInventory{},
+				OrderStore:       orderStore,
+				EventPublisher:   &stubPublisher{},
+			}
+			req := httptest.NewRequest(http.MethodPost, "/v1/orders", strings.NewReader(tc.body))
+			req.Header.Set(idempotencyHeader, "idem-pricing")
+			rec := httptest.NewRecorder()
+			h.CreateOrder(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status code mismatch: got=%d want=%d body=%q", rec.Code, tc.wantStatus, rec.Body.String())
+			}
+			if tc.wantStatus != http.StatusCreated {
+				if idempotencyStore.reserveCalls != 0 {
+					t.Fatalf("rejected order should not consume the idempotency key: calls=%d", idempotencyStore.reserveCalls)
+				}
+				return
+			}
+			if got := orderStore.lastParams.Items[0].UnitPrice.Minor; got != tc.wantPriceCents {
+				t.Fatalf("charged price mismatch: got=%d want=%d", got, tc.wantPriceCents)
+			}
+			if got := orderStore.lastParams.Total.Minor; got != tc.wantTotalCents {
+				t.Fatalf("order total mismatch: got=%d want=%d", got, tc.wantTotalCents)
+			}
+			if got := orderStore.lastParams.Total.Currency; got != "USD" {
+				t.Fatalf("currency should be normalized: got=%q want=%q", got, "USD")
+			}
+		})
+	}
+}
+
+func TestCreateOrder_InventoryReservation(t *testing.T) {
+	t.Parallel()
+
+	body := `{"user_id":"u_123","items":[{"sku":"sku_1","qty":3}],"currency":"USD"}`
+	persisted := PersistedOrder{OrderID: "o-stock", UserID: "u_123", Total: money.New(300, "USD")}
+
+	tests := []struct {
+		name             string
+		inventory        *stubInventory
+		heldKeys         []string
+		orderStore       *stubOrderStore
+		publisher        *stubPublisher
+		wantStatus       int
+		wantShortages    []StockShortage
+		wantCommitted    bool
+		wantReleased     bool
+		wantKeyReleased  bool
+		wantOrderCreated bool
+	}{
+		{
+			name: "insufficient stock",
+			inventory: &stubInventory{reserveErr: &InsufficientStockError{Shortages: []StockShortage{
+				{SKU: "sku_1", Requested: 3, Available: 1},
+			}}},
+			orderStore:      &stubOrderStore{persisted: persisted},
+			publisher:       &stubPublisher{},
+			wantStatus:      http.StatusConflict,
+			wantShortages:   []StockShortage{{SKU: "sku_1", Requested: 3, Available: 1}},
+			wantKeyReleased: true,
+		},
+		{
+			name:            "inventory unavailable",
+			inventory:       &stubInventory{reserveErr: errors.New("connection refused")},
+			orderStore:      &stubOrderStore{persisted: persisted},
+			publisher:       &stubPublisher{},
+			wantStatus:      http.StatusServiceUnavailable,
+			wantKeyReleased: true,
+		},
+		{
+			name:       "duplicate reserves no stock",
+			inventory:  &stubInventory{},
+			heldKeys:   []string{"u_123:idem-stock"},
+			orderStore: &stubOrderStore{persisted: persisted},
+			publisher:  &stubPublisher{},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:            "persistence failure releases stock",
+			inventory:       &stubInventory{},
+			orderStore:      &stubOrderStore{err: errors.New("db down")},
+			publisher:       &stubPublisher{},
+			wantStatus:      http.StatusServiceUnavailable,
+			wantReleased:    true,
+			wantKeyReleased: true,
+		},
+		{
+			name:            "publish failure removes the order and releases stock",
+			inventory:       &stubInventory{},
+			orderStore:      &stubOrderStore{persisted: persisted},
+			publisher:       &stubPublisher{err: errors.New("nats down")},
+			wantStatus:      http.StatusServiceUnavailable,
+			wantReleased:    true,
+			wantKeyReleased: true,
+		},
+		{
+			name:             "undeletable order keeps its stock",
+			inventory:        &stubInventory{},
+			orderStore:       &stubOrderStore{persisted: persisted, deleteErr: errors.New("db down")},
+			publisher:        &stubPublisher{err: errors.New("nats down")},
+			wantStatus:       http.StatusServiceUnavailable,
+			wantCommitted:    true,
+			wantOrderCreated: true,
+		},
+		{
+			name:             "success commits stock",
+			inventory:        &stubInventory{},
+			orderStore:       &stubOrderStore{persisted: persisted},
+			publisher:        &stubPublisher{},
+			wantStatus:       http.StatusCreated,
+			wantCommitted:    true,
+			wantOrderCreated: true,
+		},
+		{
+			name:             "commit failure still accepts order",
+			inventory:        &stubInventory{commitErr: errors.New("inventory down")},
+			orderStore:       &stubOrderStore{persisted: persisted},
+			publisher:        &stubPublisher{},
+			wantStatus:       http.StatusCreated,
+			wantCommitted:    true,
+			wantOrderCreated: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			idempotencyStore := &statefulIdempotencyStore{keys: map[string]struct{}{}}
+			for _, key := range tc.heldKeys {
+				idempotencyStore.keys[key] = struct{}{}
+			}
+			h := &Handler{
+				IdempotencyStore: idempotencyStore,
+				Catalog:          defaultStubCatalog(),
+				Inventory:        tc.inventory,
+				OrderStore:       tc.orderStore,
+				EventPublisher:   tc.publisher,
+			}
+			req := httptest.NewRequest(http.MethodPost, "/v1/orders", strings.NewReader(body))
+			req.Header.Set(idempotencyHeader, "idem-stock")
+			rec := httptest.NewRecorder()
+			h.CreateOrder(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status code mismatch: got=%d want=%d body=%q", rec.Code, tc.wantStatus, rec.Body.String())
+			}
+			if tc.wantShortages != nil {
+				var resp struct {
+					Code      string          `json:"code"`
+					Shortages []StockShortage `json:"shortages"`
+				}
+				if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+					t.Fatalf("decode conflict body: %v", err)
+				}
+				if resp.Code != codeInsufficientStock {
+					t.Fatalf("code mismatch: got=%q want=%q", resp.Code, codeInsufficientStock)
+				}
+				if len(resp.Shortages) != len(tc.wantShortages) || resp.Shortages[0] != tc.wantShortages[0] {
+					t.Fatalf("shortages mismatch: got=%+v want=%+v", resp.Shortages, tc.wantShortages)
+				}
+			}
+			if got := len(idempotencyStore.released) == 1; got != tc.wantKeyReleased {
+				t.Fatalf("key released mismatch: got=%v want=%v", idempotencyStore.released, tc.wantKeyReleased)
+			}
+			// An order left behind after a failure would hold no stock.
+			if gotOrder := tc.orderStore.calls > 0 && tc.orderStore.err == nil && len(tc.orderStore.deleted) == 0; gotOrder != tc.wantOrderCreated {
+				t.Fatalf("order created mismatch: got=%v (deleted=%v) want=%v", gotOrder, tc.orderStore.deleted, tc.wantOrderCreated)
+			}
+			if got := len(tc.inventory.committed) == 1; got != tc.wantCommitted {
+				t.Fatalf("committed mismatch: got=%v want=%v", tc.inventory.committed, tc.wantCommitted)
+			}
+			if got := len(tc.inventory.released) == 1; got != tc.wantReleased {
+				t.Fatalf("released mismatch: got=%v want=%v", tc.inventory.released, tc.wantReleased)
+			}
+			if tc.wantReleased && tc.wantCommitted {
+				t.Fatal("a reservation cannot be both committed and released")
+			}
+			if tc.heldKeys != nil {
+				if len(tc.inventory.reserved) != 0 {
+					t.Fatalf("duplicate should not reserve stock: got=%+v", tc.inventory.reserved)
+				}
+				return
+			}
+			if tc.inventory.reserveErr == nil && (len(tc.inventory.reserved) != 1 || tc.inventory.reserved[0].Qty != 3) {
+				t.Fatalf("reserved items mismatch: got=%+v", tc.inventory.reserved)
+			}
+		})
+	}
+}
+
+type stubCatalog struct {
+	prices map[string]catalog.Price
+	err    error
+}
+
+func defaultStubCatalog() *stubCatalog {
+	return &stubCatalog{prices: map[string]catalog.Price{
+		"sku_1":       {SKU: "sku_1", Name: "Widget", UnitPrice: money.New(100, "USD"), Active: true},
+		"sku_retired": {SKU: "sku_retired", Name: "Old Widget", UnitPrice: money.New(50, "USD"), Active: false},
+	}}
+}
+
+func (c *stubCatalog) LookupPrices(_ context.Context, currency money.Currency, skus []string) (map[string]catalog.Price, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	out := map[string]catalog.Price{}
+	for _, sku := range skus {
+		if p, ok := c.prices[sku]; ok && p.UnitPrice.Currency == currency {
+			out[sku] = p
+		}
+	}
+	return out, nil
+}
+
+type hashLookupIdempotencyStore struct {
+	hashes map[string]string
+}
+
+func (s *hashLookupIdempotencyStore) Reserve(_ context.Context, key string, _ time.Duration) (bool, error) {
+	_, exists := s.hashes[key]
+	return !exists, nil
+}
+
+func (s *hashLookupIdempotencyStore) LookupRequestHash(_ context.Context, key string) (string, bool, error) {
+	hash, ok := s.hashes[key]
+	return hash, ok, nil
+}
+
 type stubIdempotencyStore struct {
 	reserveResult bool
 	reserveErr    error
@@ -269,7 +1165,9 @@ func (s *stubIdempotencyStore) Reserve(_ context.Context, _ string, _ time.Durat
 }
 
 type statefulIdempotencyStore struct {
-	keys map[string]struct{}
+	keys      map[string]struct{}
+	responses map[string]IdempotencyResponse
+	released  []string
 }
 
 func (s *statefulIdempotencyStore) Reserve(_ context.Context, key string, _ time.Duration) (bool, error) {
@@ -280,14 +1178,64 @@ func (s *statefulIdempotencyStore) Reserve(_ context.Context, key string, _ time
 	return true, nil
 }
 
+func (s *statefulIdempotencyStore) RecordResponse(_ context.Context, key string, resp IdempotencyResponse) error {
+	if s.responses == nil {
+		s.responses = map[string]IdempotencyResponse{}
+	}
+	s.responses[key] = resp
+	return nil
+}
+
+func (s *statefulIdempotencyStore) LookupRequestHash(_ context.Context, key string) (string, bool, error) {
+	resp, ok := s.responses[key]
+	return resp.RequestHash, ok, nil
+}
+
+func (s *statefulIdempotencyStore) LookupResponse(_ context.Context, key string) (IdempotencyResponse, bool, error) {
+	resp, ok := s.responses[key]
+	return resp, ok, nil
+}
+
+func (s *statefulIdempotencyStore) Release(_ context.Context, key string) error {
+	if _, ok := s.responses[key]; !ok {
+		delete(s.keys, key)
+		s.released = append(s.released, key)
+	}
+	return nil
+}
+
 type stubOrderStore struct {
-	calls     int
-	persisted PersistedOrder
-	err       error
+	calls      int
+	lastParams CreateOrderParams
+	persisted  PersistedOrder
+	err        error
+	order      Order
+	getErr     error
+	deleted    []string
+	deleteErr  error
+}
+
+func (s *stubOrderStore) DeleteOrder(_ context.Context, orderID string) error {
+	if s.deleteErr != nil {
+		return s.deleteErr
+	}
+	s.deleted = append(s.deleted, orderID)
+	return nil
+}
+
+func (s *stubOrderStore) GetOrder(_ context.Context, orderID string) (Order, error) {
+	if s.getErr != nil {
+		return Order{}, s.getErr
+	}
+	if orderID != s.order.OrderID {
+		return Order{}, ErrOrderNotFound
+	}
+	return s.order, nil
 }
 
-func (s *stubOrderStore) CreateOrder(_ context.Context, _ CreateOrderParams) (PersistedOrder, error) {
+func (s *stubOrderStore) CreateOrder(_ context.Context, params CreateOrderParams) (PersistedOrder, error) {
 	s.calls++
+	s.lastParams = params
 	if s.err != nil {
 		return PersistedOrder{}, s.err
 	}
@@ -305,3 +1253,29 @@ func (p *stubPublisher) PublishOrdersCreated(_ context.Context, event OrdersCrea
 	p.lastEvent = event
 	return p.err
 }
+
+type stubInventory struct {
+	reserveErr error
+	commitErr  error
+	reserved   []OrderItem
+	committed  []string
+	released   []string
+}
+
+func (i *stubInventory) Reserve(_ context.Context, orderID string, items []OrderItem) (string, error) {
+	if i.reserveErr != nil {
+		return "", i.reserveErr
+	}
+	i.reserved = items
+	return "res-" + orderID, nil
+}
+
+func (i *stubInventory) Commit(_ context.Context, reservationID string) error {
+	i.committed = append(i.committed, reservationID)
+	return i.commitErr
+}
+
+func (i *stubInventory) Release(_ context.Context, reservationID string) error {
+	i.released = append(i.released, reservationID)
+	return nil
+}
@@ -0,0 +1,93 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+)
+
+// FallbackIdempotencyStore reserves keys in Primary and only consults Fallback
+// when Primary returns an error. Keys reserved in one store are not visible to
+// the other, so a retry that straddles a Primary outage may be accepted twice.
+type FallbackIdempotencyStore struct {
+	Primary  IdempotencyStore
+	Fallback IdempotencyStore
+	Metrics  *metricsx.Registry
+}
+
+func (s *FallbackIdempotencyStore) Reserve(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	reserved, err := s.Primary.Reserve(ctx, key, ttl)
+	if err == nil {
+		return reserved, nil
+	}
+	if s.Metrics != nil {
+		s.Metrics.Inc("idempotency_fallback_total")
+	}
+
+	reserved, fallbackErr := s.Fallback.Reserve(ctx, key, ttl)
+	if fallbackErr != nil {
+		return false, errors.Join(err, fallbackErr)
+	}
+	return reserved, nil
+}
+
+func (s *FallbackIdempotencyStore) RecordResponse(ctx context.Context, key string, resp IdempotencyResponse) error {
+	var errs []error
+	for _, store := range []IdempotencyStore{s.Primary, s.Fallback} {
+		if recorder, ok := store.(IdempotencyResponseRecorder); ok {
+			errs = append(errs, recorder.RecordResponse(ctx, key, resp))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *FallbackIdempotencyStore) LookupRequestHash(ctx context.Context, key string) (string, bool, error) {
+	var errs []error
+	for _, store := range []IdempotencyStore{s.Primary, s.Fallback} {
+		lookup, ok := store.(IdempotencyRequestHashLookup)
+		if !ok {
+			continue
+		}
+		hash, found, err := lookup.LookupRequestHash(ctx, key)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if found {
+			return hash, true, nil
+		}
+	}
+	return "", false, errors.Join(errs...)
+}
+
+func (s *FallbackIdempotencyStore) LookupResponse(ctx context.Context, key string) (IdempotencyResponse, bool, error) {
+	var errs []error
+	for _, store := range []IdempotencyStore{s.Primary, s.Fallback} {
+		lookup, ok := store.(IdempotencyResponseLookup)
+		if !ok {
+			continue
+		}
+		resp, found, err := lookup.LookupResponse(ctx, key)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if found {
+			return resp, true, nil
+		}
+	}
+	return IdempotencyResponse{}, false, errors.Join(errs...)
+}
+
+// Release gives the key up in both stores, since either may hold it.
+func (s *FallbackIdempotencyStore) Release(ctx context.Context, key string) error {
+	var errs []error
+	for _, store := range []IdempotencyStore{s.Primary, s.Fallback} {
+		if releaser, ok := store.(IdempotencyKeyReleaser); ok {
+			errs = append(errs, releaser.Release(ctx, key))
+		}
+	}
+	return errors.Join(errs...)
+}
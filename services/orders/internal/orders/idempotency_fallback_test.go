@@ -0,0 +1,131 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/triad-platform/triad-app/pkg/metricsx"
+)
+
+func TestFallbackIdempotencyStore_Reserve(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		primary       *recordingIdempotencyStore
+		fallback      *recordingIdempotencyStore
+		wantReserved  bool
+		wantErr       bool
+		wantFallbacks int
+	}{
+		{
+			name:          "primary reserves",
+			primary:       &recordingIdempotencyStore{reserveResult: true},
+			fallback:      &recordingIdempotencyStore{reserveResult: true},
+			wantReserved:  true,
+			wantFallbacks: 0,
+		},
+		{
+			name:          "primary reports duplicate",
+			primary:       &recordingIdempotencyStore{reserveResult: false},
+			fallback:      &recordingIdempotencyStore{reserveResult: true},
+			wantReserved:  false,
+			wantFallbacks: 0,
+		},
+		{
+			name:          "primary down uses fallback",
+			primary:       &recordingIdempotencyStore{reserveErr: errors.New("redis down")},
+			fallback:      &recordingIdempotencyStore{reserveResult: true},
+			wantReserved:  true,
+			wantFallbacks: 1,
+		},
+		{
+			name:          "both down",
+			primary:       &recordingIdempotencyStore{reserveErr: errors.New("redis down")},
+			fallback:      &recordingIdempotencyStore{reserveErr: errors.New("postgres down")},
+			wantReserved:  false,
+			wantErr:       true,
+			wantFallbacks: 1,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := &FallbackIdempotencyStore{Primary: tc.primary, Fallback: tc.fallback, Metrics: metricsx.NewRegistry("test")}
+			reserved, err := store.Reserve(context.Background(), "k", time.Minute)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("error mismatch: gotErr=%v wantErr=%v err=%v", err != nil, tc.wantErr, err)
+			}
+			if reserved != tc.wantReserved {
+				t.Fatalf("reserved mismatch: got=%v want=%v", reserved, tc.wantReserved)
+			}
+			if tc.fallback.reserveCalls != tc.wantFallbacks {
+				t.Fatalf("fallback calls mismatch: got=%d want=%d", tc.fallback.reserveCalls, tc.wantFallbacks)
+			}
+		})
+	}
+}
+
+func TestFallbackIdempotencyStore_RecordResponse(t *testing.T) {
+	t.Parallel()
+
+	primary := &stubIdempotencyStore{reserveResult: true}
+	fallback := &recordingIdempotencyStore{}
+	store := &FallbackIdempotencyStore{Primary: primary, Fallback: fallback}
+
+	resp := IdempotencyResponse{RequestHash: "abc", StatusCode: 201, Body: []byte(`{}`)}
+	if err := store.RecordResponse(context.Background(), "k", resp); err != nil {
+		t.Fatalf("record response failed: %v", err)
+	}
+	if fallback.recorded["k"].RequestHash != "abc" {
+		t.Fatalf("fallback recorder should receive response: got=%+v", fallback.recorded["k"])
+	}
+}
+
+func TestFallbackIdempotencyStore_ReplayAndRelease(t *testing.T) {
+	t.Parallel()
+
+	primary := &statefulIdempotencyStore{keys: map[string]struct{}{}}
+	fallback := &statefulIdempotencyStore{keys: map[string]struct{}{"k": {}}, responses: map[string]IdempotencyResponse{"k": {RequestHash: "abc", StatusCode: 201}}}
+	store := &FallbackIdempotencyStore{Primary: primary, Fallback: fallback}
+	ctx := context.Background()
+
+	resp, found, err := store.LookupResponse(ctx, "k")
+	if err != nil || !found || resp.RequestHash != "abc" {
+		t.Fatalf("lookup should find the fallback response: got=%+v found=%v err=%v", resp, found, err)
+	}
+
+	_, _ = primary.Reserve(ctx, "pending", time.Minute)
+	_, _ = fallback.Reserve(ctx, "pending", time.Minute)
+	if err := store.Release(ctx, "pending"); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	if len(primary.released) != 1 || len(fallback.released) != 1 {
+		t.Fatalf("release should reach both stores: primary=%v fallback=%v", primary.released, fallback.released)
+	}
+}
+
+type recordingIdempotencyStore struct {
+	reserveResult bool
+	reserveErr    error
+	reserveCalls  int
+	recorded      map[string]IdempotencyResponse
+}
+
+func (s *recordingIdempotencyStore) Reserve(_ context.Context, _ string, _ time.Duration) (bool, error) {
+	s.reserveCalls++
+	return s.reserveResult, s.reserveErr
+}
+
+func (s *recordingIdempotencyStore) RecordResponse(_ context.Context, key string, resp IdempotencyResponse) error {
+	if s.recorded == nil {
+		s.recorded = map[string]IdempotencyResponse{}
+	}
+	s.recorded[key] = resp
+	return nil
+}
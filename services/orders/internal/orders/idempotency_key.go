@@ -0,0 +1,64 @@
+package orders
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"regexp"
+
+	"github.com/triad-platform/triad-app/pkg/money"
+)
+
+const maxIdempotencyKeyLength = 128
+
+// ':' is reserved as the scope separator, so a client key can never be
+// crafted to look like another principal's scope.
+var idempotencyKeyPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+var (
+	errIdempotencyKeyTooLong = errors.New("Idempotency-Key exceeds 128 characters")
+	errIdempotencyKeyCharset = errors.New("Idempotency-Key may only contain letters, digits, '.', '_' and '-'")
+)
+
+func validateIdempotencyKey(key string) error {
+	if len(key) > maxIdempotencyKeyLength {
+		return errIdempotencyKeyTooLong
+	}
+	if !idempotencyKeyPattern.MatchString(key) {
+		return errIdempotencyKeyCharset
+	}
+	return nil
+}
+
+// scopedIdempotencyKey namespaces a client key by the principal that sent it so
+// two users picking the same key never collide.
+func scopedIdempotencyKey(principal, key string) string {
+	return principal + ":" + key
+}
+
+// hashCreateOrderRequest fingerprints the decoded request rather than the raw
+// body, so retries that only differ in whitespace or field order match.
+// Client-supplied prices are ignored when pricing, so they are not hashed either.
+func hashCreateOrderRequest(req CreateOrderRequest) string {
+	type canonicalItem struct {
+		SKU string `json:"sku"`
+		Qty int    `json:"qty"`
+	}
+	canonical := struct {
+		UserID   string          `json:"user_id"`
+		Currency money.Currency  `json:"currency"`
+		Items    []canonicalItem `json:"items"`
+	}{
+		UserID:   req.UserID,
+		Currency: req.Currency,
+		Items:    make([]canonicalItem, 0, len(req.Items)),
+	}
+	for _, item := range req.Items {
+		canonical.Items = append(canonical.Items, canonicalItem{SKU: item.SKU, Qty: item.Qty})
+	}
+
+	b, _ := json.Marshal(canonical)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
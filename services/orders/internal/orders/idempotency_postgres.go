@@ -0,0 +1,149 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+)
+
+type PostgresIdempotencyStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresIdempotencyStore(pool *pgxpool.Pool) *PostgresIdempotencyStore {
+	return &PostgresIdempotencyStore{pool: pool}
+}
+
+func (s *PostgresIdempotencyStore) EnsureSchema(ctx context.Context) error {
+	_, err := s.pool.Exec(ctx, `
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+	key TEXT PRIMARY KEY,
+	expires_at TIMESTAMPTZ NOT NULL,
+	request_hash TEXT,
+	response_status INTEGER,
+	response_body BYTEA,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
+`)
+	return err
+}
+
+// Reserve inserts the key, or takes over a row whose expiry has passed but has
+// not been cleaned up yet. A live row means the key is already in use.
+func (s *PostgresIdempotencyStore) Reserve(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	var reserved string
+	err := s.pool.QueryRow(ctx, `
+INSERT INTO idempotency_keys (key, expires_at)
+VALUES ($1, NOW() + make_interval(secs => $2))
+ON CONFLICT (key) DO UPDATE
+SET expires_at = EXCLUDED.expires_at,
+	request_hash = NULL,
+	response_status = NULL,
+	response_body = NULL,
+	created_at = NOW()
+WHERE idempotency_keys.expires_at <= NOW()
+RETURNING key`,
+		key, ttl.Seconds(),
+	).Scan(&reserved)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *PostgresIdempotencyStore) RecordResponse(ctx context.Context, key string, resp IdempotencyResponse) error {
+	_, err := s.pool.Exec(
+		ctx,
+		`UPDATE idempotency_keys SET request_hash = $2, response_status = $3, response_body = $4 WHERE key = $1`,
+		key, resp.RequestHash, resp.StatusCode, resp.Body,
+	)
+	return err
+}
+
+func (s *PostgresIdempotencyStore) LookupRequestHash(ctx context.Context, key string) (string, bool, error) {
+	var hash *string
+	err := s.pool.QueryRow(
+		ctx,
+		`SELECT request_hash FROM idempotency_keys WHERE key = $1 AND expires_at > NOW()`,
+		key,
+	).Scan(&hash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	if hash == nil {
+		return "", false, nil
+	}
+	return *hash, true, nil
+}
+
+// LookupResponse returns the response recorded for a live key; a key whose
+// request is still in flight has none.
+func (s *PostgresIdempotencyStore) LookupResponse(ctx context.Context, key string) (IdempotencyResponse, bool, error) {
+	var resp IdempotencyResponse
+	err := s.pool.QueryRow(
+		ctx,
+		`SELECT request_hash, response_status, response_body FROM idempotency_keys
+WHERE key = $1 AND expires_at > NOW() AND response_status IS NOT NULL`,
+		key,
+	).Scan(&resp.RequestHash, &resp.StatusCode, &resp.Body)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return IdempotencyResponse{}, false, nil
+	}
+	if err != nil {
+		return IdempotencyResponse{}, false, err
+	}
+	return resp, true, nil
+}
+
+// Release deletes a key whose request never recorded a response.
+func (s *PostgresIdempotencyStore) Release(ctx context.Context, key string) error {
+	_, err := s.pool.Exec(ctx, `DELETE FROM idempotency_keys WHERE key = $1 AND response_status IS NULL`, key)
+	return err
+}
+
+func (s *PostgresIdempotencyStore) DeleteExpired(ctx context.Context) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// RunCleanup deletes expired keys every interval until ctx is cancelled.
+func (s *PostgresIdempotencyStore) RunCleanup(ctx context.Context, interval time.Duration, log zerolog.Logger) {
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := s.DeleteExpired(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Error().Err(err).Msg("idempotency key cleanup failed")
+				}
+				continue
+			}
+			if deleted > 0 {
+				log.Info().Int64("deleted", deleted).Msg("expired idempotency keys removed")
+			}
+		}
+	}
+}
@@ -2,16 +2,39 @@ package orders
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+// reservedMarker is the value held by a key whose first request has not
+// finished yet; it is replaced by the recorded response once that is known.
+const reservedMarker = "1"
+
+// releaseReserved deletes a key only while it still holds reservedMarker, so
+// a release never drops a response recorded by a concurrent request.
+var releaseReserved = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
 type RedisIdempotencyStore struct {
 	client *redis.Client
 	prefix string
 }
 
+// redisIdempotencyRecord is the value stored for a finished request. Keys
+// written before responses were recorded hold the bare request hash.
+type redisIdempotencyRecord struct {
+	RequestHash string `json:"request_hash"`
+	StatusCode  int    `json:"status_code"`
+	Body        []byte `json:"body"`
+}
+
 func NewRedisIdempotencyStore(client *redis.Client, prefix string) *RedisIdempotencyStore {
 	return &RedisIdempotencyStore{
 		client: client,
@@ -20,5 +43,69 @@ func NewRedisIdempotencyStore(client *redis.Client, prefix string) *RedisIdempot
 }
 
 func (s *RedisIdempotencyStore) Reserve(ctx context.Context, key string, ttl time.Duration) (bool, error) {
-	return s.client.SetNX(ctx, s.prefix+key, "1", ttl).Result()
+	return s.client.SetNX(ctx, s.prefix+key, reservedMarker, ttl).Result()
+}
+
+func (s *RedisIdempotencyStore) RecordResponse(ctx context.Context, key string, resp IdempotencyResponse) error {
+	value, err := json.Marshal(redisIdempotencyRecord{
+		RequestHash: resp.RequestHash,
+		StatusCode:  resp.StatusCode,
+		Body:        resp.Body,
+	})
+	if err != nil {
+		return err
+	}
+	err = s.client.SetArgs(ctx, s.prefix+key, value, redis.SetArgs{
+		Mode:    "XX",
+		KeepTTL: true,
+	}).Err()
+	if errors.Is(err, redis.Nil) {
+		// Key expired between reserve and record; nothing left to annotate.
+		return nil
+	}
+	return err
+}
+
+func (s *RedisIdempotencyStore) LookupRequestHash(ctx context.Context, key string) (string, bool, error) {
+	v, found, err := s.get(ctx, key)
+	if err != nil || !found {
+		return "", false, err
+	}
+	var record redisIdempotencyRecord
+	if err := json.Unmarshal([]byte(v), &record); err != nil {
+		return v, true, nil
+	}
+	return record.RequestHash, true, nil
+}
+
+func (s *RedisIdempotencyStore) LookupResponse(ctx context.Context, key string) (IdempotencyResponse, bool, error) {
+	v, found, err := s.get(ctx, key)
+	if err != nil || !found {
+		return IdempotencyResponse{}, false, err
+	}
+	var record redisIdempotencyRecord
+	if err := json.Unmarshal([]byte(v), &record); err != nil || record.StatusCode == 0 {
+		// A bare request hash carries no response to replay.
+		return IdempotencyResponse{}, false, nil
+	}
+	return IdempotencyResponse{RequestHash: record.RequestHash, StatusCode: record.StatusCode, Body: record.Body}, true, nil
+}
+
+func (s *RedisIdempotencyStore) Release(ctx context.Context, key string) error {
+	return releaseReserved.Run(ctx, s.client, []string{s.prefix + key}, reservedMarker).Err()
+}
+
+// get returns the value of a key that holds a finished request.
+func (s *RedisIdempotencyStore) get(ctx context.Context, key string) (string, bool, error) {
+	v, err := s.client.Get(ctx, s.prefix+key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	if v == reservedMarker {
+		return "", false, nil
+	}
+	return v, true, nil
 }
@@ -0,0 +1,116 @@
+package orders
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// HTTPInventoryClient talks to the inventory service's reservation API.
+type HTTPInventoryClient struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+func NewHTTPInventoryClient(baseURL string, timeout time.Duration) *HTTPInventoryClient {
+	return &HTTPInventoryClient{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Client:  &http.Client{Timeout: timeout},
+	}
+}
+
+type inventoryReserveItem struct {
+	SKU string `json:"sku"`
+	Qty int    `json:"qty"`
+}
+
+type inventoryReserveRequest struct {
+	OrderID string                 `json:"order_id"`
+	Items   []inventoryReserveItem `json:"items"`
+}
+
+type inventoryReservation struct {
+	ID string `json:"reservation_id"`
+}
+
+type inventoryConflict struct {
+	Shortages []StockShortage `json:"shortages"`
+}
+
+func (c *HTTPInventoryClient) Reserve(ctx context.Context, orderID string, items []OrderItem) (string, error) {
+	payload := inventoryReserveRequest{OrderID: orderID, Items: make([]inventoryReserveItem, 0, len(items))}
+	for _, item := range items {
+		payload.Items = append(payload.Items, inventoryReserveItem{SKU: item.SKU, Qty: item.Qty})
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("marshal reserve payload: %w", err)
+	}
+
+	resp, err := c.post(ctx, "/v1/reservations", body)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		var reservation inventoryReservation
+		if err := json.NewDecoder(resp.Body).Decode(&reservation); err != nil {
+			return "", fmt.Errorf("decode reservation: %w", err)
+		}
+		if reservation.ID == "" {
+			return "", fmt.Errorf("reserve: response has no reservation_id")
+		}
+		return reservation.ID, nil
+	case http.StatusConflict:
+		var conflict inventoryConflict
+		if err := json.NewDecoder(resp.Body).Decode(&conflict); err != nil {
+			return "", fmt.Errorf("decode insufficient stock response: %w", err)
+		}
+		return "", &InsufficientStockError{Shortages: conflict.Shortages}
+	default:
+		return "", fmt.Errorf("reserve failed: unexpected status %d", resp.StatusCode)
+	}
+}
+
+func (c *HTTPInventoryClient) Commit(ctx context.Context, reservationID string) error {
+	return c.transition(ctx, reservationID, "commit")
+}
+
+func (c *HTTPInventoryClient) Release(ctx context.Context, reservationID string) error {
+	return c.transition(ctx, reservationID, "release")
+}
+
+func (c *HTTPInventoryClient) transition(ctx context.Context, reservationID, action string) error {
+	resp, err := c.post(ctx, "/v1/reservations/"+url.PathEscape(reservationID)+"/"+action, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s reservation failed: unexpected status %d", action, resp.StatusCode)
+	}
+	return nil
+}
+
+func (c *HTTPInventoryClient) post(ctx context.Context, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create inventory request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("send inventory request: %w", err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,102 @@
+package orders
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPInventoryClient_Reserve(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		wantID    string
+		wantStock bool
+		wantErr   bool
+	}{
+		{name: "reserved", status: http.StatusCreated, body: `{"reservation_id":"r-1","status":"pending"}`, wantID: "r-1"},
+		{name: "insufficient stock", status: http.StatusConflict, body: `{"code":"insufficient_stock","shortages":[{"sku":"sku_1","requested":2,"available":0}]}`, wantStock: true, wantErr: true},
+		{name: "server error", status: http.StatusServiceUnavailable, body: "reservation failed", wantErr: true},
+		{name: "missing id", status: http.StatusCreated, body: `{}`, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got inventoryReserveRequest
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != "/v1/reservations" {
+					t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+				}
+				_ = json.NewDecoder(r.Body).Decode(&got)
+				w.WriteHeader(tc.status)
+				_, _ = w.Write([]byte(tc.body))
+			}))
+			defer srv.Close()
+
+			client := NewHTTPInventoryClient(srv.URL+"/", time.Second)
+			id, err := client.Reserve(context.Background(), "o-1", []OrderItem{{SKU: "sku_1", Qty: 2}})
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("error mismatch: err=%v wantErr=%v", err, tc.wantErr)
+			}
+			var insufficient *InsufficientStockError
+			if errors.As(err, &insufficient) != tc.wantStock {
+				t.Fatalf("insufficient stock mismatch: err=%v", err)
+			}
+			if tc.wantStock && (len(insufficient.Shortages) != 1 || insufficient.Shortages[0].SKU != "sku_1") {
+				t.Fatalf("shortages mismatch: got=%+v", insufficient.Shortages)
+			}
+			if id != tc.wantID {
+				t.Fatalf("reservation id mismatch: got=%q want=%q", id, tc.wantID)
+			}
+			if got.OrderID != "o-1" || len(got.Items) != 1 || got.Items[0].Qty != 2 {
+				t.Fatalf("request payload mismatch: got=%+v", got)
+			}
+		})
+	}
+}
+
+func TestHTTPInventoryClient_CommitAndRelease(t *testing.T) {
+	t.Parallel()
+
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path == "/v1/reservations/r-closed/commit" {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := NewHTTPInventoryClient(srv.URL, time.Second)
+	if err := client.Commit(context.Background(), "r-1"); err != nil {
+		t.Fatalf("commit returned error: %v", err)
+	}
+	if err := client.Release(context.Background(), "r-2"); err != nil {
+		t.Fatalf("release returned error: %v", err)
+	}
+	if err := client.Commit(context.Background(), "r-closed"); err == nil {
+		t.Fatal("commit of a closed reservation should fail")
+	}
+
+	want := []string{"/v1/reservations/r-1/commit", "/v1/reservations/r-2/release", "/v1/reservations/r-closed/commit"}
+	if len(paths) != len(want) {
+		t.Fatalf("paths mismatch: got=%v want=%v", paths, want)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Fatalf("paths mismatch: got=%v want=%v", paths, want)
+		}
+	}
+}
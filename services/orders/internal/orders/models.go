@@ -1,61 +1,82 @@
 package orders
 
-import "encoding/json"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/triad-platform/triad-app/pkg/money"
+)
 
 const OrdersCreatedSubject = "orders.created.v1"
 
 type CreateOrderRequest struct {
-	UserID   string      `json:"user_id"`
-	Items    []OrderItem `json:"items"`
-	Currency string      `json:"currency"`
+	UserID   string         `json:"user_id"`
+	Items    []OrderItem    `json:"items"`
+	Currency money.Currency `json:"currency"`
 }
 
 type OrderItem struct {
-	SKU        string `json:"sku"`
-	Qty        int    `json:"-"`
-	PriceCents int    `json:"-"`
-}
-
-func (o *OrderItem) UnmarshalJSON(data []byte) error {
-	type rawOrderItem struct {
-		SKU        string `json:"sku"`
-		Qty        int    `json:"qty"`
-		Quantity   int    `json:"quantity"`
-		PriceCents int    `json:"price_cents"`
-		UnitPrice  int    `json:"unit_price"`
-	}
+	SKU string `json:"sku"`
+	Qty int    `json:"qty"`
+	// UnitPrice is filled from the catalog; prices sent by clients are ignored.
+	UnitPrice money.Money `json:"-"`
+}
 
-	var raw rawOrderItem
-	if err := json.Unmarshal(data, &raw); err != nil {
-		return err
-	}
+// ErrOrderNotFound is returned by order lookups for unknown IDs.
+var ErrOrderNotFound = errors.New("order not found")
 
-	o.SKU = raw.SKU
-	if raw.Quantity > 0 {
-		o.Qty = raw.Quantity
-	} else {
-		o.Qty = raw.Qty
-	}
-	if raw.UnitPrice > 0 {
-		o.PriceCents = raw.UnitPrice
-	} else {
-		o.PriceCents = raw.PriceCents
-	}
-	return nil
+// Order is the read model returned by GET /v1/orders/{id}.
+type Order struct {
+	OrderID   string      `json:"order_id"`
+	UserID    string      `json:"user_id"`
+	Status    string      `json:"status"`
+	Total     money.Money `json:"total"`
+	Items     []OrderLine `json:"items"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+type OrderLine struct {
+	SKU       string      `json:"sku"`
+	Qty       int         `json:"qty"`
+	UnitPrice money.Money `json:"unit_price"`
 }
 
 type CreateOrderResponse struct {
-	OrderID string `json:"order_id"`
-	Status  string `json:"status"`
+	OrderID        string `json:"order_id"`
+	Status         string `json:"status"`
+	IdempotencyKey string `json:"idempotency_key"`
 }
 
 type OrdersCreatedEvent struct {
-	Type       string `json:"type"`
-	Version    int    `json:"version"`
-	OrderID    string `json:"order_id"`
-	UserID     string `json:"user_id"`
-	RequestID  string `json:"request_id"`
-	TotalCents int    `json:"total_cents"`
-	Currency   string `json:"currency"`
-	CreatedAt  string `json:"created_at"`
+	Type      string `json:"type"`
+	Version   int    `json:"version"`
+	OrderID   string `json:"order_id"`
+	UserID    string `json:"user_id"`
+	RequestID string `json:"request_id"`
+	// TotalCents is in the minor units of Currency; the name predates non-cent currencies.
+	TotalCents int64          `json:"total_cents"`
+	Currency   money.Currency `json:"currency"`
+	CreatedAt  string         `json:"created_at"`
+}
+
+type StockShortage struct {
+	SKU       string `json:"sku"`
+	Requested int    `json:"requested"`
+	Available int    `json:"available"`
+}
+
+// InsufficientStockError is returned by an InventoryClient when at least one
+// item cannot be reserved; nothing is held in that case.
+type InsufficientStockError struct {
+	Shortages []StockShortage
+}
+
+func (e *InsufficientStockError) Error() string {
+	parts := make([]string, 0, len(e.Shortages))
+	for _, s := range e.Shortages {
+		parts = append(parts, fmt.Sprintf("%s (requested %d, available %d)", s.SKU, s.Requested, s.Available))
+	}
+	return "insufficient stock: " + strings.Join(parts, ", ")
 }
@@ -0,0 +1,46 @@
+package orders
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// OrderCursor is the position of the last order on a page; orders are sorted
+// by creation time and then ID, both descending.
+type OrderCursor struct {
+	CreatedAt time.Time
+	OrderID   string
+}
+
+type pageToken struct {
+	CreatedAt time.Time `json:"t"`
+	OrderID   string    `json:"id"`
+}
+
+var errMalformedPageToken = errors.New("malformed page token")
+
+// encodePageToken makes an opaque token so clients do not come to depend on
+// the cursor's shape.
+func encodePageToken(c OrderCursor) string {
+	b, _ := json.Marshal(pageToken{CreatedAt: c.CreatedAt.UTC(), OrderID: c.OrderID})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodePageToken returns nil for the empty token, which starts at the newest
+// order.
+func decodePageToken(token string) (*OrderCursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, errMalformedPageToken
+	}
+	var t pageToken
+	if err := json.Unmarshal(b, &t); err != nil || t.OrderID == "" || t.CreatedAt.IsZero() {
+		return nil, errMalformedPageToken
+	}
+	return &OrderCursor{CreatedAt: t.CreatedAt, OrderID: t.OrderID}, nil
+}
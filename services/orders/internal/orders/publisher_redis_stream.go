@@ -0,0 +1,42 @@
+package orders
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type RedisStreamEventPublisher struct {
+	client *redis.Client
+	stream string
+	maxLen int64
+}
+
+func NewRedisStreamEventPublisher(client *redis.Client, stream string, maxLen int64) *RedisStreamEventPublisher {
+	if stream == "" {
+		stream = OrdersCreatedSubject
+	}
+	return &RedisStreamEventPublisher{
+		client: client,
+		stream: stream,
+		maxLen: maxLen,
+	}
+}
+
+func (p *RedisStreamEventPublisher) PublishOrdersCreated(ctx context.Context, event OrdersCreatedEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	// Approximate trimming keeps XADD O(1); consumers must keep up within maxLen entries.
+	return p.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: p.stream,
+		MaxLen: p.maxLen,
+		Approx: p.maxLen > 0,
+		Values: map[string]any{
+			"type":    event.Type,
+			"payload": payload,
+		},
+	}).Err()
+}
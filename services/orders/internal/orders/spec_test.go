@@ -0,0 +1,44 @@
+package orders
+
+import (
+	"net/http"
+	"slices"
+	"sort"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/triad-platform/triad-app/contracts/api"
+	"github.com/triad-platform/triad-app/pkg/openapi"
+)
+
+// TestRoutes_MatchOpenAPISpec fails when orders.Routes and the operations the
+// public spec tags "orders" drift apart.
+func TestRoutes_MatchOpenAPISpec(t *testing.T) {
+	t.Parallel()
+
+	doc, err := openapi.Parse(api.OpenAPI)
+	if err != nil {
+		t.Fatalf("parse spec: %v", err)
+	}
+	var want []string
+	for _, op := range doc.Operations() {
+		if slices.Contains(op.Tags, "orders") {
+			want = append(want, op.Method+" "+op.Path)
+		}
+	}
+
+	var got []string
+	walk := func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		got = append(got, method+" "+route)
+		return nil
+	}
+	if err := chi.Walk(Routes(&Handler{}), walk); err != nil {
+		t.Fatalf("walk routes: %v", err)
+	}
+	sort.Strings(want)
+	sort.Strings(got)
+
+	if !slices.Equal(got, want) {
+		t.Fatalf("routes and spec drifted: routes=%v spec=%v", got, want)
+	}
+}
@@ -2,41 +2,43 @@ package orders
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/triad-platform/triad-app/pkg/money"
 )
 
 type CreateOrderParams struct {
-	OrderID  string
-	UserID   string
-	Items    []OrderItem
-	Currency string
+	OrderID string
+	UserID  string
+	Items   []OrderItem
+	Total   money.Money
 }
 
 type PersistedOrder struct {
-	OrderID    string
-	UserID     string
-	TotalCents int
-	Currency   string
-	CreatedAt  time.Time
+	OrderID   string
+	UserID    string
+	Total     money.Money
+	CreatedAt time.Time
 }
 
 type PostgresOrderStore struct {
-	conn *pgx.Conn
+	pool *pgxpool.Pool
 }
 
-func NewPostgresOrderStore(conn *pgx.Conn) *PostgresOrderStore {
-	return &PostgresOrderStore{conn: conn}
+func NewPostgresOrderStore(pool *pgxpool.Pool) *PostgresOrderStore {
+	return &PostgresOrderStore{pool: pool}
 }
 
 func (s *PostgresOrderStore) EnsureSchema(ctx context.Context) error {
-	_, err := s.conn.Exec(ctx, `
+	_, err := s.pool.Exec(ctx, `
 CREATE TABLE IF NOT EXISTS orders (
 	id TEXT PRIMARY KEY,
 	user_id TEXT NOT NULL,
-	total_cents INTEGER NOT NULL,
+	total_cents BIGINT NOT NULL,
 	currency TEXT NOT NULL,
 	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
@@ -46,15 +48,35 @@ CREATE TABLE IF NOT EXISTS order_items (
 	order_id TEXT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
 	sku TEXT NOT NULL,
 	qty INTEGER NOT NULL,
-	price_cents INTEGER NOT NULL
+	price_cents BIGINT NOT NULL
 );
+
+CREATE INDEX IF NOT EXISTS orders_user_created_idx ON orders (user_id, created_at DESC, id DESC);
+
+-- Widen amount columns created before money moved to int64 minor units.
+DO $$
+BEGIN
+	IF EXISTS (
+		SELECT 1 FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = 'orders'
+			AND column_name = 'total_cents' AND data_type = 'integer'
+	) THEN
+		ALTER TABLE orders ALTER COLUMN total_cents TYPE BIGINT;
+	END IF;
+	IF EXISTS (
+		SELECT 1 FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = 'order_items'
+			AND column_name = 'price_cents' AND data_type = 'integer'
+	) THEN
+		ALTER TABLE order_items ALTER COLUMN price_cents TYPE BIGINT;
+	END IF;
+END $$;
 `)
 	return err
 }
 
 func (s *PostgresOrderStore) CreateOrder(ctx context.Context, params CreateOrderParams) (PersistedOrder, error) {
-	totalCents := calculateTotalCents(params.Items)
-	tx, err := s.conn.Begin(ctx)
+	tx, err := s.pool.Begin(ctx)
 	if err != nil {
 		return PersistedOrder{}, err
 	}
@@ -64,7 +86,7 @@ func (s *PostgresOrderStore) CreateOrder(ctx context.Context, params CreateOrder
 	err = tx.QueryRow(
 		ctx,
 		`INSERT INTO orders (id, user_id, total_cents, currency) VALUES ($1, $2, $3, $4) RETURNING created_at`,
-		params.OrderID, params.UserID, totalCents, params.Currency,
+		params.OrderID, params.UserID, params.Total.Minor, params.Total.Currency,
 	).Scan(&createdAt)
 	if err != nil {
 		return PersistedOrder{}, err
@@ -74,7 +96,7 @@ func (s *PostgresOrderStore) CreateOrder(ctx context.Context, params CreateOrder
 		_, err = tx.Exec(
 			ctx,
 			`INSERT INTO order_items (order_id, sku, qty, price_cents) VALUES ($1, $2, $3, $4)`,
-			params.OrderID, item.SKU, item.Qty, item.PriceCents,
+			params.OrderID, item.SKU, item.Qty, item.UnitPrice.Minor,
 		)
 		if err != nil {
 			return PersistedOrder{}, err
@@ -86,10 +108,104 @@ func (s *PostgresOrderStore) CreateOrder(ctx context.Context, params CreateOrder
 	}
 
 	return PersistedOrder{
-		OrderID:    params.OrderID,
-		UserID:     params.UserID,
-		TotalCents: totalCents,
-		Currency:   params.Currency,
-		CreatedAt:  createdAt,
+		OrderID:   params.OrderID,
+		UserID:    params.UserID,
+		Total:     params.Total,
+		CreatedAt: createdAt,
 	}, nil
 }
+
+// DeleteOrder removes the order and, by cascade, its items.
+func (s *PostgresOrderStore) DeleteOrder(ctx context.Context, orderID string) error {
+	_, err := s.pool.Exec(ctx, `DELETE FROM orders WHERE id = $1`, orderID)
+	return err
+}
+
+func (s *PostgresOrderStore) GetOrder(ctx context.Context, orderID string) (Order, error) {
+	order := Order{OrderID: orderID, Status: "created"}
+	err := s.pool.QueryRow(
+		ctx,
+		`SELECT user_id, total_cents, currency, created_at FROM orders WHERE id = $1`,
+		orderID,
+	).Scan(&order.UserID, &order.Total.Minor, &order.Total.Currency, &order.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Order{}, ErrOrderNotFound
+	}
+	if err != nil {
+		return Order{}, err
+	}
+
+	rows, err := s.pool.Query(
+		ctx,
+		`SELECT sku, qty, price_cents FROM order_items WHERE order_id = $1 ORDER BY id`,
+		orderID,
+	)
+	if err != nil {
+		return Order{}, err
+	}
+	defer rows.Close()
+	order.Items = []OrderLine{}
+	for rows.Next() {
+		line := OrderLine{UnitPrice: money.Zero(order.Total.Currency)}
+		if err := rows.Scan(&line.SKU, &line.Qty, &line.UnitPrice.Minor); err != nil {
+			return Order{}, err
+		}
+		order.Items = append(order.Items, line)
+	}
+	return order, rows.Err()
+}
+
+func (s *PostgresOrderStore) ListOrders(ctx context.Context, userID string, limit int, after *OrderCursor) ([]Order, error) {
+	query := `SELECT id, user_id, total_cents, currency, created_at FROM orders WHERE user_id = $1`
+	args := []any{userID}
+	if after != nil {
+		query += ` AND (created_at, id) < ($2, $3)`
+		args = append(args, after.CreatedAt, after.OrderID)
+	}
+	query += fmt.Sprintf(` ORDER BY created_at DESC, id DESC LIMIT %d`, limit)
+
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	orders := []Order{}
+	index := map[string]int{}
+	ids := []string{}
+	for rows.Next() {
+		order := Order{Status: "created", Items: []OrderLine{}}
+		if err := rows.Scan(&order.OrderID, &order.UserID, &order.Total.Minor, &order.Total.Currency, &order.CreatedAt); err != nil {
+			return nil, err
+		}
+		index[order.OrderID] = len(orders)
+		ids = append(ids, order.OrderID)
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(orders) == 0 {
+		return orders, nil
+	}
+
+	itemRows, err := s.pool.Query(
+		ctx,
+		`SELECT order_id, sku, qty, price_cents FROM order_items WHERE order_id = ANY($1) ORDER BY id`,
+		ids,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer itemRows.Close()
+	for itemRows.Next() {
+		var orderID string
+		var line OrderLine
+		if err := itemRows.Scan(&orderID, &line.SKU, &line.Qty, &line.UnitPrice.Minor); err != nil {
+			return nil, err
+		}
+		order := &orders[index[orderID]]
+		line.UnitPrice.Currency = order.Total.Currency
+		order.Items = append(order.Items, line)
+	}
+	return orders, itemRows.Err()
+}
@@ -0,0 +1,102 @@
+package orders
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/triad-platform/triad-app/pkg/httpx"
+	"github.com/triad-platform/triad-app/pkg/money"
+)
+
+// errMalformedJSON reports a body that is not a JSON object at all, as opposed
+// to well-formed JSON holding values of the wrong type.
+var errMalformedJSON = errors.New("invalid json")
+
+// decodeCreateOrderRequest decodes body field by field so a value of the wrong
+// type is reported against its path, including the item index, instead of
+// failing the whole body.
+func decodeCreateOrderRequest(body io.Reader) (CreateOrderRequest, []httpx.FieldError, error) {
+	var raw struct {
+		UserID   json.RawMessage `json:"user_id"`
+		Items    json.RawMessage `json:"items"`
+		Currency json.RawMessage `json:"currency"`
+	}
+	if err := json.NewDecoder(body).Decode(&raw); err != nil {
+		return CreateOrderRequest{}, nil, errMalformedJSON
+	}
+
+	var req CreateOrderRequest
+	var errs []httpx.FieldError
+	if !isNull(raw.UserID) {
+		if err := json.Unmarshal(raw.UserID, &req.UserID); err != nil {
+			errs = append(errs, typeError("user_id", err))
+		}
+	}
+	if !isNull(raw.Currency) {
+		if err := json.Unmarshal(raw.Currency, &req.Currency); err != nil {
+			if errors.Is(err, money.ErrUnknownCurrency) {
+				errs = append(errs, httpx.FieldError{Field: "currency", Code: httpx.FieldUnsupported, Detail: "unsupported currency"})
+			} else {
+				errs = append(errs, typeError("currency", err))
+			}
+		}
+	}
+	if !isNull(raw.Items) {
+		var items []json.RawMessage
+		if err := json.Unmarshal(raw.Items, &items); err != nil {
+			errs = append(errs, typeError("items", err))
+		}
+		for i, rawItem := range items {
+			var item OrderItem
+			if err := json.Unmarshal(rawItem, &item); err != nil {
+				field := fmt.Sprintf("items[%d]", i)
+				var typeErr *json.UnmarshalTypeError
+				if errors.As(err, &typeErr) && typeErr.Field != "" {
+					field += "." + typeErr.Field
+				}
+				errs = append(errs, typeError(field, err))
+				continue
+			}
+			req.Items = append(req.Items, item)
+		}
+	}
+	return req, errs, nil
+}
+
+// validate reports every missing or out-of-range field of a decoded request.
+func (r CreateOrderRequest) validate() []httpx.FieldError {
+	var errs []httpx.FieldError
+	if r.UserID == "" {
+		errs = append(errs, httpx.FieldError{Field: "user_id", Code: httpx.FieldRequired})
+	}
+	if r.Currency.IsZero() {
+		errs = append(errs, httpx.FieldError{Field: "currency", Code: httpx.FieldRequired})
+	}
+	if len(r.Items) == 0 {
+		errs = append(errs, httpx.FieldError{Field: "items", Code: httpx.FieldRequired, Detail: "at least one item is required"})
+	}
+	for i, item := range r.Items {
+		if item.SKU == "" {
+			errs = append(errs, httpx.FieldError{Field: fmt.Sprintf("items[%d].sku", i), Code: httpx.FieldRequired})
+		}
+		if item.Qty <= 0 {
+			errs = append(errs, httpx.FieldError{Field: fmt.Sprintf("items[%d].qty", i), Code: httpx.FieldNotPositive})
+		}
+	}
+	return errs
+}
+
+func typeError(field string, err error) httpx.FieldError {
+	fe := httpx.FieldError{Field: field, Code: httpx.FieldInvalidType}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		fe.Detail = "expected " + typeErr.Type.String() + ", got " + typeErr.Value
+	}
+	return fe
+}
+
+func isNull(raw json.RawMessage) bool {
+	return len(raw) == 0 || string(raw) == "null"
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -19,7 +20,12 @@ import (
 	workerpkg "github.com/triad-platform/triad-app/services/worker/internal/worker"
 )
 
-const ordersCreatedSubject = "orders.created.v1"
+const (
+	ordersCreatedSubject = "orders.created.v1"
+
+	transportNATS         = "nats"
+	transportRedisStreams = "redis-streams"
+)
 
 func natsURL() string {
 	return config.Getenv("NATS_URL", "nats://localhost:4222")
@@ -29,6 +35,56 @@ func notificationsURL() string {
 	return config.Getenv("NOTIFICATIONS_URL", "http://localhost:8082")
 }
 
+func eventTransport() string {
+	return strings.ToLower(strings.TrimSpace(config.Getenv("EVENT_TRANSPORT", transportNATS)))
+}
+
+func redisStreamConsumer() string {
+	if name := strings.TrimSpace(os.Getenv("REDIS_STREAM_CONSUMER")); name != "" {
+		return name
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return host
+	}
+	return "worker"
+}
+
+func redisStreamMaxLen() int64 {
+	n, err := strconv.ParseInt(config.Getenv("REDIS_STREAM_MAXLEN", "100000"), 10, 64)
+	if err != nil || n < 0 {
+		return 100000
+	}
+	return n
+}
+
+func redisStreamMaxDeliveries() int64 {
+	n, err := strconv.ParseInt(config.Getenv("REDIS_STREAM_MAX_DELIVERIES", "5"), 10, 64)
+	if err != nil || n <= 0 {
+		return 5
+	}
+	return n
+}
+
+func processingTTL() time.Duration {
+	ttl, err := time.ParseDuration(config.Getenv("PROCESSING_TTL", "168h"))
+	if err != nil || ttl <= 0 {
+		return 7 * 24 * time.Hour
+	}
+	return ttl
+}
+
+// notifyTimeout bounds one POST /v1/notify. Notifications delivers before it
+// answers, retrying each target, so the default covers a webhook and an email
+// target each spending NOTIFY_MAX_ATTEMPTS (3) tries at their default
+// timeouts (5s and 10s) plus backoff.
+func notifyTimeout() time.Duration {
+	d, err := time.ParseDuration(config.Getenv("NOTIFY_TIMEOUT", "60s"))
+	if err != nil || d <= 0 {
+		return 60 * time.Second
+	}
+	return d
+}
+
 func metricsPort() string {
 	return config.Getenv("WORKER_METRICS_PORT", "9091")
 }
@@ -37,7 +93,7 @@ func main() {
 	log := logx.New()
 	metrics := metricsx.NewRegistry("triad_worker")
 
-	_ = natsURL()
+	transport := eventTransport()
 	redisAddr := config.Getenv("REDIS_ADDR", "localhost:6379")
 
 	redisOptions := &redis.Options{
@@ -56,31 +112,56 @@ func main() {
 	redisClient := redis.NewClient(redisOptions)
 	defer redisClient.Close()
 
+	processing := workerpkg.NewRedisProcessingStore(redisClient, "worker:processing:", processingTTL())
 	processor := &workerpkg.Processor{
 		IdempotencyStore: workerpkg.NewRedisIdempotencyStore(redisClient, ""),
-		Notifier:         workerpkg.NewHTTPNotifier(notificationsURL(), 3*time.Second),
+		ProcessingStore:  processing,
+		Notifier:         workerpkg.NewHTTPNotifier(notificationsURL(), notifyTimeout()),
 		Metrics:          metrics,
 		KeyPrefix:        "worker:orders-created:",
 		IdempotencyTTL:   24 * time.Hour,
 	}
 
-	nc, err := nats.Connect(natsURL())
-	if err != nil {
-		log.Fatal().Err(err).Msg("failed to connect to NATS")
-	}
-	defer nc.Close()
-
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	errCh := make(chan error, 1)
-	go func() {
-		errCh <- workerpkg.RunNATSSubscriber(ctx, nc, ordersCreatedSubject, processor, log)
-	}()
+	switch transport {
+	case transportNATS:
+		nc, err := nats.Connect(natsURL())
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to connect to NATS")
+		}
+		defer nc.Close()
+		processor.StatusPublisher = workerpkg.NewNATSStatusPublisher(nc, workerpkg.OrderStatusSubject)
+		go func() {
+			errCh <- workerpkg.RunNATSSubscriber(ctx, nc, ordersCreatedSubject, processor, log)
+		}()
+	case transportRedisStreams:
+		processor.StatusPublisher = workerpkg.NewRedisStreamStatusPublisher(redisClient, workerpkg.OrderStatusSubject, redisStreamMaxLen())
+		streamCfg := workerpkg.RedisStreamConfig{
+			Stream:   ordersCreatedSubject,
+			Group:    config.Getenv("REDIS_STREAM_GROUP", "worker"),
+			Consumer: redisStreamConsumer(),
+			// Entries are not reclaimed while their notify call may still be
+			// running on another consumer.
+			MinIdle:       notifyTimeout() + 30*time.Second,
+			MaxDeliveries: redisStreamMaxDeliveries(),
+		}
+		go func() {
+			errCh <- workerpkg.RunRedisStreamSubscriber(ctx, redisClient, streamCfg, processor, log)
+		}()
+	default:
+		log.Fatal().Str("transport", transport).Msg("unsupported EVENT_TRANSPORT")
+	}
 
+	// The metrics port also serves the internal processing API.
+	mux := http.NewServeMux()
+	mux.Handle(workerpkg.ProcessingPattern, workerpkg.ProcessingHandler(processing))
+	mux.Handle("/", metrics.Handler())
 	metricsSrv := &http.Server{
 		Addr:              ":" + metricsPort(),
-		Handler:           metrics.Handler(),
+		Handler:           mux,
 		ReadHeaderTimeout: 5 * time.Second,
 	}
 	go func() {
@@ -89,9 +170,15 @@ func main() {
 		}
 	}()
 
-	log.Info().
-		Str("subject", ordersCreatedSubject).
-		Msg("worker started and subscribed to NATS")
+	if transport == transportNATS {
+		log.Info().
+			Str("subject", ordersCreatedSubject).
+			Msg("worker started and subscribed to NATS")
+	} else {
+		log.Info().
+			Str("stream", ordersCreatedSubject).
+			Msg("worker started and consuming Redis stream")
+	}
 
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
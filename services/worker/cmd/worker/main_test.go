@@ -1,6 +1,9 @@
 package main
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func TestNATSURL_Default(t *testing.T) {
 	t.Setenv("NATS_URL", "")
@@ -44,6 +47,45 @@ func TestMetricsPort_Override(t *testing.T) {
 	}
 }
 
+func TestEventTransport_Default(t *testing.T) {
+	t.Setenv("EVENT_TRANSPORT", "")
+	if got, want := eventTransport(), transportNATS; got != want {
+		t.Fatalf("default event transport mismatch: got=%q want=%q", got, want)
+	}
+}
+
+func TestEventTransport_Override(t *testing.T) {
+	t.Setenv("EVENT_TRANSPORT", "REDIS-STREAMS")
+	if got, want := eventTransport(), transportRedisStreams; got != want {
+		t.Fatalf("override event transport mismatch: got=%q want=%q", got, want)
+	}
+}
+
+func TestRedisStreamConsumer_Override(t *testing.T) {
+	t.Setenv("REDIS_STREAM_CONSUMER", "worker-7")
+	if got, want := redisStreamConsumer(), "worker-7"; got != want {
+		t.Fatalf("override consumer mismatch: got=%q want=%q", got, want)
+	}
+}
+
 func TestWorker_SubscriptionAndIdempotency_Pending(t *testing.T) {
 	t.Skip("TODO(phase-1): add subscription, retry, and idempotency tests once worker processing is implemented")
 }
+
+func TestProcessingTTL(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "", want: 7 * 24 * time.Hour},
+		{value: "48h", want: 48 * time.Hour},
+		{value: "-1h", want: 7 * 24 * time.Hour},
+		{value: "soon", want: 7 * 24 * time.Hour},
+	}
+	for _, tc := range tests {
+		t.Setenv("PROCESSING_TTL", tc.value)
+		if got := processingTTL(); got != tc.want {
+			t.Fatalf("processing ttl mismatch for %q: got=%v want=%v", tc.value, got, tc.want)
+		}
+	}
+}
@@ -22,3 +22,7 @@ func NewRedisIdempotencyStore(client *redis.Client, prefix string) *RedisIdempot
 func (s *RedisIdempotencyStore) Reserve(ctx context.Context, key string, ttl time.Duration) (bool, error) {
 	return s.client.SetNX(ctx, s.prefix+key, "1", ttl).Result()
 }
+
+func (s *RedisIdempotencyStore) Release(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.prefix+key).Err()
+}
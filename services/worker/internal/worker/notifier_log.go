@@ -15,8 +15,8 @@ func (n *LogNotifier) NotifyOrderCreated(_ context.Context, event OrdersCreatedE
 		Str("order_id", event.OrderID).
 		Str("user_id", event.UserID).
 		Str("request_id", event.RequestID).
-		Int("total_cents", event.TotalCents).
-		Str("currency", event.Currency).
+		Int64("total_cents", event.TotalCents).
+		Str("currency", event.Currency.String()).
 		Msg("worker processed orders.created.v1 event")
 	return nil
 }
@@ -0,0 +1,140 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Processing states recorded per order.
+const (
+	ProcessingReceived = "received"
+	ProcessingNotified = "notified"
+	ProcessingFailed   = "failed"
+)
+
+// ErrProcessingNotFound is returned when the worker has not seen an order.
+var ErrProcessingNotFound = errors.New("processing record not found")
+
+// ProcessingRecord is the worker's view of one order. Attempts counts the
+// deliveries the worker acted on; redeliveries skipped as duplicates are
+// only counted in messages_duplicates_total.
+type ProcessingRecord struct {
+	OrderID       string     `json:"order_id"`
+	State         string     `json:"state"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	ReceivedAt    time.Time  `json:"received_at"`
+	LastAttemptAt time.Time  `json:"last_attempt_at"`
+	NotifiedAt    *time.Time `json:"notified_at,omitempty"`
+	FailedAt      *time.Time `json:"failed_at,omitempty"`
+}
+
+// ProcessingStore records what happened to each order. A failure after a
+// success keeps the order notified.
+type ProcessingStore interface {
+	RecordAttempt(ctx context.Context, orderID string, at time.Time) error
+	RecordNotified(ctx context.Context, orderID string, at time.Time) error
+	RecordFailed(ctx context.Context, orderID, reason string, at time.Time) error
+	Get(ctx context.Context, orderID string) (ProcessingRecord, error)
+}
+
+type RedisProcessingStore struct {
+	client *redis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+func NewRedisProcessingStore(client *redis.Client, prefix string, ttl time.Duration) *RedisProcessingStore {
+	if prefix == "" {
+		prefix = "worker:processing:"
+	}
+	if ttl <= 0 {
+		ttl = 7 * 24 * time.Hour
+	}
+	return &RedisProcessingStore{client: client, prefix: prefix, ttl: ttl}
+}
+
+func (s *RedisProcessingStore) RecordAttempt(ctx context.Context, orderID string, at time.Time) error {
+	key, ts := s.prefix+orderID, formatProcessingTime(at)
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HIncrBy(ctx, key, "attempts", 1)
+		pipe.HSetNX(ctx, key, "received_at", ts)
+		pipe.HSetNX(ctx, key, "state", ProcessingReceived)
+		pipe.HSet(ctx, key, "last_attempt_at", ts)
+		pipe.Expire(ctx, key, s.ttl)
+		return nil
+	})
+	return err
+}
+
+func (s *RedisProcessingStore) RecordNotified(ctx context.Context, orderID string, at time.Time) error {
+	key := s.prefix + orderID
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, "state", ProcessingNotified, "notified_at", formatProcessingTime(at))
+		pipe.Expire(ctx, key, s.ttl)
+		return nil
+	})
+	return err
+}
+
+// RecordFailed keeps the reason even if a later attempt succeeds; the state
+// only becomes failed while the order has not been notified.
+func (s *RedisProcessingStore) RecordFailed(ctx context.Context, orderID, reason string, at time.Time) error {
+	key := s.prefix + orderID
+	return s.client.Watch(ctx, func(tx *redis.Tx) error {
+		state, err := tx.HGet(ctx, key, "state").Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, key, "last_error", reason, "failed_at", formatProcessingTime(at))
+			if state != ProcessingNotified {
+				pipe.HSet(ctx, key, "state", ProcessingFailed)
+			}
+			pipe.Expire(ctx, key, s.ttl)
+			return nil
+		})
+		return err
+	}, key)
+}
+
+func (s *RedisProcessingStore) Get(ctx context.Context, orderID string) (ProcessingRecord, error) {
+	fields, err := s.client.HGetAll(ctx, s.prefix+orderID).Result()
+	if err != nil {
+		return ProcessingRecord{}, err
+	}
+	if len(fields) == 0 {
+		return ProcessingRecord{}, ErrProcessingNotFound
+	}
+	attempts, _ := strconv.Atoi(fields["attempts"])
+	record := ProcessingRecord{
+		OrderID:       orderID,
+		State:         fields["state"],
+		Attempts:      attempts,
+		LastError:     fields["last_error"],
+		ReceivedAt:    parseProcessingTime(fields["received_at"]),
+		LastAttemptAt: parseProcessingTime(fields["last_attempt_at"]),
+	}
+	if v, ok := fields["notified_at"]; ok {
+		t := parseProcessingTime(v)
+		record.NotifiedAt = &t
+	}
+	if v, ok := fields["failed_at"]; ok {
+		t := parseProcessingTime(v)
+		record.FailedAt = &t
+	}
+	return record, nil
+}
+
+func formatProcessingTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func parseProcessingTime(v string) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, v)
+	return t
+}
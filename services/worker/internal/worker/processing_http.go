@@ -0,0 +1,32 @@
+package worker
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/triad-platform/triad-app/pkg/httpx"
+)
+
+// ProcessingPattern is the internal route serving one order's processing
+// record; the gateway checks ownership before calling it.
+const ProcessingPattern = "GET /v1/orders/{orderID}/processing"
+
+// CodeProcessingNotFound means the worker has not received the order yet.
+const CodeProcessingNotFound = "processing_not_found"
+
+func ProcessingHandler(store ProcessingStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		record, err := store.Get(r.Context(), r.PathValue("orderID"))
+		if errors.Is(err, ErrProcessingNotFound) {
+			httpx.Error(w, r, http.StatusNotFound, CodeProcessingNotFound, "order not processed yet")
+			return
+		}
+		if err != nil {
+			httpx.Error(w, r, http.StatusServiceUnavailable, httpx.CodeServiceUnavailable, "processing store unavailable")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(record)
+	}
+}
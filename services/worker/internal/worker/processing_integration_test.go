@@ -0,0 +1,53 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRedisProcessingStore(t *testing.T) {
+	client := redisIntegrationClient(t)
+	defer client.Close()
+
+	ctx := context.Background()
+	store := NewRedisProcessingStore(client, fmt.Sprintf("test:processing:%d:", time.Now().UnixNano()), time.Minute)
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	if _, err := store.Get(ctx, "o-1"); !errors.Is(err, ErrProcessingNotFound) {
+		t.Fatalf("unknown order error mismatch: got=%v want=%v", err, ErrProcessingNotFound)
+	}
+	if err := store.RecordAttempt(ctx, "o-1", at); err != nil {
+		t.Fatalf("record attempt: %v", err)
+	}
+	if err := store.RecordFailed(ctx, "o-1", "notify: timeout", at.Add(time.Second)); err != nil {
+		t.Fatalf("record failed: %v", err)
+	}
+	got, err := store.Get(ctx, "o-1")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.State != ProcessingFailed || got.LastError != "notify: timeout" || got.FailedAt == nil {
+		t.Fatalf("failed record mismatch: got=%+v", got)
+	}
+
+	if err := store.RecordAttempt(ctx, "o-1", at.Add(2*time.Second)); err != nil {
+		t.Fatalf("record retry: %v", err)
+	}
+	if err := store.RecordNotified(ctx, "o-1", at.Add(3*time.Second)); err != nil {
+		t.Fatalf("record notified: %v", err)
+	}
+	// A redelivery failing after the notification does not undo it.
+	if err := store.RecordFailed(ctx, "o-1", "reserve idempotency key: timeout", at.Add(4*time.Second)); err != nil {
+		t.Fatalf("record late failure: %v", err)
+	}
+	got, err = store.Get(ctx, "o-1")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.State != ProcessingNotified || got.Attempts != 2 || !got.ReceivedAt.Equal(at) || !got.LastAttemptAt.Equal(at.Add(2*time.Second)) || got.NotifiedAt == nil {
+		t.Fatalf("notified record mismatch: got=%+v", got)
+	}
+}
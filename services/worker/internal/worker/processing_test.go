@@ -0,0 +1,186 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProcessOrdersCreated_RecordsProcessing(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		store         *stubStore
+		notifier      *stubNotifier
+		wantState     string
+		wantLastError string
+	}{
+		{name: "notified", store: &stubStore{reserveResult: true}, notifier: &stubNotifier{}, wantState: ProcessingNotified},
+		{name: "notifier error", store: &stubStore{reserveResult: true}, notifier: &stubNotifier{err: errors.New("connection refused")}, wantState: ProcessingFailed, wantLastError: "notify: connection refused"},
+		{name: "idempotency error", store: &stubStore{reserveErr: errors.New("redis down")}, notifier: &stubNotifier{}, wantState: ProcessingFailed, wantLastError: "reserve idempotency key: redis down"},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			processing := newStubProcessingStore()
+			p := &Processor{
+				IdempotencyStore: tc.store,
+				Notifier:         tc.notifier,
+				ProcessingStore:  processing,
+			}
+			_, _ = p.ProcessOrdersCreated(context.Background(), OrdersCreatedEvent{OrderID: "o-1"})
+
+			got, err := processing.Get(context.Background(), "o-1")
+			if err != nil {
+				t.Fatalf("get processing record: %v", err)
+			}
+			if got.State != tc.wantState || got.LastError != tc.wantLastError || got.Attempts != 1 {
+				t.Fatalf("record mismatch: got=%+v want state=%q last_error=%q attempts=1", got, tc.wantState, tc.wantLastError)
+			}
+			if got.ReceivedAt.IsZero() || (tc.wantState == ProcessingNotified) != (got.NotifiedAt != nil) || (tc.wantState == ProcessingFailed) != (got.FailedAt != nil) {
+				t.Fatalf("timestamps mismatch: got=%+v", got)
+			}
+		})
+	}
+}
+
+func TestProcessOrdersCreated_DuplicateIsNotAnAttempt(t *testing.T) {
+	t.Parallel()
+
+	processing := newStubProcessingStore()
+	p := &Processor{
+		IdempotencyStore: &statefulStore{keys: map[string]struct{}{}},
+		Notifier:         &stubNotifier{},
+		ProcessingStore:  processing,
+	}
+	event := OrdersCreatedEvent{OrderID: "o-1"}
+	_, _ = p.ProcessOrdersCreated(context.Background(), event)
+	_, _ = p.ProcessOrdersCreated(context.Background(), event)
+
+	got, err := processing.Get(context.Background(), "o-1")
+	if err != nil {
+		t.Fatalf("get processing record: %v", err)
+	}
+	if got.State != ProcessingNotified || got.Attempts != 1 {
+		t.Fatalf("record mismatch: got=%+v want state=%q attempts=1", got, ProcessingNotified)
+	}
+}
+
+func TestProcessOrdersCreated_ProcessingStoreErrorIsNotFatal(t *testing.T) {
+	t.Parallel()
+
+	processing := newStubProcessingStore()
+	processing.err = errors.New("redis down")
+	p := &Processor{
+		IdempotencyStore: &stubStore{reserveResult: true},
+		Notifier:         &stubNotifier{},
+		ProcessingStore:  processing,
+	}
+	processed, err := p.ProcessOrdersCreated(context.Background(), OrdersCreatedEvent{OrderID: "o-1"})
+	if err != nil || !processed {
+		t.Fatalf("process mismatch: processed=%v err=%v", processed, err)
+	}
+}
+
+func TestProcessingHandler(t *testing.T) {
+	t.Parallel()
+
+	store := newStubProcessingStore()
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	_ = store.RecordAttempt(context.Background(), "o-1", at)
+	_ = store.RecordNotified(context.Background(), "o-1", at.Add(time.Second))
+	mux := http.NewServeMux()
+	mux.Handle(ProcessingPattern, ProcessingHandler(store))
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantState  string
+	}{
+		{name: "known order", path: "/v1/orders/o-1/processing", wantStatus: http.StatusOK, wantState: ProcessingNotified},
+		{name: "unknown order", path: "/v1/orders/o-2/processing", wantStatus: http.StatusNotFound},
+	}
+	for _, tc := range tests {
+		resp := runHandler(mux, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if resp.StatusCode != tc.wantStatus {
+			t.Fatalf("%s: status mismatch: got=%d want=%d", tc.name, resp.StatusCode, tc.wantStatus)
+		}
+		if tc.wantState == "" {
+			continue
+		}
+		var got ProcessingRecord
+		if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+			t.Fatalf("%s: decode response: %v", tc.name, err)
+		}
+		if got.OrderID != "o-1" || got.State != tc.wantState || got.Attempts != 1 || !got.ReceivedAt.Equal(at) {
+			t.Fatalf("%s: record mismatch: got=%+v", tc.name, got)
+		}
+	}
+}
+
+// stubProcessingStore mirrors RedisProcessingStore in memory.
+type stubProcessingStore struct {
+	records map[string]ProcessingRecord
+	err     error
+}
+
+func newStubProcessingStore() *stubProcessingStore {
+	return &stubProcessingStore{records: map[string]ProcessingRecord{}}
+}
+
+func (s *stubProcessingStore) RecordAttempt(_ context.Context, orderID string, at time.Time) error {
+	if s.err != nil {
+		return s.err
+	}
+	r, ok := s.records[orderID]
+	if !ok {
+		r = ProcessingRecord{OrderID: orderID, State: ProcessingReceived, ReceivedAt: at}
+	}
+	r.Attempts++
+	r.LastAttemptAt = at
+	s.records[orderID] = r
+	return nil
+}
+
+func (s *stubProcessingStore) RecordNotified(_ context.Context, orderID string, at time.Time) error {
+	if s.err != nil {
+		return s.err
+	}
+	r := s.records[orderID]
+	r.State, r.NotifiedAt = ProcessingNotified, &at
+	s.records[orderID] = r
+	return nil
+}
+
+func (s *stubProcessingStore) RecordFailed(_ context.Context, orderID, reason string, at time.Time) error {
+	if s.err != nil {
+		return s.err
+	}
+	r := s.records[orderID]
+	r.LastError, r.FailedAt = reason, &at
+	if r.State != ProcessingNotified {
+		r.State = ProcessingFailed
+	}
+	s.records[orderID] = r
+	return nil
+}
+
+func (s *stubProcessingStore) Get(_ context.Context, orderID string) (ProcessingRecord, error) {
+	if s.err != nil {
+		return ProcessingRecord{}, s.err
+	}
+	r, ok := s.records[orderID]
+	if !ok {
+		return ProcessingRecord{}, ErrProcessingNotFound
+	}
+	return r, nil
+}
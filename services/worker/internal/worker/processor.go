@@ -4,34 +4,65 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/triad-platform/triad-app/pkg/metricsx"
+	"github.com/triad-platform/triad-app/pkg/money"
 )
 
 type OrdersCreatedEvent struct {
+	OrderID    string         `json:"order_id"`
+	UserID     string         `json:"user_id"`
+	RequestID  string         `json:"request_id"`
+	TotalCents int64          `json:"total_cents"`
+	Currency   money.Currency `json:"currency"`
+	CreatedAt  string         `json:"created_at"`
+}
+
+// OrderStatusSubject carries status changes after an order is created.
+const OrderStatusSubject = "orders.status.v1"
+
+// OrderStatusNotified is published once the customer has been notified.
+const OrderStatusNotified = "notified"
+
+type OrderStatusChangedEvent struct {
+	Type       string `json:"type"`
+	Version    int    `json:"version"`
 	OrderID    string `json:"order_id"`
 	UserID     string `json:"user_id"`
+	Status     string `json:"status"`
 	RequestID  string `json:"request_id"`
-	TotalCents int    `json:"total_cents"`
-	Currency   string `json:"currency"`
-	CreatedAt  string `json:"created_at"`
+	OccurredAt string `json:"occurred_at"`
 }
 
+// IdempotencyStore reserves a key per event. Release gives a key back when
+// the event's side effects failed, so a redelivery retries them.
 type IdempotencyStore interface {
 	Reserve(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	Release(ctx context.Context, key string) error
 }
 
 type Notifier interface {
 	NotifyOrderCreated(ctx context.Context, event OrdersCreatedEvent) error
 }
 
+// StatusPublisher announces order status changes, for example to the
+// gateway's order event streams.
+type StatusPublisher interface {
+	PublishOrderStatus(ctx context.Context, event OrderStatusChangedEvent) error
+}
+
 type Processor struct {
 	IdempotencyStore IdempotencyStore
 	Notifier         Notifier
-	Metrics          *metricsx.Registry
-	KeyPrefix        string
-	IdempotencyTTL   time.Duration
+	// StatusPublisher is optional; without it no status events are sent.
+	StatusPublisher StatusPublisher
+	// ProcessingStore is optional; without it no per-order state is kept.
+	ProcessingStore ProcessingStore
+	Metrics         *metricsx.Registry
+	KeyPrefix       string
+	IdempotencyTTL  time.Duration
 }
 
 func DecodeOrdersCreated(data []byte) (OrdersCreatedEvent, error) {
@@ -44,7 +75,7 @@ func DecodeOrdersCreated(data []byte) (OrdersCreatedEvent, error) {
 
 func (p *Processor) ProcessOrdersCreated(ctx context.Context, event OrdersCreatedEvent) (bool, error) {
 	start := time.Now()
-	defer p.observeDuration("process_orders_created_duration", time.Since(start))
+	defer func() { p.observeDuration("process_orders_created_duration", time.Since(start)) }()
 	p.inc("messages_received_total")
 
 	if event.OrderID == "" {
@@ -53,34 +84,86 @@ func (p *Processor) ProcessOrdersCreated(ctx context.Context, event OrdersCreate
 	}
 	if p.IdempotencyStore == nil {
 		p.inc("messages_errors_total")
-		return false, errors.New("idempotency store not configured")
+		return false, p.fail(ctx, event, errors.New("idempotency store not configured"))
 	}
 	if p.Notifier == nil {
 		p.inc("messages_errors_total")
-		return false, errors.New("notifier not configured")
+		return false, p.fail(ctx, event, errors.New("notifier not configured"))
 	}
 
 	reserved, err := p.IdempotencyStore.Reserve(ctx, p.key(event.OrderID), p.idempotencyTTL())
+	if err == nil && !reserved {
+		// Duplicate event replay; no side effects should run twice, and it
+		// is not an attempt.
+		p.inc("messages_duplicates_total")
+		return false, nil
+	}
+	p.recordProcessing(func(store ProcessingStore) error {
+		return store.RecordAttempt(ctx, event.OrderID, time.Now())
+	})
 	if err != nil {
 		p.inc("messages_errors_total")
 		p.inc("idempotency_errors_total")
-		return false, err
-	}
-	if !reserved {
-		// Duplicate event replay; no side effects should run twice.
-		p.inc("messages_duplicates_total")
-		return false, nil
+		return false, p.fail(ctx, event, fmt.Errorf("reserve idempotency key: %w", err))
 	}
 
 	if err := p.Notifier.NotifyOrderCreated(ctx, event); err != nil {
 		p.inc("messages_errors_total")
 		p.inc("notifier_errors_total")
-		return false, err
+		// Give the key back so the redelivered or reclaimed message retries
+		// the notification instead of being skipped as a duplicate.
+		if releaseErr := p.IdempotencyStore.Release(ctx, p.key(event.OrderID)); releaseErr != nil {
+			p.inc("idempotency_errors_total")
+		}
+		return false, p.fail(ctx, event, fmt.Errorf("notify: %w", err))
 	}
 	p.inc("messages_processed_total")
+	p.recordProcessing(func(store ProcessingStore) error {
+		return store.RecordNotified(ctx, event.OrderID, time.Now())
+	})
+	p.publishStatus(ctx, event, OrderStatusNotified)
 	return true, nil
 }
 
+// publishStatus is best effort: the notification has already been sent, so a
+// failure is counted rather than retried.
+func (p *Processor) publishStatus(ctx context.Context, event OrdersCreatedEvent, status string) {
+	if p.StatusPublisher == nil {
+		return
+	}
+	err := p.StatusPublisher.PublishOrderStatus(ctx, OrderStatusChangedEvent{
+		Type:       "OrderStatusChanged",
+		Version:    1,
+		OrderID:    event.OrderID,
+		UserID:     event.UserID,
+		Status:     status,
+		RequestID:  event.RequestID,
+		OccurredAt: time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		p.inc("status_publish_errors_total")
+	}
+}
+
+// fail records err as the order's failure reason and returns it.
+func (p *Processor) fail(ctx context.Context, event OrdersCreatedEvent, err error) error {
+	p.recordProcessing(func(store ProcessingStore) error {
+		return store.RecordFailed(ctx, event.OrderID, err.Error(), time.Now())
+	})
+	return err
+}
+
+// recordProcessing is best effort, like publishStatus: processing state is
+// for diagnostics and must not change the outcome of a message.
+func (p *Processor) recordProcessing(record func(ProcessingStore) error) {
+	if p.ProcessingStore == nil {
+		return
+	}
+	if err := record(p.ProcessingStore); err != nil {
+		p.inc("processing_record_errors_total")
+	}
+}
+
 func (p *Processor) HandleOrdersCreatedMessage(ctx context.Context, data []byte) (bool, error) {
 	event, err := DecodeOrdersCreated(data)
 	if err != nil {
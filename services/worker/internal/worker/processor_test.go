@@ -132,6 +132,70 @@ func TestProcessOrdersCreated_ReplayIdempotency(t *testing.T) {
 	}
 }
 
+func TestProcessOrdersCreated_RetriesFailedNotify(t *testing.T) {
+	t.Parallel()
+
+	store := &statefulStore{keys: map[string]struct{}{}}
+	notifier := &stubNotifier{err: errors.New("notifications unavailable")}
+	p := &Processor{IdempotencyStore: store, Notifier: notifier}
+	event := OrdersCreatedEvent{OrderID: "o-retry", UserID: "u-1", TotalCents: 1500, Currency: "USD"}
+
+	if _, err := p.ProcessOrdersCreated(context.Background(), event); err == nil {
+		t.Fatal("expected the failed notify to be returned")
+	}
+	notifier.err = nil
+	processed, err := p.ProcessOrdersCreated(context.Background(), event)
+	if err != nil || !processed {
+		t.Fatalf("redelivery after a failed notify should be processed: processed=%v err=%v", processed, err)
+	}
+	if notifier.calls != 2 {
+		t.Fatalf("notifier calls mismatch: got=%d want=2", notifier.calls)
+	}
+}
+
+func TestProcessOrdersCreated_PublishesNotifiedStatus(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		notifier      *stubNotifier
+		publisher     *stubStatusPublisher
+		wantProcessed bool
+		wantStatuses  int
+	}{
+		{name: "notified", notifier: &stubNotifier{}, publisher: &stubStatusPublisher{}, wantProcessed: true, wantStatuses: 1},
+		{name: "publish error keeps the event processed", notifier: &stubNotifier{}, publisher: &stubStatusPublisher{err: errors.New("bus down")}, wantProcessed: true, wantStatuses: 1},
+		{name: "notifier error", notifier: &stubNotifier{err: errors.New("notify failed")}, publisher: &stubStatusPublisher{}, wantStatuses: 0},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := &Processor{
+				IdempotencyStore: &stubStore{reserveResult: true},
+				Notifier:         tc.notifier,
+				StatusPublisher:  tc.publisher,
+			}
+			processed, _ := p.ProcessOrdersCreated(context.Background(), OrdersCreatedEvent{OrderID: "o-1", UserID: "u-1", RequestID: "req-1"})
+			if processed != tc.wantProcessed {
+				t.Fatalf("processed mismatch: got=%v want=%v", processed, tc.wantProcessed)
+			}
+			if len(tc.publisher.events) != tc.wantStatuses {
+				t.Fatalf("status events mismatch: got=%d want=%d", len(tc.publisher.events), tc.wantStatuses)
+			}
+			if tc.wantStatuses == 0 {
+				return
+			}
+			got := tc.publisher.events[0]
+			if got.OrderID != "o-1" || got.UserID != "u-1" || got.Status != OrderStatusNotified || got.RequestID != "req-1" || got.OccurredAt == "" {
+				t.Fatalf("status event mismatch: got=%+v", got)
+			}
+		})
+	}
+}
+
 func TestHandleOrdersCreatedMessage(t *testing.T) {
 	t.Parallel()
 
@@ -163,6 +227,10 @@ func (s *stubStore) Reserve(_ context.Context, _ string, _ time.Duration) (bool,
 	return s.reserveResult, s.reserveErr
 }
 
+func (s *stubStore) Release(_ context.Context, _ string) error {
+	return nil
+}
+
 type statefulStore struct {
 	keys map[string]struct{}
 }
@@ -175,6 +243,11 @@ func (s *statefulStore) Reserve(_ context.Context, key string, _ time.Duration)
 	return true, nil
 }
 
+func (s *statefulStore) Release(_ context.Context, key string) error {
+	delete(s.keys, key)
+	return nil
+}
+
 type stubNotifier struct {
 	calls int
 	err   error
@@ -184,3 +257,13 @@ func (n *stubNotifier) NotifyOrderCreated(_ context.Context, _ OrdersCreatedEven
 	n.calls++
 	return n.err
 }
+
+type stubStatusPublisher struct {
+	events []OrderStatusChangedEvent
+	err    error
+}
+
+func (p *stubStatusPublisher) PublishOrderStatus(_ context.Context, event OrderStatusChangedEvent) error {
+	p.events = append(p.events, event)
+	return p.err
+}
@@ -0,0 +1,60 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/nats-io/nats.go"
+	"github.com/redis/go-redis/v9"
+)
+
+type NATSStatusPublisher struct {
+	conn    *nats.Conn
+	subject string
+}
+
+func NewNATSStatusPublisher(conn *nats.Conn, subject string) *NATSStatusPublisher {
+	if subject == "" {
+		subject = OrderStatusSubject
+	}
+	return &NATSStatusPublisher{conn: conn, subject: subject}
+}
+
+func (p *NATSStatusPublisher) PublishOrderStatus(_ context.Context, event OrderStatusChangedEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return p.conn.Publish(p.subject, payload)
+}
+
+// RedisStreamStatusPublisher writes entries in the same type/payload shape as
+// the orders service's stream publisher.
+type RedisStreamStatusPublisher struct {
+	client *redis.Client
+	stream string
+	maxLen int64
+}
+
+func NewRedisStreamStatusPublisher(client *redis.Client, stream string, maxLen int64) *RedisStreamStatusPublisher {
+	if stream == "" {
+		stream = OrderStatusSubject
+	}
+	return &RedisStreamStatusPublisher{client: client, stream: stream, maxLen: maxLen}
+}
+
+func (p *RedisStreamStatusPublisher) PublishOrderStatus(ctx context.Context, event OrderStatusChangedEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return p.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: p.stream,
+		MaxLen: p.maxLen,
+		Approx: p.maxLen > 0,
+		Values: map[string]any{
+			"type":    event.Type,
+			"payload": payload,
+		},
+	}).Err()
+}
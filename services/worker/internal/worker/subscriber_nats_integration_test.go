@@ -2,6 +2,7 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"os"
 	"sync"
 	"testing"
@@ -89,15 +90,27 @@ func (s *syncStatefulStore) Reserve(_ context.Context, key string, _ time.Durati
 	return true, nil
 }
 
+func (s *syncStatefulStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, key)
+	return nil
+}
+
+// syncNotifier fails its first failFirst calls.
 type syncNotifier struct {
-	mu    sync.Mutex
-	calls int
+	mu        sync.Mutex
+	calls     int
+	failFirst int
 }
 
 func (n *syncNotifier) NotifyOrderCreated(_ context.Context, _ OrdersCreatedEvent) error {
 	n.mu.Lock()
+	defer n.mu.Unlock()
 	n.calls++
-	n.mu.Unlock()
+	if n.calls <= n.failFirst {
+		return errors.New("notifications unavailable")
+	}
 	return nil
 }
 
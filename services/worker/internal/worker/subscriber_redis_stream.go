@@ -0,0 +1,254 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+)
+
+type RedisStreamConfig struct {
+	Stream   string
+	Group    string
+	Consumer string
+	// BatchSize bounds how many entries a single XREADGROUP/XAUTOCLAIM call returns.
+	BatchSize int64
+	// Block is how long XREADGROUP waits for new entries before the loop re-checks ctx.
+	Block time.Duration
+	// MinIdle is how long an entry must sit unacknowledged in another consumer's
+	// pending list before it is reclaimed with XAUTOCLAIM.
+	MinIdle time.Duration
+	// ReclaimInterval is how often the pending entries list is scanned.
+	ReclaimInterval time.Duration
+	// MaxDeliveries caps how often an entry is handed to a consumer (5).
+	// A reclaimed entry past the cap is copied to DeadLetterStream and acked.
+	MaxDeliveries int64
+	// DeadLetterStream defaults to "<Stream>.dead".
+	DeadLetterStream string
+}
+
+func (c RedisStreamConfig) withDefaults() RedisStreamConfig {
+	if c.Group == "" {
+		c.Group = "worker"
+	}
+	if c.Consumer == "" {
+		c.Consumer = "worker-1"
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 16
+	}
+	if c.Block <= 0 {
+		c.Block = 2 * time.Second
+	}
+	if c.MinIdle <= 0 {
+		c.MinIdle = 30 * time.Second
+	}
+	if c.ReclaimInterval <= 0 {
+		c.ReclaimInterval = 15 * time.Second
+	}
+	if c.MaxDeliveries <= 0 {
+		c.MaxDeliveries = 5
+	}
+	if c.DeadLetterStream == "" {
+		c.DeadLetterStream = c.Stream + ".dead"
+	}
+	return c
+}
+
+func RunRedisStreamSubscriber(
+	ctx context.Context,
+	client *redis.Client,
+	cfg RedisStreamConfig,
+	processor *Processor,
+	log zerolog.Logger,
+) error {
+	cfg = cfg.withDefaults()
+	if cfg.Stream == "" {
+		return errors.New("redis stream name is required")
+	}
+
+	err := client.XGroupCreateMkStream(ctx, cfg.Stream, cfg.Group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("create consumer group: %w", err)
+	}
+
+	// Reclaim once at startup so entries left behind by a crashed consumer
+	// are not stuck until the first interval elapses.
+	lastReclaim := time.Time{}
+	for {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if time.Since(lastReclaim) >= cfg.ReclaimInterval {
+			if err := reclaimPending(ctx, client, cfg, processor, log); err != nil && ctx.Err() == nil {
+				log.Error().Err(err).Str("stream", cfg.Stream).Msg("failed to reclaim pending stream entries")
+			}
+			lastReclaim = time.Now()
+		}
+
+		streams, err := client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    cfg.Group,
+			Consumer: cfg.Consumer,
+			Streams:  []string{cfg.Stream, ">"},
+			Count:    cfg.BatchSize,
+			Block:    cfg.Block,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("read stream: %w", err)
+		}
+
+		for _, stream := range streams {
+			for _, msg := range stream.Messages {
+				handleStreamMessage(ctx, client, cfg, processor, msg, log)
+			}
+		}
+	}
+}
+
+func reclaimPending(
+	ctx context.Context,
+	client *redis.Client,
+	cfg RedisStreamConfig,
+	processor *Processor,
+	log zerolog.Logger,
+) error {
+	start := "0-0"
+	for {
+		msgs, next, err := client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   cfg.Stream,
+			Group:    cfg.Group,
+			Consumer: cfg.Consumer,
+			MinIdle:  cfg.MinIdle,
+			Start:    start,
+			Count:    cfg.BatchSize,
+		}).Result()
+		if err != nil {
+			return err
+		}
+		deliveries, err := deliveryCounts(ctx, client, cfg, msgs)
+		if err != nil {
+			return err
+		}
+		for _, msg := range msgs {
+			if deliveries[msg.ID] > cfg.MaxDeliveries {
+				deadLetter(ctx, client, cfg, processor, msg, deliveries[msg.ID], log)
+				continue
+			}
+			log.Info().Str("stream", cfg.Stream).Str("id", msg.ID).Msg("reclaimed pending stream entry")
+			handleStreamMessage(ctx, client, cfg, processor, msg, log)
+		}
+		if next == "0-0" || next == "" {
+			return nil
+		}
+		start = next
+	}
+}
+
+// deliveryCounts returns how often each of msgs has been delivered,
+// counting the XAUTOCLAIM that just handed them to this consumer.
+func deliveryCounts(ctx context.Context, client *redis.Client, cfg RedisStreamConfig, msgs []redis.XMessage) (map[string]int64, error) {
+	counts := make(map[string]int64, len(msgs))
+	if len(msgs) == 0 {
+		return counts, nil
+	}
+	pending, err := client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream:   cfg.Stream,
+		Group:    cfg.Group,
+		Start:    msgs[0].ID,
+		End:      msgs[len(msgs)-1].ID,
+		Count:    int64(len(msgs)),
+		Consumer: cfg.Consumer,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("read pending entries: %w", err)
+	}
+	for _, p := range pending {
+		counts[p.ID] = p.RetryCount
+	}
+	return counts, nil
+}
+
+// deadLetter moves an entry that kept failing to the dead-letter stream.
+// The entry stays pending if the copy fails, so it is not lost.
+func deadLetter(
+	ctx context.Context,
+	client *redis.Client,
+	cfg RedisStreamConfig,
+	processor *Processor,
+	msg redis.XMessage,
+	deliveries int64,
+	log zerolog.Logger,
+) {
+	values := make(map[string]any, len(msg.Values)+2)
+	for k, v := range msg.Values {
+		values[k] = v
+	}
+	values["source_id"] = msg.ID
+	values["deliveries"] = deliveries
+	if err := client.XAdd(ctx, &redis.XAddArgs{Stream: cfg.DeadLetterStream, Values: values}).Err(); err != nil {
+		log.Error().Err(err).Str("stream", cfg.Stream).Str("id", msg.ID).Msg("failed to dead-letter stream entry")
+		return
+	}
+	ackStreamMessage(ctx, client, cfg, msg.ID, log)
+	processor.inc("messages_dead_lettered_total")
+	log.Error().
+		Str("stream", cfg.Stream).
+		Str("id", msg.ID).
+		Str("dead_letter_stream", cfg.DeadLetterStream).
+		Int64("deliveries", deliveries).
+		Msg("stream entry exceeded max deliveries; moved to dead-letter stream")
+}
+
+func handleStreamMessage(
+	ctx context.Context,
+	client *redis.Client,
+	cfg RedisStreamConfig,
+	processor *Processor,
+	msg redis.XMessage,
+	log zerolog.Logger,
+) {
+	payload, ok := msg.Values["payload"].(string)
+	if !ok {
+		// Poison entry: it can never succeed, so ack it instead of reclaiming forever.
+		log.Error().Str("stream", cfg.Stream).Str("id", msg.ID).Msg("stream entry has no payload; acking and dropping")
+		ackStreamMessage(ctx, client, cfg, msg.ID, log)
+		return
+	}
+	event, err := DecodeOrdersCreated([]byte(payload))
+	if err != nil {
+		log.Error().Err(err).Str("stream", cfg.Stream).Str("id", msg.ID).Msg("undecodable stream entry; acking and dropping")
+		ackStreamMessage(ctx, client, cfg, msg.ID, log)
+		return
+	}
+
+	processed, err := processor.ProcessOrdersCreated(ctx, event)
+	if err != nil {
+		// Leave the entry pending; it will be retried via XAUTOCLAIM after
+		// MinIdle until MaxDeliveries is reached.
+		log.Error().Err(err).Str("stream", cfg.Stream).Str("id", msg.ID).Msg("failed to process stream entry")
+		return
+	}
+	ackStreamMessage(ctx, client, cfg, msg.ID, log)
+	if !processed {
+		log.Info().Str("stream", cfg.Stream).Str("id", msg.ID).Msg("duplicate event ignored")
+		return
+	}
+	log.Info().Str("stream", cfg.Stream).Str("id", msg.ID).Msg("message processed")
+}
+
+func ackStreamMessage(ctx context.Context, client *redis.Client, cfg RedisStreamConfig, id string, log zerolog.Logger) {
+	if err := client.XAck(ctx, cfg.Stream, cfg.Group, id).Err(); err != nil {
+		log.Error().Err(err).Str("stream", cfg.Stream).Str("id", id).Msg("failed to ack stream entry")
+	}
+}
@@ -0,0 +1,316 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+)
+
+func redisIntegrationClient(t *testing.T) *redis.Client {
+	t.Helper()
+
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr, DialTimeout: 500 * time.Millisecond, MaxRetries: -1})
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		_ = client.Close()
+		t.Skipf("skipping integration test; Redis not reachable at %s: %v", addr, err)
+	}
+	return client
+}
+
+func TestRunRedisStreamSubscriber_ReplayIdempotency(t *testing.T) {
+	client := redisIntegrationClient(t)
+	defer client.Close()
+
+	stream := fmt.Sprintf("orders.created.v1.integration.%d", time.Now().UnixNano())
+	defer client.Del(context.Background(), stream)
+
+	notifier := &syncNotifier{}
+	processor := &Processor{
+		IdempotencyStore: &syncStatefulStore{keys: map[string]struct{}{}},
+		Notifier:         notifier,
+		KeyPrefix:        "worker:orders-created:",
+		IdempotencyTTL:   time.Minute,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := RedisStreamConfig{
+		Stream:   stream,
+		Group:    "worker-integration",
+		Consumer: "consumer-a",
+		Block:    100 * time.Millisecond,
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- RunRedisStreamSubscriber(ctx, client, cfg, processor, zerolog.Nop())
+	}()
+
+	payload := `{"order_id":"o-stream-1","user_id":"u-1","total_cents":1500,"currency":"USD","created_at":"2026-02-27T00:00:00Z"}`
+	for i := 0; i < 2; i++ {
+		if err := client.XAdd(context.Background(), &redis.XAddArgs{
+			Stream: stream,
+			Values: map[string]any{"type": "OrdersCreated", "payload": payload},
+		}).Err(); err != nil {
+			t.Fatalf("xadd #%d failed: %v", i+1, err)
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		pending, err := client.XPending(context.Background(), stream, cfg.Group).Result()
+		if err == nil && notifier.Calls() == 1 && pending.Count == 0 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if got := notifier.Calls(); got != 1 {
+		t.Fatalf("duplicate replay should notify exactly once: got=%d want=1", got)
+	}
+	pending, err := client.XPending(context.Background(), stream, cfg.Group).Result()
+	if err != nil {
+		t.Fatalf("xpending failed: %v", err)
+	}
+	if pending.Count != 0 {
+		t.Fatalf("all entries should be acknowledged: pending=%d", pending.Count)
+	}
+
+	cancel()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatalf("subscriber returned error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("subscriber did not exit after cancel")
+	}
+}
+
+func TestRunRedisStreamSubscriber_ReclaimsPendingEntries(t *testing.T) {
+	client := redisIntegrationClient(t)
+	defer client.Close()
+
+	stream := fmt.Sprintf("orders.created.v1.reclaim.%d", time.Now().UnixNano())
+	defer client.Del(context.Background(), stream)
+
+	group := "worker-reclaim"
+	if err := client.XGroupCreateMkStream(context.Background(), stream, group, "0").Err(); err != nil {
+		t.Fatalf("create group failed: %v", err)
+	}
+	payload := `{"order_id":"o-stream-reclaim","user_id":"u-1","total_cents":1500,"currency":"USD","created_at":"2026-02-27T00:00:00Z"}`
+	if err := client.XAdd(context.Background(), &redis.XAddArgs{
+		Stream: stream,
+		Values: map[string]any{"type": "OrdersCreated", "payload": payload},
+	}).Err(); err != nil {
+		t.Fatalf("xadd failed: %v", err)
+	}
+
+	// Simulate a consumer that read the entry and crashed before acking.
+	if err := client.XReadGroup(context.Background(), &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: "crashed-consumer",
+		Streams:  []string{stream, ">"},
+		Count:    1,
+	}).Err(); err != nil {
+		t.Fatalf("xreadgroup failed: %v", err)
+	}
+
+	notifier := &syncNotifier{}
+	processor := &Processor{
+		IdempotencyStore: &syncStatefulStore{keys: map[string]struct{}{}},
+		Notifier:         notifier,
+		IdempotencyTTL:   time.Minute,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- RunRedisStreamSubscriber(ctx, client, RedisStreamConfig{
+			Stream:          stream,
+			Group:           group,
+			Consumer:        "consumer-b",
+			Block:           50 * time.Millisecond,
+			MinIdle:         10 * time.Millisecond,
+			ReclaimInterval: 50 * time.Millisecond,
+		}, processor, zerolog.Nop())
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if notifier.Calls() == 1 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if got := notifier.Calls(); got != 1 {
+		t.Fatalf("pending entry should be reclaimed and processed once: got=%d want=1", got)
+	}
+
+	cancel()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatalf("subscriber returned error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("subscriber did not exit after cancel")
+	}
+}
+
+func TestRunRedisStreamSubscriber_RetriesFailedNotify(t *testing.T) {
+	client := redisIntegrationClient(t)
+	defer client.Close()
+
+	stream := fmt.Sprintf("orders.created.v1.retry.%d", time.Now().UnixNano())
+	defer client.Del(context.Background(), stream)
+
+	notifier := &syncNotifier{failFirst: 1}
+	processor := &Processor{
+		IdempotencyStore: &syncStatefulStore{keys: map[string]struct{}{}},
+		Notifier:         notifier,
+		IdempotencyTTL:   time.Minute,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := RedisStreamConfig{
+		Stream:          stream,
+		Group:           "worker-retry",
+		Consumer:        "consumer-a",
+		Block:           50 * time.Millisecond,
+		MinIdle:         10 * time.Millisecond,
+		ReclaimInterval: 50 * time.Millisecond,
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- RunRedisStreamSubscriber(ctx, client, cfg, processor, zerolog.Nop())
+	}()
+
+	payload := `{"order_id":"o-stream-retry","user_id":"u-1","total_cents":1500,"currency":"USD","created_at":"2026-02-27T00:00:00Z"}`
+	if err := client.XAdd(context.Background(), &redis.XAddArgs{
+		Stream: stream,
+		Values: map[string]any{"type": "OrdersCreated", "payload": payload},
+	}).Err(); err != nil {
+		t.Fatalf("xadd failed: %v", err)
+	}
+
+	// The first notify fails and leaves the entry pending; the reclaim must
+	// retry it rather than ack it as a duplicate.
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		pending, err := client.XPending(context.Background(), stream, cfg.Group).Result()
+		if err == nil && notifier.Calls() == 2 && pending.Count == 0 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if got := notifier.Calls(); got != 2 {
+		t.Fatalf("failed notify should be retried once: got=%d want=2", got)
+	}
+	pending, err := client.XPending(context.Background(), stream, cfg.Group).Result()
+	if err != nil {
+		t.Fatalf("xpending failed: %v", err)
+	}
+	if pending.Count != 0 {
+		t.Fatalf("retried entry should be acknowledged: pending=%d", pending.Count)
+	}
+
+	cancel()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatalf("subscriber returned error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("subscriber did not exit after cancel")
+	}
+}
+
+func TestRunRedisStreamSubscriber_DeadLettersAfterMaxDeliveries(t *testing.T) {
+	client := redisIntegrationClient(t)
+	defer client.Close()
+
+	stream := fmt.Sprintf("orders.created.v1.dead.%d", time.Now().UnixNano())
+	defer client.Del(context.Background(), stream, stream+".dead")
+
+	notifier := &syncNotifier{failFirst: 1 << 30}
+	processor := &Processor{
+		IdempotencyStore: &syncStatefulStore{keys: map[string]struct{}{}},
+		Notifier:         notifier,
+		IdempotencyTTL:   time.Minute,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := RedisStreamConfig{
+		Stream:          stream,
+		Group:           "worker-dead",
+		Consumer:        "consumer-a",
+		Block:           20 * time.Millisecond,
+		MinIdle:         10 * time.Millisecond,
+		ReclaimInterval: 30 * time.Millisecond,
+		MaxDeliveries:   3,
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- RunRedisStreamSubscriber(ctx, client, cfg, processor, zerolog.Nop())
+	}()
+
+	payload := `{"order_id":"o-stream-dead","user_id":"u-1","total_cents":1500,"currency":"USD","created_at":"2026-02-27T00:00:00Z"}`
+	id, err := client.XAdd(context.Background(), &redis.XAddArgs{
+		Stream: stream,
+		Values: map[string]any{"type": "OrdersCreated", "payload": payload},
+	}).Result()
+	if err != nil {
+		t.Fatalf("xadd failed: %v", err)
+	}
+
+	var dead []redis.XMessage
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		dead, _ = client.XRange(context.Background(), stream+".dead", "-", "+").Result()
+		if len(dead) > 0 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if len(dead) != 1 {
+		t.Fatalf("dead-letter entries mismatch: got=%d want=1", len(dead))
+	}
+	if dead[0].Values["source_id"] != id || dead[0].Values["payload"] != payload || dead[0].Values["deliveries"] != "4" {
+		t.Fatalf("dead-letter entry mismatch: got=%v", dead[0].Values)
+	}
+	if got := notifier.Calls(); got != int(cfg.MaxDeliveries) {
+		t.Fatalf("notify attempts mismatch: got=%d want=%d", got, cfg.MaxDeliveries)
+	}
+	pending, err := client.XPending(context.Background(), stream, cfg.Group).Result()
+	if err != nil {
+		t.Fatalf("xpending failed: %v", err)
+	}
+	if pending.Count != 0 {
+		t.Fatalf("dead-lettered entry should be acknowledged: pending=%d", pending.Count)
+	}
+
+	cancel()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatalf("subscriber returned error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("subscriber did not exit after cancel")
+	}
+}